@@ -0,0 +1,137 @@
+// Command storage-migrate copies uploaded files from one storage backend to
+// another and rewrites file_uploads/temp_file_uploads.storage_path to the
+// backend-neutral object key.
+//
+//	go run ./cmd/storage-migrate -from local -to s3
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"formhub/internal/config"
+	"formhub/pkg/database"
+	"formhub/pkg/storage"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+type storedFile struct {
+	id          string
+	storagePath string
+	size        int64
+	contentType string
+}
+
+func main() {
+	from := flag.String("from", "local", "source storage type (local or s3)")
+	to := flag.String("to", "s3", "destination storage type (local or s3)")
+	dryRun := flag.Bool("dry-run", false, "list the files that would be copied without copying them")
+	deleteSource := flag.Bool("delete-source", false, "delete each file from the source backend after it is copied")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatalf("Source and destination storage must differ")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db, err := database.NewMySQLDB(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	srcCfg := cfg.Storage
+	srcCfg.Type = *from
+	src, err := storage.New(srcCfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize source storage: %v", err)
+	}
+
+	dstCfg := cfg.Storage
+	dstCfg.Type = *to
+	dst, err := storage.New(dstCfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize destination storage: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, table := range []string{"file_uploads", "temp_file_uploads"} {
+		copied, failed := migrateTable(ctx, db, table, src, dst, cfg.UploadPath, *dryRun, *deleteSource)
+		log.Printf("%s: %d copied, %d failed", table, copied, failed)
+	}
+}
+
+func migrateTable(ctx context.Context, db *sql.DB, table string, src, dst storage.Storage, uploadPath string, dryRun, deleteSource bool) (int, int) {
+	rows, err := db.Query("SELECT id, storage_path, size, content_type FROM " + table)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", table, err)
+	}
+
+	var files []storedFile
+	for rows.Next() {
+		var file storedFile
+		if err := rows.Scan(&file.id, &file.storagePath, &file.size, &file.contentType); err != nil {
+			log.Fatalf("Failed to scan %s row: %v", table, err)
+		}
+		files = append(files, file)
+	}
+	rows.Close()
+
+	copied, failed := 0, 0
+	for _, file := range files {
+		key := objectKey(file.storagePath, uploadPath)
+
+		if dryRun {
+			log.Printf("[dry-run] %s %s -> %s", table, file.storagePath, key)
+			continue
+		}
+
+		exists, err := dst.Exists(ctx, key)
+		if err != nil {
+			log.Printf("Failed to check %s in %s: %v", key, dst.Name(), err)
+			failed++
+			continue
+		}
+
+		if !exists {
+			if err := storage.Copy(ctx, src, dst, key, file.size, file.contentType); err != nil {
+				log.Printf("Failed to copy %s: %v", key, err)
+				failed++
+				continue
+			}
+		}
+
+		if key != file.storagePath {
+			if _, err := db.Exec("UPDATE "+table+" SET storage_path = ? WHERE id = ?", key, file.id); err != nil {
+				log.Printf("Failed to update storage path for %s: %v", file.id, err)
+				failed++
+				continue
+			}
+		}
+
+		if deleteSource {
+			if err := src.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete %s from %s: %v", key, src.Name(), err)
+			}
+		}
+
+		copied++
+	}
+
+	return copied, failed
+}
+
+// objectKey converts a stored path into a storage key. Rows written before the
+// storage abstraction hold filesystem paths inside the upload directory.
+func objectKey(storagePath, uploadPath string) string {
+	if rel, err := filepath.Rel(uploadPath, storagePath); err == nil && !strings.HasPrefix(rel, "..") {
+		return storage.CleanKey(filepath.ToSlash(rel))
+	}
+	return storage.CleanKey(storagePath)
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"formhub/pkg/storage"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,8 +17,17 @@ type Config struct {
 	DatabaseURL   string
 	RedisURL      string
 	JWTSecret     string
+	APIKeySecret  string // Key for stored API key hashes and encrypted TOTP secrets
 	AllowedOrigins []string
 	SMTPConfig    SMTPConfig
+	BaseURL       string // Public URL of this API, used to build links in emails and exports
+	FrontendURL   string // Public URL of the dashboard, used for links users open in a browser
+	UploadPath    string
+	FileURLSecret string // HMAC key for signed file download URLs
+	FileURLTTL    time.Duration
+	ExportLinkTTL time.Duration // Lifetime of the signed file links in submission exports
+	Storage       storage.Config
+	WebhookLogRetention time.Duration // How long webhook delivery attempts are kept
 }
 
 type SMTPConfig struct {
@@ -38,6 +49,7 @@ func Load() (*Config, error) {
 		DatabaseURL:   getEnv("DATABASE_URL", "postgres://localhost:5432/formhub?sslmode=disable"),
 		RedisURL:      getEnv("REDIS_URL", "redis://localhost:6379"),
 		JWTSecret:     getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
+		APIKeySecret:  getEnv("API_KEY_SECRET", ""),
 		AllowedOrigins: strings.Split(getEnv("ALLOWED_ORIGINS", "http://localhost:3000,http://localhost:5173"), ","),
 		SMTPConfig: SMTPConfig{
 			Host:      getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -47,6 +59,32 @@ func Load() (*Config, error) {
 			FromEmail: getEnv("FROM_EMAIL", "noreply@formhub.com"),
 			FromName:  getEnv("FROM_NAME", "FormHub"),
 		},
+		BaseURL:       strings.TrimRight(getEnv("API_BASE_URL", "http://localhost:8080"), "/"),
+		FrontendURL:   strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:3000"), "/"),
+		UploadPath:    getEnv("UPLOAD_PATH", "./uploads"),
+		FileURLSecret: getEnv("FILE_URL_SECRET", ""),
+		FileURLTTL:    time.Duration(getEnvAsInt("FILE_URL_TTL_HOURS", 168)) * time.Hour,
+		ExportLinkTTL: time.Duration(getEnvAsInt("EXPORT_LINK_TTL_HOURS", 168)) * time.Hour,
+		WebhookLogRetention: time.Duration(getEnvAsInt("WEBHOOK_LOG_RETENTION_DAYS", 30)) * 24 * time.Hour,
+	}
+
+	cfg.Storage = storage.Config{
+		Type:        getEnv("STORAGE_TYPE", "local"),
+		LocalPath:   cfg.UploadPath,
+		S3Endpoint:  getEnv("S3_ENDPOINT", ""),
+		S3Region:    getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:    getEnv("S3_BUCKET", ""),
+		S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle: getEnv("S3_PATH_STYLE", "false") == "true",
+	}
+
+	// Fall back to the JWT secret so signed URLs work without extra setup
+	if cfg.FileURLSecret == "" {
+		cfg.FileURLSecret = cfg.JWTSecret
+	}
+	if cfg.APIKeySecret == "" {
+		cfg.APIKeySecret = cfg.JWTSecret
 	}
 
 	// Validate required fields
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"formhub/internal/models"
+	"formhub/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AccountHandler struct {
+	accountService *services.AccountService
+}
+
+func NewAccountHandler(accountService *services.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+func (h *AccountHandler) UpdateProfile(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.accountService.UpdateProfile(userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Profile updated successfully",
+		"user":    user,
+	})
+}
+
+// ChangePassword replaces the password and signs out every other session
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	sessionID := c.MustGet("session_id").(uuid.UUID)
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.ChangePassword(userID, sessionID, req.CurrentPassword, req.NewPassword); err != nil {
+		if errors.Is(err, services.ErrIncorrectPassword) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidAccountToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func (h *AccountHandler) ResendVerification(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if err := h.accountService.ResendVerificationEmail(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword answers the same way whether or not the email has an account
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a reset link has been sent"})
+}
+
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(req.Token, req.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidAccountToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully; please sign in again"})
+}
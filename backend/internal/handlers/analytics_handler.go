@@ -332,6 +332,32 @@ func (h *AnalyticsHandler) GetLifecycleStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// GetSubmissionRejections returns the form's recently rejected submissions, such as posts from
+// origins outside its allow-list
+func (h *AnalyticsHandler) GetSubmissionRejections(c *gin.Context) {
+	formID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid form ID"})
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	workspaceID := c.MustGet("workspace_id").(uuid.UUID)
+	rejections, err := h.submissionLifecycleService.GetSubmissionRejections(c.Request.Context(), formID, workspaceID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get submission rejections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rejections": rejections})
+}
+
 // GetPendingActions returns pending actions requiring attention
 func (h *AnalyticsHandler) GetPendingActions(c *gin.Context) {
 	userID := h.getUserIDFromContext(c)
@@ -1,21 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"formhub/internal/models"
 	"formhub/internal/services"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
+	authService    *services.AuthService
+	accountService *services.AccountService
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, accountService *services.AccountService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:    authService,
+		accountService: accountService,
 	}
 }
 
@@ -26,12 +32,16 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Register(req)
+	response, err := h.authService.Register(req, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := h.accountService.SendVerificationEmail(response.User); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", response.User.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -42,8 +52,12 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Login(req)
+	response, err := h.authService.Login(req, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
+		if errors.Is(err, services.ErrSSORequired) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "sso_required"})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -60,23 +74,68 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Validate refresh token
-	claims, err := h.authService.ValidateToken(req.RefreshToken)
+	response, err := h.authService.RefreshTokens(req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout revokes the current session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID := c.MustGet("session_id").(uuid.UUID)
+	if sessionID == uuid.Nil {
+		// Token issued before sessions existed; it expires on its own
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 		return
 	}
 
-	// Get user
-	user, err := h.authService.GetUserByID(claims.UserID)
+	if err := h.authService.Logout(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	sessionID := c.MustGet("session_id").(uuid.UUID)
+
+	sessions, err := h.authService.ListSessions(userID, sessionID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
 		return
 	}
 
-	// Generate new tokens (this would normally have different logic for refresh)
-	response := &models.AuthResponse{User: user}
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(userID, sessionID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
 }
 
 func (h *AuthHandler) GetProfile(c *gin.Context) {
@@ -95,39 +154,54 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
-func (h *AuthHandler) UpdateProfile(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+// UpdateUserRole changes another user's role
+func (h *AuthHandler) UpdateUserRole(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
 	var req struct {
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-		Company   string `json:"company"`
+		Role models.UserRole `json:"role" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	user, err := h.authService.UpdateUserRole(targetID, req.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if errors.Is(err, services.ErrLastOwner) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
 
-	// TODO: Implement profile update in auth service
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Profile updated successfully",
-		"user_id": userID,
-		"updates": req,
+		"message": "Role updated successfully",
+		"user":    user,
 	})
 }
 
 func (h *AuthHandler) GetAPIKeys(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+	workspaceID, exists := c.Get("workspace_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	apiKeys, err := h.authService.GetUserAPIKeys(userID.(uuid.UUID))
+	apiKeys, err := h.authService.GetWorkspaceAPIKeys(workspaceID.(uuid.UUID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API keys"})
 		return
@@ -143,16 +217,18 @@ func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Name string `json:"name" binding:"required"`
-	}
+	var req models.CreateAPIKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	apiKey, err := h.authService.CreateAPIKey(userID.(uuid.UUID), req.Name)
+	apiKey, err := h.authService.CreateAPIKey(userID.(uuid.UUID), c.MustGet("workspace_id").(uuid.UUID), req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKeyRequest) || strings.HasSuffix(err.Error(), "not found in workspace") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
 		return
 	}
@@ -164,7 +240,7 @@ func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
 }
 
 func (h *AuthHandler) DeleteAPIKey(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+	workspaceID, exists := c.Get("workspace_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -177,10 +253,51 @@ func (h *AuthHandler) DeleteAPIKey(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.DeleteAPIKey(keyID, userID.(uuid.UUID)); err != nil {
+	if err := h.authService.DeleteAPIKey(keyID, workspaceID.(uuid.UUID)); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
-}
\ No newline at end of file
+}
+
+// RotateAPIKey issues a new secret for the key; the old one keeps working for the grace period
+func (h *AuthHandler) RotateAPIKey(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var req models.RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	gracePeriod := services.DefaultAPIKeyGracePeriod
+	if req.GracePeriodHours != nil {
+		gracePeriod = time.Duration(*req.GracePeriodHours) * time.Hour
+	}
+
+	apiKey, err := h.authService.RotateAPIKey(keyID, c.MustGet("workspace_id").(uuid.UUID), gracePeriod)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKeyRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Error() == "API key not found or unauthorized" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key rotated successfully",
+		"api_key": apiKey,
+	})
+}
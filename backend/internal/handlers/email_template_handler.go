@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"formhub/internal/models"
 	"formhub/internal/services"
@@ -51,8 +52,13 @@ func (h *EmailTemplateHandler) CreateTemplate(c *gin.Context) {
 	}
 
 	userID := getUserIDFromContext(c)
-	template, err := h.templateService.CreateTemplate(userID, req)
+	workspaceID := getWorkspaceIDFromContext(c)
+	template, err := h.templateService.CreateTemplate(userID, workspaceID, req)
 	if err != nil {
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -84,7 +90,7 @@ func (h *EmailTemplateHandler) GetTemplate(c *gin.Context) {
 }
 
 func (h *EmailTemplateHandler) ListTemplates(c *gin.Context) {
-	userID := getUserIDFromContext(c)
+	workspaceID := getWorkspaceIDFromContext(c)
 
 	// Parse query parameters
 	var templateType *models.EmailTemplateType
@@ -105,7 +111,7 @@ func (h *EmailTemplateHandler) ListTemplates(c *gin.Context) {
 		language = &l
 	}
 
-	templates, err := h.templateService.ListTemplates(userID, templateType, formID, language)
+	templates, err := h.templateService.ListTemplates(workspaceID, templateType, formID, language)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -133,6 +139,10 @@ func (h *EmailTemplateHandler) UpdateTemplate(c *gin.Context) {
 	userID := getUserIDFromContext(c)
 	template, err := h.templateService.UpdateTemplate(userID, templateID, req)
 	if err != nil {
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -153,6 +163,10 @@ func (h *EmailTemplateHandler) DeleteTemplate(c *gin.Context) {
 	userID := getUserIDFromContext(c)
 	err = h.templateService.DeleteTemplate(userID, templateID)
 	if err != nil {
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -234,8 +248,13 @@ func (h *EmailTemplateHandler) CreateProvider(c *gin.Context) {
 	}
 
 	userID := getUserIDFromContext(c)
-	provider, err := h.providerService.CreateProvider(userID, req)
+	workspaceID := getWorkspaceIDFromContext(c)
+	provider, err := h.providerService.CreateProvider(userID, workspaceID, req)
 	if err != nil {
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -247,8 +266,8 @@ func (h *EmailTemplateHandler) CreateProvider(c *gin.Context) {
 }
 
 func (h *EmailTemplateHandler) ListProviders(c *gin.Context) {
-	userID := getUserIDFromContext(c)
-	providers, err := h.providerService.ListProviders(userID)
+	workspaceID := getWorkspaceIDFromContext(c)
+	providers, err := h.providerService.ListProviders(workspaceID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -614,6 +633,17 @@ func getUserIDFromContext(c *gin.Context) uuid.UUID {
 	return uuid.New() // In production, this should be an error
 }
 
+func getWorkspaceIDFromContext(c *gin.Context) uuid.UUID {
+	// Set by the WorkspaceContext middleware for every protected route
+	if workspaceID, ok := c.Get("workspace_id"); ok {
+		if id, ok := workspaceID.(uuid.UUID); ok {
+			return id
+		}
+	}
+
+	return uuid.Nil
+}
+
 func parseDateRange(c *gin.Context) (time.Time, time.Time) {
 	defaultStart := time.Now().AddDate(0, -1, 0) // 1 month ago
 	defaultEnd := time.Now()
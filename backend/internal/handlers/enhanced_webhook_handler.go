@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"formhub/internal/models"
 	"formhub/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // EnhancedWebhookHandler handles webhook management API endpoints
@@ -16,6 +20,7 @@ type EnhancedWebhookHandler struct {
 	webhookService   *services.EnhancedWebhookService
 	integrationManager *services.IntegrationManager
 	authService      *services.AuthService
+	formService      *services.FormService
 }
 
 // NewEnhancedWebhookHandler creates a new enhanced webhook handler
@@ -23,11 +28,13 @@ func NewEnhancedWebhookHandler(
 	webhookService *services.EnhancedWebhookService,
 	integrationManager *services.IntegrationManager,
 	authService *services.AuthService,
+	formService *services.FormService,
 ) *EnhancedWebhookHandler {
 	return &EnhancedWebhookHandler{
 		webhookService:   webhookService,
 		integrationManager: integrationManager,
 		authService:      authService,
+		formService:      formService,
 	}
 }
 
@@ -48,17 +55,22 @@ func (ewh *EnhancedWebhookHandler) CreateWebhookEndpoint(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canManageForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
 	
 	if err := ewh.webhookService.CreateWebhookEndpoint(formID, &endpoint); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create endpoint", "details": err.Error()})
+		ewh.webhookEndpointError(c, "Failed to create endpoint", err)
 		return
 	}
 	
+	c.Header("ETag", endpointETag(endpoint.Version))
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "Webhook endpoint created successfully",
@@ -83,20 +95,36 @@ func (ewh *EnhancedWebhookHandler) UpdateWebhookEndpoint(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canManageForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
 	
-	if err := ewh.webhookService.UpdateWebhookEndpoint(formID, endpointID, &updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update endpoint", "details": err.Error()})
+	// The version comes from If-Match, or from the endpoint as it was read
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
 		return
 	}
+	if version == 0 {
+		version = updates.Version
+	}
 	
+	if err := ewh.webhookService.UpdateWebhookEndpoint(formID, endpointID, &updates, version); err != nil {
+		ewh.webhookEndpointError(c, "Failed to update endpoint", err)
+		return
+	}
+	
+	c.Header("ETag", endpointETag(updates.Version))
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Webhook endpoint updated successfully",
+		"success":  true,
+		"message":  "Webhook endpoint updated successfully",
+		"endpoint": updates,
 	})
 }
 
@@ -111,14 +139,24 @@ func (ewh *EnhancedWebhookHandler) DeleteWebhookEndpoint(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canManageForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
 	
-	if err := ewh.webhookService.DeleteWebhookEndpoint(formID, endpointID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete endpoint", "details": err.Error()})
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return
+	}
+	
+	if err := ewh.webhookService.DeleteWebhookEndpoint(formID, endpointID, version); err != nil {
+		ewh.webhookEndpointError(c, "Failed to delete endpoint", err)
 		return
 	}
 	
@@ -137,29 +175,57 @@ func (ewh *EnhancedWebhookHandler) GetWebhookEndpoints(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canAccessForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
 	
-	config, err := ewh.webhookService.GetFormWebhookConfig(formID)
+	endpoints, err := ewh.webhookService.ListWebhookEndpoints(formID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get endpoints", "details": err.Error()})
 		return
 	}
 	
-	endpoints := []services.WebhookEndpoint{}
-	if config != nil {
-		endpoints = config.Endpoints
-	}
-	
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"endpoints": endpoints,
 	})
 }
 
+// GetWebhookEndpoint returns one of the form's endpoints with its version as the ETag
+func (ewh *EnhancedWebhookHandler) GetWebhookEndpoint(c *gin.Context) {
+	formID := c.Param("formId")
+	endpointID := c.Param("endpointId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canAccessForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	endpoint, err := ewh.webhookService.GetWebhookEndpoint(formID, endpointID)
+	if err != nil {
+		ewh.webhookEndpointError(c, "Failed to get endpoint", err)
+		return
+	}
+
+	c.Header("ETag", endpointETag(endpoint.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"endpoint": endpoint,
+	})
+}
+
 // TestWebhookEndpoint tests a webhook endpoint
 func (ewh *EnhancedWebhookHandler) TestWebhookEndpoint(c *gin.Context) {
 	formID := c.Param("formId")
@@ -171,7 +237,11 @@ func (ewh *EnhancedWebhookHandler) TestWebhookEndpoint(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canManageForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
@@ -179,7 +249,7 @@ func (ewh *EnhancedWebhookHandler) TestWebhookEndpoint(c *gin.Context) {
 	
 	result, err := ewh.webhookService.TestWebhookEndpoint(formID, endpointID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to test endpoint", "details": err.Error()})
+		ewh.webhookEndpointError(c, "Failed to test endpoint", err)
 		return
 	}
 	
@@ -194,6 +264,267 @@ func (ewh *EnhancedWebhookHandler) TestWebhookEndpoint(c *gin.Context) {
 	})
 }
 
+// RotateWebhookSecret issues a new signing secret for the endpoint; deliveries are signed with the
+// old one as well for the grace period
+func (ewh *EnhancedWebhookHandler) RotateWebhookSecret(c *gin.Context) {
+	formID := c.Param("formId")
+	endpointID := c.Param("endpointId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canManageForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var req models.RotateWebhookSecretRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+	}
+
+	gracePeriod := services.DefaultWebhookSecretGracePeriod
+	if req.GracePeriodHours != nil {
+		gracePeriod = time.Duration(*req.GracePeriodHours) * time.Hour
+	}
+
+	endpoint, err := ewh.webhookService.RotateWebhookSecret(formID, endpointID, gracePeriod)
+	if err != nil {
+		ewh.webhookEndpointError(c, "Failed to rotate secret", err)
+		return
+	}
+
+	c.Header("ETag", endpointETag(endpoint.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "Webhook secret rotated successfully",
+		"endpoint": endpoint,
+	})
+}
+
+// Account-Level Webhook Endpoints
+
+// ListAccountWebhookEndpoints returns the current workspace's account-level endpoints
+func (ewh *EnhancedWebhookHandler) ListAccountWebhookEndpoints(c *gin.Context) {
+	workspaceID := c.MustGet("workspace_id").(uuid.UUID)
+
+	endpoints, err := ewh.webhookService.ListAccountWebhookEndpoints(workspaceID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get endpoints", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"endpoints": endpoints,
+	})
+}
+
+// CreateAccountWebhookEndpoint creates an endpoint receiving events from all of the workspace's
+// forms or from the forms in form_ids
+func (ewh *EnhancedWebhookHandler) CreateAccountWebhookEndpoint(c *gin.Context) {
+	workspaceID := c.MustGet("workspace_id").(uuid.UUID)
+
+	var endpoint services.WebhookEndpoint
+	if err := c.ShouldBindJSON(&endpoint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid endpoint data", "details": err.Error()})
+		return
+	}
+
+	if err := ewh.webhookService.CreateAccountWebhookEndpoint(workspaceID.String(), &endpoint); err != nil {
+		ewh.webhookEndpointError(c, "Failed to create endpoint", err)
+		return
+	}
+
+	c.Header("ETag", endpointETag(endpoint.Version))
+	c.JSON(http.StatusCreated, gin.H{
+		"success":  true,
+		"message":  "Webhook endpoint created successfully",
+		"endpoint": endpoint,
+	})
+}
+
+// GetAccountWebhookEndpoint returns an account-level endpoint with its version as the ETag
+func (ewh *EnhancedWebhookHandler) GetAccountWebhookEndpoint(c *gin.Context) {
+	workspaceID := c.MustGet("workspace_id").(uuid.UUID)
+
+	endpoint, err := ewh.webhookService.GetAccountWebhookEndpoint(workspaceID.String(), c.Param("endpointId"))
+	if err != nil {
+		ewh.webhookEndpointError(c, "Failed to get endpoint", err)
+		return
+	}
+
+	c.Header("ETag", endpointETag(endpoint.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"endpoint": endpoint,
+	})
+}
+
+// UpdateAccountWebhookEndpoint replaces an account-level endpoint's configuration and subscriptions
+func (ewh *EnhancedWebhookHandler) UpdateAccountWebhookEndpoint(c *gin.Context) {
+	workspaceID := c.MustGet("workspace_id").(uuid.UUID)
+
+	var updates services.WebhookEndpoint
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid endpoint data", "details": err.Error()})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return
+	}
+	if version == 0 {
+		version = updates.Version
+	}
+
+	if err := ewh.webhookService.UpdateAccountWebhookEndpoint(workspaceID.String(), c.Param("endpointId"), &updates, version); err != nil {
+		ewh.webhookEndpointError(c, "Failed to update endpoint", err)
+		return
+	}
+
+	c.Header("ETag", endpointETag(updates.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "Webhook endpoint updated successfully",
+		"endpoint": updates,
+	})
+}
+
+// DeleteAccountWebhookEndpoint deletes an account-level endpoint
+func (ewh *EnhancedWebhookHandler) DeleteAccountWebhookEndpoint(c *gin.Context) {
+	workspaceID := c.MustGet("workspace_id").(uuid.UUID)
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return
+	}
+
+	if err := ewh.webhookService.DeleteAccountWebhookEndpoint(workspaceID.String(), c.Param("endpointId"), version); err != nil {
+		ewh.webhookEndpointError(c, "Failed to delete endpoint", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook endpoint deleted successfully",
+	})
+}
+
+// RotateAccountWebhookSecret issues a new signing secret for an account-level endpoint
+func (ewh *EnhancedWebhookHandler) RotateAccountWebhookSecret(c *gin.Context) {
+	workspaceID := c.MustGet("workspace_id").(uuid.UUID)
+
+	var req models.RotateWebhookSecretRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+	}
+
+	gracePeriod := services.DefaultWebhookSecretGracePeriod
+	if req.GracePeriodHours != nil {
+		gracePeriod = time.Duration(*req.GracePeriodHours) * time.Hour
+	}
+
+	endpoint, err := ewh.webhookService.RotateAccountWebhookSecret(workspaceID.String(), c.Param("endpointId"), gracePeriod)
+	if err != nil {
+		ewh.webhookEndpointError(c, "Failed to rotate secret", err)
+		return
+	}
+
+	c.Header("ETag", endpointETag(endpoint.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "Webhook secret rotated successfully",
+		"endpoint": endpoint,
+	})
+}
+
+func (ewh *EnhancedWebhookHandler) webhookEndpointError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookEndpointNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+	case errors.Is(err, services.ErrWebhookEndpointConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Webhook endpoint has changed; fetch it again and retry"})
+	case errors.Is(err, services.ErrInvalidGracePeriod), errors.Is(err, services.ErrInvalidEndpointForms),
+		errors.Is(err, services.ErrUnknownWebhookEvent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+// endpointETag is the entity tag of an endpoint version
+func endpointETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion reads the endpoint version from the If-Match header. It returns 0 when the header
+// is absent or "*", in which case the change is not conditional.
+func ifMatchVersion(c *gin.Context) (int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// Webhook Events
+
+// ListWebhookEvents returns the event catalogue endpoints can subscribe to
+func (ewh *EnhancedWebhookHandler) ListWebhookEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"events":  services.WebhookEventCatalogue(),
+	})
+}
+
+// GetWebhookEventSchema returns the JSON Schema of an event's data, for the version given by the
+// version query parameter or the latest one
+func (ewh *EnhancedWebhookHandler) GetWebhookEventSchema(c *gin.Context) {
+	version := 0
+	if v := c.Query("version"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schema version"})
+			return
+		}
+		version = parsed
+	}
+
+	definition, err := services.WebhookEventSchema(c.Param("type"), version)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownWebhookEvent):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook event not found"})
+		case errors.Is(err, services.ErrWebhookEventSchemaMissing):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook event schema version not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event schema", "details": err.Error()})
+		}
+		return
+	}
+
+	c.Header("X-Webhook-Event-Version", strconv.Itoa(definition.Version))
+	c.Data(http.StatusOK, "application/schema+json", definition.Schema)
+}
+
 // Webhook Analytics
 
 // GetWebhookAnalytics returns comprehensive webhook analytics
@@ -205,7 +536,11 @@ func (ewh *EnhancedWebhookHandler) GetWebhookAnalytics(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canAccessForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
@@ -239,7 +574,11 @@ func (ewh *EnhancedWebhookHandler) GetRealtimeWebhookStats(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canAccessForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
@@ -266,7 +605,11 @@ func (ewh *EnhancedWebhookHandler) GetWebhookMonitoring(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canAccessForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
@@ -284,6 +627,374 @@ func (ewh *EnhancedWebhookHandler) GetWebhookMonitoring(c *gin.Context) {
 	})
 }
 
+// Delivery Logs
+
+// ListWebhookLogs returns a page of the form's delivery attempts, filtered by endpoint, delivery,
+// event, outcome or a search term
+func (ewh *EnhancedWebhookHandler) ListWebhookLogs(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canAccessForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	filter := services.WebhookLogFilter{
+		EndpointID: c.Query("endpoint_id"),
+		DeliveryID: c.Query("delivery_id"),
+		EventID:    c.Query("event_id"),
+		EventType:  c.Query("event_type"),
+		ErrorClass: c.Query("error_class"),
+		Search:     c.Query("q"),
+		Limit:      50,
+	}
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := parseInt(l); err == nil && parsed > 0 && parsed <= 200 {
+			filter.Limit = parsed
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := parseInt(o); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+	if s := c.Query("success"); s != "" {
+		success, err := strconv.ParseBool(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "success must be true or false"})
+			return
+		}
+		filter.Success = &success
+	}
+	if code := c.Query("status_code"); code != "" {
+		parsed, err := parseInt(code)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status_code"})
+			return
+		}
+		filter.StatusCode = parsed
+	}
+	for param, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected RFC 3339"})
+				return
+			}
+			*target = &parsed
+		}
+	}
+
+	logs, total, err := ewh.webhookService.ListWebhookLogs(formID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook logs", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"logs":    logs,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
+
+// GetWebhookLog returns a delivery attempt with the request sent and the response received
+func (ewh *EnhancedWebhookHandler) GetWebhookLog(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canAccessForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	entry, err := ewh.webhookService.GetWebhookLog(formID, c.Param("logId"))
+	if err != nil {
+		ewh.webhookLogError(c, "Failed to get webhook log", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"log":     entry,
+	})
+}
+
+// CompareWebhookLogs returns the attempts named in ids, comma separated, side by side
+func (ewh *EnhancedWebhookHandler) CompareWebhookLogs(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canAccessForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var logIDs []string
+	for _, id := range strings.Split(c.Query("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			logIDs = append(logIDs, id)
+		}
+	}
+
+	comparison, err := ewh.webhookService.CompareWebhookLogs(formID, logIDs)
+	if err != nil {
+		ewh.webhookLogError(c, "Failed to compare webhook logs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"comparison": comparison,
+	})
+}
+
+// ResendWebhookAttempt sends a logged attempt's original payload to its endpoint again
+func (ewh *EnhancedWebhookHandler) ResendWebhookAttempt(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canManageForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	result, err := ewh.webhookService.ResendWebhookAttempt(formID, c.Param("logId"))
+	if err != nil {
+		ewh.webhookLogError(c, "Failed to resend webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": result.Success,
+		"result":  result,
+	})
+}
+
+func (ewh *EnhancedWebhookHandler) webhookLogError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookLogNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook log not found"})
+	case errors.Is(err, services.ErrWebhookEndpointNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+	case errors.Is(err, services.ErrWebhookLogNotResendable), errors.Is(err, services.ErrInvalidWebhookLogComparison):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+// Dead-Lettered Deliveries
+
+// ListDeadLetters returns the form's dead-lettered deliveries, optionally for one endpoint
+func (ewh *EnhancedWebhookHandler) ListDeadLetters(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canAccessForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := parseInt(l); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := parseInt(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	deliveries, total, err := ewh.webhookService.ListDeadLetters(formID, c.Query("endpoint_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list dead letters", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"dead_letters": deliveries,
+		"total":        total,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+// GetDeadLetter returns a dead-lettered delivery with the event it carries
+func (ewh *EnhancedWebhookHandler) GetDeadLetter(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canAccessForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	delivery, err := ewh.webhookService.GetDeadLetter(formID, c.Param("deliveryId"))
+	if err != nil {
+		ewh.deadLetterError(c, "Failed to get dead letter", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"dead_letter": delivery,
+	})
+}
+
+// ReplayDeadLetter queues a dead-lettered delivery again
+func (ewh *EnhancedWebhookHandler) ReplayDeadLetter(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canManageForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	if err := ewh.webhookService.ReplayDeadLetter(formID, c.Param("deliveryId")); err != nil {
+		ewh.deadLetterError(c, "Failed to replay dead letter", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Delivery queued for replay",
+	})
+}
+
+// ReplayDeadLetters queues all of the form's dead-lettered deliveries again, optionally for one endpoint
+func (ewh *EnhancedWebhookHandler) ReplayDeadLetters(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canManageForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	replayed, err := ewh.webhookService.ReplayDeadLetters(formID, c.Query("endpoint_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay dead letters", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"replayed": replayed,
+	})
+}
+
+// PurgeDeadLetter deletes a dead-lettered delivery
+func (ewh *EnhancedWebhookHandler) PurgeDeadLetter(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canManageForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	if err := ewh.webhookService.PurgeDeadLetter(formID, c.Param("deliveryId")); err != nil {
+		ewh.deadLetterError(c, "Failed to purge dead letter", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Dead letter purged",
+	})
+}
+
+// PurgeDeadLetters deletes all of the form's dead-lettered deliveries, optionally for one endpoint
+func (ewh *EnhancedWebhookHandler) PurgeDeadLetters(c *gin.Context) {
+	formID := c.Param("formId")
+
+	// Validate user permissions
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !ewh.canManageForm(userID, formID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	purged, err := ewh.webhookService.PurgeDeadLetters(formID, c.Query("endpoint_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge dead letters", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"purged":  purged,
+	})
+}
+
+func (ewh *EnhancedWebhookHandler) deadLetterError(c *gin.Context, message string, err error) {
+	if errors.Is(err, services.ErrWebhookDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+}
+
 // Third-party Integrations
 
 // ListIntegrations returns available integrations
@@ -463,7 +1174,11 @@ func (ewh *EnhancedWebhookHandler) InstallMarketplaceIntegration(c *gin.Context)
 	formID := c.Param("formId")
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canManageForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
@@ -501,6 +1216,19 @@ func (ewh *EnhancedWebhookHandler) GetMarketplaceCategories(c *gin.Context) {
 
 // Helper methods
 
+// contextUserID returns the ID of the authenticated user, or false when the request carries none
+func contextUserID(c *gin.Context) (string, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	userID, ok := value.(uuid.UUID)
+	if !ok || userID == uuid.Nil {
+		return "", false
+	}
+	return userID.String(), true
+}
+
 func (ewh *EnhancedWebhookHandler) parseTimeRange(c *gin.Context) (*services.TimeRange, error) {
 	defaultStart := time.Now().AddDate(0, 0, -7) // 7 days ago
 	defaultEnd := time.Now()
@@ -525,15 +1253,31 @@ func (ewh *EnhancedWebhookHandler) parseTimeRange(c *gin.Context) (*services.Tim
 }
 
 func (ewh *EnhancedWebhookHandler) canAccessForm(userID, formID string) bool {
-	// Implementation would check if user has read access to the form
-	// For this example, we'll assume all authenticated users can access
-	return userID != ""
+	// Any member of the form's workspace can read its webhook configuration
+	return ewh.hasFormRole(userID, formID, models.WorkspaceRoleViewer)
 }
 
 func (ewh *EnhancedWebhookHandler) canManageForm(userID, formID string) bool {
-	// Implementation would check if user has write access to the form
-	// For this example, we'll assume all authenticated users can manage
-	return userID != ""
+	// Viewers cannot change where submissions are delivered
+	return ewh.hasFormRole(userID, formID, models.WorkspaceRoleMember)
+}
+
+func (ewh *EnhancedWebhookHandler) hasFormRole(userID, formID string, min models.WorkspaceRole) bool {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return false
+	}
+	fid, err := uuid.Parse(formID)
+	if err != nil {
+		return false
+	}
+
+	form, err := ewh.formService.GetFormByID(fid)
+	if err != nil {
+		return false
+	}
+
+	return ewh.formService.AuthorizeForm(form, uid, min) == nil
 }
 
 // WebSocket endpoint for real-time webhook monitoring
@@ -545,7 +1289,11 @@ func (ewh *EnhancedWebhookHandler) WebSocketMonitoring(c *gin.Context) {
 	}
 	
 	// Validate user permissions
-	userID, _ := ewh.authService.GetUserIDFromContext(c)
+	userID, ok := contextUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 	if !ewh.canAccessForm(userID, formID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"formhub/internal/models"
+	"formhub/internal/services"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FileHandler struct {
+	fileUploadService *services.FileUploadService
+	workspaceService  *services.WorkspaceService
+}
+
+func NewFileHandler(fileUploadService *services.FileUploadService, workspaceService *services.WorkspaceService) *FileHandler {
+	return &FileHandler{
+		fileUploadService: fileUploadService,
+		workspaceService:  workspaceService,
+	}
+}
+
+// DownloadFile streams a submission attachment to members of the form's workspace
+func (h *FileHandler) DownloadFile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	workspaceID, err := h.fileUploadService.GetFileWorkspaceID(fileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	uid := userID.(uuid.UUID)
+	if h.workspaceService.Authorize(workspaceID, uid, models.WorkspaceRoleViewer) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	h.serveFile(c, fileID, &models.FileDownloadLog{
+		UserID:     &uid,
+		AccessType: "authenticated",
+	})
+}
+
+// GetSignedURL issues a fresh expiring download link for a file in one of the user's workspaces
+func (h *FileHandler) GetSignedURL(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	workspaceID, err := h.fileUploadService.GetFileWorkspaceID(fileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	if h.workspaceService.Authorize(workspaceID, userID.(uuid.UUID), models.WorkspaceRoleViewer) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	url := h.fileUploadService.SignedURL(fileID)
+	if url == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Signed URLs are not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// DownloadSignedFile streams a file for anyone holding a valid signed link
+func (h *FileHandler) DownloadSignedFile(c *gin.Context) {
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	if err := h.fileUploadService.VerifySignedURL(fileID, c.Query("expires"), c.Query("signature")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired download link"})
+		return
+	}
+
+	h.serveFile(c, fileID, &models.FileDownloadLog{
+		AccessType: "signed_url",
+	})
+}
+
+func (h *FileHandler) serveFile(c *gin.Context, fileID uuid.UUID, entry *models.FileDownloadLog) {
+	file, reader, err := h.fileUploadService.OpenFile(c.Request.Context(), fileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer reader.Close()
+
+	entry.FileID = file.ID
+	entry.IPAddress = c.ClientIP()
+	entry.UserAgent = c.GetHeader("User-Agent")
+	if err := h.fileUploadService.RecordDownload(entry); err != nil {
+		log.Printf("Failed to record download of file %s: %v", file.ID, err)
+	}
+
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.OriginalName}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-store")
+	c.Status(http.StatusOK)
+
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("Failed to stream file %s: %v", file.ID, err)
+	}
+}
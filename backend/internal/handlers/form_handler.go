@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"formhub/internal/models"
 	"formhub/internal/services"
 	"net/http"
@@ -26,18 +27,31 @@ func NewFormHandler(formService *services.FormService, authService *services.Aut
 }
 
 func (h *FormHandler) GetForms(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+	workspaceID, exists := c.Get("workspace_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	forms, err := h.formService.GetUserForms(userID.(uuid.UUID))
+	forms, err := h.formService.GetWorkspaceForms(workspaceID.(uuid.UUID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get forms"})
 		return
 	}
 
+	// Keys limited to some forms only see those
+	if value, ok := c.Get("api_key"); ok {
+		if apiKey, ok := value.(*models.APIKey); ok {
+			allowed := forms[:0]
+			for _, form := range forms {
+				if apiKey.AllowsForm(form.ID) {
+					allowed = append(allowed, form)
+				}
+			}
+			forms = allowed
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"forms": forms})
 }
 
@@ -54,11 +68,18 @@ func (h *FormHandler) CreateForm(c *gin.Context) {
 		return
 	}
 
-	form, err := h.formService.CreateForm(userID.(uuid.UUID), req)
+	form, err := h.formService.CreateForm(userID.(uuid.UUID), c.MustGet("workspace_id").(uuid.UUID), req)
 	if err != nil {
-		if err.Error() == "form limit reached for plan free" || 
-		   err.Error() == "form limit reached for plan starter" {
-			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidFormRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if limitErr, ok := services.AsPlanLimitError(err); ok {
+			c.JSON(limitErr.StatusCode(), gin.H{"error": err.Error(), "capability": limitErr.Capability})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -91,8 +112,8 @@ func (h *FormHandler) GetForm(c *gin.Context) {
 		return
 	}
 
-	// Check ownership
-	if form.UserID != userID.(uuid.UUID) {
+	// Check workspace membership
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleViewer) != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
@@ -122,10 +143,18 @@ func (h *FormHandler) UpdateForm(c *gin.Context) {
 
 	form, err := h.formService.UpdateForm(formID, userID.(uuid.UUID), req)
 	if err != nil {
-		if err.Error() == "unauthorized" {
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
+		if errors.Is(err, services.ErrInvalidFormRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if limitErr, ok := services.AsPlanLimitError(err); ok {
+			c.JSON(limitErr.StatusCode(), gin.H{"error": err.Error(), "capability": limitErr.Capability})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -151,6 +180,10 @@ func (h *FormHandler) DeleteForm(c *gin.Context) {
 	}
 
 	if err := h.formService.DeleteForm(formID, userID.(uuid.UUID)); err != nil {
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -173,11 +206,18 @@ func (h *FormHandler) CreateFormWithFields(c *gin.Context) {
 	}
 
 	// Create the base form first
-	form, err := h.formService.CreateForm(userID.(uuid.UUID), req.CreateFormRequest)
+	form, err := h.formService.CreateForm(userID.(uuid.UUID), c.MustGet("workspace_id").(uuid.UUID), req.CreateFormRequest)
 	if err != nil {
-		if err.Error() == "form limit reached for plan free" || 
-		   err.Error() == "form limit reached for plan starter" {
-			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidFormRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if limitErr, ok := services.AsPlanLimitError(err); ok {
+			c.JSON(limitErr.StatusCode(), gin.H{"error": err.Error(), "capability": limitErr.Capability})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -188,7 +228,11 @@ func (h *FormHandler) CreateFormWithFields(c *gin.Context) {
 	if len(req.Fields) > 0 {
 		createdFields, err := h.formService.CreateFormFields(form.ID, req.Fields)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrInvalidFormRequest) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{
 				"error": "Form created but failed to add custom fields: " + err.Error(),
 				"form": form,
 			})
@@ -234,8 +278,8 @@ func (h *FormHandler) GetFormWithFields(c *gin.Context) {
 		return
 	}
 
-	// Check ownership
-	if form.UserID != userID.(uuid.UUID) {
+	// Check workspace membership
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleViewer) != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
@@ -283,7 +327,7 @@ func (h *FormHandler) AddFormField(c *gin.Context) {
 		return
 	}
 
-	if form.UserID != userID.(uuid.UUID) {
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleMember) != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
@@ -291,6 +335,10 @@ func (h *FormHandler) AddFormField(c *gin.Context) {
 	// Create the field
 	field, err := h.formService.CreateFormField(formID, req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidFormRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -337,14 +385,22 @@ func (h *FormHandler) UpdateFormField(c *gin.Context) {
 		return
 	}
 
-	if form.UserID != userID.(uuid.UUID) {
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleMember) != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
 	// Update the field
-	field, err := h.formService.UpdateFormField(fieldID, req)
+	field, err := h.formService.UpdateFormField(formID, fieldID, req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidFormRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrFormFieldNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Form field not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -385,13 +441,17 @@ func (h *FormHandler) DeleteFormField(c *gin.Context) {
 		return
 	}
 
-	if form.UserID != userID.(uuid.UUID) {
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleMember) != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
 	// Delete the field
-	if err := h.formService.DeleteFormField(fieldID); err != nil {
+	if err := h.formService.DeleteFormField(formID, fieldID); err != nil {
+		if errors.Is(err, services.ErrFormFieldNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Form field not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -415,10 +475,7 @@ func (h *FormHandler) ReorderFormFields(c *gin.Context) {
 	}
 
 	var req struct {
-		FieldOrder []struct {
-			ID    uuid.UUID `json:"id" binding:"required"`
-			Order int       `json:"order" binding:"required"`
-		} `json:"field_order" binding:"required"`
+		FieldOrder []models.FieldOrder `json:"field_order" binding:"required,dive"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -433,13 +490,17 @@ func (h *FormHandler) ReorderFormFields(c *gin.Context) {
 		return
 	}
 
-	if form.UserID != userID.(uuid.UUID) {
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleMember) != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
 	// Update field orders
 	if err := h.formService.UpdateFieldOrder(formID, req.FieldOrder); err != nil {
+		if errors.Is(err, services.ErrFormFieldNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Form field not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -447,8 +508,14 @@ func (h *FormHandler) ReorderFormFields(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Form field order updated successfully"})
 }
 
-// ValidateFormFields validates form field configuration
+// ValidateFormFields validates sample data against the form's fields and rules
 func (h *FormHandler) ValidateFormFields(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	formIDStr := c.Param("id")
 	formID, err := uuid.Parse(formIDStr)
 	if err != nil {
@@ -456,6 +523,17 @@ func (h *FormHandler) ValidateFormFields(c *gin.Context) {
 		return
 	}
 
+	form, err := h.formService.GetFormByID(formID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Form not found"})
+		return
+	}
+
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleViewer) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
 	var req struct {
 		Data map[string]interface{} `json:"data" binding:"required"`
 	}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"formhub/internal/models"
+	"formhub/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MFAHandler struct {
+	mfaService  *services.MFAService
+	authService *services.AuthService
+}
+
+func NewMFAHandler(mfaService *services.MFAService, authService *services.AuthService) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  mfaService,
+		authService: authService,
+	}
+}
+
+// VerifyLogin exchanges the MFA token from Login and an authenticator or recovery code for tokens
+func (h *MFAHandler) VerifyLogin(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.authService.CompleteMFALogin(req.MFAToken, req.Code, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		h.mfaError(c, err, "Failed to verify code")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// BeginEnrollment returns a new secret and its provisioning URI for the user's authenticator app
+func (h *MFAHandler) BeginEnrollment(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	enrollment, err := h.mfaService.BeginEnrollment(userID)
+	if err != nil {
+		h.mfaError(c, err, "Failed to start two-factor enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmEnrollment enables MFA and returns the recovery codes, which are not shown again
+func (h *MFAHandler) ConfirmEnrollment(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := h.mfaService.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		h.mfaError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+func (h *MFAHandler) Disable(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.mfaService.Disable(userID, req.Password, req.Code); err != nil {
+		h.mfaError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := h.mfaService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		h.mfaError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+func (h *MFAHandler) mfaError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode), errors.Is(err, services.ErrInvalidMFAChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFALocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrIncorrectPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
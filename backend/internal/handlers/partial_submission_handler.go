@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"formhub/internal/models"
+	"formhub/internal/services"
+	"formhub/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PartialSubmissionHandler serves the saved progress of multi-step forms. Respondents are
+// identified by the X-Progress-Token issued when they first save, and the final submit goes
+// through SubmissionHandler.
+type PartialSubmissionHandler struct {
+	partialService    *services.PartialSubmissionService
+	submissionService *services.SubmissionService
+}
+
+func NewPartialSubmissionHandler(partialService *services.PartialSubmissionService, submissionService *services.SubmissionService) *PartialSubmissionHandler {
+	return &PartialSubmissionHandler{
+		partialService:    partialService,
+		submissionService: submissionService,
+	}
+}
+
+// SaveProgress validates and saves the values of one step. Without an X-Progress-Token new progress
+// is started and its token returned.
+func (h *PartialSubmissionHandler) SaveProgress(c *gin.Context) {
+	var req models.SaveProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 400,
+			Message:    "Invalid JSON data: " + err.Error(),
+		})
+		return
+	}
+
+	form, ok := h.resolveForm(c, req.AccessKey, req.FormID)
+	if !ok {
+		return
+	}
+
+	token := c.GetHeader("X-Progress-Token")
+	partial, result, err := h.partialService.SaveProgress(form, token, req.Step, req.Data, c.ClientIP(), c.GetHeader("User-Agent"), c.GetHeader("Referer"))
+	if err != nil {
+		response := services.ProgressResponse(err)
+		c.JSON(response.StatusCode, response)
+		return
+	}
+	if !result.IsValid {
+		c.JSON(http.StatusUnprocessableEntity, models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 422,
+			Message:    "Form validation failed",
+			Data: map[string]interface{}{
+				"validation_errors": result.Errors,
+				"field_results":     result.FieldResults,
+			},
+		})
+		return
+	}
+
+	data := map[string]interface{}{"progress": partial}
+	if partial.Token != "" {
+		data["progress_token"] = partial.Token
+	}
+
+	c.JSON(http.StatusOK, models.SubmissionResponse{
+		Success:    true,
+		StatusCode: 200,
+		Message:    "Progress saved",
+		Data:       data,
+	})
+}
+
+// GetProgress returns the progress saved under the request's X-Progress-Token
+func (h *PartialSubmissionHandler) GetProgress(c *gin.Context) {
+	form, ok := h.resolveForm(c, c.Query("access_key"), "")
+	if !ok {
+		return
+	}
+
+	token := c.GetHeader("X-Progress-Token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 400,
+			Message:    "X-Progress-Token header is required",
+		})
+		return
+	}
+
+	partial, err := h.partialService.GetProgress(form.ID, token)
+	if err != nil {
+		response := services.ProgressResponse(err)
+		c.JSON(response.StatusCode, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SubmissionResponse{
+		Success:    true,
+		StatusCode: 200,
+		Message:    "Progress found",
+		Data:       map[string]interface{}{"progress": partial},
+	})
+}
+
+// ResumeProgress returns the progress a signed resume link points to. The response carries a new
+// progress token, which the form sends as X-Progress-Token from then on.
+func (h *PartialSubmissionHandler) ResumeProgress(c *gin.Context) {
+	form, ok := h.resolveForm(c, c.Query("access_key"), "")
+	if !ok {
+		return
+	}
+
+	partial, err := h.partialService.ResumeProgress(form.ID, c.Param("partialId"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		response := services.ProgressResponse(err)
+		c.JSON(response.StatusCode, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SubmissionResponse{
+		Success:    true,
+		StatusCode: 200,
+		Message:    "Progress found",
+		Data: map[string]interface{}{
+			"progress_token": partial.Token,
+			"progress":       partial,
+		},
+	})
+}
+
+// SendResumeLink emails the respondent a signed link back to the token's saved progress
+func (h *PartialSubmissionHandler) SendResumeLink(c *gin.Context) {
+	var req models.ResumeLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 400,
+			Message:    "Invalid JSON data: " + err.Error(),
+		})
+		return
+	}
+
+	form, ok := h.resolveForm(c, req.AccessKey, req.FormID)
+	if !ok {
+		return
+	}
+
+	err := h.partialService.SendResumeLink(form, c.GetHeader("X-Progress-Token"), req.Email, req.ResumeURL, c.ClientIP())
+	if err != nil {
+		response := services.ProgressResponse(err)
+		c.JSON(response.StatusCode, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SubmissionResponse{
+		Success:    true,
+		StatusCode: 200,
+		Message:    "Resume link sent",
+	})
+}
+
+// resolveForm finds the active form the request is addressed to, writing the error response if
+// there is none. A form public ID in the path takes precedence over one in the payload.
+func (h *PartialSubmissionHandler) resolveForm(c *gin.Context, accessKey, formPublicID string) (*models.Form, bool) {
+	if formID := c.Param("formId"); formID != "" {
+		formPublicID = formID
+	}
+
+	form, _, err := h.submissionService.ResolveForm(accessKey, formPublicID, c.ClientIP(), utils.RequestOrigin(c.Request))
+	if err != nil {
+		response := services.FormResolutionResponse(err)
+		c.JSON(response.StatusCode, response)
+		return nil, false
+	}
+
+	if !form.IsActive {
+		c.JSON(http.StatusForbidden, models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 403,
+			Message:    "Form is not active",
+		})
+		return nil, false
+	}
+
+	return form, true
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"formhub/internal/services"
 	"formhub/pkg/utils"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -20,6 +22,7 @@ type SpamAdminHandler struct {
 	mlClassifier        *services.NaiveBayesSpamClassifier
 	webhookService      *services.WebhookService
 	authService         *services.AuthService
+	submissionService   *services.SubmissionService
 }
 
 // NewSpamAdminHandler creates a new spam admin handler
@@ -29,6 +32,7 @@ func NewSpamAdminHandler(
 	mlClassifier *services.NaiveBayesSpamClassifier,
 	webhookService *services.WebhookService,
 	authService *services.AuthService,
+	submissionService *services.SubmissionService,
 ) *SpamAdminHandler {
 	return &SpamAdminHandler{
 		spamService:        spamService,
@@ -36,6 +40,7 @@ func NewSpamAdminHandler(
 		mlClassifier:       mlClassifier,
 		webhookService:     webhookService,
 		authService:        authService,
+		submissionService:  submissionService,
 	}
 }
 
@@ -81,10 +86,8 @@ func (sah *SpamAdminHandler) UpdateSpamProtectionConfig(c *gin.Context) {
 	
 	// Update configuration
 	if err := sah.spamService.UpdateGlobalConfig(&configUpdate); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to update configuration",
-			"message": err.Error(),
-		})
+		log.Printf("Failed to update spam protection configuration: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update configuration"})
 		return
 	}
 	
@@ -140,10 +143,8 @@ func (sah *SpamAdminHandler) UpdateFormSpamConfig(c *gin.Context) {
 	formConfig.FormID = formID
 	
 	if err := sah.spamService.UpdateFormConfig(&formConfig); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to update form configuration",
-			"message": err.Error(),
-		})
+		log.Printf("Failed to update spam configuration of form %s: %v", formID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update form configuration"})
 		return
 	}
 	
@@ -173,10 +174,8 @@ func (sah *SpamAdminHandler) GetSpamStatistics(c *gin.Context) {
 	}
 	
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve statistics",
-			"message": err.Error(),
-		})
+		log.Printf("Failed to retrieve spam statistics for form %q: %v", formID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
 		return
 	}
 	
@@ -233,10 +232,10 @@ func (sah *SpamAdminHandler) GetQuarantinedSubmissions(c *gin.Context) {
 
 // ReviewQuarantinedSubmission reviews a quarantined submission
 func (sah *SpamAdminHandler) ReviewQuarantinedSubmission(c *gin.Context) {
-	submissionID := c.Param("submissionId")
-	if submissionID == "" {
+	submissionID, err := uuid.Parse(c.Param("submissionId"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Submission ID is required",
+			"error": "Invalid submission ID",
 		})
 		return
 	}
@@ -262,16 +261,27 @@ func (sah *SpamAdminHandler) ReviewQuarantinedSubmission(c *gin.Context) {
 	}
 	
 	// Get user information from context
-	userID := c.GetString("user_id")
-	if userID == "" {
-		userID = "admin" // Default for now
+	userID := c.MustGet("user_id").(uuid.UUID).String()
+	
+	submission, err := sah.submissionService.ReviewQuarantinedSubmission(submissionID, review.Action, userID, review.Notes)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSubmissionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		case errors.Is(err, services.ErrSubmissionNotQuarantined):
+			c.JSON(http.StatusConflict, gin.H{"error": "Submission is not quarantined"})
+		default:
+			log.Printf("Failed to review quarantined submission %s: %v", submissionID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review submission"})
+		}
+		return
 	}
 	
-	// Process the review (this would update the database)
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Submission reviewed successfully",
 		"submission_id": submissionID,
 		"action":        review.Action,
+		"spam_action":   submission.SpamAction,
 		"reviewed_by":   userID,
 		"reviewed_at":   time.Now(),
 		"notes":         review.Notes,
@@ -403,10 +413,8 @@ func (sah *SpamAdminHandler) GetWebhookStatus(c *gin.Context) {
 	}
 	
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve webhook history",
-			"message": err.Error(),
-		})
+		log.Printf("Failed to retrieve spam webhook history of form %s: %v", formID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook history"})
 		return
 	}
 	
@@ -441,10 +449,8 @@ func (sah *SpamAdminHandler) TestWebhook(c *gin.Context) {
 	// Test the webhook
 	response, err := sah.webhookService.TestWebhook(&config)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Webhook test failed",
-			"message": err.Error(),
-		})
+		log.Printf("Spam webhook test failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Webhook test failed"})
 		return
 	}
 	
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"formhub/internal/models"
+	"formhub/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SSOHandler struct {
+	ssoService  *services.SSOService
+	authService *services.AuthService
+}
+
+func NewSSOHandler(ssoService *services.SSOService, authService *services.AuthService) *SSOHandler {
+	return &SSOHandler{
+		ssoService:  ssoService,
+		authService: authService,
+	}
+}
+
+// Discover tells the login page whether an email signs in through SSO, and where to send the user
+func (h *SSOHandler) Discover(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := h.ssoService.ConnectionForEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, services.ErrSSOConnectionNotFound) {
+			c.JSON(http.StatusOK, gin.H{"sso": false})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up SSO connection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sso":           true,
+		"connection_id": conn.ID,
+		"protocol":      conn.Protocol,
+		"enforced":      conn.EnforceSSO,
+		"login_url":     conn.LoginURL,
+	})
+}
+
+// StartLogin redirects the browser to the connection's identity provider
+func (h *SSOHandler) StartLogin(c *gin.Context) {
+	connectionID, ok := parseConnectionID(c)
+	if !ok {
+		return
+	}
+
+	redirectURL, err := h.ssoService.StartLogin(connectionID, c.Query("login_hint"))
+	if err != nil {
+		h.redirectError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// OIDCCallback receives the provider's authorization code and hands the dashboard a login code
+func (h *SSOHandler) OIDCCallback(c *gin.Context) {
+	connectionID, ok := parseConnectionID(c)
+	if !ok {
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		log.Printf("SSO connection %s: provider returned %s: %s", connectionID, providerError, c.Query("error_description"))
+		c.Redirect(http.StatusFound, h.ssoService.CallbackURL("", services.ErrSSOLoginRejected))
+		return
+	}
+
+	code, err := h.ssoService.HandleOIDCCallback(connectionID, c.Query("state"), c.Query("code"))
+	if err != nil {
+		h.redirectError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.ssoService.CallbackURL(code, nil))
+}
+
+// SAMLACS is the assertion consumer service the identity provider posts its response to
+func (h *SSOHandler) SAMLACS(c *gin.Context) {
+	connectionID, ok := parseConnectionID(c)
+	if !ok {
+		return
+	}
+
+	code, err := h.ssoService.HandleSAMLResponse(connectionID, c.Request)
+	if err != nil {
+		h.redirectError(c, err)
+		return
+	}
+
+	// 303 so the browser follows the POST with a GET
+	c.Redirect(http.StatusSeeOther, h.ssoService.CallbackURL(code, nil))
+}
+
+func (h *SSOHandler) SAMLMetadata(c *gin.Context) {
+	connectionID, ok := parseConnectionID(c)
+	if !ok {
+		return
+	}
+
+	metadata, err := h.ssoService.SAMLMetadata(connectionID)
+	if err != nil {
+		if errors.Is(err, services.ErrSSOConnectionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build SAML metadata"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// Exchange trades the login code from the dashboard callback for tokens
+func (h *SSOHandler) Exchange(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.authService.CompleteSSOLogin(req.Code, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSSOState) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete SSO sign-in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Connection administration
+
+func (h *SSOHandler) ListConnections(c *gin.Context) {
+	connections, err := h.ssoService.ListConnections()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get SSO connections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"connections": connections})
+}
+
+func (h *SSOHandler) CreateConnection(c *gin.Context) {
+	var req models.CreateSSOConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := h.ssoService.CreateConnection(req)
+	if err != nil {
+		h.connectionError(c, err, "Failed to create SSO connection")
+		return
+	}
+
+	c.JSON(http.StatusCreated, conn)
+}
+
+func (h *SSOHandler) GetConnection(c *gin.Context) {
+	connectionID, ok := parseConnectionID(c)
+	if !ok {
+		return
+	}
+
+	conn, err := h.ssoService.GetConnection(connectionID)
+	if err != nil {
+		h.connectionError(c, err, "Failed to get SSO connection")
+		return
+	}
+
+	c.JSON(http.StatusOK, conn)
+}
+
+func (h *SSOHandler) UpdateConnection(c *gin.Context) {
+	connectionID, ok := parseConnectionID(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateSSOConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := h.ssoService.UpdateConnection(connectionID, req)
+	if err != nil {
+		h.connectionError(c, err, "Failed to update SSO connection")
+		return
+	}
+
+	c.JSON(http.StatusOK, conn)
+}
+
+func (h *SSOHandler) DeleteConnection(c *gin.Context) {
+	connectionID, ok := parseConnectionID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.ssoService.DeleteConnection(connectionID); err != nil {
+		h.connectionError(c, err, "Failed to delete SSO connection")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SSO connection deleted"})
+}
+
+// redirectError sends a failed browser sign-in back to the dashboard with an error it can show
+func (h *SSOHandler) redirectError(c *gin.Context, err error) {
+	if !errors.Is(err, services.ErrSSOConnectionNotFound) &&
+		!errors.Is(err, services.ErrInvalidSSOState) &&
+		!errors.Is(err, services.ErrSSOLoginRejected) {
+		log.Printf("SSO sign-in failed: %v", err)
+		err = services.ErrSSOLoginRejected
+	}
+	c.Redirect(http.StatusSeeOther, h.ssoService.CallbackURL("", err))
+}
+
+func (h *SSOHandler) connectionError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSSOConnectionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSSOConnection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSSODomainTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func parseConnectionID(c *gin.Context) (uuid.UUID, bool) {
+	connectionID, err := uuid.Parse(c.Param("connectionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SSO connection ID"})
+		return uuid.Nil, false
+	}
+	return connectionID, true
+}
@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"formhub/internal/models"
 	"formhub/internal/services"
+	"formhub/pkg/utils"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
 )
 
@@ -18,11 +23,14 @@ type SubmissionHandler struct {
 	authService            *services.AuthService
 	fileUploadService      *services.FileUploadService
 	fieldValidationService *services.FieldValidationService
+	exportService          *services.SubmissionExportService
+	usageService           *services.UsageService
 }
 
-func NewSubmissionHandler(submissionService *services.SubmissionService, formService *services.FormService, authService *services.AuthService, fileUploadService *services.FileUploadService, fieldValidationService *services.FieldValidationService) *SubmissionHandler {
-	// Set the form service in submission service for cross-service communication
+func NewSubmissionHandler(submissionService *services.SubmissionService, formService *services.FormService, authService *services.AuthService, fileUploadService *services.FileUploadService, fieldValidationService *services.FieldValidationService, exportService *services.SubmissionExportService, usageService *services.UsageService) *SubmissionHandler {
+	// Set the form and file services in submission service for cross-service communication
 	submissionService.SetFormService(formService)
+	submissionService.SetFileUploadService(fileUploadService)
 	
 	return &SubmissionHandler{
 		submissionService:      submissionService,
@@ -30,10 +38,30 @@ func NewSubmissionHandler(submissionService *services.SubmissionService, formSer
 		authService:            authService,
 		fileUploadService:      fileUploadService,
 		fieldValidationService: fieldValidationService,
+		exportService:          exportService,
+		usageService:           usageService,
 	}
 }
 
 func (h *SubmissionHandler) HandleSubmission(c *gin.Context) {
+	h.handleSubmission(c, false)
+}
+
+// CompleteSubmission is the final submit of a multi-step form. The values saved under the
+// X-Progress-Token are submitted together with any sent in the request.
+func (h *SubmissionHandler) CompleteSubmission(c *gin.Context) {
+	if c.GetHeader("X-Progress-Token") == "" {
+		c.JSON(http.StatusBadRequest, models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 400,
+			Message:    "X-Progress-Token header is required",
+		})
+		return
+	}
+	h.handleSubmission(c, true)
+}
+
+func (h *SubmissionHandler) handleSubmission(c *gin.Context, completeProgress bool) {
 	// Get session ID for file uploads
 	sessionID := c.GetHeader("X-Session-ID")
 	if sessionID == "" {
@@ -58,8 +86,13 @@ func (h *SubmissionHandler) HandleSubmission(c *gin.Context) {
 			return
 		}
 	} else if strings.Contains(contentType, "application/json") {
-		// Handle JSON submission
-		if err := c.ShouldBindJSON(&req); err != nil {
+		// Handle JSON submission; the body is read twice, into the request and into its fields
+		var fields map[string]interface{}
+		err := c.ShouldBindBodyWith(&req, binding.JSON)
+		if err == nil {
+			err = c.ShouldBindBodyWith(&fields, binding.JSON)
+		}
+		if err != nil {
 			c.JSON(http.StatusBadRequest, models.SubmissionResponse{
 				Success:    false,
 				StatusCode: 400,
@@ -67,63 +100,36 @@ func (h *SubmissionHandler) HandleSubmission(c *gin.Context) {
 			})
 			return
 		}
+
+		req.Data = make(map[string]interface{})
+		for key, value := range fields {
+			if !h.isReservedField(key) {
+				req.Data[key] = value
+			}
+		}
 	} else {
 		// Handle regular form data
-		if err := c.ShouldBind(&req); err != nil {
+		if err := h.handleFormSubmission(c, &req); err != nil {
 			c.JSON(http.StatusBadRequest, models.SubmissionResponse{
 				Success:    false,
 				StatusCode: 400,
-				Message:    "Invalid form data: " + err.Error(),
+				Message:    err.Error(),
 			})
 			return
 		}
-		
-		// Extract additional form fields into Data map
-		req.Data = make(map[string]interface{})
-		for key, values := range c.Request.PostForm {
-			if !h.isReservedField(key) {
-				if len(values) == 1 {
-					req.Data[key] = values[0]
-				} else {
-					req.Data[key] = values
-				}
-			}
-		}
 	}
 
-	// Get form and validate access
-	form, apiKey, err := h.getFormByAccessKey(req.AccessKey)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, models.SubmissionResponse{
-			Success:    false,
-			StatusCode: 401,
-			Message:    "Invalid access key",
-		})
-		return
+	// A form public ID in the path takes precedence over one in the payload
+	if formID := c.Param("formId"); formID != "" {
+		req.FormID = formID
 	}
 
-	// Validate form submission with advanced field validation
-	validationResult, err := h.fieldValidationService.ValidateFormSubmission(form.ID, req.Data)
+	// Get form and validate access
+	req.Origin = utils.RequestOrigin(c.Request)
+	form, apiKey, err := h.submissionService.ResolveForm(req.AccessKey, req.FormID, c.ClientIP(), req.Origin)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.SubmissionResponse{
-			Success:    false,
-			StatusCode: 500,
-			Message:    "Validation error",
-		})
-		return
-	}
-
-	// Return validation errors if any
-	if !validationResult.IsValid {
-		c.JSON(http.StatusBadRequest, models.SubmissionResponse{
-			Success:    false,
-			StatusCode: 400,
-			Message:    "Form validation failed",
-			Data: map[string]interface{}{
-				"validation_errors": validationResult.Errors,
-				"field_results":     validationResult.FieldResults,
-			},
-		})
+		response := services.FormResolutionResponse(err)
+		c.JSON(response.StatusCode, response)
 		return
 	}
 
@@ -132,20 +138,30 @@ func (h *SubmissionHandler) HandleSubmission(c *gin.Context) {
 	userAgent := c.GetHeader("User-Agent")
 	referrer := c.GetHeader("Referer")
 
-	// Create enhanced submission request with validated data
+	// Create enhanced submission request for the resolved form
 	enhancedReq := models.SubmissionRequest{
 		AccessKey:         req.AccessKey,
-		Data:              validationResult.FieldResults,
+		FormID:            form.PublicID,
+		Data:              req.Data, // Validated against the form's fields by the submission service
+		Origin:            req.Origin,
 		Email:             req.Email,
 		Subject:           req.Subject,
 		Message:           req.Message,
 		RedirectURL:       req.RedirectURL,
 		RecaptchaResponse: req.RecaptchaResponse,
+		HCaptchaResponse:  req.HCaptchaResponse,
+		TurnstileResponse: req.TurnstileResponse,
+		BehavioralData:    req.BehavioralData,
 		Files:             req.Files,
+		CompleteProgress:  completeProgress,
+		ProgressToken:     c.GetHeader("X-Progress-Token"),
+	}
+	if enhancedReq.BehavioralData == "" {
+		enhancedReq.BehavioralData = c.GetHeader("X-Behavioral-Data")
 	}
 
 	// Handle the submission with file support
-	response, err := h.submissionService.HandleSubmissionWithFiles(enhancedReq, ipAddress, userAgent, referrer, sessionID, hasFiles)
+	response, err := h.submissionService.HandleSubmissionWithFiles(form, apiKey, enhancedReq, ipAddress, userAgent, referrer, sessionID, hasFiles)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.SubmissionResponse{
 			Success:    false,
@@ -155,6 +171,11 @@ func (h *SubmissionHandler) HandleSubmission(c *gin.Context) {
 		return
 	}
 
+	// Blocked submissions count against the client IP in the spam middleware's IP flag check
+	if response.SpamAction == models.SpamActionBlock {
+		c.Set("spam_blocked", true)
+	}
+
 	// Return appropriate status code
 	statusCode := response.StatusCode
 	if statusCode == 0 {
@@ -173,9 +194,10 @@ func (h *SubmissionHandler) UploadFiles(c *gin.Context) {
 	}
 
 	// Validate access key
-	form, _, err := h.getFormByAccessKey(req.AccessKey)
+	form, _, err := h.submissionService.ResolveFormByID(req.AccessKey, req.FormID, c.ClientIP(), utils.RequestOrigin(c.Request))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access key"})
+		response := services.FormResolutionResponse(err)
+		c.JSON(response.StatusCode, gin.H{"error": response.Message})
 		return
 	}
 
@@ -184,6 +206,10 @@ func (h *SubmissionHandler) UploadFiles(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "File uploads are not enabled for this form"})
 		return
 	}
+	if err := h.usageService.CheckFeature(form.WorkspaceID, services.CapabilityFileUploads); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File uploads are not included in the form owner's plan"})
+		return
+	}
 
 	// Get form field to validate file settings
 	field, err := h.fieldValidationService.GetFormFieldByName(form.ID, req.FieldName)
@@ -244,9 +270,10 @@ func (h *SubmissionHandler) BulkUploadFiles(c *gin.Context) {
 	}
 
 	// Validate access key
-	form, _, err := h.getFormByAccessKey(req.AccessKey)
+	form, _, err := h.submissionService.ResolveFormByID(req.AccessKey, req.FormID, c.ClientIP(), utils.RequestOrigin(c.Request))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access key"})
+		response := services.FormResolutionResponse(err)
+		c.JSON(response.StatusCode, gin.H{"error": response.Message})
 		return
 	}
 
@@ -255,6 +282,10 @@ func (h *SubmissionHandler) BulkUploadFiles(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "File uploads are not enabled for this form"})
 		return
 	}
+	if err := h.usageService.CheckFeature(form.WorkspaceID, services.CapabilityFileUploads); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File uploads are not included in the form owner's plan"})
+		return
+	}
 
 	// Get form field
 	field, err := h.fieldValidationService.GetFormFieldByName(form.ID, req.FieldName)
@@ -328,6 +359,8 @@ func (h *SubmissionHandler) handleMultipartSubmission(c *gin.Context, req *model
 			switch key {
 			case "access_key":
 				req.AccessKey = values[0]
+			case "form_id":
+				req.FormID = values[0]
 			case "email":
 				req.Email = values[0]
 			case "subject":
@@ -338,6 +371,12 @@ func (h *SubmissionHandler) handleMultipartSubmission(c *gin.Context, req *model
 				req.RedirectURL = values[0]
 			case "g-recaptcha-response":
 				req.RecaptchaResponse = values[0]
+			case "h-captcha-response":
+				req.HCaptchaResponse = values[0]
+			case "cf-turnstile-response":
+				req.TurnstileResponse = values[0]
+			case "_behavioral_data":
+				req.BehavioralData = values[0]
 			}
 		} else {
 			if len(values) == 1 {
@@ -370,24 +409,43 @@ func (h *SubmissionHandler) handleMultipartSubmission(c *gin.Context, req *model
 	return nil
 }
 
+// handleFormSubmission reads a URL-encoded submission. Reserved fields, _behavioral_data among
+// them, bind to the request by their form tags; the rest become its data.
+func (h *SubmissionHandler) handleFormSubmission(c *gin.Context, req *models.SubmissionRequest) error {
+	if err := c.ShouldBind(req); err != nil {
+		return fmt.Errorf("Invalid form data: %w", err)
+	}
+
+	req.Data = make(map[string]interface{})
+	for key, values := range c.Request.PostForm {
+		if !h.isReservedField(key) {
+			if len(values) == 1 {
+				req.Data[key] = values[0]
+			} else {
+				req.Data[key] = values
+			}
+		}
+	}
+
+	return nil
+}
+
 func (h *SubmissionHandler) isReservedField(key string) bool {
 	reservedFields := map[string]bool{
 		"access_key":            true,
+		"form_id":               true,
 		"email":                 true,
 		"subject":               true,
 		"message":               true,
 		"redirect":              true,
 		"g-recaptcha-response":  true,
+		"h-captcha-response":    true,
+		"cf-turnstile-response": true,
+		"_behavioral_data":      true,
 	}
 	return reservedFields[key]
 }
 
-func (h *SubmissionHandler) getFormByAccessKey(accessKey string) (*models.Form, *models.APIKey, error) {
-	// This is a placeholder - in a real implementation, you'd query the database
-	// For now, we'll delegate to the form service
-	return h.formService.GetFormByAccessKey(accessKey)
-}
-
 func (h *SubmissionHandler) GetSubmissions(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -409,19 +467,153 @@ func (h *SubmissionHandler) GetSubmissions(c *gin.Context) {
 		return
 	}
 
-	if form.UserID != userID.(uuid.UUID) {
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleViewer) != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
-	// TODO: Implement GetSubmissionsByFormID in submission service
+	params, err := parseSubmissionListParams(c, "desc")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.submissionService.ListSubmissions(formID, params)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get submissions"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"submissions": []interface{}{},
-		"message":     "Submissions endpoint not yet implemented",
+		"submissions": result.Submissions,
+		"next_cursor": result.NextCursor,
+		"has_more":    result.HasMore,
 		"form_id":     formID,
 	})
 }
 
+// ExportSubmissions streams all submissions of a form as CSV, XLSX or NDJSON
+func (h *SubmissionHandler) ExportSubmissions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	formID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid form ID"})
+		return
+	}
+
+	form, err := h.formService.GetFormByID(formID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Form not found"})
+		return
+	}
+
+	if h.formService.AuthorizeForm(form, userID.(uuid.UUID), models.WorkspaceRoleViewer) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	format := services.ExportFormat(c.DefaultQuery("format", "csv"))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or ndjson"})
+		return
+	}
+
+	// Exports read oldest first, like the form's history, unless sort=desc is asked for
+	params, err := parseSubmissionListParams(c, "asc")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("submissions_%s_%s.%s", formID.String(), time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Content-Type", format.ContentType())
+	c.Status(http.StatusOK)
+
+	// Headers are already sent once streaming starts, so failures can only be logged
+	if err := h.exportService.ExportSubmissions(c.Writer, form, format, params); err != nil {
+		log.Printf("Failed to export submissions for form %s: %v", formID, err)
+	}
+}
+
+// parseSubmissionListParams reads pagination, filter and sort options from the query string,
+// sorting by defaultSort when none is given. Field filters use the form field[<name>]=value
+// (exact) and field_contains[<name>]=value.
+func parseSubmissionListParams(c *gin.Context, defaultSort string) (models.SubmissionListParams, error) {
+	params := models.SubmissionListParams{
+		Cursor:        c.Query("cursor"),
+		SortOrder:     c.DefaultQuery("sort", defaultSort),
+		FieldEquals:   c.QueryMap("field"),
+		FieldContains: c.QueryMap("field_contains"),
+	}
+
+	if params.SortOrder != "asc" && params.SortOrder != "desc" {
+		return params, fmt.Errorf("sort must be asc or desc")
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 100 {
+			return params, fmt.Errorf("limit must be between 1 and 100")
+		}
+		params.Limit = limit
+	}
+
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		startDate, err := parseQueryTime(startDateStr)
+		if err != nil {
+			return params, fmt.Errorf("invalid start_date")
+		}
+		params.StartDate = &startDate
+	}
+
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		endDate, err := parseQueryTime(endDateStr)
+		if err != nil {
+			return params, fmt.Errorf("invalid end_date")
+		}
+		// A bare date includes the whole day
+		if len(endDateStr) == len("2006-01-02") {
+			endDate = endDate.Add(24*time.Hour - time.Nanosecond)
+		}
+		params.EndDate = &endDate
+	}
+
+	boolFilters := map[string]**bool{
+		"is_spam":      &params.IsSpam,
+		"email_sent":   &params.EmailSent,
+		"webhook_sent": &params.WebhookSent,
+	}
+	for name, target := range boolFilters {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return params, fmt.Errorf("%s must be true or false", name)
+			}
+			*target = &parsed
+		}
+	}
+
+	return params, nil
+}
+
+// parseQueryTime accepts either an RFC3339 timestamp or a YYYY-MM-DD date
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
 func (h *SubmissionHandler) GetSubmission(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
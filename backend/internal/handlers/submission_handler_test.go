@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"formhub/internal/models"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testBehavioralData = `{"keystrokes":42,"mouse_movements":17,"time_on_page_ms":5300}`
+
+func newSubmissionContext(req *http.Request) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	return c
+}
+
+func TestHandleMultipartSubmissionReadsBehavioralData(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("access_key", "key")
+	writer.WriteField("name", "Ada")
+	writer.WriteField("_behavioral_data", testBehavioralData)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/submit", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var submission models.SubmissionRequest
+	h := &SubmissionHandler{}
+	if err := h.handleMultipartSubmission(newSubmissionContext(req), &submission, "session"); err != nil {
+		t.Fatalf("handleMultipartSubmission: %v", err)
+	}
+
+	if submission.BehavioralData != testBehavioralData {
+		t.Errorf("BehavioralData = %q; want %q", submission.BehavioralData, testBehavioralData)
+	}
+	if _, ok := submission.Data["_behavioral_data"]; ok {
+		t.Error("_behavioral_data was stored as a form field")
+	}
+	if submission.Data["name"] != "Ada" {
+		t.Errorf("Data[name] = %v; want Ada", submission.Data["name"])
+	}
+}
+
+func TestHandleFormSubmissionReadsBehavioralData(t *testing.T) {
+	form := url.Values{}
+	form.Set("access_key", "key")
+	form.Set("name", "Ada")
+	form.Set("_behavioral_data", testBehavioralData)
+
+	req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var submission models.SubmissionRequest
+	h := &SubmissionHandler{}
+	if err := h.handleFormSubmission(newSubmissionContext(req), &submission); err != nil {
+		t.Fatalf("handleFormSubmission: %v", err)
+	}
+
+	if submission.BehavioralData != testBehavioralData {
+		t.Errorf("BehavioralData = %q; want %q", submission.BehavioralData, testBehavioralData)
+	}
+	if _, ok := submission.Data["_behavioral_data"]; ok {
+		t.Error("_behavioral_data was stored as a form field")
+	}
+	if submission.Data["name"] != "Ada" {
+		t.Errorf("Data[name] = %v; want Ada", submission.Data["name"])
+	}
+}
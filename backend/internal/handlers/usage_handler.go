@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"formhub/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type UsageHandler struct {
+	usageService *services.UsageService
+}
+
+func NewUsageHandler(usageService *services.UsageService) *UsageHandler {
+	return &UsageHandler{
+		usageService: usageService,
+	}
+}
+
+// GetUsage returns the current workspace's plan usage for the current billing period
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	workspaceID, exists := c.Get("workspace_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	usage, err := h.usageService.GetUsage(workspaceID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"usage": usage})
+}
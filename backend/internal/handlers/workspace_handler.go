@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"formhub/internal/models"
+	"formhub/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type WorkspaceHandler struct {
+	workspaceService *services.WorkspaceService
+	authService      *services.AuthService
+}
+
+func NewWorkspaceHandler(workspaceService *services.WorkspaceService, authService *services.AuthService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+		authService:      authService,
+	}
+}
+
+func (h *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	workspaces, err := h.workspaceService.GetUserWorkspaces(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workspaces"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"workspaces":           workspaces,
+		"current_workspace_id": c.MustGet("workspace_id"),
+	})
+}
+
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req models.CreateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := h.workspaceService.CreateWorkspace(userID, req.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workspace"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"workspace": workspace})
+}
+
+// UpdateWorkspace renames the workspace or sets whether members must use two-factor authentication
+func (h *WorkspaceHandler) UpdateWorkspace(c *gin.Context) {
+	workspaceID, ok := h.authorize(c, models.WorkspaceRoleAdmin)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := h.workspaceService.UpdateWorkspace(workspaceID, c.MustGet("user_id").(uuid.UUID), req)
+	if err != nil {
+		if errors.Is(err, services.ErrMFANotEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Enable two-factor authentication on your own account before requiring it"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workspace"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"workspace": workspace})
+}
+
+// SwitchWorkspace makes the workspace current and returns an access token scoped to it
+func (h *WorkspaceHandler) SwitchWorkspace(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	sessionID := c.MustGet("session_id").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	response, err := h.authService.SwitchWorkspace(userID, sessionID, workspaceID)
+	if err != nil {
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this workspace"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch workspace"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkspaceHandler) ListMembers(c *gin.Context) {
+	workspaceID, ok := h.authorize(c, models.WorkspaceRoleViewer)
+	if !ok {
+		return
+	}
+
+	members, err := h.workspaceService.ListMembers(workspaceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+func (h *WorkspaceHandler) UpdateMemberRole(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Role models.WorkspaceRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	if err := h.workspaceService.UpdateMemberRole(workspaceID, userID, memberID, req.Role); err != nil {
+		h.memberError(c, err, "Failed to update member role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated successfully"})
+}
+
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(workspaceID, userID, memberID); err != nil {
+		h.memberError(c, err, "Failed to remove member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func (h *WorkspaceHandler) InviteMember(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	invitation, err := h.workspaceService.InviteMember(workspaceID, userID, req)
+	if err != nil {
+		h.memberError(c, err, "Failed to invite member")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invitation": invitation})
+}
+
+func (h *WorkspaceHandler) ListInvitations(c *gin.Context) {
+	workspaceID, ok := h.authorize(c, models.WorkspaceRoleAdmin)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.workspaceService.ListInvitations(workspaceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+func (h *WorkspaceHandler) RevokeInvitation(c *gin.Context) {
+	workspaceID, ok := h.authorize(c, models.WorkspaceRoleAdmin)
+	if !ok {
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	if err := h.workspaceService.RevokeInvitation(workspaceID, invitationID); err != nil {
+		if errors.Is(err, services.ErrInvitationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// AcceptInvitation adds the signed-in user to the workspace named by an emailed invitation token
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := h.workspaceService.AcceptInvitation(req.Token, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvitationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		case errors.Is(err, services.ErrInvitationAccepted), errors.Is(err, services.ErrInvitationExpired):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvitationWrongAddressee):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Invitation accepted",
+		"workspace": workspace,
+	})
+}
+
+// authorize checks the current user's role in the workspace named by the :id path parameter
+func (h *WorkspaceHandler) authorize(c *gin.Context, min models.WorkspaceRole) (uuid.UUID, bool) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return uuid.Nil, false
+	}
+
+	if err := h.workspaceService.Authorize(workspaceID, userID, min); err != nil {
+		if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace permissions"})
+			return uuid.Nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check workspace membership"})
+		return uuid.Nil, false
+	}
+
+	return workspaceID, true
+}
+
+func (h *WorkspaceHandler) memberError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrWorkspaceAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace permissions"})
+	case errors.Is(err, services.ErrLastWorkspaceOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
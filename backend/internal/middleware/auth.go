@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"errors"
+	"formhub/internal/models"
 	"formhub/internal/services"
+	"formhub/pkg/utils"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 func AuthRequired(authService *services.AuthService) gin.HandlerFunc {
@@ -33,9 +37,201 @@ func AuthRequired(authService *services.AuthService) gin.HandlerFunc {
 			return
 		}
 
+		// The role in the token may be stale, so use the one stored for the user now
+		role, err := authService.CurrentRole(claims.UserID)
+		if err != nil {
+			if errors.Is(err, services.ErrUserNotFound) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+			}
+			c.Abort()
+			return
+		}
+		if !role.Valid() {
+			role = models.RoleMember
+		}
+
 		// Set user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Set("user_role", role)
+		c.Set("workspace_id", claims.WorkspaceID)
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
+}
+
+// AuthOrAPIKey accepts either a user's access token, like AuthRequired, or an API key in the
+// X-API-Key header. Key requests act as the key's creator within the key's workspace and may only
+// reach routes guarded by RequireScope with a scope the key holds.
+func AuthOrAPIKey(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			AuthRequired(authService)(c)
+			return
+		}
+
+		apiKey, err := authService.AuthenticateAPIKey(key, c.ClientIP(), utils.RequestOrigin(c.Request))
+		if err != nil {
+			if errors.Is(err, services.ErrAPIKeyRestricted) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed from this address or origin"})
+			} else if errors.Is(err, services.ErrInvalidAccessKey) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check API key"})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", apiKey.UserID)
+		c.Set("user_role", models.RoleMember)
+		c.Set("workspace_id", apiKey.WorkspaceID)
+		c.Set("api_key", apiKey)
+		c.Next()
+	}
+}
+
+// RequireScope allows API key requests only when the key holds the scope; user sessions pass.
+// Must run after AuthOrAPIKey.
+func RequireScope(scope models.APIKeyScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, exists := c.Get("api_key"); exists {
+			if apiKey, ok := value.(*models.APIKey); !ok || !apiKey.HasScope(scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks the required scope", "scope": scope})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireKeyForm refuses API key requests for a form outside the key's FormIDs. The form's ID is
+// read from the param route parameter. Must run after AuthOrAPIKey.
+func RequireKeyForm(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := contextAPIKey(c); apiKey != nil {
+			// Malformed IDs are left for the handler to reject
+			if formID, err := uuid.Parse(c.Param(param)); err == nil && !apiKey.AllowsForm(formID) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed to access this form"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// submissionForms finds the form of a submission, as SubmissionService.GetSubmissionFormID does
+type submissionForms interface {
+	GetSubmissionFormID(submissionID uuid.UUID) (uuid.UUID, error)
+}
+
+// RequireKeySubmission is RequireKeyForm for routes addressing a submission by its ID in the param
+// route parameter; the submission's form must be one of the key's FormIDs.
+func RequireKeySubmission(submissions submissionForms, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := contextAPIKey(c)
+		if apiKey == nil || len(apiKey.FormIDs) == 0 {
+			c.Next()
+			return
+		}
+
+		submissionID, err := uuid.Parse(c.Param(param))
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		formID, err := submissions.GetSubmissionFormID(submissionID)
+		if err != nil {
+			if errors.Is(err, services.ErrSubmissionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check submission access"})
+			}
+			c.Abort()
+			return
+		}
+		if !apiKey.AllowsForm(formID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed to access this submission"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail allows only users who have confirmed their email address. Must run after
+// AuthRequired.
+func RequireVerifiedEmail(accountService *services.AccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		verified, err := accountService.IsEmailVerified(c.MustGet("user_id").(uuid.UUID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email verification"})
+			c.Abort()
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address first"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole allows only users holding one of the given roles. Must run after AuthRequired.
+func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := contextRole(c)
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+		c.Abort()
+	}
+}
+
+// RequirePermission allows only users whose role grants the permission. Must run after AuthRequired.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !contextRole(c).Can(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied", "permission": permission})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// contextAPIKey returns the API key the request authenticated with, or nil for user sessions
+func contextAPIKey(c *gin.Context) *models.APIKey {
+	if value, exists := c.Get("api_key"); exists {
+		if apiKey, ok := value.(*models.APIKey); ok {
+			return apiKey
+		}
+	}
+	return nil
+}
+
+func contextRole(c *gin.Context) models.UserRole {
+	if role, exists := c.Get("user_role"); exists {
+		if userRole, ok := role.(models.UserRole); ok {
+			return userRole
+		}
+	}
+	return ""
 }
\ No newline at end of file
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"formhub/internal/models"
+	"formhub/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeSubmissionForms maps submission IDs to their forms for RequireKeySubmission
+type fakeSubmissionForms map[uuid.UUID]uuid.UUID
+
+func (f fakeSubmissionForms) GetSubmissionFormID(submissionID uuid.UUID) (uuid.UUID, error) {
+	formID, ok := f[submissionID]
+	if !ok {
+		return uuid.Nil, services.ErrSubmissionNotFound
+	}
+	return formID, nil
+}
+
+// newKeyRouter serves path behind guard for a request authenticated with apiKey; a nil key stands
+// for a user session
+func newKeyRouter(apiKey *models.APIKey, path string, guard gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(path, func(c *gin.Context) {
+		if apiKey != nil {
+			c.Set("api_key", apiKey)
+		}
+		c.Next()
+	}, guard, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func serve(router *gin.Engine, path string) int {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder.Code
+}
+
+func TestRequireKeyForm(t *testing.T) {
+	ownForm, otherForm := uuid.New(), uuid.New()
+	limitedKey := &models.APIKey{ID: uuid.New(), FormIDs: []uuid.UUID{ownForm}, Scopes: []models.APIKeyScope{models.ScopeFormsRead}}
+	workspaceKey := &models.APIKey{ID: uuid.New(), Scopes: []models.APIKeyScope{models.ScopeFormsRead}}
+
+	tests := []struct {
+		name   string
+		apiKey *models.APIKey
+		path   string
+		want   int
+	}{
+		{"limited key, its form", limitedKey, "/forms/" + ownForm.String(), http.StatusOK},
+		{"limited key, another form", limitedKey, "/forms/" + otherForm.String(), http.StatusForbidden},
+		{"limited key, another form's submissions", limitedKey, "/forms/" + otherForm.String() + "/submissions", http.StatusForbidden},
+		{"limited key, malformed ID", limitedKey, "/forms/not-a-uuid", http.StatusOK},
+		{"workspace key, any form", workspaceKey, "/forms/" + otherForm.String(), http.StatusOK},
+		{"user session", nil, "/forms/" + otherForm.String(), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newKeyRouter(tt.apiKey, "/forms/:id", RequireKeyForm("id"))
+			router.GET("/forms/:id/submissions", func(c *gin.Context) {
+				if tt.apiKey != nil {
+					c.Set("api_key", tt.apiKey)
+				}
+				c.Next()
+			}, RequireKeyForm("id"), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			if got := serve(router, tt.path); got != tt.want {
+				t.Errorf("GET %s = %d; want %d", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireKeySubmission(t *testing.T) {
+	ownForm, otherForm := uuid.New(), uuid.New()
+	ownSubmission, otherSubmission := uuid.New(), uuid.New()
+	submissions := fakeSubmissionForms{ownSubmission: ownForm, otherSubmission: otherForm}
+
+	limitedKey := &models.APIKey{ID: uuid.New(), FormIDs: []uuid.UUID{ownForm}, Scopes: []models.APIKeyScope{models.ScopeSubmissionsRead}}
+	workspaceKey := &models.APIKey{ID: uuid.New(), Scopes: []models.APIKeyScope{models.ScopeSubmissionsRead}}
+
+	tests := []struct {
+		name         string
+		apiKey       *models.APIKey
+		submissionID uuid.UUID
+		want         int
+	}{
+		{"limited key, submission to its form", limitedKey, ownSubmission, http.StatusOK},
+		{"limited key, submission to another form", limitedKey, otherSubmission, http.StatusForbidden},
+		{"limited key, unknown submission", limitedKey, uuid.New(), http.StatusNotFound},
+		{"workspace key", workspaceKey, otherSubmission, http.StatusOK},
+		{"user session", nil, otherSubmission, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newKeyRouter(tt.apiKey, "/submissions/:id", RequireKeySubmission(submissions, "id"))
+
+			path := "/submissions/" + tt.submissionID.String()
+			if got := serve(router, path); got != tt.want {
+				t.Errorf("GET %s = %d; want %d", path, got, tt.want)
+			}
+		})
+	}
+}
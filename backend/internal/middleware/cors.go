@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"formhub/internal/services"
+	"formhub/pkg/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// submitCORSHeaders are the request headers embedded forms may send to the submit endpoints
+const submitCORSHeaders = "Origin, Content-Type, Accept, X-API-Key, X-Session-ID, X-Progress-Token, X-Behavioral-Data"
+
+// SkipPaths runs handler for every request except those under one of the path prefixes
+func SkipPaths(handler gin.HandlerFunc, prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		handler(c)
+	}
+}
+
+// FormCORS answers CORS for the public submit endpoints from the addressed form's allow-list
+// rather than the dashboard's origins. Preflights from an origin the form refuses get a 403.
+// Requests that name no form in the path are allowed from any origin here; the submission
+// path enforces the form's allow-list once it knows the form.
+func FormCORS(formService *services.FormService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		allowed := true
+		if publicID := c.Param("formId"); publicID != "" {
+			form, err := formService.GetFormByPublicID(publicID)
+			allowed = err == nil && utils.OriginAllowed(services.FormAllowedOrigins(form), origin)
+		}
+
+		c.Header("Vary", "Origin")
+		if allowed {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Expose-Headers", "Content-Length")
+		}
+
+		if c.Request.Method != http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		if !allowed {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", submitCORSHeaders)
+		c.Header("Access-Control-Max-Age", "43200")
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
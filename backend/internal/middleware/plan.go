@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"formhub/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequirePlanFeature rejects requests in workspaces whose plan does not include the capability.
+// Must run after WorkspaceContext.
+func RequirePlanFeature(usageService *services.UsageService, capability services.PlanCapability) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workspaceID, exists := c.Get("workspace_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		if err := usageService.CheckFeature(workspaceID.(uuid.UUID), capability); err != nil {
+			if limitErr, ok := services.AsPlanLimitError(err); ok {
+				c.JSON(limitErr.StatusCode(), gin.H{"error": err.Error(), "capability": limitErr.Capability})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check plan"})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -207,6 +207,31 @@ func (sdm *SpamDetectionMiddleware) SpamProtection() gin.HandlerFunc {
 	}
 }
 
+// IPFlagCheck blocks form submissions from IPs flagged for repeated spam. Spam analysis itself runs
+// per form in the submission service; each submission it blocks counts against the sending IP here.
+func (sdm *SpamDetectionMiddleware) IPFlagCheck() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !sdm.isFormSubmissionEndpoint(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		
+		clientIP := c.ClientIP()
+		if sdm.isIPFlagged(clientIP) {
+			sdm.stats.Blocked++
+			sdm.handleBlockedRequest(c, "ip_flagged", "IP address is flagged for suspicious activity")
+			return
+		}
+		
+		c.Next()
+		
+		if blocked, _ := c.Get("spam_blocked"); blocked == true {
+			sdm.stats.SpamDetected++
+			sdm.updateIPTracking(clientIP, sdm.extractFormID(c), c.GetHeader("User-Agent"), "blocked", 1.0, []string{"spam_protection"})
+		}
+	}
+}
+
 // HoneypotProtection middleware specifically for honeypot field detection
 func (sdm *SpamDetectionMiddleware) HoneypotProtection(honeypotFields []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"errors"
+	"formhub/internal/models"
+	"formhub/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WorkspaceContext confirms the user still belongs to the workspace their token is scoped to and
+// meets its MFA requirement, and sets workspace_id and workspace_role in the context. Tokens issued
+// before workspaces existed fall back to the user's default workspace. Must run after AuthRequired.
+func WorkspaceContext(workspaceService *services.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("user_id").(uuid.UUID)
+
+		workspaceID, _ := c.Get("workspace_id")
+		id, _ := workspaceID.(uuid.UUID)
+		if id == uuid.Nil {
+			defaultID, err := workspaceService.DefaultWorkspace(userID)
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "No workspace available"})
+				c.Abort()
+				return
+			}
+			id = defaultID
+		}
+
+		role, err := workspaceService.GetRole(id, userID)
+		if err != nil {
+			if errors.Is(err, services.ErrWorkspaceAccessDenied) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You are no longer a member of this workspace"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check workspace membership"})
+			}
+			c.Abort()
+			return
+		}
+
+		// API keys are machine credentials; the MFA requirement applies to people signing in
+		if _, isAPIKey := c.Get("api_key"); !isAPIKey {
+			satisfied, err := workspaceService.MFASatisfied(id, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check workspace membership"})
+				c.Abort()
+				return
+			}
+			if !satisfied {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "This workspace requires two-factor authentication; enable it to continue",
+					"code":  "mfa_required",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("workspace_id", id)
+		c.Set("workspace_role", role)
+		c.Next()
+	}
+}
+
+// RequireWorkspaceRole allows only members with at least the given role in the current workspace.
+// Must run after WorkspaceContext.
+func RequireWorkspaceRole(min models.WorkspaceRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("workspace_role")
+		if workspaceRole, ok := role.(models.WorkspaceRole); !ok || !workspaceRole.AtLeast(min) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -22,6 +22,7 @@ const (
 	EventTypeFileUploadStart    AnalyticsEventType = "file_upload_start"
 	EventTypeFileUploadComplete AnalyticsEventType = "file_upload_complete"
 	EventTypeRecaptchaSolve     AnalyticsEventType = "recaptcha_solve"
+	EventTypeFormStepComplete   AnalyticsEventType = "form_step_complete" // A step of a multi-step form was saved
 )
 
 // Device Types
@@ -83,6 +84,7 @@ type FormConversionFunnel struct {
 	TotalCompletes       int               `json:"total_completes" db:"total_completes"`
 	TotalAbandons        int               `json:"total_abandons" db:"total_abandons"`
 	AbandonmentPoints    map[string]int    `json:"abandonment_points" db:"abandonment_points"`
+	StepDropOffs         []FunnelStep      `json:"step_drop_offs,omitempty" db:"step_drop_offs"`
 	ConversionRate       float64           `json:"conversion_rate" db:"conversion_rate"`
 	CompletionRate       float64           `json:"completion_rate" db:"completion_rate"`
 	AvgTimeToSubmit      *int              `json:"average_time_to_submit,omitempty" db:"average_time_to_submit"`
@@ -91,6 +93,14 @@ type FormConversionFunnel struct {
 	UpdatedAt            time.Time         `json:"updated_at" db:"updated_at"`
 }
 
+// FunnelStep counts the respondents of a multi-step form who reached a step and who left on it
+type FunnelStep struct {
+	Step        int     `json:"step"`
+	Reached     int     `json:"reached"`
+	DroppedOff  int     `json:"dropped_off"`
+	DropOffRate float64 `json:"drop_off_rate"` // Percentage of those who reached the step
+}
+
 // Submission Lifecycle Tracking
 type SubmissionStatus string
 
@@ -160,6 +170,23 @@ type SubmissionLifecycle struct {
 	UpdatedAt               time.Time              `json:"updated_at" db:"updated_at"`
 }
 
+// SubmissionRejectionReason explains why a public submission was refused before it was saved
+type SubmissionRejectionReason string
+
+const (
+	SubmissionRejectionOrigin SubmissionRejectionReason = "origin_not_allowed"
+)
+
+// SubmissionRejection records a public submission refused before it was saved
+type SubmissionRejection struct {
+	ID        uuid.UUID                 `json:"id" db:"id"`
+	FormID    uuid.UUID                 `json:"form_id" db:"form_id"`
+	Reason    SubmissionRejectionReason `json:"reason" db:"reason"`
+	Origin    *string                   `json:"origin,omitempty" db:"origin"`
+	IPAddress *string                   `json:"ip_address,omitempty" db:"ip_address"`
+	CreatedAt time.Time                 `json:"created_at" db:"created_at"`
+}
+
 // UserSession represents a user session for journey tracking
 type UserSession struct {
 	ID                   uuid.UUID    `json:"id" db:"id"`
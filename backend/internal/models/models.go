@@ -8,37 +8,251 @@ import (
 
 // User represents a client who uses the form service
 type User struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	Email       string    `json:"email" db:"email"`
-	Password    string    `json:"-" db:"password_hash"`
-	FirstName   string    `json:"first_name" db:"first_name"`
-	LastName    string    `json:"last_name" db:"last_name"`
-	Company     string    `json:"company" db:"company"`
-	PlanType    string    `json:"plan_type" db:"plan_type"` // free, starter, professional, enterprise
-	IsActive    bool      `json:"is_active" db:"is_active"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID                 uuid.UUID  `json:"id" db:"id"`
+	Email              string     `json:"email" db:"email"`
+	Password           string     `json:"-" db:"password_hash"`
+	FirstName          string     `json:"first_name" db:"first_name"`
+	LastName           string     `json:"last_name" db:"last_name"`
+	Company            string     `json:"company" db:"company"`
+	PlanType           string     `json:"plan_type" db:"plan_type"` // free, starter, professional, enterprise
+	Role               UserRole   `json:"role" db:"role"`
+	EmailVerifiedAt    *time.Time `json:"email_verified_at" db:"email_verified_at"` // Nil until the current email is confirmed
+	MFAEnabledAt       *time.Time `json:"mfa_enabled_at" db:"mfa_enabled_at"`       // Nil unless TOTP two-factor authentication is on
+	CurrentWorkspaceID *uuid.UUID `json:"current_workspace_id,omitempty" db:"current_workspace_id"`
+	IsActive           bool       `json:"is_active" db:"is_active"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
 }
 
-// APIKey represents an API key for form submissions
+// APIKey represents an API key for form submissions and the management API
 type APIKey struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	UserID      uuid.UUID `json:"user_id" db:"user_id"`
-	Name        string    `json:"name" db:"name"`
-	KeyHash     string    `json:"-" db:"key_hash"`
-	Key         string    `json:"key,omitempty"` // Only shown when created
-	Permissions string    `json:"permissions" db:"permissions"` // JSON string of permissions
-	RateLimit   int       `json:"rate_limit" db:"rate_limit"`   // requests per minute
-	IsActive    bool      `json:"is_active" db:"is_active"`
-	LastUsedAt  *time.Time `json:"last_used_at" db:"last_used_at"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID                   uuid.UUID     `json:"id" db:"id"`
+	UserID               uuid.UUID     `json:"user_id" db:"user_id"` // Creator; management API calls act as this user
+	WorkspaceID          uuid.UUID     `json:"workspace_id" db:"workspace_id"`
+	Name                 string        `json:"name" db:"name"`
+	KeyHash              string        `json:"-" db:"key_hash"`
+	Key                  string        `json:"key,omitempty"`                                    // Only shown when created or rotated
+	Scopes               []APIKeyScope `json:"scopes" db:"scopes"`
+	RateLimit            int           `json:"rate_limit" db:"rate_limit"`                       // requests per minute
+	FormIDs              []uuid.UUID   `json:"form_ids"`                                         // Forms the key may submit to and manage; empty means any form in the workspace
+	DefaultFormFallback  bool          `json:"default_form_fallback" db:"default_form_fallback"` // Submissions without a form go to the newest form, creating one if needed
+	AllowedIPs           []string      `json:"allowed_ips" db:"allowed_ips"`                     // IPs or CIDR ranges; empty allows any
+	AllowedOrigins       []string      `json:"allowed_origins" db:"allowed_origins"`             // Origins such as https://example.com or https://*.example.com; empty allows any
+	ExpiresAt            *time.Time    `json:"expires_at" db:"expires_at"`
+	PreviousKeyExpiresAt *time.Time    `json:"previous_key_expires_at,omitempty" db:"previous_key_expires_at"` // End of the grace period for the key replaced by the last rotation
+	IsActive             bool          `json:"is_active" db:"is_active"`
+	LastUsedAt           *time.Time    `json:"last_used_at" db:"last_used_at"`
+	CreatedAt            time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// HasScope reports whether the key was granted the scope
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsForm reports whether the key may use the form; keys without FormIDs may use any form in
+// their workspace
+func (k *APIKey) AllowsForm(formID uuid.UUID) bool {
+	if len(k.FormIDs) == 0 {
+		return true
+	}
+	for _, id := range k.FormIDs {
+		if id == formID {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyScope is an operation an API key may perform
+type APIKeyScope string
+
+const (
+	ScopeSubmit           APIKeyScope = "submit"            // Public submissions and file uploads
+	ScopeFormsRead        APIKeyScope = "forms:read"        // List and read forms
+	ScopeSubmissionsRead  APIKeyScope = "submissions:read"  // List, read and export submissions
+	ScopeSubmissionsWrite APIKeyScope = "submissions:write" // Delete submissions
+	ScopeWebhooksManage   APIKeyScope = "webhooks:manage"   // Configure and test form webhooks
+)
+
+// Valid reports whether s is a known scope
+func (s APIKeyScope) Valid() bool {
+	switch s {
+	case ScopeSubmit, ScopeFormsRead, ScopeSubmissionsRead, ScopeSubmissionsWrite, ScopeWebhooksManage:
+		return true
+	}
+	return false
+}
+
+// Workspace groups users so forms, templates, providers and API keys can be shared
+type Workspace struct {
+	ID         uuid.UUID     `json:"id" db:"id"`
+	Name       string        `json:"name" db:"name"`
+	OwnerID    uuid.UUID     `json:"owner_id" db:"owner_id"`       // Billing owner; the workspace uses this user's plan
+	RequireMFA bool          `json:"require_mfa" db:"require_mfa"` // Members without two-factor authentication are refused access
+	Role       WorkspaceRole `json:"role,omitempty"`               // Requesting user's role, when listed for a user
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// WorkspaceMember is a user's membership in a workspace
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID     `json:"workspace_id" db:"workspace_id"`
+	UserID      uuid.UUID     `json:"user_id" db:"user_id"`
+	Email       string        `json:"email"`
+	FirstName   string        `json:"first_name"`
+	LastName    string        `json:"last_name"`
+	Role        WorkspaceRole `json:"role" db:"role"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+}
+
+// WorkspaceInvitation is a pending email invitation to join a workspace
+type WorkspaceInvitation struct {
+	ID          uuid.UUID     `json:"id" db:"id"`
+	WorkspaceID uuid.UUID     `json:"workspace_id" db:"workspace_id"`
+	Email       string        `json:"email" db:"email"`
+	Role        WorkspaceRole `json:"role" db:"role"`
+	TokenHash   string        `json:"-" db:"token_hash"`
+	InvitedBy   uuid.UUID     `json:"invited_by" db:"invited_by"`
+	ExpiresAt   time.Time     `json:"expires_at" db:"expires_at"`
+	AcceptedAt  *time.Time    `json:"accepted_at,omitempty" db:"accepted_at"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+}
+
+// WorkspaceRole is a member's access level within a workspace
+type WorkspaceRole string
+
+const (
+	WorkspaceRoleOwner  WorkspaceRole = "owner"  // Everything, including deleting the workspace
+	WorkspaceRoleAdmin  WorkspaceRole = "admin"  // Manage members, invitations and email providers
+	WorkspaceRoleMember WorkspaceRole = "member" // Create and edit forms and templates
+	WorkspaceRoleViewer WorkspaceRole = "viewer" // Read-only access
+)
+
+var workspaceRoleRank = map[WorkspaceRole]int{
+	WorkspaceRoleViewer: 1,
+	WorkspaceRoleMember: 2,
+	WorkspaceRoleAdmin:  3,
+	WorkspaceRoleOwner:  4,
+}
+
+// Valid reports whether r is a known workspace role
+func (r WorkspaceRole) Valid() bool {
+	_, ok := workspaceRoleRank[r]
+	return ok
+}
+
+// AtLeast reports whether r grants at least the access of min
+func (r WorkspaceRole) AtLeast(min WorkspaceRole) bool {
+	return workspaceRoleRank[r] >= workspaceRoleRank[min]
+}
+
+type CreateAPIKeyRequest struct {
+	Name                string        `json:"name" binding:"required"`
+	Scopes              []APIKeyScope `json:"scopes"` // Defaults to submit only
+	FormIDs             []uuid.UUID   `json:"form_ids"`
+	DefaultFormFallback bool          `json:"default_form_fallback"`
+	AllowedIPs          []string      `json:"allowed_ips"`
+	AllowedOrigins      []string      `json:"allowed_origins"`
+	ExpiresAt           *time.Time    `json:"expires_at"`
+}
+
+type RotateAPIKeyRequest struct {
+	GracePeriodHours *int `json:"grace_period_hours"` // How long the old key keeps working; defaults to 24
+}
+
+type RotateWebhookSecretRequest struct {
+	GracePeriodHours *int `json:"grace_period_hours"` // How long deliveries stay signed with the old secret; defaults to 24
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// UpdateWorkspaceRequest changes only the fields that are present
+type UpdateWorkspaceRequest struct {
+	Name       *string `json:"name"`
+	RequireMFA *bool   `json:"require_mfa"`
+}
+
+type InviteMemberRequest struct {
+	Email string        `json:"email" binding:"required,email"`
+	Role  WorkspaceRole `json:"role" binding:"required"`
+}
+
+// SSOProtocol is how an SSO connection talks to its identity provider
+type SSOProtocol string
+
+const (
+	SSOProtocolOIDC SSOProtocol = "oidc"
+	SSOProtocolSAML SSOProtocol = "saml"
+)
+
+// SSOConnection lets users whose email is in one of its domains sign in through an identity provider
+type SSOConnection struct {
+	ID               uuid.UUID     `json:"id" db:"id"`
+	WorkspaceID      uuid.UUID     `json:"workspace_id" db:"workspace_id"` // Workspace users join on sign-in
+	Name             string        `json:"name" db:"name"`
+	Protocol         SSOProtocol   `json:"protocol" db:"protocol"`
+	Domains          []string      `json:"domains"`                                // Lower-case email domains routed to this connection
+	DefaultRole      WorkspaceRole `json:"default_role" db:"default_role"`         // Role given to users added to the workspace
+	JITProvisioning  bool          `json:"jit_provisioning" db:"jit_provisioning"` // Create accounts for unknown users on first login
+	EnforceSSO       bool          `json:"enforce_sso" db:"enforce_sso"`           // Refuse password logins for the connection's domains
+	OIDCIssuer       string        `json:"oidc_issuer,omitempty" db:"oidc_issuer"`
+	OIDCClientID     string        `json:"oidc_client_id,omitempty" db:"oidc_client_id"`
+	OIDCClientSecret string        `json:"-" db:"oidc_client_secret"`
+	SAMLIdPMetadata  string        `json:"-" db:"saml_idp_metadata"`
+	LoginURL         string        `json:"login_url"`                   // Starts a sign-in through this connection
+	RedirectURL      string        `json:"redirect_url,omitempty"`      // OIDC redirect URI to register at the IdP
+	SAMLMetadataURL  string        `json:"saml_metadata_url,omitempty"` // SP metadata to register at the IdP
+	SAMLACSURL       string        `json:"saml_acs_url,omitempty"`      // Assertion consumer service URL
+	IsActive         bool          `json:"is_active" db:"is_active"`
+	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+type CreateSSOConnectionRequest struct {
+	WorkspaceID      uuid.UUID     `json:"workspace_id" binding:"required"`
+	Name             string        `json:"name" binding:"required"`
+	Protocol         SSOProtocol   `json:"protocol" binding:"required"`
+	Domains          []string      `json:"domains" binding:"required,min=1"`
+	DefaultRole      WorkspaceRole `json:"default_role"`     // Defaults to member
+	JITProvisioning  *bool         `json:"jit_provisioning"` // Defaults to true
+	EnforceSSO       bool          `json:"enforce_sso"`
+	OIDCIssuer       string        `json:"oidc_issuer"`
+	OIDCClientID     string        `json:"oidc_client_id"`
+	OIDCClientSecret string        `json:"oidc_client_secret"`
+	SAMLMetadataXML  string        `json:"saml_metadata_xml"` // IdP metadata, given inline or as a URL
+	SAMLMetadataURL  string        `json:"saml_metadata_url"`
+}
+
+// UpdateSSOConnectionRequest changes only the fields that are present
+type UpdateSSOConnectionRequest struct {
+	Name             *string        `json:"name"`
+	Domains          []string       `json:"domains"`
+	DefaultRole      *WorkspaceRole `json:"default_role"`
+	JITProvisioning  *bool          `json:"jit_provisioning"`
+	EnforceSSO       *bool          `json:"enforce_sso"`
+	OIDCIssuer       *string        `json:"oidc_issuer"`
+	OIDCClientID     *string        `json:"oidc_client_id"`
+	OIDCClientSecret *string        `json:"oidc_client_secret"`
+	SAMLMetadataXML  *string        `json:"saml_metadata_xml"`
+	SAMLMetadataURL  *string        `json:"saml_metadata_url"`
+	IsActive         *bool          `json:"is_active"`
 }
 
 // Form represents a form configuration
 type Form struct {
 	ID              uuid.UUID `json:"id" db:"id"`
-	UserID          uuid.UUID `json:"user_id" db:"user_id"`
+	PublicID        string    `json:"public_id" db:"public_id"` // Identifies the form in public /submit requests
+	UserID          uuid.UUID `json:"user_id" db:"user_id"` // Creator
+	WorkspaceID     uuid.UUID `json:"workspace_id" db:"workspace_id"`
 	Name            string    `json:"name" db:"name"`
 	Description     string    `json:"description" db:"description"`
 	TargetEmail     string    `json:"target_email" db:"target_email"`
@@ -48,10 +262,12 @@ type Form struct {
 	RedirectURL     string    `json:"redirect_url" db:"redirect_url"`
 	WebhookURL      string    `json:"webhook_url" db:"webhook_url"`
 	SpamProtection  bool      `json:"spam_protection" db:"spam_protection"`
-	RecaptchaSecret string    `json:"-" db:"recaptcha_secret"`
+	RecaptchaSecret string    `json:"-" db:"recaptcha_secret"` // Secret key of the captcha provider
+	CaptchaProvider string    `json:"captcha_provider" db:"captcha_provider"` // utils.CaptchaProvider required on submissions; empty for none
+	CaptchaMinScore float64   `json:"captcha_min_score" db:"captcha_min_score"` // Lowest reCAPTCHA v3 score accepted
 	FileUploads     bool      `json:"file_uploads" db:"file_uploads"`
 	MaxFileSize     int64     `json:"max_file_size" db:"max_file_size"` // in bytes
-	AllowedOrigins  string    `json:"allowed_origins" db:"allowed_origins"` // JSON array of domains
+	AllowedOrigins  string    `json:"allowed_origins" db:"allowed_origins"` // JSON array of origins allowed to submit; empty allows any
 	IsActive        bool      `json:"is_active" db:"is_active"`
 	SubmissionCount int64     `json:"submission_count" db:"submission_count"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
@@ -69,15 +285,26 @@ type Submission struct {
 	Referrer   string                 `json:"referrer" db:"referrer"`
 	IsSpam     bool                   `json:"is_spam" db:"is_spam"`
 	SpamScore  float64                `json:"spam_score" db:"spam_score"`
+	SpamAction SpamAction             `json:"spam_action" db:"spam_action"`
 	EmailSent  bool                   `json:"email_sent" db:"email_sent"`
 	WebhookSent bool                  `json:"webhook_sent" db:"webhook_sent"`
 	CreatedAt  time.Time              `json:"created_at" db:"created_at"`
 }
 
+// SpamAction is the outcome of spam analysis for a submission
+type SpamAction string
+
+const (
+	SpamActionAllow      SpamAction = "allow"      // Delivered normally
+	SpamActionQuarantine SpamAction = "quarantine" // Stored for review, no notifications
+	SpamActionBlock      SpamAction = "block"      // Stored for audit, rejected and no notifications
+)
+
 // FileUpload represents an uploaded file
 type FileUpload struct {
 	ID           uuid.UUID `json:"id" db:"id"`
 	SubmissionID uuid.UUID `json:"submission_id" db:"submission_id"`
+	FieldName    string    `json:"field_name,omitempty" db:"field_name"`
 	FileName     string    `json:"file_name" db:"file_name"`
 	OriginalName string    `json:"original_name" db:"original_name"`
 	ContentType  string    `json:"content_type" db:"content_type"`
@@ -86,6 +313,50 @@ type FileUpload struct {
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 }
 
+// FileDownloadLog records every download of a stored file
+type FileDownloadLog struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	FileID     uuid.UUID  `json:"file_id" db:"file_id"`
+	UserID     *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	AccessType string     `json:"access_type" db:"access_type"` // authenticated, signed_url
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// OutboxMessage is a notification recorded alongside a submission and dispatched in the background
+type OutboxMessage struct {
+	ID             uuid.UUID    `json:"id" db:"id"`
+	SubmissionID   uuid.UUID    `json:"submission_id" db:"submission_id"`
+	FormID         uuid.UUID    `json:"form_id" db:"form_id"`
+	Kind           OutboxKind   `json:"kind" db:"kind"`
+	IdempotencyKey string       `json:"idempotency_key" db:"idempotency_key"`
+	Status         OutboxStatus `json:"status" db:"status"`
+	Attempts       int          `json:"attempts" db:"attempts"`
+	LastError      string       `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt  time.Time    `json:"next_attempt_at" db:"next_attempt_at"`
+	DispatchedAt   *time.Time   `json:"dispatched_at,omitempty" db:"dispatched_at"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+}
+
+// OutboxKind identifies where an outbox message is delivered
+type OutboxKind string
+
+const (
+	OutboxKindEmail   OutboxKind = "email"
+	OutboxKindWebhook OutboxKind = "webhook"
+)
+
+// OutboxStatus represents the dispatch state of an outbox message
+type OutboxStatus string
+
+const (
+	OutboxStatusPending    OutboxStatus = "pending"
+	OutboxStatusProcessing OutboxStatus = "processing"
+	OutboxStatusDispatched OutboxStatus = "dispatched"
+	OutboxStatusFailed     OutboxStatus = "failed"
+)
+
 // PlanLimits defines limits for each plan type
 type PlanLimits struct {
 	SubmissionsPerMonth int
@@ -141,6 +412,86 @@ var PlanLimitsMap = map[string]PlanLimits{
 	},
 }
 
+// UserRole is a user's access level across FormHub
+type UserRole string
+
+const (
+	RoleOwner  UserRole = "owner"
+	RoleAdmin  UserRole = "admin"
+	RoleMember UserRole = "member"
+	RoleViewer UserRole = "viewer"
+)
+
+// Permission names an action guarded by role-based access control
+type Permission string
+
+const (
+	PermissionSpamRead      Permission = "spam:read"      // view spam statistics, configs and quarantine
+	PermissionSpamManage    Permission = "spam:manage"    // per-form config, quarantine review, IP reputation, webhook tests
+	PermissionSpamConfigure Permission = "spam:configure" // global spam protection config
+	PermissionSpamTrain     Permission = "spam:train"     // retrain the ML classifier
+	PermissionSpamExport    Permission = "spam:export"
+	PermissionUsersManage   Permission = "users:manage"
+	PermissionSSOManage     Permission = "sso:manage" // configure identity provider connections
+)
+
+var RolePermissionsMap = map[UserRole][]Permission{
+	RoleOwner: {
+		PermissionSpamRead, PermissionSpamManage, PermissionSpamConfigure,
+		PermissionSpamTrain, PermissionSpamExport, PermissionUsersManage, PermissionSSOManage,
+	},
+	RoleAdmin: {
+		PermissionSpamRead, PermissionSpamManage, PermissionSpamExport,
+	},
+	RoleMember: {},
+	RoleViewer: {},
+}
+
+// Valid reports whether r is a known role
+func (r UserRole) Valid() bool {
+	_, ok := RolePermissionsMap[r]
+	return ok
+}
+
+// Can reports whether the role grants the permission
+func (r UserRole) Can(permission Permission) bool {
+	for _, granted := range RolePermissionsMap[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// UsageMeter reports consumption of a metered plan limit; Limit is -1 when unlimited
+type UsageMeter struct {
+	Used      int     `json:"used"`
+	Limit     int     `json:"limit"`
+	Remaining int     `json:"remaining"`
+	Percent   float64 `json:"percent"`
+}
+
+// UsageWarning is a soft warning raised when a metered limit reaches 80% or 100%
+type UsageWarning struct {
+	Capability string  `json:"capability"`
+	Level      string  `json:"level"` // approaching, reached
+	Used       int     `json:"used"`
+	Limit      int     `json:"limit"`
+	Percent    float64 `json:"percent"`
+	Message    string  `json:"message"`
+}
+
+// UsageSummary is a user's plan usage for the current billing period
+type UsageSummary struct {
+	PlanType    string          `json:"plan_type"`
+	PeriodStart time.Time       `json:"period_start"`
+	PeriodEnd   time.Time       `json:"period_end"`
+	Submissions UsageMeter      `json:"submissions"`
+	Forms       UsageMeter      `json:"forms"`
+	Features    map[string]bool `json:"features"`
+	Warnings    []UsageWarning  `json:"warnings"`
+}
+
 // Advanced Form Field Types
 type FormFieldType string
 
@@ -175,7 +526,9 @@ type FormField struct {
 	Options       []FormFieldOption         `json:"options,omitempty"`
 	Validation    FormFieldValidation       `json:"validation" db:"validation"`
 	FileSettings  *FormFieldFileSettings    `json:"file_settings,omitempty" db:"file_settings"`
+	Rules         []FormFieldRule           `json:"rules,omitempty" db:"rules"` // Conditional show/hide/require rules
 	Order         int                       `json:"order" db:"field_order"`
+	Step          int                       `json:"step" db:"step"` // 1-based page of a multi-step form
 	IsActive      bool                      `json:"is_active" db:"is_active"`
 	CreatedAt     time.Time                 `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time                 `json:"updated_at" db:"updated_at"`
@@ -212,6 +565,51 @@ type FormFieldFileSettings struct {
 	AllowMultiple   bool     `json:"allow_multiple"`
 }
 
+// FieldRuleAction is what a field rule does to its field when its conditions match
+type FieldRuleAction string
+
+const (
+	FieldRuleShow    FieldRuleAction = "show"    // Field is hidden unless a show rule matches
+	FieldRuleHide    FieldRuleAction = "hide"    // Field is hidden when a hide rule matches
+	FieldRuleRequire FieldRuleAction = "require" // Field is required when a require rule matches
+)
+
+// RuleOperator compares another field's submitted value with a condition's value
+type RuleOperator string
+
+const (
+	ConditionEquals      RuleOperator = "equals"
+	ConditionNotEquals   RuleOperator = "not_equals"
+	ConditionIn          RuleOperator = "in"     // Value is a list
+	ConditionNotIn       RuleOperator = "not_in" // Value is a list
+	ConditionContains    RuleOperator = "contains"
+	ConditionEmpty       RuleOperator = "empty"
+	ConditionNotEmpty    RuleOperator = "not_empty"
+	ConditionGreaterThan RuleOperator = "greater_than"
+	ConditionLessThan    RuleOperator = "less_than"
+)
+
+// FormFieldRule applies an action to its field when its conditions match, e.g.
+// show company_size when type equals business
+type FormFieldRule struct {
+	Action     FieldRuleAction `json:"action"`
+	Match      string          `json:"match,omitempty"` // "all" (default) or "any" of the conditions
+	Conditions []RuleCondition `json:"conditions"`
+}
+
+// RuleCondition tests the submitted value of another field
+type RuleCondition struct {
+	Field    string       `json:"field"`
+	Operator RuleOperator `json:"operator"`
+	Value    interface{}  `json:"value,omitempty"`
+}
+
+// FieldOrder sets a field's position when reordering a form's fields
+type FieldOrder struct {
+	ID    uuid.UUID `json:"id" binding:"required"`
+	Order int       `json:"order"`
+}
+
 // Enhanced Form with Field Configuration Support
 type FormWithFields struct {
 	Form   `json:",inline"`
@@ -234,6 +632,7 @@ type FormValidationResult struct {
 	IsValid      bool                           `json:"is_valid"`
 	Errors       map[string][]string           `json:"errors,omitempty"`
 	FieldResults map[string]FieldValidationResult `json:"field_results,omitempty"`
+	HiddenFields []string                       `json:"hidden_fields,omitempty"` // Fields hidden by their rules; their values are dropped
 }
 
 // Field Validation Result
@@ -257,10 +656,55 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// UpdateProfileRequest changes only the fields that are present
+type UpdateProfileRequest struct {
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Company   *string `json:"company"`
+	Email     *string `json:"email" binding:"omitempty,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// AuthResponse carries either tokens or, when the password was accepted but a second factor is
+// needed, an MFA token to exchange at /auth/mfa/verify
 type AuthResponse struct {
-	User         *User  `json:"user"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	User         *User  `json:"user,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+// MFAEnrollment is a new TOTP secret awaiting confirmation
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI to render as a QR code
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // Authenticator code or recovery code
+}
+
+// Session is a signed-in device. Each session holds one live refresh token, rotated on every use.
+type Session struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	IPAddress  string    `json:"ip_address" db:"ip_address"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	Current    bool      `json:"current"`
 }
 
 type CreateFormRequest struct {
@@ -273,7 +717,9 @@ type CreateFormRequest struct {
 	RedirectURL     string   `json:"redirect_url"`
 	WebhookURL      string   `json:"webhook_url"`
 	SpamProtection  bool     `json:"spam_protection"`
-	RecaptchaSecret string   `json:"recaptcha_secret"`
+	RecaptchaSecret string   `json:"recaptcha_secret"` // Captcha provider secret; kept on update when omitted
+	CaptchaProvider string   `json:"captcha_provider"` // recaptcha_v2, recaptcha_v3, hcaptcha or turnstile
+	CaptchaMinScore float64  `json:"captcha_min_score"` // reCAPTCHA v3 only; defaults to 0.5
 	FileUploads     bool     `json:"file_uploads"`
 	MaxFileSize     int64    `json:"max_file_size"`
 	AllowedOrigins  []string `json:"allowed_origins"`
@@ -296,7 +742,9 @@ type CreateFormFieldRequest struct {
 	Options      []CreateFieldOptionRequest `json:"options,omitempty"`
 	Validation   FormFieldValidation       `json:"validation"`
 	FileSettings *FormFieldFileSettings    `json:"file_settings,omitempty"`
+	Rules        []FormFieldRule           `json:"rules,omitempty"`
 	Order        int                       `json:"order"`
+	Step         int                       `json:"step"` // Defaults to 1
 }
 
 // Create Field Option Request
@@ -310,13 +758,52 @@ type CreateFieldOptionRequest struct {
 // Enhanced Submission Request with File Support
 type SubmissionRequest struct {
 	AccessKey         string                 `json:"access_key" form:"access_key" binding:"required"`
+	FormID            string                 `json:"form_id" form:"form_id"` // Form public ID; may also be given in the /submit path
 	Data              map[string]interface{} `json:"-" form:"-"`
+	Origin            string                 `json:"-" form:"-"` // Submitter's origin, set by the handler from Origin or Referer
 	Email             string                 `json:"email" form:"email"`
 	Subject           string                 `json:"subject" form:"subject"`
 	Message           string                 `json:"message" form:"message"`
 	RedirectURL       string                 `json:"redirect" form:"redirect"`
 	RecaptchaResponse string                 `json:"g-recaptcha-response" form:"g-recaptcha-response"`
+	HCaptchaResponse  string                 `json:"h-captcha-response" form:"h-captcha-response"`
+	TurnstileResponse string                 `json:"cf-turnstile-response" form:"cf-turnstile-response"`
+	BehavioralData    string                 `json:"_behavioral_data" form:"_behavioral_data"` // JSON BehavioralProfile from the form script
 	Files             []FileUploadResult     `json:"files,omitempty"`
+	CompleteProgress  bool                   `json:"-" form:"-"` // Final submit of saved progress, set by the handler
+	ProgressToken     string                 `json:"-" form:"-"` // X-Progress-Token of the saved progress to submit
+}
+
+// PartialSubmission is the saved progress of a respondent working through a multi-step form
+type PartialSubmission struct {
+	ID           uuid.UUID              `json:"id" db:"id"`
+	FormID       uuid.UUID              `json:"form_id" db:"form_id"`
+	SessionID    string                 `json:"session_id" db:"session_id"`
+	Data         map[string]interface{} `json:"data" db:"data"`                 // JSON data
+	CurrentStep  int                    `json:"current_step" db:"current_step"` // Furthest step saved
+	Email        *string                `json:"-" db:"email"`
+	SubmissionID *uuid.UUID             `json:"submission_id,omitempty" db:"submission_id"`
+	CompletedAt  *time.Time             `json:"completed_at,omitempty" db:"completed_at"`
+	ExpiresAt    time.Time              `json:"expires_at" db:"expires_at"`
+	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
+	Token        string                 `json:"-" db:"-"` // Progress token; only known right after it is issued
+}
+
+// SaveProgressRequest saves the values of one step of a multi-step form
+type SaveProgressRequest struct {
+	AccessKey string                 `json:"access_key" binding:"required"`
+	FormID    string                 `json:"form_id"` // Form public ID; may also be given in the path
+	Step      int                    `json:"step" binding:"required,min=1"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// ResumeLinkRequest emails the respondent a signed link back to their saved progress
+type ResumeLinkRequest struct {
+	AccessKey string `json:"access_key" binding:"required"`
+	FormID    string `json:"form_id"`
+	Email     string `json:"email" binding:"required,email"`
+	ResumeURL string `json:"resume_url" binding:"required,url"` // Page hosting the form; the link adds the resume token to it
 }
 
 // File Upload Request
@@ -340,6 +827,28 @@ type SubmissionResponse struct {
 	Message    string                 `json:"message"`
 	Data       map[string]interface{} `json:"data,omitempty"`
 	RedirectURL string                `json:"redirect_url,omitempty"`
+	SpamAction  SpamAction            `json:"-"` // Set when spam protection blocked the submission
+}
+
+// SubmissionListParams holds pagination, filter and sort options for listing submissions
+type SubmissionListParams struct {
+	Cursor        string            `json:"cursor,omitempty"`
+	Limit         int               `json:"limit"`
+	SortOrder     string            `json:"sort_order"` // asc or desc (by created_at)
+	StartDate     *time.Time        `json:"start_date,omitempty"`
+	EndDate       *time.Time        `json:"end_date,omitempty"`
+	IsSpam        *bool             `json:"is_spam,omitempty"`
+	EmailSent     *bool             `json:"email_sent,omitempty"`
+	WebhookSent   *bool             `json:"webhook_sent,omitempty"`
+	FieldEquals   map[string]string `json:"field_equals,omitempty"`   // data key -> exact value
+	FieldContains map[string]string `json:"field_contains,omitempty"` // data key -> substring
+}
+
+// SubmissionListResponse is a single page of submissions
+type SubmissionListResponse struct {
+	Submissions []Submission `json:"submissions"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+	HasMore     bool         `json:"has_more"`
 }
 
 // Email Template System Models
@@ -360,6 +869,7 @@ const (
 type EmailProvider struct {
 	ID          uuid.UUID         `json:"id" db:"id"`
 	UserID      uuid.UUID         `json:"user_id" db:"user_id"`
+	WorkspaceID uuid.UUID         `json:"workspace_id" db:"workspace_id"`
 	Name        string            `json:"name" db:"name"`
 	Type        EmailProviderType `json:"type" db:"type"`
 	Config      EmailProviderConfig `json:"config" db:"config"` // JSON configuration
@@ -407,6 +917,7 @@ const (
 type EmailTemplate struct {
 	ID             uuid.UUID         `json:"id" db:"id"`
 	UserID         uuid.UUID         `json:"user_id" db:"user_id"`
+	WorkspaceID    uuid.UUID         `json:"workspace_id" db:"workspace_id"`
 	FormID         *uuid.UUID        `json:"form_id,omitempty" db:"form_id"` // Optional: specific to a form
 	Name           string            `json:"name" db:"name"`
 	Description    string            `json:"description" db:"description"`
@@ -475,7 +986,7 @@ type EmailQueue struct {
 	UserID         uuid.UUID              `json:"user_id" db:"user_id"`
 	FormID         *uuid.UUID             `json:"form_id,omitempty" db:"form_id"`
 	SubmissionID   *uuid.UUID             `json:"submission_id,omitempty" db:"submission_id"`
-	TemplateID     uuid.UUID              `json:"template_id" db:"template_id"`
+	TemplateID     *uuid.UUID             `json:"template_id,omitempty" db:"template_id"` // nil for built-in submission notifications
 	ProviderID     *uuid.UUID             `json:"provider_id,omitempty" db:"provider_id"`
 	ToEmails       []string               `json:"to_emails" db:"to_emails"` // JSON array
 	CCEmails       []string               `json:"cc_emails" db:"cc_emails"` // JSON array
@@ -490,6 +1001,7 @@ type EmailQueue struct {
 	Attempts       int                    `json:"attempts" db:"attempts"`
 	LastError      string                 `json:"last_error" db:"last_error"`
 	Priority       int                    `json:"priority" db:"priority"` // Higher number = higher priority
+	IdempotencyKey string                 `json:"-" db:"idempotency_key"`
 	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time              `json:"updated_at" db:"updated_at"`
 }
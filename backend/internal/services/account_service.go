@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"formhub/internal/models"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidAccountToken is returned for unknown, expired or already used verification and reset tokens
+var ErrInvalidAccountToken = fmt.Errorf("invalid or expired token")
+
+// ErrIncorrectPassword is returned when a password change is attempted with the wrong current password
+var ErrIncorrectPassword = fmt.Errorf("current password is incorrect")
+
+// ErrEmailTaken is returned when a profile update would reuse another account's email
+var ErrEmailTaken = fmt.Errorf("email is already in use")
+
+// Account token purposes
+const (
+	tokenPurposeEmailVerification = "email_verification"
+	tokenPurposePasswordReset     = "password_reset"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+
+	// passwordResetCooldown limits reset emails to one per address in this window
+	passwordResetCooldown = time.Minute
+)
+
+// AccountService handles the account lifecycle outside of sign-in: email verification, password
+// reset and change, and profile updates. Tokens are emailed once and only their hash is stored.
+type AccountService struct {
+	db          *sql.DB
+	redis       *redis.Client
+	authService *AuthService
+	emailQueue  *EmailQueueService
+	frontendURL string
+}
+
+func NewAccountService(db *sql.DB, redis *redis.Client, authService *AuthService, emailQueue *EmailQueueService, frontendURL string) *AccountService {
+	return &AccountService{
+		db:          db,
+		redis:       redis,
+		authService: authService,
+		emailQueue:  emailQueue,
+		frontendURL: frontendURL,
+	}
+}
+
+// SendVerificationEmail emails the user a link to confirm their address. Earlier verification
+// links stop working.
+func (s *AccountService) SendVerificationEmail(user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := s.issueToken(user, tokenPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.frontendURL, token)
+	return s.send(user, "Confirm your FormHub email address", fmt.Sprintf(
+		"Hi %s,\n\nConfirm your email address to finish setting up your FormHub account: %s\n\nThis link expires in 48 hours.",
+		user.FirstName, link,
+	))
+}
+
+// ResendVerificationEmail sends a fresh verification link to a signed-in user
+func (s *AccountService) ResendVerificationEmail(userID uuid.UUID) error {
+	user, err := s.authService.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.SendVerificationEmail(user)
+}
+
+// VerifyEmail marks the address the token was sent to as verified. The token is rejected if the
+// user has changed their email since it was issued.
+func (s *AccountService) VerifyEmail(token string) error {
+	return s.consumeToken(token, tokenPurposeEmailVerification, func(tx *sql.Tx, userID uuid.UUID, tokenEmail string) error {
+		result, err := tx.Exec(
+			`UPDATE users SET email_verified_at = ?, updated_at = ? WHERE id = ? AND email = ?`,
+			time.Now(), time.Now(), userID, tokenEmail,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to verify email: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrInvalidAccountToken
+		}
+		return nil
+	})
+}
+
+// IsEmailVerified reports whether the user has confirmed their current email address
+func (s *AccountService) IsEmailVerified(userID uuid.UUID) (bool, error) {
+	var verifiedAt sql.NullTime
+	if err := s.db.QueryRow(`SELECT email_verified_at FROM users WHERE id = ?`, userID).Scan(&verifiedAt); err != nil {
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+	return verifiedAt.Valid, nil
+}
+
+// RequestPasswordReset emails a reset link if the address belongs to an active account. It
+// reports nothing about whether the account exists, so callers always answer the same way.
+func (s *AccountService) RequestPasswordReset(emailAddress string) error {
+	cooldownKey := fmt.Sprintf("account:password_reset:%s", strings.ToLower(emailAddress))
+	allowed, err := s.redis.SetNX(context.Background(), cooldownKey, 1, passwordResetCooldown).Result()
+	if err != nil {
+		log.Printf("Failed to check password reset cooldown: %v", err)
+	} else if !allowed {
+		return nil
+	}
+
+	user := &models.User{}
+	err = s.db.QueryRow(
+		`SELECT id, email, first_name FROM users WHERE email = ? AND is_active = true`, emailAddress,
+	).Scan(&user.ID, &user.Email, &user.FirstName)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	token, err := s.issueToken(user, tokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.frontendURL, token)
+	return s.send(user, "Reset your FormHub password", fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to reset the password for your FormHub account. If it was you, choose a new password here: %s\n\nThis link expires in one hour. If you didn't ask for it, you can ignore this email.",
+		user.FirstName, link,
+	))
+}
+
+// ResetPassword sets a new password using an emailed reset token and signs the user out
+// everywhere. The token only works while the account still has the email it was sent to, and
+// following the link proves control of that mailbox, so the email counts as verified.
+func (s *AccountService) ResetPassword(token, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var resetUserID uuid.UUID
+	err = s.consumeToken(token, tokenPurposePasswordReset, func(tx *sql.Tx, userID uuid.UUID, tokenEmail string) error {
+		now := time.Now()
+		result, err := tx.Exec(`
+			UPDATE users SET password_hash = ?, email_verified_at = COALESCE(email_verified_at, ?), updated_at = ?
+			WHERE id = ? AND email = ? AND is_active = true`,
+			string(hashedPassword), now, now, userID, tokenEmail,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reset password: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrInvalidAccountToken
+		}
+		resetUserID = userID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.authService.RevokeUserSessions(resetUserID, uuid.Nil)
+}
+
+// ChangePassword replaces the password of a signed-in user after checking the current one.
+// Every other session is signed out; the session making the change stays signed in.
+func (s *AccountService) ChangePassword(userID, sessionID uuid.UUID, currentPassword, newPassword string) error {
+	var passwordHash string
+	if err := s.db.QueryRow(`SELECT password_hash FROM users WHERE id = ? AND is_active = true`, userID).Scan(&passwordHash); err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(currentPassword)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if _, err := s.db.Exec(
+		`UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?`, string(hashedPassword), time.Now(), userID,
+	); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	// Outstanding reset links were issued for the old password
+	if _, err := s.db.Exec(
+		`DELETE FROM user_tokens WHERE user_id = ? AND purpose = ?`, userID, tokenPurposePasswordReset,
+	); err != nil {
+		log.Printf("Failed to delete password reset tokens for user %s: %v", userID, err)
+	}
+
+	return s.authService.RevokeUserSessions(userID, sessionID)
+}
+
+// UpdateProfile changes the user's name, company and email. A new email must be verified again.
+func (s *AccountService) UpdateProfile(userID uuid.UUID, req models.UpdateProfileRequest) (*models.User, error) {
+	user, err := s.authService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.FirstName != nil {
+		user.FirstName = *req.FirstName
+	}
+	if req.LastName != nil {
+		user.LastName = *req.LastName
+	}
+	if req.Company != nil {
+		user.Company = *req.Company
+	}
+
+	emailChanged := req.Email != nil && !strings.EqualFold(*req.Email, user.Email)
+	if emailChanged {
+		var exists bool
+		err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND id != ?)`, *req.Email, userID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check email: %w", err)
+		}
+		if exists {
+			return nil, ErrEmailTaken
+		}
+		user.Email = *req.Email
+		user.EmailVerifiedAt = nil
+	}
+
+	user.UpdatedAt = time.Now()
+	_, err = s.db.Exec(`
+		UPDATE users SET first_name = ?, last_name = ?, company = ?, email = ?, email_verified_at = ?, updated_at = ?
+		WHERE id = ?`,
+		user.FirstName, user.LastName, user.Company, user.Email, user.EmailVerifiedAt, user.UpdatedAt, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	if emailChanged {
+		if err := s.SendVerificationEmail(user); err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", userID, err)
+		}
+	}
+
+	return user, nil
+}
+
+// issueToken stores a new single-use token for the user's current email, replacing any unused
+// token with the same purpose, and returns the token to email
+func (s *AccountService) issueToken(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`DELETE FROM user_tokens WHERE user_id = ? AND purpose = ? AND used_at IS NULL`, user.ID, purpose,
+	); err != nil {
+		return "", fmt.Errorf("failed to replace token: %w", err)
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO user_tokens (token_hash, user_id, purpose, email, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		hashAccountToken(token), user.ID, purpose, user.Email, now.Add(ttl), now,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return token, nil
+}
+
+// consumeToken marks a token used and runs apply in the same transaction, so a token can only
+// ever take effect once
+func (s *AccountService) consumeToken(token, purpose string, apply func(tx *sql.Tx, userID uuid.UUID, tokenEmail string) error) error {
+	tokenHash := hashAccountToken(token)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID uuid.UUID
+	var tokenEmail string
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT user_id, email, expires_at, used_at FROM user_tokens
+		WHERE token_hash = ? AND purpose = ?
+		FOR UPDATE`, tokenHash, purpose,
+	).Scan(&userID, &tokenEmail, &expiresAt, &usedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidAccountToken
+		}
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+
+	if usedAt.Valid || time.Now().After(expiresAt) {
+		return ErrInvalidAccountToken
+	}
+
+	if _, err := tx.Exec(`UPDATE user_tokens SET used_at = ? WHERE token_hash = ?`, time.Now(), tokenHash); err != nil {
+		return fmt.Errorf("failed to use token: %w", err)
+	}
+
+	if err := apply(tx, userID, tokenEmail); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit token: %w", err)
+	}
+	return nil
+}
+
+// CleanupTokens deletes account tokens that expired more than a day ago
+func (s *AccountService) CleanupTokens() error {
+	if _, err := s.db.Exec(`DELETE FROM user_tokens WHERE expires_at < ?`, time.Now().Add(-24*time.Hour)); err != nil {
+		return fmt.Errorf("failed to clean up account tokens: %w", err)
+	}
+	return nil
+}
+
+// send queues an account email to the user, so it is retried like any other queued email
+func (s *AccountService) send(user *models.User, subject, body string) error {
+	if s.emailQueue == nil {
+		return nil
+	}
+
+	queued := &models.EmailQueue{
+		UserID:      user.ID,
+		ToEmails:    []string{user.Email},
+		Subject:     subject,
+		TextContent: body,
+		Priority:    10, // The user is waiting for it
+	}
+	if err := s.emailQueue.QueueEmail(queued); err != nil {
+		return fmt.Errorf("failed to queue %q email: %w", subject, err)
+	}
+	return nil
+}
+
+func hashAccountToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
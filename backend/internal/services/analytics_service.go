@@ -694,6 +694,71 @@ func (s *AnalyticsService) aggregateFormFunnelData(formID uuid.UUID, eventTime t
 	_, err := s.db.ExecContext(ctx, query, formID, formID, eventTime, formID, date)
 	if err != nil {
 		log.Printf("Failed to aggregate funnel data: %v", err)
+		return
+	}
+
+	s.aggregateStepDropOffs(ctx, formID, date)
+}
+
+// RefreshFormFunnel re-aggregates the form's funnel for the day of date
+func (s *AnalyticsService) RefreshFormFunnel(formID uuid.UUID, date time.Time) {
+	s.aggregateFormFunnelData(formID, date)
+}
+
+// aggregateStepDropOffs counts, for the multi-step progress started on date, how many respondents
+// reached each step and how many stopped there without submitting
+func (s *AnalyticsService) aggregateStepDropOffs(ctx context.Context, formID uuid.UUID, date string) {
+	query := `
+		SELECT current_step, COUNT(*), COUNT(completed_at)
+		FROM form_partial_submissions
+		WHERE form_id = ? AND DATE(created_at) = ?
+		GROUP BY current_step
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, formID, date)
+	if err != nil {
+		log.Printf("Failed to aggregate step drop-offs: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	stopped := make(map[int]int)
+	furthest := make(map[int]int)
+	lastStep := 0
+	for rows.Next() {
+		var step, total, completed int
+		if err := rows.Scan(&step, &total, &completed); err != nil {
+			log.Printf("Failed to aggregate step drop-offs: %v", err)
+			return
+		}
+		furthest[step] = total
+		stopped[step] = total - completed
+		if step > lastStep {
+			lastStep = step
+		}
+	}
+	if lastStep == 0 {
+		return
+	}
+
+	steps := make([]models.FunnelStep, 0, lastStep)
+	reached := 0
+	for step := lastStep; step >= 1; step-- {
+		reached += furthest[step]
+		funnelStep := models.FunnelStep{Step: step, Reached: reached, DroppedOff: stopped[step]}
+		if reached > 0 {
+			funnelStep.DropOffRate = float64(stopped[step]) * 100.0 / float64(reached)
+		}
+		steps = append([]models.FunnelStep{funnelStep}, steps...)
+	}
+
+	stepsJSON, _ := json.Marshal(steps)
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE form_conversion_funnels SET step_drop_offs = ? WHERE form_id = ? AND date = ?`,
+		string(stepsJSON), formID, date,
+	)
+	if err != nil {
+		log.Printf("Failed to store step drop-offs: %v", err)
 	}
 }
 
@@ -814,7 +879,7 @@ func (s *AnalyticsService) generateRandomString(length int) string {
 func (s *AnalyticsService) GetFormConversionFunnel(ctx context.Context, formID uuid.UUID, userID uuid.UUID, startDate, endDate time.Time) ([]models.FormConversionFunnel, error) {
 	query := `
 		SELECT id, form_id, user_id, date, total_views, total_starts, total_submits,
-		       total_completes, total_abandons, abandonment_points, conversion_rate,
+		       total_completes, total_abandons, abandonment_points, step_drop_offs, conversion_rate,
 		       completion_rate, average_time_to_submit, average_time_to_abandon,
 		       created_at, updated_at
 		FROM form_conversion_funnels
@@ -831,12 +896,12 @@ func (s *AnalyticsService) GetFormConversionFunnel(ctx context.Context, formID u
 	var funnels []models.FormConversionFunnel
 	for rows.Next() {
 		var funnel models.FormConversionFunnel
-		var abandonmentPointsJSON sql.NullString
+		var abandonmentPointsJSON, stepDropOffsJSON sql.NullString
 
 		err := rows.Scan(
 			&funnel.ID, &funnel.FormID, &funnel.UserID, &funnel.Date,
 			&funnel.TotalViews, &funnel.TotalStarts, &funnel.TotalSubmits,
-			&funnel.TotalCompletes, &funnel.TotalAbandons, &abandonmentPointsJSON,
+			&funnel.TotalCompletes, &funnel.TotalAbandons, &abandonmentPointsJSON, &stepDropOffsJSON,
 			&funnel.ConversionRate, &funnel.CompletionRate, &funnel.AvgTimeToSubmit,
 			&funnel.AvgTimeToAbandon, &funnel.CreatedAt, &funnel.UpdatedAt,
 		)
@@ -849,6 +914,11 @@ func (s *AnalyticsService) GetFormConversionFunnel(ctx context.Context, formID u
 			json.Unmarshal([]byte(abandonmentPointsJSON.String), &funnel.AbandonmentPoints)
 		}
 
+		// Parse per-step drop-off of multi-step forms
+		if stepDropOffsJSON.Valid {
+			json.Unmarshal([]byte(stepDropOffsJSON.String), &funnel.StepDropOffs)
+		}
+
 		funnels = append(funnels, funnel)
 	}
 
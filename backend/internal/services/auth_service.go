@@ -1,10 +1,19 @@
 package services
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"formhub/internal/models"
+	"formhub/pkg/utils"
+	"log"
+	"net"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,27 +22,90 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+var ErrInvalidRefreshToken = fmt.Errorf("invalid refresh token")
+
+// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again.
+// The token has most likely been stolen, so its whole session is revoked.
+var ErrRefreshTokenReused = fmt.Errorf("refresh token reused")
+
+// ErrUserNotFound is returned for users that do not exist or have been deactivated
+var ErrUserNotFound = fmt.Errorf("user not found")
+
+// ErrLastOwner is returned when a role change would leave the installation without an owner
+var ErrLastOwner = fmt.Errorf("cannot demote the last owner")
+
+// ErrSessionNotFound is returned for sessions that do not exist, are already revoked or belong to
+// another user
+var ErrSessionNotFound = fmt.Errorf("session not found")
+
+// ErrInvalidAPIKeyRequest is returned for API key settings that fail validation
+var ErrInvalidAPIKeyRequest = fmt.Errorf("invalid API key request")
+
+// ErrAPIKeyRestricted is returned when an API key is used from outside its IP or origin allow-lists
+var ErrAPIKeyRestricted = fmt.Errorf("access key is not allowed from this address or origin")
+
+// ErrAPIKeyScope is returned when an API key lacks the scope an operation requires
+var ErrAPIKeyScope = fmt.Errorf("access key lacks the required scope")
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+
+	// userRoleCacheTTL bounds how long a cached role is trusted should its invalidation fail
+	userRoleCacheTTL = time.Minute
+)
+
+const (
+	// apiKeyPrefix marks FormHub API keys so they are easy to spot in code and secret scanners
+	apiKeyPrefix = "fh_"
+
+	// DefaultAPIKeyGracePeriod is how long a rotated key keeps working when no grace period is given
+	DefaultAPIKeyGracePeriod = 24 * time.Hour
+	maxAPIKeyGracePeriod     = 30 * 24 * time.Hour
+)
+
 type AuthService struct {
-	db        *sql.DB
-	redis     *redis.Client
-	jwtSecret []byte
+	db               *sql.DB
+	redis            *redis.Client
+	jwtSecret        []byte
+	apiKeySecret     []byte
+	workspaceService *WorkspaceService
+	mfaService       *MFAService
+	ssoService       *SSOService
 }
 
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID      uuid.UUID       `json:"user_id"`
+	Email       string          `json:"email"`
+	Role        models.UserRole `json:"role"`
+	WorkspaceID uuid.UUID       `json:"workspace_id"` // Workspace selected when the token was issued
+	SessionID   uuid.UUID       `json:"sid"`          // Session the token belongs to; revoking it rejects the token
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(db *sql.DB, redis *redis.Client, jwtSecret string) *AuthService {
+func NewAuthService(db *sql.DB, redis *redis.Client, jwtSecret, apiKeySecret string) *AuthService {
 	return &AuthService{
-		db:        db,
-		redis:     redis,
-		jwtSecret: []byte(jwtSecret),
+		db:           db,
+		redis:        redis,
+		jwtSecret:    []byte(jwtSecret),
+		apiKeySecret: []byte(apiKeySecret),
 	}
 }
 
-func (s *AuthService) Register(req models.RegisterRequest) (*models.AuthResponse, error) {
+func (s *AuthService) SetWorkspaceService(workspaceService *WorkspaceService) {
+	s.workspaceService = workspaceService
+}
+
+func (s *AuthService) SetMFAService(mfaService *MFAService) {
+	s.mfaService = mfaService
+}
+
+func (s *AuthService) SetSSOService(ssoService *SSOService) {
+	s.ssoService = ssoService
+}
+
+func (s *AuthService) Register(req models.RegisterRequest, userAgent, ipAddress string) (*models.AuthResponse, error) {
 	// Check if user already exists
 	var exists bool
 	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", req.Email).Scan(&exists)
@@ -60,6 +132,7 @@ func (s *AuthService) Register(req models.RegisterRequest) (*models.AuthResponse
 		LastName:  req.LastName,
 		Company:   req.Company,
 		PlanType:  "free",
+		Role:      models.RoleMember,
 		IsActive:  true,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -67,53 +140,47 @@ func (s *AuthService) Register(req models.RegisterRequest) (*models.AuthResponse
 
 	query := `
 		INSERT INTO users (id, email, password_hash, first_name, last_name, company, 
-			plan_type, is_active, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			plan_type, role, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = s.db.Exec(query,
 		user.ID, user.Email, user.Password, user.FirstName, user.LastName,
-		user.Company, user.PlanType, user.IsActive, user.CreatedAt, user.UpdatedAt,
+		user.Company, user.PlanType, user.Role, user.IsActive, user.CreatedAt, user.UpdatedAt,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err := s.generateTokens(user)
+	// Every user starts with a personal workspace
+	workspace, err := s.workspaceService.CreatePersonalWorkspace(user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
 	}
 
 	// Create default API key
-	_, err = s.CreateAPIKey(user.ID, "Default API Key")
+	_, err = s.CreateAPIKey(user.ID, workspace.ID, models.CreateAPIKeyRequest{Name: "Default API Key"})
 	if err != nil {
 		// Log error but don't fail registration
 		fmt.Printf("Failed to create default API key: %v\n", err)
 	}
 
-	// Clear password before returning
-	user.Password = ""
-
-	return &models.AuthResponse{
-		User:         user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return s.startSession(user, userAgent, ipAddress)
 }
 
-func (s *AuthService) Login(req models.LoginRequest) (*models.AuthResponse, error) {
+func (s *AuthService) Login(req models.LoginRequest, userAgent, ipAddress string) (*models.AuthResponse, error) {
 	user := &models.User{}
 	query := `
 		SELECT id, email, password_hash, first_name, last_name, company, 
-			plan_type, is_active, created_at, updated_at
+			plan_type, role, email_verified_at, mfa_enabled_at, is_active, created_at, updated_at
 		FROM users WHERE email = ? AND is_active = true
 	`
 
 	err := s.db.QueryRow(query, req.Email).Scan(
 		&user.ID, &user.Email, &user.Password, &user.FirstName, &user.LastName,
-		&user.Company, &user.PlanType, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+		&user.Company, &user.PlanType, &user.Role, &user.EmailVerifiedAt, &user.MFAEnabledAt,
+		&user.IsActive, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -123,24 +190,271 @@ func (s *AuthService) Login(req models.LoginRequest) (*models.AuthResponse, erro
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
+	// Domains whose SSO connection enforces it never sign in with a password
+	if required, err := s.ssoService.SSORequired(user.Email); err != nil {
+		return nil, err
+	} else if required {
+		return nil, ErrSSORequired
+	}
+
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 		return nil, fmt.Errorf("invalid email or password")
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err := s.generateTokens(user)
+	// With two-factor authentication on, the password only earns a challenge for the second step
+	if user.MFAEnabledAt != nil {
+		mfaToken, err := s.mfaService.CreateChallenge(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.AuthResponse{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	if err := s.resolveWorkspace(user); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, userAgent, ipAddress)
+}
+
+// CompleteMFALogin finishes a login that Login answered with an MFA challenge
+func (s *AuthService) CompleteMFALogin(mfaToken, code, userAgent, ipAddress string) (*models.AuthResponse, error) {
+	userID, err := s.mfaService.VerifyChallenge(mfaToken, code)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+		return nil, err
 	}
 
-	// Clear password before returning
-	user.Password = ""
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveWorkspace(user); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, userAgent, ipAddress)
+}
+
+// CompleteSSOLogin exchanges the login code from a finished SSO sign-in for tokens. The identity
+// provider authenticated the user, so no local second factor is asked for.
+func (s *AuthService) CompleteSSOLogin(loginCode, userAgent, ipAddress string) (*models.AuthResponse, error) {
+	userID, err := s.ssoService.ConsumeLoginCode(loginCode)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveWorkspace(user); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, userAgent, ipAddress)
+}
+
+// RefreshTokens exchanges a refresh token for a new token pair. Each refresh token is single use:
+// presenting one that was already rotated revokes its session.
+func (s *AuthService) RefreshTokens(refreshToken, userAgent, ipAddress string) (*models.AuthResponse, error) {
+	tokenHash := hashRefreshToken(refreshToken)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sessionID, userID uuid.UUID
+	var usedAt, revokedAt sql.NullTime
+	var expiresAt time.Time
+	err = tx.QueryRow(`
+		SELECT t.session_id, t.used_at, t.expires_at, us.user_id, us.revoked_at
+		FROM refresh_tokens t
+		JOIN user_sessions us ON us.id = t.session_id
+		WHERE t.token_hash = ?
+		FOR UPDATE`, tokenHash,
+	).Scan(&sessionID, &usedAt, &expiresAt, &userID, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if revokedAt.Valid || time.Now().After(expiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if usedAt.Valid {
+		tx.Rollback()
+		log.Printf("Refresh token reuse detected for session %s; revoking session", sessionID)
+		if err := s.revokeSession(sessionID); err != nil {
+			log.Printf("Failed to revoke session %s: %v", sessionID, err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ?`, now, tokenHash); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	newRefreshToken, newExpiresAt, err := s.issueRefreshToken(tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE user_sessions SET user_agent = ?, ip_address = ?, last_used_at = ?, expires_at = ?
+		WHERE id = ?`,
+		userAgent, ipAddress, now, newExpiresAt, sessionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit refresh token rotation: %w", err)
+	}
+
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err := s.resolveWorkspace(user); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.generateAccessToken(user, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
 
 	return &models.AuthResponse{
 		User:         user,
 		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
+
+// Logout revokes the session the request was authenticated with
+func (s *AuthService) Logout(sessionID uuid.UUID) error {
+	return s.revokeSession(sessionID)
+}
+
+// ListSessions returns the user's active sessions, flagging the one making the request
+func (s *AuthService) ListSessions(userID, currentSessionID uuid.UUID) ([]models.Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at
+		FROM user_sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY last_used_at DESC`
+
+	rows, err := s.db.Query(query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(
+			&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+			&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		session.Current = session.ID == currentSessionID
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession ends one of the user's sessions; its refresh token stops working immediately
+// and its access tokens are rejected for the rest of their lifetime
+func (s *AuthService) RevokeSession(userID, sessionID uuid.UUID) error {
+	var ownerID uuid.UUID
+	err := s.db.QueryRow(`SELECT user_id FROM user_sessions WHERE id = ? AND revoked_at IS NULL`, sessionID).Scan(&ownerID)
+	if err == sql.ErrNoRows || (err == nil && ownerID != userID) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return s.revokeSession(sessionID)
+}
+
+// RevokeUserSessions ends every active session of the user except keep, which may be uuid.Nil
+func (s *AuthService) RevokeUserSessions(userID, keep uuid.UUID) error {
+	rows, err := s.db.Query(
+		`SELECT id FROM user_sessions WHERE user_id = ? AND id != ? AND revoked_at IS NULL AND expires_at > ?`,
+		userID, keep, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	var sessionIDs []uuid.UUID
+	for rows.Next() {
+		var sessionID uuid.UUID
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	rows.Close()
+
+	// Revoke every session even if one fails, so a Redis outage never leaves the rest active
+	var revokeErr error
+	for _, sessionID := range sessionIDs {
+		if err := s.revokeSession(sessionID); err != nil && revokeErr == nil {
+			revokeErr = err
+		}
+	}
+	return revokeErr
+}
+
+// CleanupSessions deletes sessions that expired or were revoked more than a day ago
+func (s *AuthService) CleanupSessions() error {
+	cutoff := time.Now().Add(-24 * time.Hour)
+	_, err := s.db.Exec(`
+		DELETE FROM user_sessions
+		WHERE expires_at < ? OR (revoked_at IS NOT NULL AND revoked_at < ?)`,
+		cutoff, cutoff,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clean up sessions: %w", err)
+	}
+	return nil
+}
+
+// SwitchWorkspace makes workspaceID the user's current workspace and issues an access token scoped
+// to it. The session's refresh token stays valid; refreshed tokens pick up the current workspace.
+func (s *AuthService) SwitchWorkspace(userID, sessionID, workspaceID uuid.UUID) (*models.AuthResponse, error) {
+	if err := s.workspaceService.SwitchWorkspace(userID, workspaceID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	user.CurrentWorkspaceID = &workspaceID
+
+	accessToken, err := s.generateAccessToken(user, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	return &models.AuthResponse{
+		User:        user,
+		AccessToken: accessToken,
 	}, nil
 }
 
@@ -156,30 +470,35 @@ func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if claims.SessionID != uuid.Nil && s.isSessionRevoked(claims.SessionID) {
+		return nil, fmt.Errorf("session revoked")
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return claims, nil
 }
 
 func (s *AuthService) GetUserByID(userID uuid.UUID) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, email, first_name, last_name, company, plan_type, is_active,
-			created_at, updated_at
+		SELECT id, email, first_name, last_name, company, plan_type, role, email_verified_at,
+			mfa_enabled_at, is_active, created_at, updated_at
 		FROM users WHERE id = ? AND is_active = true
 	`
 
 	err := s.db.QueryRow(query, userID).Scan(
 		&user.ID, &user.Email, &user.FirstName, &user.LastName,
-		&user.Company, &user.PlanType, &user.IsActive,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.Company, &user.PlanType, &user.Role, &user.EmailVerifiedAt,
+		&user.MFAEnabledAt, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -187,52 +506,201 @@ func (s *AuthService) GetUserByID(userID uuid.UUID) (*models.User, error) {
 	return user, nil
 }
 
-func (s *AuthService) CreateAPIKey(userID uuid.UUID, name string) (*models.APIKey, error) {
-	// Generate a random API key
-	key := uuid.New().String() + "-" + uuid.New().String()
-	keyHash := fmt.Sprintf("%x", md5.Sum([]byte(key)))
+// CurrentRole returns the user's role as stored now rather than the one in their access token, so
+// role changes apply to the next request. Roles are cached in Redis until UpdateUserRole changes them.
+func (s *AuthService) CurrentRole(userID uuid.UUID) (models.UserRole, error) {
+	ctx := context.Background()
+	key := userRoleKey(userID)
+
+	cached, err := s.redis.Get(ctx, key).Result()
+	if err == nil && models.UserRole(cached).Valid() {
+		return models.UserRole(cached), nil
+	}
+	if err != nil && err != redis.Nil {
+		log.Printf("Failed to read cached role of user %s, checking the database: %v", userID, err)
+	}
+
+	var role models.UserRole
+	err = s.db.QueryRow(`SELECT role FROM users WHERE id = ? AND is_active = true`, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get user role: %w", err)
+	}
+
+	if err := s.redis.Set(ctx, key, string(role), userRoleCacheTTL).Err(); err != nil {
+		log.Printf("Failed to cache role of user %s: %v", userID, err)
+	}
+	return role, nil
+}
+
+// UpdateUserRole changes a user's role. The last owner cannot be demoted, so the
+// installation always keeps someone able to manage roles.
+func (s *AuthService) UpdateUserRole(userID uuid.UUID, role models.UserRole) (*models.User, error) {
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the owner rows so two concurrent demotions cannot both see another owner remaining
+	var currentRole models.UserRole
+	err = tx.QueryRow("SELECT role FROM users WHERE id = ? AND is_active = true FOR UPDATE", userID).Scan(&currentRole)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if currentRole == models.RoleOwner && role != models.RoleOwner {
+		rows, err := tx.Query("SELECT id FROM users WHERE role = ? AND is_active = true FOR UPDATE", models.RoleOwner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count owners: %w", err)
+		}
+		owners := 0
+		for rows.Next() {
+			owners++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to count owners: %w", err)
+		}
+		if owners <= 1 {
+			return nil, ErrLastOwner
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE users SET role = ?, updated_at = ? WHERE id = ?", role, time.Now(), userID); err != nil {
+		return nil, fmt.Errorf("failed to update user role: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit role change: %w", err)
+	}
+
+	// Drop the cached role so the change applies to tokens already issued
+	if err := s.redis.Del(context.Background(), userRoleKey(userID)).Err(); err != nil {
+		log.Printf("Failed to invalidate cached role of user %s: %v", userID, err)
+	}
+
+	return s.GetUserByID(userID)
+}
+
+// CreateAPIKey creates a key for the workspace, optionally limited to some of its forms, to
+// callers from given IPs or origins, or to a fixed lifetime. Keys without scopes may only submit.
+func (s *AuthService) CreateAPIKey(userID, workspaceID uuid.UUID, req models.CreateAPIKeyRequest) (*models.APIKey, error) {
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = []models.APIKeyScope{models.ScopeSubmit}
+	}
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+		}
+	}
+
+	for _, entry := range req.AllowedIPs {
+		if net.ParseIP(entry) == nil {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return nil, fmt.Errorf("%w: %q is not an IP address or CIDR range", ErrInvalidAPIKeyRequest, entry)
+			}
+		}
+	}
+
+	allowedOrigins := make([]string, 0, len(req.AllowedOrigins))
+	for _, entry := range req.AllowedOrigins {
+		origin := utils.NormalizeOrigin(entry)
+		if origin == "" {
+			return nil, fmt.Errorf("%w: %q is not an origin such as https://example.com", ErrInvalidAPIKeyRequest, entry)
+		}
+		allowedOrigins = append(allowedOrigins, origin)
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyRequest)
+	}
+
+	if err := s.checkWorkspaceForms(workspaceID, req.FormIDs); err != nil {
+		return nil, err
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
 
 	apiKey := &models.APIKey{
-		ID:          uuid.New(),
-		UserID:      userID,
-		Name:        name,
-		KeyHash:     keyHash,
-		Key:         key, // Only shown when created
-		Permissions: "form_submit",
-		RateLimit:   1000, // requests per minute
-		IsActive:    true,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		ID:                  uuid.New(),
+		UserID:              userID,
+		WorkspaceID:         workspaceID,
+		Name:                req.Name,
+		KeyHash:             s.hashAPIKey(key),
+		Key:                 key, // Only shown when created
+		Scopes:              scopes,
+		RateLimit:           1000, // requests per minute
+		FormIDs:             req.FormIDs,
+		DefaultFormFallback: req.DefaultFormFallback,
+		AllowedIPs:          req.AllowedIPs,
+		AllowedOrigins:      allowedOrigins,
+		ExpiresAt:           req.ExpiresAt,
+		IsActive:            true,
+		CreatedAt:           time.Now(),
+		UpdatedAt:           time.Now(),
+	}
+
+	scopesJSON, _ := json.Marshal(apiKey.Scopes)
+	allowedIPsJSON, _ := json.Marshal(apiKey.AllowedIPs)
+	allowedOriginsJSON, _ := json.Marshal(apiKey.AllowedOrigins)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
 	query := `
-		INSERT INTO api_keys (id, user_id, name, key_hash, permissions, rate_limit,
-			is_active, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO api_keys (id, user_id, workspace_id, name, key_hash, scopes, rate_limit,
+			default_form_fallback, allowed_ips, allowed_origins, expires_at, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := s.db.Exec(query,
-		apiKey.ID, apiKey.UserID, apiKey.Name, apiKey.KeyHash,
-		apiKey.Permissions, apiKey.RateLimit, apiKey.IsActive,
-		apiKey.CreatedAt, apiKey.UpdatedAt,
+	_, err = tx.Exec(query,
+		apiKey.ID, apiKey.UserID, apiKey.WorkspaceID, apiKey.Name, apiKey.KeyHash,
+		scopesJSON, apiKey.RateLimit, apiKey.DefaultFormFallback, allowedIPsJSON, allowedOriginsJSON,
+		apiKey.ExpiresAt, apiKey.IsActive, apiKey.CreatedAt, apiKey.UpdatedAt,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create API key: %w", err)
 	}
 
+	for _, formID := range apiKey.FormIDs {
+		if _, err := tx.Exec(`INSERT INTO api_key_forms (api_key_id, form_id) VALUES (?, ?)`, apiKey.ID, formID); err != nil {
+			return nil, fmt.Errorf("failed to scope API key to form: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
 	return apiKey, nil
 }
 
-func (s *AuthService) GetUserAPIKeys(userID uuid.UUID) ([]models.APIKey, error) {
+func (s *AuthService) GetWorkspaceAPIKeys(workspaceID uuid.UUID) ([]models.APIKey, error) {
 	query := `
-		SELECT id, user_id, name, permissions, rate_limit, is_active,
-			last_used_at, created_at, updated_at
-		FROM api_keys WHERE user_id = ? AND is_active = true
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys WHERE workspace_id = ? AND is_active = true
 		ORDER BY created_at DESC
 	`
 
-	rows, err := s.db.Query(query, userID)
+	rows, err := s.db.Query(query, workspaceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get API keys: %w", err)
 	}
@@ -240,28 +708,130 @@ func (s *AuthService) GetUserAPIKeys(userID uuid.UUID) ([]models.APIKey, error)
 
 	var apiKeys []models.APIKey
 	for rows.Next() {
-		var apiKey models.APIKey
-		err := rows.Scan(
-			&apiKey.ID, &apiKey.UserID, &apiKey.Name, &apiKey.Permissions,
-			&apiKey.RateLimit, &apiKey.IsActive, &apiKey.LastUsedAt,
-			&apiKey.CreatedAt, &apiKey.UpdatedAt,
-		)
+		apiKey, err := scanAPIKey(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan API key: %w", err)
 		}
-		apiKeys = append(apiKeys, apiKey)
+		apiKeys = append(apiKeys, *apiKey)
+	}
+	rows.Close()
+
+	for i := range apiKeys {
+		formIDs, err := getAPIKeyFormIDs(s.db, apiKeys[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys[i].FormIDs = formIDs
 	}
 
 	return apiKeys, nil
 }
 
-func (s *AuthService) DeleteAPIKey(keyID uuid.UUID, userID uuid.UUID) error {
+// RotateAPIKey gives the key a new secret. The old secret keeps working for the grace period so
+// integrations can switch over without downtime; rotating again ends any earlier grace period.
+func (s *AuthService) RotateAPIKey(keyID, workspaceID uuid.UUID, gracePeriod time.Duration) (*models.APIKey, error) {
+	if gracePeriod < 0 || gracePeriod > maxAPIKeyGracePeriod {
+		return nil, fmt.Errorf("%w: grace period must be between 0 and %d hours", ErrInvalidAPIKeyRequest, int(maxAPIKeyGracePeriod.Hours()))
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	// MySQL applies assignments left to right, so previous_key_hash receives the old key_hash
+	result, err := s.db.Exec(`
+		UPDATE api_keys SET previous_key_hash = key_hash, previous_key_expires_at = ?, key_hash = ?, updated_at = ?
+		WHERE id = ? AND workspace_id = ? AND is_active = true
+	`, now.Add(gracePeriod), s.hashAPIKey(key), now, keyID, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate API key: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("API key not found or unauthorized")
+	}
+
+	apiKey, err := scanAPIKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	formIDs, err := getAPIKeyFormIDs(s.db, apiKey.ID)
+	if err != nil {
+		return nil, err
+	}
+	apiKey.FormIDs = formIDs
+	apiKey.Key = key // Only shown when rotated
+
+	return apiKey, nil
+}
+
+// AuthenticateAPIKey finds the active, unexpired key and checks the caller's IP and origin against
+// its allow-lists. A key replaced by rotation works until its grace period ends, and keys still
+// stored under the legacy MD5 hash are rehashed on first use.
+func (s *AuthService) AuthenticateAPIKey(key, ipAddress, origin string) (*models.APIKey, error) {
+	keyHash := s.hashAPIKey(key)
+	legacyHash := legacyAPIKeyHash(key)
+	now := time.Now()
+
+	query := `
+		SELECT ` + apiKeyColumns + `, previous_key_hash
+		FROM api_keys
+		WHERE is_active = true
+			AND (key_hash IN (?, ?) OR (previous_key_hash IN (?, ?) AND previous_key_expires_at > ?))
+	`
+
+	var previousHash sql.NullString
+	apiKey, err := scanAPIKey(s.db.QueryRow(query, keyHash, legacyHash, keyHash, legacyHash, now), &previousHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAccessKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt) {
+		return nil, ErrInvalidAccessKey
+	}
+	if !ipAllowed(apiKey.AllowedIPs, ipAddress) || !utils.OriginAllowed(apiKey.AllowedOrigins, origin) {
+		return nil, ErrAPIKeyRestricted
+	}
+
+	formIDs, err := getAPIKeyFormIDs(s.db, apiKey.ID)
+	if err != nil {
+		return nil, err
+	}
+	apiKey.FormIDs = formIDs
+
+	if apiKey.KeyHash == legacyHash || previousHash.String == legacyHash {
+		_, err := s.db.Exec(`
+			UPDATE api_keys
+			SET key_hash = IF(key_hash = ?, ?, key_hash),
+				previous_key_hash = IF(previous_key_hash = ?, ?, previous_key_hash)
+			WHERE id = ?
+		`, legacyHash, keyHash, legacyHash, keyHash, apiKey.ID)
+		if err != nil {
+			log.Printf("Failed to rehash legacy API key %s: %v", apiKey.ID, err)
+		}
+	}
+
+	// Update last used timestamp
+	s.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", now, apiKey.ID)
+
+	return apiKey, nil
+}
+
+func (s *AuthService) DeleteAPIKey(keyID uuid.UUID, workspaceID uuid.UUID) error {
 	query := `
 		UPDATE api_keys SET is_active = false, updated_at = ?
-		WHERE id = ? AND user_id = ?
+		WHERE id = ? AND workspace_id = ?
 	`
 
-	result, err := s.db.Exec(query, time.Now(), keyID, userID)
+	result, err := s.db.Exec(query, time.Now(), keyID, workspaceID)
 	if err != nil {
 		return fmt.Errorf("failed to delete API key: %w", err)
 	}
@@ -274,40 +844,270 @@ func (s *AuthService) DeleteAPIKey(keyID uuid.UUID, userID uuid.UUID) error {
 	return nil
 }
 
-func (s *AuthService) generateTokens(user *models.User) (string, string, error) {
-	// Access token (15 minutes)
-	accessClaims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   user.ID.String(),
-		},
+// hashAPIKey returns the stored form of an API key: an HMAC-SHA256 keyed with the server secret
+func (s *AuthService) hashAPIKey(key string) string {
+	mac := hmac.New(sha256.New, s.apiKeySecret)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// legacyAPIKeyHash is the unsalted MD5 that keys were stored under before HMAC hashing
+func legacyAPIKeyHash(key string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(key)))
+}
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+const apiKeyColumns = `id, user_id, workspace_id, name, key_hash, scopes, rate_limit, default_form_fallback,
+	allowed_ips, allowed_origins, expires_at, previous_key_expires_at, is_active, last_used_at,
+	created_at, updated_at`
+
+// scanAPIKey scans a row selected with apiKeyColumns followed by any extra columns.
+// Errors from Scan are returned unwrapped so callers can check for sql.ErrNoRows.
+func scanAPIKey(row rowScanner, extra ...interface{}) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	var scopes, allowedIPs, allowedOrigins []byte
+
+	dest := []interface{}{
+		&apiKey.ID, &apiKey.UserID, &apiKey.WorkspaceID, &apiKey.Name, &apiKey.KeyHash, &scopes,
+		&apiKey.RateLimit, &apiKey.DefaultFormFallback, &allowedIPs, &allowedOrigins, &apiKey.ExpiresAt,
+		&apiKey.PreviousKeyExpiresAt, &apiKey.IsActive, &apiKey.LastUsedAt, &apiKey.CreatedAt, &apiKey.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(s.jwtSecret)
+	if err := json.Unmarshal(scopes, &apiKey.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to decode API key scopes: %w", err)
+	}
+	if len(allowedIPs) > 0 {
+		if err := json.Unmarshal(allowedIPs, &apiKey.AllowedIPs); err != nil {
+			return nil, fmt.Errorf("failed to decode API key allowed IPs: %w", err)
+		}
+	}
+	if len(allowedOrigins) > 0 {
+		if err := json.Unmarshal(allowedOrigins, &apiKey.AllowedOrigins); err != nil {
+			return nil, fmt.Errorf("failed to decode API key allowed origins: %w", err)
+		}
+	}
+
+	return &apiKey, nil
+}
+
+// ipAllowed reports whether ipAddress matches one of the allowed IPs or CIDR ranges; an empty
+// list allows any address
+func ipAllowed(allowed []string, ipAddress string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return false
+	}
+
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkWorkspaceForms verifies that every form belongs to the workspace
+func (s *AuthService) checkWorkspaceForms(workspaceID uuid.UUID, formIDs []uuid.UUID) error {
+	for _, formID := range formIDs {
+		var exists bool
+		err := s.db.QueryRow(
+			`SELECT EXISTS(SELECT 1 FROM forms WHERE id = ? AND workspace_id = ? AND is_active = true)`, formID, workspaceID,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check form: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("form %s not found in workspace", formID)
+		}
+	}
+	return nil
+}
+
+// startSession records a new session for the user and returns its first token pair
+func (s *AuthService) startSession(user *models.User, userAgent, ipAddress string) (*models.AuthResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	sessionID := uuid.New()
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO user_sessions (id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sessionID, user.ID, userAgent, ipAddress, now, now, now.Add(refreshTokenTTL),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	refreshToken, _, err := s.issueRefreshToken(tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit session: %w", err)
+	}
+
+	accessToken, err := s.generateAccessToken(user, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	// Clear password before returning
+	user.Password = ""
+
+	return &models.AuthResponse{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// issueRefreshToken stores a new opaque refresh token for the session. Only its hash is kept.
+func (s *AuthService) issueRefreshToken(tx *sql.Tx, sessionID uuid.UUID) (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	now := time.Now()
+	expiresAt := now.Add(refreshTokenTTL)
+	_, err := tx.Exec(`
+		INSERT INTO refresh_tokens (token_hash, session_id, expires_at, created_at)
+		VALUES (?, ?, ?, ?)`,
+		hashRefreshToken(token), sessionID, expiresAt, now,
+	)
 	if err != nil {
-		return "", "", err
+		return "", time.Time{}, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return token, expiresAt, nil
+}
+
+func (s *AuthService) generateAccessToken(user *models.User, sessionID uuid.UUID) (string, error) {
+	var workspaceID uuid.UUID
+	if user.CurrentWorkspaceID != nil {
+		workspaceID = *user.CurrentWorkspaceID
 	}
 
-	// Refresh token (7 days)
-	refreshClaims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+	claims := &Claims{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Role:        user.Role,
+		WorkspaceID: workspaceID,
+		SessionID:   sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   user.ID.String(),
 		},
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(s.jwtSecret)
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+}
+
+// revokeSession marks the session revoked and remembers it in Redis for as long as its
+// access tokens can still be valid, so ValidateToken can reject them without a database hit
+func (s *AuthService) revokeSession(sessionID uuid.UUID) error {
+	_, err := s.db.Exec(
+		`UPDATE user_sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now(), sessionID,
+	)
 	if err != nil {
-		return "", "", err
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	if err := s.redis.Set(context.Background(), revokedSessionKey(sessionID), 1, accessTokenTTL).Err(); err != nil {
+		return fmt.Errorf("failed to cache revoked session: %w", err)
+	}
+
+	return nil
+}
+
+// isSessionRevoked checks the Redis revocation list, falling back to the session row when Redis
+// is unavailable. Tokens are rejected when neither can be read.
+func (s *AuthService) isSessionRevoked(sessionID uuid.UUID) bool {
+	exists, err := s.redis.Exists(context.Background(), revokedSessionKey(sessionID)).Result()
+	if err == nil {
+		return exists > 0
 	}
+	log.Printf("Failed to check revoked session %s in Redis, checking the database: %v", sessionID, err)
 
-	return accessTokenString, refreshTokenString, nil
-}
\ No newline at end of file
+	var revokedAt sql.NullTime
+	err = s.db.QueryRow(`SELECT revoked_at FROM user_sessions WHERE id = ?`, sessionID).Scan(&revokedAt)
+	if err == sql.ErrNoRows {
+		return true // Sessions are only deleted well after they expired or were revoked
+	}
+	if err != nil {
+		log.Printf("Failed to check revoked session %s: %v", sessionID, err)
+		return true
+	}
+	return revokedAt.Valid
+}
+
+// resolveWorkspace sets the workspace the user's tokens are scoped to
+func (s *AuthService) resolveWorkspace(user *models.User) error {
+	workspaceID, err := s.workspaceService.DefaultWorkspace(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+	user.CurrentWorkspaceID = &workspaceID
+	return nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func revokedSessionKey(sessionID uuid.UUID) string {
+	return fmt.Sprintf("auth:revoked_session:%s", sessionID)
+}
+
+func userRoleKey(userID uuid.UUID) string {
+	return fmt.Sprintf("auth:user_role:%s", userID)
+}
+
+// getAPIKeyFormIDs returns the forms a key is scoped to; none means every form in its workspace
+func getAPIKeyFormIDs(db *sql.DB, apiKeyID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := db.Query(`SELECT form_id FROM api_key_forms WHERE api_key_id = ?`, apiKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key forms: %w", err)
+	}
+	defer rows.Close()
+
+	formIDs := []uuid.UUID{}
+	for rows.Next() {
+		var formID uuid.UUID
+		if err := rows.Scan(&formID); err != nil {
+			return nil, fmt.Errorf("failed to scan API key form: %w", err)
+		}
+		formIDs = append(formIDs, formID)
+	}
+
+	return formIDs, rows.Err()
+}
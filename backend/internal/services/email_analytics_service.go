@@ -11,7 +11,8 @@ import (
 )
 
 type EmailAnalyticsService struct {
-	db *sql.DB
+	db             *sql.DB
+	webhookService *EnhancedWebhookService
 }
 
 type AnalyticsReport struct {
@@ -87,6 +88,11 @@ func NewEmailAnalyticsService(db *sql.DB) *EmailAnalyticsService {
 	}
 }
 
+// SetWebhookService sets the service email.opened events are published through
+func (s *EmailAnalyticsService) SetWebhookService(webhookService *EnhancedWebhookService) {
+	s.webhookService = webhookService
+}
+
 // CreateAnalytics creates a new analytics entry
 func (s *EmailAnalyticsService) CreateAnalytics(analytics *models.EmailAnalytics) error {
 	if analytics.ID == uuid.Nil {
@@ -128,13 +134,15 @@ func (s *EmailAnalyticsService) RecordEmailOpen(queueID uuid.UUID, userAgent, ip
 	now := time.Now()
 
 	// Get analytics record
-	query := `SELECT id, open_count, opened_at FROM email_analytics WHERE queue_id = ?`
+	query := `SELECT id, form_id, email_address, open_count, opened_at FROM email_analytics WHERE queue_id = ?`
 	
 	var analyticsID uuid.UUID
+	var formID sql.NullString
+	var emailAddress string
 	var openCount int
 	var openedAt sql.NullTime
 
-	err := s.db.QueryRow(query, queueID).Scan(&analyticsID, &openCount, &openedAt)
+	err := s.db.QueryRow(query, queueID).Scan(&analyticsID, &formID, &emailAddress, &openCount, &openedAt)
 	if err != nil {
 		return fmt.Errorf("failed to get analytics record: %w", err)
 	}
@@ -154,6 +162,14 @@ func (s *EmailAnalyticsService) RecordEmailOpen(queueID uuid.UUID, userAgent, ip
 		return fmt.Errorf("failed to record email open: %w", err)
 	}
 
+	// Only the first open is an event; later ones just bump the count
+	if !openedAt.Valid && formID.Valid {
+		if id, err := uuid.Parse(formID.String); err == nil {
+			publishWebhookEvent(s.webhookService, id, WebhookEventEmailOpened, "email:"+analyticsID.String()+":opened",
+				emailOpenedEventData(queueID, emailAddress, userAgent, now.UTC()))
+		}
+	}
+
 	return nil
 }
 
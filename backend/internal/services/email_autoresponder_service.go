@@ -404,7 +404,7 @@ func (s *EmailAutoresponderService) ProcessAutoresponders(submission *models.Sub
 				providerID = *autoresponder.ProviderID
 			} else {
 				// Use default provider
-				defaultProvider, err := s.providerService.GetDefaultProvider(user.ID)
+				defaultProvider, err := s.providerService.GetDefaultProvider(form.WorkspaceID)
 				if err != nil {
 					continue // Skip if no provider available
 				}
@@ -417,7 +417,7 @@ func (s *EmailAutoresponderService) ProcessAutoresponders(submission *models.Sub
 				UserID:       user.ID,
 				FormID:       &submission.FormID,
 				SubmissionID: &submission.ID,
-				TemplateID:   autoresponder.TemplateID,
+				TemplateID:   &autoresponder.TemplateID,
 				ProviderID:   &providerID,
 				ToEmails:     []string{recipientEmail},
 				CCEmails:     autoresponder.CCEmails,
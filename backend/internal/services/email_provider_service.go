@@ -17,7 +17,8 @@ import (
 )
 
 type EmailProviderService struct {
-	db *sql.DB
+	db               *sql.DB
+	workspaceService *WorkspaceService
 }
 
 type EmailMessage struct {
@@ -356,8 +357,17 @@ func NewEmailProviderService(db *sql.DB) *EmailProviderService {
 	}
 }
 
+func (s *EmailProviderService) SetWorkspaceService(workspaceService *WorkspaceService) {
+	s.workspaceService = workspaceService
+}
+
 // CreateProvider creates a new email provider configuration
-func (s *EmailProviderService) CreateProvider(userID uuid.UUID, req models.CreateEmailProviderRequest) (*models.EmailProvider, error) {
+func (s *EmailProviderService) CreateProvider(userID, workspaceID uuid.UUID, req models.CreateEmailProviderRequest) (*models.EmailProvider, error) {
+	// Provider credentials are shared by the whole workspace
+	if err := s.workspaceService.Authorize(workspaceID, userID, models.WorkspaceRoleAdmin); err != nil {
+		return nil, err
+	}
+
 	// Create provider instance to validate configuration
 	provider, err := s.createProviderInstance(req.Type, req.Config)
 	if err != nil {
@@ -376,33 +386,34 @@ func (s *EmailProviderService) CreateProvider(userID uuid.UUID, req models.Creat
 
 	// If this is set as default, unset other defaults
 	if req.IsDefault {
-		if err := s.unsetDefaultProvider(userID); err != nil {
+		if err := s.unsetDefaultProvider(workspaceID); err != nil {
 			return nil, fmt.Errorf("failed to unset previous default: %w", err)
 		}
 	}
 
 	// Create provider record
 	providerRecord := &models.EmailProvider{
-		ID:        uuid.New(),
-		UserID:    userID,
-		Name:      req.Name,
-		Type:      req.Type,
-		Config:    req.Config,
-		IsActive:  true,
-		IsDefault: req.IsDefault,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:          uuid.New(),
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		Name:        req.Name,
+		Type:        req.Type,
+		Config:      req.Config,
+		IsActive:    true,
+		IsDefault:   req.IsDefault,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 
 	// Insert into database
 	configJSON, _ := json.Marshal(providerRecord.Config)
 	query := `
 		INSERT INTO email_providers (
-			id, user_id, name, type, config, is_active, is_default, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			id, user_id, workspace_id, name, type, config, is_active, is_default, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	_, err = s.db.Exec(query,
-		providerRecord.ID, providerRecord.UserID, providerRecord.Name,
+		providerRecord.ID, providerRecord.UserID, providerRecord.WorkspaceID, providerRecord.Name,
 		providerRecord.Type, configJSON, providerRecord.IsActive,
 		providerRecord.IsDefault, providerRecord.CreatedAt, providerRecord.UpdatedAt,
 	)
@@ -413,18 +424,18 @@ func (s *EmailProviderService) CreateProvider(userID uuid.UUID, req models.Creat
 	return providerRecord, nil
 }
 
-// GetProvider retrieves a provider by ID
+// GetProvider retrieves a provider by ID if the user can view its workspace
 func (s *EmailProviderService) GetProvider(userID, providerID uuid.UUID) (*models.EmailProvider, error) {
 	query := `
-		SELECT id, user_id, name, type, config, is_active, is_default, created_at, updated_at
+		SELECT id, user_id, workspace_id, name, type, config, is_active, is_default, created_at, updated_at
 		FROM email_providers 
-		WHERE id = ? AND user_id = ?`
+		WHERE id = ?`
 
 	var provider models.EmailProvider
 	var configJSON []byte
 
-	err := s.db.QueryRow(query, providerID, userID).Scan(
-		&provider.ID, &provider.UserID, &provider.Name, &provider.Type,
+	err := s.db.QueryRow(query, providerID).Scan(
+		&provider.ID, &provider.UserID, &provider.WorkspaceID, &provider.Name, &provider.Type,
 		&configJSON, &provider.IsActive, &provider.IsDefault,
 		&provider.CreatedAt, &provider.UpdatedAt,
 	)
@@ -432,6 +443,10 @@ func (s *EmailProviderService) GetProvider(userID, providerID uuid.UUID) (*model
 		return nil, fmt.Errorf("failed to get provider: %w", err)
 	}
 
+	if err := s.workspaceService.Authorize(provider.WorkspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
 	// Parse config JSON
 	if len(configJSON) > 0 {
 		json.Unmarshal(configJSON, &provider.Config)
@@ -440,15 +455,15 @@ func (s *EmailProviderService) GetProvider(userID, providerID uuid.UUID) (*model
 	return &provider, nil
 }
 
-// ListProviders retrieves all providers for a user
-func (s *EmailProviderService) ListProviders(userID uuid.UUID) ([]models.EmailProvider, error) {
+// ListProviders retrieves all providers for a workspace
+func (s *EmailProviderService) ListProviders(workspaceID uuid.UUID) ([]models.EmailProvider, error) {
 	query := `
-		SELECT id, user_id, name, type, config, is_active, is_default, created_at, updated_at
+		SELECT id, user_id, workspace_id, name, type, config, is_active, is_default, created_at, updated_at
 		FROM email_providers 
-		WHERE user_id = ? AND is_active = true
+		WHERE workspace_id = ? AND is_active = true
 		ORDER BY is_default DESC, created_at DESC`
 
-	rows, err := s.db.Query(query, userID)
+	rows, err := s.db.Query(query, workspaceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list providers: %w", err)
 	}
@@ -460,7 +475,7 @@ func (s *EmailProviderService) ListProviders(userID uuid.UUID) ([]models.EmailPr
 		var configJSON []byte
 
 		err := rows.Scan(
-			&provider.ID, &provider.UserID, &provider.Name, &provider.Type,
+			&provider.ID, &provider.UserID, &provider.WorkspaceID, &provider.Name, &provider.Type,
 			&configJSON, &provider.IsActive, &provider.IsDefault,
 			&provider.CreatedAt, &provider.UpdatedAt,
 		)
@@ -479,18 +494,18 @@ func (s *EmailProviderService) ListProviders(userID uuid.UUID) ([]models.EmailPr
 	return providers, nil
 }
 
-// GetDefaultProvider gets the default provider for a user
-func (s *EmailProviderService) GetDefaultProvider(userID uuid.UUID) (*models.EmailProvider, error) {
+// GetDefaultProvider gets the default provider for a workspace
+func (s *EmailProviderService) GetDefaultProvider(workspaceID uuid.UUID) (*models.EmailProvider, error) {
 	query := `
-		SELECT id, user_id, name, type, config, is_active, is_default, created_at, updated_at
+		SELECT id, user_id, workspace_id, name, type, config, is_active, is_default, created_at, updated_at
 		FROM email_providers 
-		WHERE user_id = ? AND is_default = true AND is_active = true`
+		WHERE workspace_id = ? AND is_default = true AND is_active = true`
 
 	var provider models.EmailProvider
 	var configJSON []byte
 
-	err := s.db.QueryRow(query, userID).Scan(
-		&provider.ID, &provider.UserID, &provider.Name, &provider.Type,
+	err := s.db.QueryRow(query, workspaceID).Scan(
+		&provider.ID, &provider.UserID, &provider.WorkspaceID, &provider.Name, &provider.Type,
 		&configJSON, &provider.IsActive, &provider.IsDefault,
 		&provider.CreatedAt, &provider.UpdatedAt,
 	)
@@ -508,6 +523,11 @@ func (s *EmailProviderService) GetDefaultProvider(userID uuid.UUID) (*models.Ema
 
 // UpdateProvider updates an existing provider
 func (s *EmailProviderService) UpdateProvider(userID, providerID uuid.UUID, req models.CreateEmailProviderRequest) (*models.EmailProvider, error) {
+	workspaceID, err := s.authorizeProvider(userID, providerID, models.WorkspaceRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create provider instance to validate configuration
 	provider, err := s.createProviderInstance(req.Type, req.Config)
 	if err != nil {
@@ -521,7 +541,7 @@ func (s *EmailProviderService) UpdateProvider(userID, providerID uuid.UUID, req
 
 	// If this is set as default, unset other defaults
 	if req.IsDefault {
-		if err := s.unsetDefaultProvider(userID); err != nil {
+		if err := s.unsetDefaultProvider(workspaceID); err != nil {
 			return nil, fmt.Errorf("failed to unset previous default: %w", err)
 		}
 	}
@@ -531,11 +551,11 @@ func (s *EmailProviderService) UpdateProvider(userID, providerID uuid.UUID, req
 	query := `
 		UPDATE email_providers SET
 			name = ?, type = ?, config = ?, is_default = ?, updated_at = ?
-		WHERE id = ? AND user_id = ?`
+		WHERE id = ?`
 
 	_, err = s.db.Exec(query,
 		req.Name, req.Type, configJSON, req.IsDefault, time.Now(),
-		providerID, userID,
+		providerID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update provider: %w", err)
@@ -546,9 +566,13 @@ func (s *EmailProviderService) UpdateProvider(userID, providerID uuid.UUID, req
 
 // DeleteProvider soft deletes a provider
 func (s *EmailProviderService) DeleteProvider(userID, providerID uuid.UUID) error {
-	query := `UPDATE email_providers SET is_active = false, updated_at = ? WHERE id = ? AND user_id = ?`
+	if _, err := s.authorizeProvider(userID, providerID, models.WorkspaceRoleAdmin); err != nil {
+		return err
+	}
+
+	query := `UPDATE email_providers SET is_active = false, updated_at = ? WHERE id = ?`
 	
-	_, err := s.db.Exec(query, time.Now(), providerID, userID)
+	_, err := s.db.Exec(query, time.Now(), providerID)
 	if err != nil {
 		return fmt.Errorf("failed to delete provider: %w", err)
 	}
@@ -618,12 +642,27 @@ func (s *EmailProviderService) createProviderInstance(providerType models.EmailP
 	}
 }
 
-func (s *EmailProviderService) unsetDefaultProvider(userID uuid.UUID) error {
-	query := `UPDATE email_providers SET is_default = false WHERE user_id = ? AND is_default = true`
-	_, err := s.db.Exec(query, userID)
+func (s *EmailProviderService) unsetDefaultProvider(workspaceID uuid.UUID) error {
+	query := `UPDATE email_providers SET is_default = false WHERE workspace_id = ? AND is_default = true`
+	_, err := s.db.Exec(query, workspaceID)
 	return err
 }
 
+// authorizeProvider checks the user's role in the provider's workspace and returns that workspace
+func (s *EmailProviderService) authorizeProvider(userID, providerID uuid.UUID, min models.WorkspaceRole) (uuid.UUID, error) {
+	var workspaceID uuid.UUID
+	err := s.db.QueryRow("SELECT workspace_id FROM email_providers WHERE id = ?", providerID).Scan(&workspaceID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get provider: %w", err)
+	}
+
+	if err := s.workspaceService.Authorize(workspaceID, userID, min); err != nil {
+		return uuid.Nil, err
+	}
+
+	return workspaceID, nil
+}
+
 func (s *EmailProviderService) testProvider(provider EmailProvider) error {
 	// For now, just validate config - in practice you might send a test email
 	return provider.ValidateConfig()
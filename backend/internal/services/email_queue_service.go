@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"formhub/internal/models"
+	"formhub/pkg/email"
 	"log"
 	"sync"
 	"time"
@@ -16,10 +17,12 @@ type EmailQueueService struct {
 	db              *sql.DB
 	providerService *EmailProviderService
 	analyticsService *EmailAnalyticsService
+	systemSender    *email.SMTPService
 	isProcessing    bool
 	processingMux   sync.RWMutex
 	stopChan        chan bool
 	config          QueueConfig
+	webhookService  *EnhancedWebhookService
 }
 
 type QueueConfig struct {
@@ -98,23 +101,43 @@ func (s *EmailQueueService) QueueEmail(email *models.EmailQueue) error {
 		INSERT INTO email_queue (
 			id, user_id, form_id, submission_id, template_id, provider_id,
 			to_emails, cc_emails, bcc_emails, subject, html_content, text_content,
-			variables, scheduled_at, status, attempts, priority, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			variables, scheduled_at, status, attempts, priority, idempotency_key,
+			created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	var idempotencyKey sql.NullString
+	if email.IdempotencyKey != "" {
+		idempotencyKey = sql.NullString{String: email.IdempotencyKey, Valid: true}
+	}
 
 	_, err := s.db.Exec(query,
 		email.ID, email.UserID, email.FormID, email.SubmissionID,
 		email.TemplateID, email.ProviderID, toEmailsJSON, ccEmailsJSON,
 		bccEmailsJSON, email.Subject, email.HTMLContent, email.TextContent,
 		variablesJSON, email.ScheduledAt, email.Status, email.Attempts,
-		email.Priority, email.CreatedAt, email.UpdatedAt,
+		email.Priority, idempotencyKey, email.CreatedAt, email.UpdatedAt,
 	)
 	if err != nil {
+		// A redelivered message with the same idempotency key is already queued
+		if idempotencyKey.Valid && s.isQueued(email.IdempotencyKey) {
+			return nil
+		}
 		return fmt.Errorf("failed to queue email: %w", err)
 	}
 
 	return nil
 }
 
+// SetSystemSender sets the SMTP service used for queued emails when the user has no email provider
+func (s *EmailQueueService) SetSystemSender(sender *email.SMTPService) {
+	s.systemSender = sender
+}
+
+// SetWebhookService sets the service email.failed events are published through
+func (s *EmailQueueService) SetWebhookService(webhookService *EnhancedWebhookService) {
+	s.webhookService = webhookService
+}
+
 // GetQueuedEmail retrieves a queued email by ID
 func (s *EmailQueueService) GetQueuedEmail(queueID uuid.UUID) (*models.EmailQueue, error) {
 	query := `
@@ -125,13 +148,13 @@ func (s *EmailQueueService) GetQueuedEmail(queueID uuid.UUID) (*models.EmailQueu
 		FROM email_queue WHERE id = ?`
 
 	var email models.EmailQueue
-	var formID, submissionID, providerID sql.NullString
+	var formID, submissionID, templateID, providerID sql.NullString
 	var sentAt sql.NullTime
 	var toEmailsJSON, ccEmailsJSON, bccEmailsJSON, variablesJSON []byte
 
 	err := s.db.QueryRow(query, queueID).Scan(
 		&email.ID, &email.UserID, &formID, &submissionID,
-		&email.TemplateID, &providerID, &toEmailsJSON, &ccEmailsJSON,
+		&templateID, &providerID, &toEmailsJSON, &ccEmailsJSON,
 		&bccEmailsJSON, &email.Subject, &email.HTMLContent, &email.TextContent,
 		&variablesJSON, &email.ScheduledAt, &sentAt, &email.Status,
 		&email.Attempts, &email.LastError, &email.Priority,
@@ -152,6 +175,11 @@ func (s *EmailQueueService) GetQueuedEmail(queueID uuid.UUID) (*models.EmailQueu
 			email.SubmissionID = &sid
 		}
 	}
+	if templateID.Valid {
+		if tid, err := uuid.Parse(templateID.String); err == nil {
+			email.TemplateID = &tid
+		}
+	}
 	if providerID.Valid {
 		if pid, err := uuid.Parse(providerID.String); err == nil {
 			email.ProviderID = &pid
@@ -220,13 +248,13 @@ func (s *EmailQueueService) ListQueuedEmails(userID *uuid.UUID, status *models.E
 	var emails []models.EmailQueue
 	for rows.Next() {
 		var email models.EmailQueue
-		var formID, submissionID, providerID sql.NullString
+		var formID, submissionID, templateID, providerID sql.NullString
 		var sentAt sql.NullTime
 		var toEmailsJSON, ccEmailsJSON, bccEmailsJSON, variablesJSON []byte
 
 		err := rows.Scan(
 			&email.ID, &email.UserID, &formID, &submissionID,
-			&email.TemplateID, &providerID, &toEmailsJSON, &ccEmailsJSON,
+			&templateID, &providerID, &toEmailsJSON, &ccEmailsJSON,
 			&bccEmailsJSON, &email.Subject, &email.HTMLContent, &email.TextContent,
 			&variablesJSON, &email.ScheduledAt, &sentAt, &email.Status,
 			&email.Attempts, &email.LastError, &email.Priority,
@@ -247,6 +275,11 @@ func (s *EmailQueueService) ListQueuedEmails(userID *uuid.UUID, status *models.E
 				email.SubmissionID = &sid
 			}
 		}
+		if templateID.Valid {
+			if tid, err := uuid.Parse(templateID.String); err == nil {
+				email.TemplateID = &tid
+			}
+		}
 		if providerID.Valid {
 			if pid, err := uuid.Parse(providerID.String); err == nil {
 				email.ProviderID = &pid
@@ -571,9 +604,12 @@ func (s *EmailQueueService) processEmail(emailID uuid.UUID) bool {
 	if email.ProviderID != nil {
 		providerID = *email.ProviderID
 	} else {
-		// Try to get default provider for user
-		defaultProvider, err := s.providerService.GetDefaultProvider(email.UserID)
+		// Try to get the default provider for the email's workspace
+		defaultProvider, err := s.providerService.GetDefaultProvider(s.emailWorkspace(email))
 		if err != nil {
+			if s.systemSender != nil {
+				return s.processSystemEmail(email)
+			}
 			s.UpdateEmailStatus(emailID, models.EmailStatusFailed, "No email provider configured")
 			return false
 		}
@@ -600,41 +636,27 @@ func (s *EmailQueueService) processEmail(emailID uuid.UUID) bool {
 	// Send email
 	result, err := s.providerService.SendEmail(providerID, message)
 	if err != nil {
-		s.IncrementAttempts(emailID)
-		s.UpdateEmailStatus(emailID, models.EmailStatusFailed, err.Error())
-		
-		// Schedule retry if we haven't exceeded max attempts
-		if email.Attempts < s.config.RetryAttempts {
-			s.RetryFailedEmail(emailID)
-		}
-		
+		s.failEmail(email, err.Error())
 		return false
 	}
 
 	if !result.Success {
-		s.IncrementAttempts(emailID)
-		s.UpdateEmailStatus(emailID, models.EmailStatusFailed, result.Error)
-		
-		// Schedule retry if we haven't exceeded max attempts
-		if email.Attempts < s.config.RetryAttempts {
-			s.RetryFailedEmail(emailID)
-		}
-		
+		s.failEmail(email, result.Error)
 		return false
 	}
 
 	// Mark as sent
-	s.UpdateEmailStatus(emailID, models.EmailStatusSent, "")
+	s.markSent(email)
 
 	// Create analytics entries for tracking
-	if s.analyticsService != nil {
+	if s.analyticsService != nil && email.TemplateID != nil {
 		for _, recipient := range email.ToEmails {
 			analytics := &models.EmailAnalytics{
 				ID:           uuid.New(),
 				QueueID:      email.ID,
 				UserID:       email.UserID,
 				FormID:       email.FormID,
-				TemplateID:   email.TemplateID,
+				TemplateID:   *email.TemplateID,
 				EmailAddress: recipient,
 				DeliveredAt:  timePtr(time.Now()),
 				CreatedAt:    time.Now(),
@@ -647,6 +669,93 @@ func (s *EmailQueueService) processEmail(emailID uuid.UUID) bool {
 	return true
 }
 
+// processSystemEmail sends a queued email through the system SMTP service
+func (s *EmailQueueService) processSystemEmail(queued *models.EmailQueue) bool {
+	err := s.systemSender.Send(email.Message{
+		ToEmails:    queued.ToEmails,
+		CCEmails:    queued.CCEmails,
+		BCCEmails:   queued.BCCEmails,
+		Subject:     queued.Subject,
+		HTMLContent: queued.HTMLContent,
+		TextContent: queued.TextContent,
+	})
+	if err != nil {
+		s.failEmail(queued, err.Error())
+		return false
+	}
+
+	s.markSent(queued)
+	return true
+}
+
+// markSent records a sent email. When it is a submission notification from the outbox, the
+// submission is flagged email_sent now that the notification has actually gone out.
+func (s *EmailQueueService) markSent(queued *models.EmailQueue) {
+	s.UpdateEmailStatus(queued.ID, models.EmailStatusSent, "")
+
+	if queued.SubmissionID == nil {
+		return
+	}
+
+	query := `
+		UPDATE submissions s
+		JOIN submission_outbox o ON o.submission_id = s.id AND o.kind = ?
+		JOIN email_queue q ON q.idempotency_key = o.idempotency_key
+		SET s.email_sent = true
+		WHERE q.id = ?`
+	if _, err := s.db.Exec(query, models.OutboxKindEmail, queued.ID); err != nil {
+		log.Printf("Failed to flag submission %s email sent: %v", queued.SubmissionID, err)
+	}
+}
+
+// failEmail records a failed send attempt and schedules a retry. Once the email is out of
+// retries its failure is reported to the form's webhooks.
+func (s *EmailQueueService) failEmail(queued *models.EmailQueue, reason string) {
+	s.IncrementAttempts(queued.ID)
+	s.UpdateEmailStatus(queued.ID, models.EmailStatusFailed, reason)
+
+	// Schedule retry if we haven't exceeded max attempts
+	attempts := queued.Attempts + 1
+	if attempts < s.config.RetryAttempts {
+		s.RetryFailedEmail(queued.ID)
+		return
+	}
+
+	if queued.FormID == nil {
+		return
+	}
+
+	publishWebhookEvent(s.webhookService, *queued.FormID, WebhookEventEmailFailed, "email:"+queued.ID.String()+":failed",
+		emailFailedEventData(queued, attempts, reason))
+}
+
+// isQueued reports whether an email with the given idempotency key already exists
+func (s *EmailQueueService) isQueued(idempotencyKey string) bool {
+	var id uuid.UUID
+	err := s.db.QueryRow(`SELECT id FROM email_queue WHERE idempotency_key = ?`, idempotencyKey).Scan(&id)
+	return err == nil
+}
+
+// emailWorkspace resolves the workspace whose providers send an email: the form's workspace
+// when the email belongs to a form, otherwise the sender's current workspace
+func (s *EmailQueueService) emailWorkspace(queued *models.EmailQueue) uuid.UUID {
+	var workspaceID uuid.UUID
+	if queued.FormID != nil {
+		if err := s.db.QueryRow(`SELECT workspace_id FROM forms WHERE id = ?`, *queued.FormID).Scan(&workspaceID); err == nil {
+			return workspaceID
+		}
+	}
+
+	var current sql.NullString
+	if err := s.db.QueryRow(`SELECT current_workspace_id FROM users WHERE id = ?`, queued.UserID).Scan(&current); err == nil && current.Valid {
+		if id, err := uuid.Parse(current.String); err == nil {
+			return id
+		}
+	}
+
+	return uuid.Nil
+}
+
 // Helper function
 func timePtr(t time.Time) *time.Time {
 	return &t
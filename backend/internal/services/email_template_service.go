@@ -16,7 +16,8 @@ import (
 )
 
 type EmailTemplateService struct {
-	db *sql.DB
+	db               *sql.DB
+	workspaceService *WorkspaceService
 }
 
 type TemplateVariable struct {
@@ -61,8 +62,16 @@ func NewEmailTemplateService(db *sql.DB) *EmailTemplateService {
 	}
 }
 
+func (s *EmailTemplateService) SetWorkspaceService(workspaceService *WorkspaceService) {
+	s.workspaceService = workspaceService
+}
+
 // CreateTemplate creates a new email template
-func (s *EmailTemplateService) CreateTemplate(userID uuid.UUID, req models.CreateEmailTemplateRequest) (*models.EmailTemplate, error) {
+func (s *EmailTemplateService) CreateTemplate(userID, workspaceID uuid.UUID, req models.CreateEmailTemplateRequest) (*models.EmailTemplate, error) {
+	if err := s.workspaceService.Authorize(workspaceID, userID, models.WorkspaceRoleMember); err != nil {
+		return nil, err
+	}
+
 	// Validate template content
 	validation := s.ValidateTemplate(req.HTMLContent, req.TextContent, req.Subject)
 	if !validation.IsValid {
@@ -72,6 +81,7 @@ func (s *EmailTemplateService) CreateTemplate(userID uuid.UUID, req models.Creat
 	template := &models.EmailTemplate{
 		ID:          uuid.New(),
 		UserID:      userID,
+		WorkspaceID: workspaceID,
 		FormID:      req.FormID,
 		Name:        req.Name,
 		Description: req.Description,
@@ -108,16 +118,16 @@ func (s *EmailTemplateService) CreateTemplate(userID uuid.UUID, req models.Creat
 	// Insert into database
 	query := `
 		INSERT INTO email_templates (
-			id, user_id, form_id, name, description, type, language,
+			id, user_id, workspace_id, form_id, name, description, type, language,
 			subject, html_content, text_content, variables, parent_id,
 			is_active, is_default, version, tags, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	variablesJSON, _ := json.Marshal(template.Variables)
 	tagsJSON, _ := json.Marshal(template.Tags)
 
 	_, err := s.db.Exec(query,
-		template.ID, template.UserID, template.FormID, template.Name,
+		template.ID, template.UserID, template.WorkspaceID, template.FormID, template.Name,
 		template.Description, template.Type, template.Language,
 		template.Subject, template.HTMLContent, template.TextContent,
 		variablesJSON, template.ParentID, template.IsActive,
@@ -131,21 +141,21 @@ func (s *EmailTemplateService) CreateTemplate(userID uuid.UUID, req models.Creat
 	return template, nil
 }
 
-// GetTemplate retrieves a template by ID
+// GetTemplate retrieves a template by ID if the user can view its workspace
 func (s *EmailTemplateService) GetTemplate(userID, templateID uuid.UUID) (*models.EmailTemplate, error) {
 	query := `
-		SELECT id, user_id, form_id, name, description, type, language,
+		SELECT id, user_id, workspace_id, form_id, name, description, type, language,
 		       subject, html_content, text_content, variables, parent_id,
 		       is_active, is_default, version, tags, created_at, updated_at
 		FROM email_templates 
-		WHERE id = ? AND user_id = ?`
+		WHERE id = ?`
 
 	var template models.EmailTemplate
 	var formID, parentID sql.NullString
 	var variablesJSON, tagsJSON []byte
 
-	err := s.db.QueryRow(query, templateID, userID).Scan(
-		&template.ID, &template.UserID, &formID, &template.Name,
+	err := s.db.QueryRow(query, templateID).Scan(
+		&template.ID, &template.UserID, &template.WorkspaceID, &formID, &template.Name,
 		&template.Description, &template.Type, &template.Language,
 		&template.Subject, &template.HTMLContent, &template.TextContent,
 		&variablesJSON, &parentID, &template.IsActive,
@@ -156,6 +166,10 @@ func (s *EmailTemplateService) GetTemplate(userID, templateID uuid.UUID) (*model
 		return nil, fmt.Errorf("failed to get template: %w", err)
 	}
 
+	if err := s.workspaceService.Authorize(template.WorkspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
 	// Parse optional fields
 	if formID.Valid {
 		if fid, err := uuid.Parse(formID.String); err == nil {
@@ -179,16 +193,16 @@ func (s *EmailTemplateService) GetTemplate(userID, templateID uuid.UUID) (*model
 	return &template, nil
 }
 
-// ListTemplates retrieves templates for a user with filtering
-func (s *EmailTemplateService) ListTemplates(userID uuid.UUID, templateType *models.EmailTemplateType, formID *uuid.UUID, language *string) ([]models.EmailTemplate, error) {
+// ListTemplates retrieves templates for a workspace with filtering
+func (s *EmailTemplateService) ListTemplates(workspaceID uuid.UUID, templateType *models.EmailTemplateType, formID *uuid.UUID, language *string) ([]models.EmailTemplate, error) {
 	query := `
-		SELECT id, user_id, form_id, name, description, type, language,
+		SELECT id, user_id, workspace_id, form_id, name, description, type, language,
 		       subject, html_content, text_content, variables, parent_id,
 		       is_active, is_default, version, tags, created_at, updated_at
 		FROM email_templates 
-		WHERE user_id = ? AND is_active = true`
+		WHERE workspace_id = ? AND is_active = true`
 	
-	args := []interface{}{userID}
+	args := []interface{}{workspaceID}
 
 	if templateType != nil {
 		query += " AND type = ?"
@@ -220,7 +234,7 @@ func (s *EmailTemplateService) ListTemplates(userID uuid.UUID, templateType *mod
 		var variablesJSON, tagsJSON []byte
 
 		err := rows.Scan(
-			&template.ID, &template.UserID, &formID, &template.Name,
+			&template.ID, &template.UserID, &template.WorkspaceID, &formID, &template.Name,
 			&template.Description, &template.Type, &template.Language,
 			&template.Subject, &template.HTMLContent, &template.TextContent,
 			&variablesJSON, &parentID, &template.IsActive,
@@ -617,6 +631,10 @@ func (s *EmailTemplateService) generateVariableDescription(varName string) strin
 
 // UpdateTemplate updates an existing template
 func (s *EmailTemplateService) UpdateTemplate(userID, templateID uuid.UUID, req models.CreateEmailTemplateRequest) (*models.EmailTemplate, error) {
+	if err := s.authorizeTemplate(userID, templateID, models.WorkspaceRoleMember); err != nil {
+		return nil, err
+	}
+
 	// Validate template content
 	validation := s.ValidateTemplate(req.HTMLContent, req.TextContent, req.Subject)
 	if !validation.IsValid {
@@ -641,7 +659,7 @@ func (s *EmailTemplateService) UpdateTemplate(userID, templateID uuid.UUID, req
 			name = ?, description = ?, type = ?, language = ?,
 			subject = ?, html_content = ?, text_content = ?, variables = ?,
 			parent_id = ?, tags = ?, updated_at = ?
-		WHERE id = ? AND user_id = ?`
+		WHERE id = ?`
 
 	variablesJSON, _ := json.Marshal(variables)
 	tagsJSON, _ := json.Marshal(req.Tags)
@@ -650,7 +668,7 @@ func (s *EmailTemplateService) UpdateTemplate(userID, templateID uuid.UUID, req
 		req.Name, req.Description, req.Type, req.Language,
 		req.Subject, req.HTMLContent, textContent, variablesJSON,
 		req.ParentID, tagsJSON, time.Now(),
-		templateID, userID,
+		templateID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update template: %w", err)
@@ -662,9 +680,13 @@ func (s *EmailTemplateService) UpdateTemplate(userID, templateID uuid.UUID, req
 
 // DeleteTemplate soft deletes a template
 func (s *EmailTemplateService) DeleteTemplate(userID, templateID uuid.UUID) error {
-	query := `UPDATE email_templates SET is_active = false, updated_at = ? WHERE id = ? AND user_id = ?`
+	if err := s.authorizeTemplate(userID, templateID, models.WorkspaceRoleMember); err != nil {
+		return err
+	}
+
+	query := `UPDATE email_templates SET is_active = false, updated_at = ? WHERE id = ?`
 	
-	_, err := s.db.Exec(query, time.Now(), templateID, userID)
+	_, err := s.db.Exec(query, time.Now(), templateID)
 	if err != nil {
 		return fmt.Errorf("failed to delete template: %w", err)
 	}
@@ -694,5 +716,16 @@ func (s *EmailTemplateService) CloneTemplate(userID, templateID uuid.UUID, newNa
 		Tags:        original.Tags,
 	}
 
-	return s.CreateTemplate(userID, cloneReq)
+	return s.CreateTemplate(userID, original.WorkspaceID, cloneReq)
+}
+
+// authorizeTemplate checks that the user has at least the given role in the template's workspace
+func (s *EmailTemplateService) authorizeTemplate(userID, templateID uuid.UUID, min models.WorkspaceRole) error {
+	var workspaceID uuid.UUID
+	err := s.db.QueryRow("SELECT workspace_id FROM email_templates WHERE id = ?", templateID).Scan(&workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get template: %w", err)
+	}
+
+	return s.workspaceService.Authorize(workspaceID, userID, min)
 }
\ No newline at end of file
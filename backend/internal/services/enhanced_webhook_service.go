@@ -9,8 +9,8 @@ import (
 	"crypto/tls"
 	"database/sql"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
+	"formhub/pkg/webhooks"
 	"io"
 	"log"
 	"math"
@@ -29,6 +29,18 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	// DefaultWebhookSecretGracePeriod is how long a replaced endpoint secret keeps signing
+	// deliveries when no grace period is given
+	DefaultWebhookSecretGracePeriod = 24 * time.Hour
+	maxWebhookSecretGracePeriod     = 30 * 24 * time.Hour
+)
+
+var (
+	ErrWebhookEndpointNotFound = fmt.Errorf("webhook endpoint not found")
+	ErrInvalidGracePeriod      = fmt.Errorf("grace period must be between 0 and %d hours", int(maxWebhookSecretGracePeriod.Hours()))
+)
+
 // EnhancedWebhookService provides comprehensive webhook and integration capabilities
 type EnhancedWebhookService struct {
 	db                *sql.DB
@@ -45,6 +57,7 @@ type EnhancedWebhookService struct {
 	maxPayloadSize    int64
 	rateLimitWindow   time.Duration
 	maxWebhooksPerMin int
+	logRetention      time.Duration
 	
 	// Security
 	signatureHeader   string
@@ -75,9 +88,14 @@ type EnhancedWebhookService struct {
 // WebhookEndpoint represents a single webhook endpoint configuration
 type WebhookEndpoint struct {
 	ID                string            `json:"id"`
+	FormID            string            `json:"form_id,omitempty"`      // Set for form endpoints
+	WorkspaceID       string            `json:"workspace_id,omitempty"` // Set for account-level endpoints
+	AllForms          bool              `json:"all_forms,omitempty"`    // Account-level endpoint receiving every form's events
+	FormIDs           []string          `json:"form_ids,omitempty"`     // Forms an account-level endpoint subscribes to otherwise
 	Name              string            `json:"name"`
 	URL               string            `json:"url"`
 	Secret            string            `json:"secret,omitempty"`
+	PreviousSecrets   []WebhookSecret   `json:"previous_secrets,omitempty"` // Replaced secrets still signing during their grace period
 	Events            []string          `json:"events"`
 	Headers           map[string]string `json:"headers,omitempty"`
 	ContentType       string            `json:"content_type"`
@@ -93,18 +111,16 @@ type WebhookEndpoint struct {
 	ConditionalRules  []ConditionalRule `json:"conditional_rules,omitempty"`
 	Priority          int               `json:"priority"` // 1 = highest
 	Tags              []string          `json:"tags"`
+	Version           int               `json:"version"` // Bumped on every change; sent back in If-Match
 	CreatedAt         time.Time         `json:"created_at"`
 	UpdatedAt         time.Time         `json:"updated_at"`
 }
 
-// FormWebhookConfig holds all webhook configurations for a form
-type FormWebhookConfig struct {
-	FormID           string            `json:"form_id"`
-	Endpoints        []WebhookEndpoint `json:"endpoints"`
-	GlobalConfig     *GlobalConfig     `json:"global_config,omitempty"`
-	IntegrationRules *IntegrationRules `json:"integration_rules,omitempty"`
-	CreatedAt        time.Time         `json:"created_at"`
-	UpdatedAt        time.Time         `json:"updated_at"`
+// WebhookSecret is an endpoint secret replaced by a rotation. Deliveries are signed with it as
+// well as the current secret until it expires.
+type WebhookSecret struct {
+	Secret    string    `json:"secret"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // TransformConfig defines field mapping and data transformation
@@ -195,14 +211,20 @@ type EndpointState struct {
 	NextReset    time.Time
 }
 
-// WorkerPool for concurrent webhook processing
+// WorkerPool for concurrent webhook processing. Deliveries are queued in the database and
+// leased by the pool, so pools on several replicas share the queue.
 type WorkerPool struct {
-	workerCount int
-	jobChan     chan *WebhookJob
-	workers     []*WebhookWorker
-	ctx         context.Context
-	cancel      context.CancelFunc
-	wg          sync.WaitGroup
+	service      *EnhancedWebhookService
+	owner        string // Lease owner recorded on claimed deliveries
+	workerCount  int
+	jobChan      chan *WebhookDelivery
+	wakeChan     chan struct{}
+	workers      []*WebhookWorker
+	pollInterval time.Duration
+	leaseTimeout time.Duration
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
 }
 
 // WebhookJob represents a webhook processing job
@@ -217,11 +239,12 @@ type WebhookJob struct {
 	CompletedAt  *time.Time
 }
 
-// WebhookWorker processes webhook jobs
+// WebhookWorker processes webhook deliveries
 type WebhookWorker struct {
 	id      int
 	service *EnhancedWebhookService
-	jobChan chan *WebhookJob
+	owner   string
+	jobChan chan *WebhookDelivery
 	quit    chan bool
 }
 
@@ -229,6 +252,7 @@ type WebhookWorker struct {
 type EnhancedWebhookEvent struct {
 	ID              string                 `json:"id"`
 	Type            string                 `json:"type"`
+	SchemaVersion   int                    `json:"schema_version,omitempty"` // Version of the type's data schema in the event catalogue
 	Timestamp       time.Time              `json:"timestamp"`
 	FormID          string                 `json:"form_id"`
 	SubmissionID    string                 `json:"submission_id,omitempty"`
@@ -484,6 +508,7 @@ func NewEnhancedWebhookService(db *sql.DB, redis *redis.Client) *EnhancedWebhook
 		maxPayloadSize:    5 * 1024 * 1024, // 5MB
 		rateLimitWindow:   time.Minute,
 		maxWebhooksPerMin: 100,
+		logRetention:      DefaultWebhookLogRetention,
 		signatureHeader:   "X-FormHub-Signature-256",
 		timestampHeader:   "X-FormHub-Timestamp",
 		userAgent:         "FormHub-Webhooks/2.0",
@@ -508,18 +533,18 @@ func NewEnhancedWebhookService(db *sql.DB, redis *redis.Client) *EnhancedWebhook
 
 // SendWebhook sends webhooks to all configured endpoints for a form
 func (ews *EnhancedWebhookService) SendWebhook(formID string, event *EnhancedWebhookEvent) error {
-	// Get webhook configuration
-	config, err := ews.getFormWebhookConfig(formID)
+	// Get the form's endpoints and the account-level endpoints subscribed to it
+	endpoints, err := loadFormEndpoints(ews.db, formID)
 	if err != nil {
-		return fmt.Errorf("failed to get webhook config: %w", err)
+		return err
 	}
 	
-	if config == nil || len(config.Endpoints) == 0 {
+	if len(endpoints) == 0 {
 		return nil // No webhooks configured
 	}
 	
 	// Filter endpoints based on event type and conditions
-	eligibleEndpoints := ews.filterEligibleEndpoints(config.Endpoints, event)
+	eligibleEndpoints := ews.filterEligibleEndpoints(endpoints, event)
 	if len(eligibleEndpoints) == 0 {
 		return nil // No eligible endpoints
 	}
@@ -537,145 +562,42 @@ func (ews *EnhancedWebhookService) SendWebhook(formID string, event *EnhancedWeb
 	// Add to analytics
 	ews.analytics.RecordWebhookJob(job)
 	
-	// Process through worker pool
-	ews.workerPool.AddJob(job)
-	
-	return nil
+	// Queue a delivery per endpoint for the worker pools
+	return ews.workerPool.AddJob(job)
 }
 
 // CreateWebhookEndpoint creates a new webhook endpoint for a form
 func (ews *EnhancedWebhookService) CreateWebhookEndpoint(formID string, endpoint *WebhookEndpoint) error {
-	// Validate endpoint
-	if err := ews.validateEndpoint(endpoint); err != nil {
-		return fmt.Errorf("invalid endpoint configuration: %w", err)
-	}
-	
-	// Generate ID if not provided
-	if endpoint.ID == "" {
-		endpoint.ID = uuid.New().String()
-	}
-	
-	// Set timestamps
-	endpoint.CreatedAt = time.Now()
-	endpoint.UpdatedAt = time.Now()
-	
-	// Get existing config or create new
-	config, err := ews.getFormWebhookConfig(formID)
-	if err != nil {
-		return fmt.Errorf("failed to get existing config: %w", err)
-	}
-	
-	if config == nil {
-		config = &FormWebhookConfig{
-			FormID:    formID,
-			Endpoints: []WebhookEndpoint{*endpoint},
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-	} else {
-		config.Endpoints = append(config.Endpoints, *endpoint)
-		config.UpdatedAt = time.Now()
-	}
-	
-	// Save to database
-	return ews.saveFormWebhookConfig(config)
+	return ews.createEndpoint(webhookEndpointScope{formID: formID}, endpoint)
 }
 
-// UpdateWebhookEndpoint updates an existing webhook endpoint
-func (ews *EnhancedWebhookService) UpdateWebhookEndpoint(formID, endpointID string, updates *WebhookEndpoint) error {
-	config, err := ews.getFormWebhookConfig(formID)
-	if err != nil {
-		return fmt.Errorf("failed to get webhook config: %w", err)
-	}
-	
-	if config == nil {
-		return fmt.Errorf("webhook config not found for form %s", formID)
-	}
-	
-	// Find and update endpoint
-	found := false
-	for i, endpoint := range config.Endpoints {
-		if endpoint.ID == endpointID {
-			// Validate updates
-			if err := ews.validateEndpoint(updates); err != nil {
-				return fmt.Errorf("invalid endpoint updates: %w", err)
-			}
-			
-			// Update fields
-			updates.ID = endpointID
-			updates.CreatedAt = endpoint.CreatedAt
-			updates.UpdatedAt = time.Now()
-			config.Endpoints[i] = *updates
-			found = true
-			break
-		}
-	}
-	
-	if !found {
-		return fmt.Errorf("endpoint %s not found in form %s", endpointID, formID)
-	}
-	
-	config.UpdatedAt = time.Now()
-	return ews.saveFormWebhookConfig(config)
+// UpdateWebhookEndpoint replaces an existing webhook endpoint's configuration. A non-zero version
+// must match the stored one, so edits based on a stale read fail instead of overwriting.
+func (ews *EnhancedWebhookService) UpdateWebhookEndpoint(formID, endpointID string, updates *WebhookEndpoint, version int) error {
+	return ews.updateEndpoint(webhookEndpointScope{formID: formID}, endpointID, updates, version)
 }
 
-// DeleteWebhookEndpoint removes a webhook endpoint
-func (ews *EnhancedWebhookService) DeleteWebhookEndpoint(formID, endpointID string) error {
-	config, err := ews.getFormWebhookConfig(formID)
-	if err != nil {
-		return fmt.Errorf("failed to get webhook config: %w", err)
-	}
-	
-	if config == nil {
-		return fmt.Errorf("webhook config not found for form %s", formID)
-	}
-	
-	// Find and remove endpoint
-	newEndpoints := make([]WebhookEndpoint, 0, len(config.Endpoints))
-	found := false
-	for _, endpoint := range config.Endpoints {
-		if endpoint.ID != endpointID {
-			newEndpoints = append(newEndpoints, endpoint)
-		} else {
-			found = true
-		}
-	}
-	
-	if !found {
-		return fmt.Errorf("endpoint %s not found in form %s", endpointID, formID)
-	}
-	
-	config.Endpoints = newEndpoints
-	config.UpdatedAt = time.Now()
-	
-	// Archive endpoint data
-	ews.archiveEndpointData(formID, endpointID)
-	
-	return ews.saveFormWebhookConfig(config)
+// RotateWebhookSecret gives the endpoint a new generated secret. Deliveries are signed with the
+// old secret too until the grace period ends, so receivers can switch over without downtime;
+// secrets from earlier rotations keep their own expiry.
+func (ews *EnhancedWebhookService) RotateWebhookSecret(formID, endpointID string, gracePeriod time.Duration) (*WebhookEndpoint, error) {
+	return ews.rotateSecret(webhookEndpointScope{formID: formID}, endpointID, gracePeriod)
+}
+
+// DeleteWebhookEndpoint removes a webhook endpoint. A non-zero version must match the stored one.
+func (ews *EnhancedWebhookService) DeleteWebhookEndpoint(formID, endpointID string, version int) error {
+	return ews.deleteEndpoint(webhookEndpointScope{formID: formID}, endpointID, version)
 }
 
 // TestWebhookEndpoint tests a webhook endpoint
 func (ews *EnhancedWebhookService) TestWebhookEndpoint(formID, endpointID string) (*WebhookTestResult, error) {
-	config, err := ews.getFormWebhookConfig(formID)
+	endpoint, err := ews.findEndpoint(formID, endpointID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get webhook config: %w", err)
-	}
-	
-	if config == nil {
-		return nil, fmt.Errorf("webhook config not found")
-	}
-	
-	// Find endpoint
-	var endpoint *WebhookEndpoint
-	for _, ep := range config.Endpoints {
-		if ep.ID == endpointID {
-			endpoint = &ep
-			break
-		}
+		return nil, err
 	}
 	
 	if endpoint == nil {
-		return nil, fmt.Errorf("endpoint not found")
+		return nil, ErrWebhookEndpointNotFound
 	}
 	
 	// Create test event
@@ -695,7 +617,7 @@ func (ews *EnhancedWebhookService) TestWebhookEndpoint(formID, endpointID string
 	}
 	
 	// Send test webhook
-	result := ews.sendSingleWebhook(endpoint, testEvent)
+	result := ews.attemptWebhook(endpoint, testEvent, 1, "")
 	
 	return &WebhookTestResult{
 		EndpointID:   endpointID,
@@ -760,7 +682,7 @@ func (ews *EnhancedWebhookService) isEventTypeSupported(supportedEvents []string
 			return true
 		}
 		
-		// Support wildcard matching (e.g., "form.*" matches "form.submitted")
+		// Support wildcard matching (e.g., "submission.*" matches "submission.created")
 		if strings.HasSuffix(supported, "*") {
 			prefix := strings.TrimSuffix(supported, "*")
 			if strings.HasPrefix(eventType, prefix) {
@@ -961,6 +883,20 @@ func (ews *EnhancedWebhookService) validateEndpoint(endpoint *WebhookEndpoint) e
 		return fmt.Errorf("domain is blocked: %s", host)
 	}
 	
+	// Validate secret
+	if endpoint.Secret != "" {
+		if _, err := webhooks.SigningKey(endpoint.Secret); err != nil {
+			return err
+		}
+	}
+	
+	// Validate event subscriptions against the catalogue
+	events, err := normalizeEventSubscriptions(endpoint.Events)
+	if err != nil {
+		return err
+	}
+	endpoint.Events = events
+	
 	// Validate method
 	if endpoint.Method != "" {
 		validMethods := map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true}
@@ -1155,45 +1091,15 @@ type MonitoringAlert struct {
 
 // Database operations
 
-func (ews *EnhancedWebhookService) getFormWebhookConfig(formID string) (*FormWebhookConfig, error) {
-	query := `SELECT webhook_config FROM forms WHERE id = ? AND webhook_config IS NOT NULL`
-	
-	var configJSON string
-	err := ews.db.QueryRow(query, formID).Scan(&configJSON)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // No config found
-		}
-		return nil, err
-	}
-	
-	var config FormWebhookConfig
-	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
-		return nil, err
-	}
-	
-	return &config, nil
-}
-
-func (ews *EnhancedWebhookService) saveFormWebhookConfig(config *FormWebhookConfig) error {
-	configJSON, err := json.Marshal(config)
-	if err != nil {
-		return err
-	}
-	
-	query := `UPDATE forms SET webhook_config = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err = ews.db.Exec(query, string(configJSON), config.FormID)
-	return err
-}
-
-func (ews *EnhancedWebhookService) archiveEndpointData(formID, endpointID string) error {
-	// Archive webhook logs and analytics data
+func (ews *EnhancedWebhookService) archiveEndpointData(endpointID string) error {
+	// Archive webhook logs and analytics data. Account-level endpoints deliver for many forms, so
+	// this goes by the endpoint ID, which is unique across forms.
 	query := `
 		UPDATE webhook_notifications 
 		SET archived = TRUE, archived_at = CURRENT_TIMESTAMP 
-		WHERE form_id = ? AND webhook_endpoint_id = ?
+		WHERE webhook_endpoint_id = ?
 	`
-	_, err := ews.db.Exec(query, formID, endpointID)
+	_, err := ews.db.Exec(query, endpointID)
 	return err
 }
 
@@ -1209,6 +1115,7 @@ func (ews *EnhancedWebhookService) startBackgroundServices() {
 	// Start scheduler for cleanup tasks
 	ews.scheduler.AddFunc("@hourly", func() {
 		ews.cleanupOldWebhookData()
+		ews.pruneWebhookLogs()
 	})
 	
 	ews.scheduler.AddFunc("*/5 * * * *", func() { // Every 5 minutes
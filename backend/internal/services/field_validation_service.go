@@ -25,8 +25,20 @@ func NewFieldValidationService(db *sql.DB) *FieldValidationService {
 	}
 }
 
-// ValidateFormSubmission validates all fields in a form submission
+// ValidateFormSubmission validates all fields in a form submission after applying the fields'
+// rules. Values of fields hidden by their rules are removed from data.
 func (s *FieldValidationService) ValidateFormSubmission(formID uuid.UUID, data map[string]interface{}) (*models.FormValidationResult, error) {
+	return s.validateSubmission(formID, data, 0)
+}
+
+// ValidateFormStep validates the fields of one step of a multi-step form. Rules still see the
+// values of every step, but no values are removed.
+func (s *FieldValidationService) ValidateFormStep(formID uuid.UUID, step int, data map[string]interface{}) (*models.FormValidationResult, error) {
+	return s.validateSubmission(formID, data, step)
+}
+
+// validateSubmission validates the fields of the step, or of every step when step is 0
+func (s *FieldValidationService) validateSubmission(formID uuid.UUID, data map[string]interface{}, step int) (*models.FormValidationResult, error) {
 	// Get form fields
 	fields, err := s.getFormFields(formID)
 	if err != nil {
@@ -39,8 +51,24 @@ func (s *FieldValidationService) ValidateFormSubmission(formID uuid.UUID, data m
 		FieldResults: make(map[string]models.FieldValidationResult),
 	}
 
-	// Validate each configured field
+	// Fields hidden by their rules are neither validated nor kept
+	hidden := s.hiddenFields(fields, data)
 	for _, field := range fields {
+		if hidden[field.Name] && step == 0 {
+			delete(data, field.Name)
+			result.HiddenFields = append(result.HiddenFields, field.Name)
+		}
+	}
+
+	// Validate each visible field; missing fields are validated as empty
+	for _, field := range fields {
+		if hidden[field.Name] || (step != 0 && field.Step != step) {
+			continue
+		}
+		if !field.Required && s.ruleApplies(field, models.FieldRuleRequire, data, hidden) {
+			field.Required = true
+		}
+
 		fieldResult := s.validateField(field, data[field.Name])
 		result.FieldResults[field.Name] = fieldResult
 
@@ -50,21 +78,201 @@ func (s *FieldValidationService) ValidateFormSubmission(formID uuid.UUID, data m
 		}
 	}
 
-	// Check for required fields that are missing
-	for _, field := range fields {
-		if field.Required {
-			value, exists := data[field.Name]
-			if !exists || s.isEmpty(value) {
-				result.IsValid = false
-				if result.Errors[field.Name] == nil {
-					result.Errors[field.Name] = []string{}
-				}
-				result.Errors[field.Name] = append(result.Errors[field.Name], "This field is required")
+	return result, nil
+}
+
+// Conditional field rules
+
+// hiddenFields evaluates the fields' show and hide rules. Conditions on a hidden field see it as
+// empty, so rules are re-evaluated until the set of hidden fields settles.
+func (s *FieldValidationService) hiddenFields(fields []models.FormField, data map[string]interface{}) map[string]bool {
+	hidden := make(map[string]bool)
+
+	for pass := 0; pass <= len(fields); pass++ {
+		changed := false
+		for _, field := range fields {
+			isHidden := s.fieldHidden(field, data, hidden)
+			if isHidden != hidden[field.Name] {
+				hidden[field.Name] = isHidden
+				changed = true
 			}
 		}
+		if !changed {
+			break
+		}
 	}
 
-	return result, nil
+	return hidden
+}
+
+// fieldHidden reports whether a hide rule matches, or the field has show rules and none match
+func (s *FieldValidationService) fieldHidden(field models.FormField, data map[string]interface{}, hidden map[string]bool) bool {
+	if s.ruleApplies(field, models.FieldRuleHide, data, hidden) {
+		return true
+	}
+
+	for _, rule := range field.Rules {
+		if rule.Action == models.FieldRuleShow {
+			return !s.ruleApplies(field, models.FieldRuleShow, data, hidden)
+		}
+	}
+	return false
+}
+
+// ruleApplies reports whether any of the field's rules with the action matches the submission
+func (s *FieldValidationService) ruleApplies(field models.FormField, action models.FieldRuleAction, data map[string]interface{}, hidden map[string]bool) bool {
+	for _, rule := range field.Rules {
+		if rule.Action == action && s.ruleMatches(rule, data, hidden) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FieldValidationService) ruleMatches(rule models.FormFieldRule, data map[string]interface{}, hidden map[string]bool) bool {
+	if len(rule.Conditions) == 0 {
+		return false
+	}
+
+	matchAny := rule.Match == "any"
+	for _, condition := range rule.Conditions {
+		var value interface{}
+		if !hidden[condition.Field] {
+			value = data[condition.Field]
+		}
+
+		matched := s.conditionMatches(condition, value)
+		if matchAny && matched {
+			return true
+		}
+		if !matchAny && !matched {
+			return false
+		}
+	}
+	return !matchAny
+}
+
+// conditionMatches compares a submitted value with the condition. Values submitted as lists, such
+// as checkbox groups, match equals and in when any item does.
+func (s *FieldValidationService) conditionMatches(condition models.RuleCondition, value interface{}) bool {
+	switch condition.Operator {
+	case models.ConditionEmpty:
+		return s.isEmpty(value)
+	case models.ConditionNotEmpty:
+		return !s.isEmpty(value)
+	case models.ConditionEquals:
+		return s.valueIn(value, []interface{}{condition.Value})
+	case models.ConditionNotEquals:
+		return !s.valueIn(value, []interface{}{condition.Value})
+	case models.ConditionIn:
+		expected, _ := condition.Value.([]interface{})
+		return s.valueIn(value, expected)
+	case models.ConditionNotIn:
+		expected, _ := condition.Value.([]interface{})
+		return !s.valueIn(value, expected)
+	case models.ConditionContains:
+		needle := s.convertToString(condition.Value)
+		for _, item := range s.valueItems(value) {
+			if strings.Contains(item, needle) {
+				return true
+			}
+		}
+		return false
+	case models.ConditionGreaterThan, models.ConditionLessThan:
+		items := s.valueItems(value)
+		if len(items) != 1 {
+			return false
+		}
+		actual, err := strconv.ParseFloat(strings.TrimSpace(items[0]), 64)
+		if err != nil {
+			return false
+		}
+		limit, err := strconv.ParseFloat(s.convertToString(condition.Value), 64)
+		if err != nil {
+			return false
+		}
+		if condition.Operator == models.ConditionGreaterThan {
+			return actual > limit
+		}
+		return actual < limit
+	default:
+		return false
+	}
+}
+
+func (s *FieldValidationService) valueIn(value interface{}, expected []interface{}) bool {
+	for _, item := range s.valueItems(value) {
+		for _, want := range expected {
+			if item == s.convertToString(want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// valueItems returns a submitted value as strings, one per item for lists
+func (s *FieldValidationService) valueItems(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []string:
+		return v
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, s.convertToString(item))
+		}
+		return items
+	default:
+		return []string{s.convertToString(v)}
+	}
+}
+
+// validateFieldRules checks a field's rules before they are stored. fieldNames holds every field
+// the form will have once the field is saved; conditions may only test those.
+func validateFieldRules(fieldName string, rules []models.FormFieldRule, fieldNames map[string]bool) error {
+	for _, rule := range rules {
+		switch rule.Action {
+		case models.FieldRuleShow, models.FieldRuleHide, models.FieldRuleRequire:
+		default:
+			return fmt.Errorf("%w: field %s: rule action must be show, hide or require", ErrInvalidFormRequest, fieldName)
+		}
+		if rule.Match != "" && rule.Match != "all" && rule.Match != "any" {
+			return fmt.Errorf("%w: field %s: rule match must be all or any", ErrInvalidFormRequest, fieldName)
+		}
+		if len(rule.Conditions) == 0 {
+			return fmt.Errorf("%w: field %s: rule needs at least one condition", ErrInvalidFormRequest, fieldName)
+		}
+
+		for _, condition := range rule.Conditions {
+			if condition.Field == "" || condition.Field == fieldName {
+				return fmt.Errorf("%w: field %s: condition must name another field", ErrInvalidFormRequest, fieldName)
+			}
+			if !fieldNames[condition.Field] {
+				return fmt.Errorf("%w: field %s: condition field %s does not exist", ErrInvalidFormRequest, fieldName, condition.Field)
+			}
+
+			switch condition.Operator {
+			case models.ConditionEmpty, models.ConditionNotEmpty:
+			case models.ConditionEquals, models.ConditionNotEquals, models.ConditionContains:
+				if condition.Value == nil {
+					return fmt.Errorf("%w: field %s: %s condition needs a value", ErrInvalidFormRequest, fieldName, condition.Operator)
+				}
+			case models.ConditionIn, models.ConditionNotIn:
+				if _, ok := condition.Value.([]interface{}); !ok {
+					return fmt.Errorf("%w: field %s: %s condition needs a list value", ErrInvalidFormRequest, fieldName, condition.Operator)
+				}
+			case models.ConditionGreaterThan, models.ConditionLessThan:
+				if _, ok := condition.Value.(float64); !ok {
+					return fmt.Errorf("%w: field %s: %s condition needs a number value", ErrInvalidFormRequest, fieldName, condition.Operator)
+				}
+			default:
+				return fmt.Errorf("%w: field %s: unknown condition operator %q", ErrInvalidFormRequest, fieldName, condition.Operator)
+			}
+		}
+	}
+	return nil
 }
 
 // ValidateField validates a single form field
@@ -75,8 +283,12 @@ func (s *FieldValidationService) validateField(field models.FormField, value int
 		Errors:  []string{},
 	}
 
-	// Skip validation if field is not required and value is empty
-	if !field.Required && s.isEmpty(value) {
+	// Empty values only need to satisfy the required flag
+	if s.isEmpty(value) {
+		if field.Required {
+			result.IsValid = false
+			result.Errors = append(result.Errors, "This field is required")
+		}
 		return result
 	}
 
@@ -324,20 +536,25 @@ func (s *FieldValidationService) validateCheckbox(result *models.FieldValidation
 		// Multiple checkboxes
 		for _, item := range v {
 			if itemStr, ok := item.(string); ok {
-				valid := false
-				for _, option := range options {
-					if option.Value == itemStr {
-						valid = true
-						break
-					}
-				}
-				if !valid {
-					result.IsValid = false
-					result.Errors = append(result.Errors, fmt.Sprintf("'%s' is not a valid option", itemStr))
-				}
+				s.validateCheckboxItem(result, itemStr, options)
 			}
 		}
+	case []string:
+		// Multiple checkboxes posted as a urlencoded or multipart form
+		for _, item := range v {
+			s.validateCheckboxItem(result, item, options)
+		}
+	}
+}
+
+func (s *FieldValidationService) validateCheckboxItem(result *models.FieldValidationResult, value string, options []models.FormFieldOption) {
+	for _, option := range options {
+		if option.Value == value {
+			return
+		}
 	}
+	result.IsValid = false
+	result.Errors = append(result.Errors, fmt.Sprintf("'%s' is not a valid option", value))
 }
 
 func (s *FieldValidationService) validateHidden(result *models.FieldValidationResult, value string, validation models.FormFieldValidation) {
@@ -396,6 +613,8 @@ func (s *FieldValidationService) isEmpty(value interface{}) bool {
 		return strings.TrimSpace(v) == ""
 	case []interface{}:
 		return len(v) == 0
+	case []string:
+		return len(v) == 0
 	default:
 		return false
 	}
@@ -419,15 +638,23 @@ func (s *FieldValidationService) convertToString(value interface{}) string {
 }
 
 func (s *FieldValidationService) getFormFields(formID uuid.UUID) ([]models.FormField, error) {
+	return loadFormFields(s.db, formID)
+}
+
+// formFieldColumns are the form_fields columns read by scanFormField
+const formFieldColumns = `id, form_id, name, label, type, required, placeholder, default_value,
+	validation, file_settings, rules, field_order, step, is_active, created_at, updated_at`
+
+// loadFormFields returns the form's active fields in order, with their options
+func loadFormFields(db *sql.DB, formID uuid.UUID) ([]models.FormField, error) {
 	query := `
-		SELECT id, form_id, name, label, type, required, placeholder, default_value,
-			validation, file_settings, field_order, is_active, created_at, updated_at
+		SELECT ` + formFieldColumns + `
 		FROM form_fields 
 		WHERE form_id = ? AND is_active = true 
-		ORDER BY field_order, created_at
+		ORDER BY step, field_order, created_at
 	`
 
-	rows, err := s.db.Query(query, formID)
+	rows, err := db.Query(query, formID)
 	if err != nil {
 		return nil, err
 	}
@@ -435,44 +662,66 @@ func (s *FieldValidationService) getFormFields(formID uuid.UUID) ([]models.FormF
 
 	var fields []models.FormField
 	for rows.Next() {
-		var field models.FormField
-		var validationJSON, fileSettingsJSON sql.NullString
-
-		err := rows.Scan(
-			&field.ID, &field.FormID, &field.Name, &field.Label, &field.Type,
-			&field.Required, &field.Placeholder, &field.DefaultValue,
-			&validationJSON, &fileSettingsJSON, &field.Order, &field.IsActive,
-			&field.CreatedAt, &field.UpdatedAt,
-		)
+		field, err := scanFormField(rows)
 		if err != nil {
-			continue
+			return nil, err
 		}
+		fields = append(fields, *field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		// Parse validation JSON
-		if validationJSON.Valid && validationJSON.String != "" {
-			json.Unmarshal([]byte(validationJSON.String), &field.Validation)
+	for i := range fields {
+		if err := loadFieldOptions(db, &fields[i]); err != nil {
+			return nil, err
 		}
+	}
 
-		// Parse file settings JSON
-		if fileSettingsJSON.Valid && fileSettingsJSON.String != "" {
-			json.Unmarshal([]byte(fileSettingsJSON.String), &field.FileSettings)
-		}
+	return fields, nil
+}
 
-		// Get field options for select, radio, checkbox fields
-		if field.Type == models.FieldTypeSelect || field.Type == models.FieldTypeRadio || field.Type == models.FieldTypeCheckbox {
-			options, err := s.getFieldOptions(field.ID)
-			if err == nil {
-				field.Options = options
-			}
-		}
+func scanFormField(row rowScanner) (*models.FormField, error) {
+	var field models.FormField
+	var placeholder, defaultValue, validationJSON, fileSettingsJSON, rulesJSON sql.NullString
 
-		fields = append(fields, field)
+	err := row.Scan(
+		&field.ID, &field.FormID, &field.Name, &field.Label, &field.Type,
+		&field.Required, &placeholder, &defaultValue,
+		&validationJSON, &fileSettingsJSON, &rulesJSON, &field.Order, &field.Step, &field.IsActive,
+		&field.CreatedAt, &field.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
 	}
+	field.Placeholder = placeholder.String
+	field.DefaultValue = defaultValue.String
 
-	return fields, nil
+	// Parse validation JSON
+	if validationJSON.Valid && validationJSON.String != "" {
+		json.Unmarshal([]byte(validationJSON.String), &field.Validation)
+	}
+
+	// Parse file settings JSON
+	if fileSettingsJSON.Valid && fileSettingsJSON.String != "" {
+		json.Unmarshal([]byte(fileSettingsJSON.String), &field.FileSettings)
+	}
+
+	if rulesJSON.Valid && rulesJSON.String != "" {
+		if err := json.Unmarshal([]byte(rulesJSON.String), &field.Rules); err != nil {
+			return nil, fmt.Errorf("failed to decode rules of field %s: %w", field.Name, err)
+		}
+	}
+
+	return &field, nil
 }
 
-func (s *FieldValidationService) getFieldOptions(fieldID uuid.UUID) ([]models.FormFieldOption, error) {
+// loadFieldOptions attaches the options of select, radio and checkbox fields
+func loadFieldOptions(db *sql.DB, field *models.FormField) error {
+	if field.Type != models.FieldTypeSelect && field.Type != models.FieldTypeRadio && field.Type != models.FieldTypeCheckbox {
+		return nil
+	}
+
 	query := `
 		SELECT id, field_id, label, value, selected, option_order
 		FROM form_field_options 
@@ -480,13 +729,13 @@ func (s *FieldValidationService) getFieldOptions(fieldID uuid.UUID) ([]models.Fo
 		ORDER BY option_order, label
 	`
 
-	rows, err := s.db.Query(query, fieldID)
+	rows, err := db.Query(query, field.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var options []models.FormFieldOption
+	field.Options = nil
 	for rows.Next() {
 		var option models.FormFieldOption
 		err := rows.Scan(
@@ -494,56 +743,32 @@ func (s *FieldValidationService) getFieldOptions(fieldID uuid.UUID) ([]models.Fo
 			&option.Value, &option.Selected, &option.Order,
 		)
 		if err != nil {
-			continue
+			return err
 		}
-		options = append(options, option)
+		field.Options = append(field.Options, option)
 	}
 
-	return options, nil
+	return rows.Err()
 }
 
 // GetFormFieldByName retrieves a specific form field by name
 func (s *FieldValidationService) GetFormFieldByName(formID uuid.UUID, fieldName string) (*models.FormField, error) {
 	query := `
-		SELECT id, form_id, name, label, type, required, placeholder, default_value,
-			validation, file_settings, field_order, is_active, created_at, updated_at
+		SELECT ` + formFieldColumns + `
 		FROM form_fields 
 		WHERE form_id = ? AND name = ? AND is_active = true
 	`
 
-	var field models.FormField
-	var validationJSON, fileSettingsJSON sql.NullString
-
-	err := s.db.QueryRow(query, formID, fieldName).Scan(
-		&field.ID, &field.FormID, &field.Name, &field.Label, &field.Type,
-		&field.Required, &field.Placeholder, &field.DefaultValue,
-		&validationJSON, &fileSettingsJSON, &field.Order, &field.IsActive,
-		&field.CreatedAt, &field.UpdatedAt,
-	)
-
+	field, err := scanFormField(s.db.QueryRow(query, formID, fieldName))
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse validation JSON
-	if validationJSON.Valid && validationJSON.String != "" {
-		json.Unmarshal([]byte(validationJSON.String), &field.Validation)
-	}
-
-	// Parse file settings JSON
-	if fileSettingsJSON.Valid && fileSettingsJSON.String != "" {
-		json.Unmarshal([]byte(fileSettingsJSON.String), &field.FileSettings)
-	}
-
-	// Get field options if applicable
-	if field.Type == models.FieldTypeSelect || field.Type == models.FieldTypeRadio || field.Type == models.FieldTypeCheckbox {
-		options, err := s.getFieldOptions(field.ID)
-		if err == nil {
-			field.Options = options
-		}
+	if err := loadFieldOptions(s.db, field); err != nil {
+		return nil, err
 	}
 
-	return &field, nil
+	return field, nil
 }
 
 // ValidateIndividualField validates a single field without form context
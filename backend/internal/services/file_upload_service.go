@@ -1,14 +1,18 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"formhub/internal/models"
+	"formhub/pkg/storage"
+	"formhub/pkg/utils"
 	"io"
+	"log"
 	"mime/multipart"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -17,14 +21,17 @@ import (
 )
 
 type FileUploadService struct {
-	db            *sql.DB
-	uploadDir     string
-	tempUploadDir string
-	maxFileSize   int64
-	allowedTypes  map[string]bool
+	db           *sql.DB
+	storage      storage.Storage
+	urlSigner    *utils.URLSigner
+	urlTTL       time.Duration
+	maxFileSize  int64
+	allowedTypes map[string]bool
+
+	webhookService *EnhancedWebhookService
 }
 
-func NewFileUploadService(db *sql.DB, uploadDir string) *FileUploadService {
+func NewFileUploadService(db *sql.DB, store storage.Storage) *FileUploadService {
 	// Default allowed MIME types - restrictive but comprehensive
 	defaultAllowedTypes := map[string]bool{
 		// Images
@@ -59,17 +66,11 @@ func NewFileUploadService(db *sql.DB, uploadDir string) *FileUploadService {
 		"video/quicktime": true,
 	}
 
-	// Ensure upload directories exist
-	os.MkdirAll(uploadDir, 0755)
-	tempDir := filepath.Join(uploadDir, "temp")
-	os.MkdirAll(tempDir, 0755)
-
 	return &FileUploadService{
-		db:            db,
-		uploadDir:     uploadDir,
-		tempUploadDir: tempDir,
-		maxFileSize:   50 * 1024 * 1024, // 50MB default
-		allowedTypes:  defaultAllowedTypes,
+		db:           db,
+		storage:      store,
+		maxFileSize:  50 * 1024 * 1024, // 50MB default
+		allowedTypes: defaultAllowedTypes,
 	}
 }
 
@@ -145,21 +146,12 @@ func (s *FileUploadService) UploadFile(file *multipart.FileHeader, fieldID uuid.
 	fileID := uuid.New()
 	ext := filepath.Ext(file.Filename)
 	fileName := fmt.Sprintf("%s%s", fileID.String(), ext)
-	filePath := filepath.Join(s.uploadDir, fileName)
+	storageKey := tempStorageKey(sessionID, fileName)
 
-	// Create destination file
-	dst, err := os.Create(filePath)
-	if err != nil {
-		return &models.FileUploadResult{
-			Error: "Failed to create destination file",
-		}, err
-	}
-	defer dst.Close()
+	ctx := context.Background()
 
-	// Copy file content
-	_, err = io.Copy(dst, src)
-	if err != nil {
-		os.Remove(filePath) // Cleanup on failure
+	// Store file content
+	if err := s.storage.Put(ctx, storageKey, src, file.Size, contentType); err != nil {
 		return &models.FileUploadResult{
 			Error: "Failed to save file",
 		}, err
@@ -172,7 +164,7 @@ func (s *FileUploadService) UploadFile(file *multipart.FileHeader, fieldID uuid.
 		OriginalName: file.Filename,
 		ContentType:  contentType,
 		Size:         file.Size,
-		StoragePath:  filePath,
+		StoragePath:  storageKey,
 		CreatedAt:    time.Now(),
 	}
 
@@ -180,20 +172,25 @@ func (s *FileUploadService) UploadFile(file *multipart.FileHeader, fieldID uuid.
 	if sessionID != "" {
 		err = s.saveTemporaryFile(fileUpload, fieldID, sessionID, fileHash)
 		if err != nil {
-			os.Remove(filePath) // Cleanup on failure
+			s.storage.Delete(ctx, storageKey) // Cleanup on failure
 			return &models.FileUploadResult{
 				Error: "Failed to save file metadata",
 			}, err
 		}
 	}
 
-	return &models.FileUploadResult{
+	result := &models.FileUploadResult{
 		ID:           fileID,
 		FileName:     fileName,
 		OriginalName: file.Filename,
 		Size:         file.Size,
 		ContentType:  contentType,
-	}, nil
+	}
+	// Without a session the file is never attached to a submission, so a link would never resolve
+	if sessionID != "" {
+		result.URL = s.SignedURL(fileID) // resolves once the submission is saved
+	}
+	return result, nil
 }
 
 // UploadMultipleFiles handles multiple file uploads with validation
@@ -304,28 +301,86 @@ func (s *FileUploadService) ValidateFieldFiles(files []models.FileUploadResult,
 	return result
 }
 
-// AssociateFilesWithSubmission associates temporary files with a submission
+// AssociateFilesWithSubmission moves a session's temporary files under the submission
+// and records them in file_uploads
 func (s *FileUploadService) AssociateFilesWithSubmission(submissionID uuid.UUID, sessionID string) error {
 	query := `
-		INSERT INTO file_uploads (id, submission_id, field_id, field_name, file_name, original_name, 
-			content_type, size, storage_path, file_hash, created_at)
-		SELECT uuid_generate_v4(), ?, 
-			(SELECT id FROM form_fields WHERE form_id = t.form_id AND name = t.field_name LIMIT 1),
-			field_name, file_name, original_name, content_type, size, storage_path, file_hash, created_at
+		SELECT t.id, t.form_id, t.field_name, t.file_name, t.original_name, t.content_type, t.size,
+			t.storage_path, t.file_hash, t.created_at,
+			(SELECT id FROM form_fields WHERE form_id = t.form_id AND name = t.field_name LIMIT 1)
 		FROM temp_file_uploads t
-		WHERE session_id = ?
+		WHERE t.session_id = ?
 	`
 
-	_, err := s.db.Exec(query, submissionID, sessionID)
+	rows, err := s.db.Query(query, sessionID)
 	if err != nil {
-		return fmt.Errorf("failed to associate files with submission: %w", err)
+		return fmt.Errorf("failed to load temporary files: %w", err)
 	}
 
-	// Clean up temporary files
-	cleanupQuery := `DELETE FROM temp_file_uploads WHERE session_id = ?`
-	_, err = s.db.Exec(cleanupQuery, sessionID)
-	
-	return err
+	type tempFile struct {
+		models.FileUpload
+		tempID   uuid.UUID
+		formID   uuid.UUID
+		fileHash string
+		fieldID  sql.NullString
+	}
+
+	var files []tempFile
+	for rows.Next() {
+		var file tempFile
+		if err := rows.Scan(
+			&file.tempID, &file.formID, &file.FieldName, &file.FileName, &file.OriginalName,
+			&file.ContentType, &file.Size, &file.StoragePath, &file.fileHash,
+			&file.CreatedAt, &file.fieldID,
+		); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan temporary file: %w", err)
+		}
+		files = append(files, file)
+	}
+	rows.Close()
+
+	ctx := context.Background()
+	insertQuery := `
+		INSERT INTO file_uploads (id, submission_id, field_id, field_name, file_name, original_name,
+			content_type, size, storage_path, file_hash, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for _, file := range files {
+		permanentKey := submissionStorageKey(submissionID, file.FileName)
+
+		var fieldID interface{}
+		if file.fieldID.Valid {
+			fieldID = file.fieldID.String
+		}
+
+		// Reuse the temporary ID so download links handed out at upload time stay valid. The row is
+		// written before the object moves, so a failed insert leaves the upload where it was.
+		_, err := s.db.Exec(insertQuery,
+			file.tempID, submissionID, fieldID, file.FieldName, file.FileName, file.OriginalName,
+			file.ContentType, file.Size, permanentKey, file.fileHash, file.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to associate files with submission: %w", err)
+		}
+
+		if err := storage.Move(ctx, s.storage, file.StoragePath, permanentKey, file.Size, file.ContentType); err != nil {
+			if _, dbErr := s.db.Exec(`DELETE FROM file_uploads WHERE id = ?`, file.tempID); dbErr != nil {
+				log.Printf("Failed to remove file record %s after a failed move: %v", file.tempID, dbErr)
+			}
+			return fmt.Errorf("failed to move file %s: %w", file.OriginalName, err)
+		}
+
+		if _, err := s.db.Exec(`DELETE FROM temp_file_uploads WHERE id = ?`, file.tempID); err != nil {
+			return fmt.Errorf("failed to remove temporary file record: %w", err)
+		}
+
+		publishWebhookEvent(s.webhookService, file.formID, WebhookEventFileUploaded, "file:"+file.tempID.String()+":uploaded",
+			fileUploadedEventData(file.tempID, submissionID, &file.FileUpload))
+	}
+
+	return nil
 }
 
 // CleanupExpiredFiles removes expired temporary files
@@ -338,13 +393,16 @@ func (s *FileUploadService) CleanupExpiredFiles() error {
 	}
 	defer rows.Close()
 
-	// Delete physical files
+	// Delete stored objects
+	ctx := context.Background()
 	for rows.Next() {
 		var storagePath string
 		if err := rows.Scan(&storagePath); err != nil {
 			continue
 		}
-		os.Remove(storagePath)
+		if err := s.storage.Delete(ctx, storagePath); err != nil {
+			log.Printf("Failed to delete expired file %s: %v", storagePath, err)
+		}
 	}
 
 	// Delete database records
@@ -378,10 +436,26 @@ func (s *FileUploadService) GetFileByID(fileID uuid.UUID) (*models.FileUpload, e
 	if submissionID.Valid {
 		file.SubmissionID, _ = uuid.Parse(submissionID.String)
 	}
+	file.FieldName = fieldName.String
 
 	return &file, nil
 }
 
+// OpenFile returns the metadata and a reader for a stored file. The caller must close the reader.
+func (s *FileUploadService) OpenFile(ctx context.Context, fileID uuid.UUID) (*models.FileUpload, io.ReadCloser, error) {
+	file, err := s.GetFileByID(fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := s.storage.Get(ctx, file.StoragePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open stored file: %w", err)
+	}
+
+	return file, reader, nil
+}
+
 // Helper methods
 
 func (s *FileUploadService) isAllowedType(contentType, extension string) bool {
@@ -424,6 +498,18 @@ func (s *FileUploadService) checkDuplicateFile(fileHash string) (*models.FileUpl
 	return &file, err
 }
 
+// tempStorageKey is where a file lives until its submission is saved
+func tempStorageKey(sessionID, fileName string) string {
+	if sessionID == "" {
+		return path.Join("temp", fileName)
+	}
+	return path.Join("temp", storage.CleanKey(sessionID), fileName)
+}
+
+func submissionStorageKey(submissionID uuid.UUID, fileName string) string {
+	return path.Join("submissions", submissionID.String(), fileName)
+}
+
 func (s *FileUploadService) saveTemporaryFile(fileUpload *models.FileUpload, fieldID uuid.UUID, sessionID, fileHash string) error {
 	query := `
 		INSERT INTO temp_file_uploads (id, field_name, session_id, file_name, original_name,
@@ -444,9 +530,82 @@ func (s *FileUploadService) saveTemporaryFile(fileUpload *models.FileUpload, fie
 	return err
 }
 
-// GetUploadDirectory returns the upload directory path
-func (s *FileUploadService) GetUploadDirectory() string {
-	return s.uploadDir
+// SetURLSigner enables signed download links with the given lifetime
+func (s *FileUploadService) SetURLSigner(urlSigner *utils.URLSigner, ttl time.Duration) {
+	s.urlSigner = urlSigner
+	s.urlTTL = ttl
+}
+
+// SetWebhookService sets the service file.uploaded events are published through
+func (s *FileUploadService) SetWebhookService(webhookService *EnhancedWebhookService) {
+	s.webhookService = webhookService
+}
+
+// SignedURL returns an expiring download link for a file, or "" if signing is not configured
+func (s *FileUploadService) SignedURL(fileID uuid.UUID) string {
+	if s.urlSigner == nil {
+		return ""
+	}
+	return s.urlSigner.SignFileURL(fileID.String(), s.urlTTL)
+}
+
+// VerifySignedURL checks the expires and signature parameters of a download link
+func (s *FileUploadService) VerifySignedURL(fileID uuid.UUID, expires, signature string) error {
+	if s.urlSigner == nil {
+		return fmt.Errorf("signed URLs are not enabled")
+	}
+	return s.urlSigner.VerifyFileURL(fileID.String(), expires, signature)
+}
+
+// GetFileWorkspaceID returns the workspace that owns the form a file was submitted to
+func (s *FileUploadService) GetFileWorkspaceID(fileID uuid.UUID) (uuid.UUID, error) {
+	query := `
+		SELECT f.workspace_id
+		FROM file_uploads fu
+		JOIN submissions sub ON sub.id = fu.submission_id
+		JOIN forms f ON f.id = sub.form_id
+		WHERE fu.id = ?
+	`
+
+	var workspaceID uuid.UUID
+	if err := s.db.QueryRow(query, fileID).Scan(&workspaceID); err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, fmt.Errorf("file not found")
+		}
+		return uuid.Nil, fmt.Errorf("failed to get file workspace: %w", err)
+	}
+
+	return workspaceID, nil
+}
+
+// RecordDownload writes a download to the audit log
+func (s *FileUploadService) RecordDownload(entry *models.FileDownloadLog) error {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO file_download_logs (id, file_id, user_id, access_type, ip_address, user_agent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.Exec(query,
+		entry.ID, entry.FileID, entry.UserID, entry.AccessType,
+		entry.IPAddress, entry.UserAgent, entry.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record file download: %w", err)
+	}
+
+	return nil
+}
+
+// GetStorage returns the storage backend used for uploaded files
+func (s *FileUploadService) GetStorage() storage.Storage {
+	return s.storage
 }
 
 // UpdateMaxFileSize updates the maximum file size limit
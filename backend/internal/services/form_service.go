@@ -1,19 +1,34 @@
 package services
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"formhub/internal/models"
+	"formhub/pkg/utils"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrInvalidFormRequest is returned when a form's settings fail validation
+var ErrInvalidFormRequest = fmt.Errorf("invalid form request")
+
+// ErrFormFieldNotFound is returned when a field does not exist on the addressed form
+var ErrFormFieldNotFound = fmt.Errorf("form field not found")
+
+// DefaultCaptchaMinScore is the lowest reCAPTCHA v3 score accepted when a form does not set one
+const DefaultCaptchaMinScore = 0.5
+
 type FormService struct {
-	db    *sql.DB
-	redis *redis.Client
+	db               *sql.DB
+	redis            *redis.Client
+	workspaceService *WorkspaceService
+	webhookService   *EnhancedWebhookService
 }
 
 func NewFormService(db *sql.DB, redis *redis.Client) *FormService {
@@ -23,27 +38,55 @@ func NewFormService(db *sql.DB, redis *redis.Client) *FormService {
 	}
 }
 
-func (s *FormService) CreateForm(userID uuid.UUID, req models.CreateFormRequest) (*models.Form, error) {
-	// Check plan limits
-	user, err := s.getUserByID(userID)
+func (s *FormService) SetWorkspaceService(workspaceService *WorkspaceService) {
+	s.workspaceService = workspaceService
+}
+
+// SetWebhookService sets the service form.updated events are published through
+func (s *FormService) SetWebhookService(webhookService *EnhancedWebhookService) {
+	s.webhookService = webhookService
+}
+
+// AuthorizeForm checks that the user belongs to the form's workspace with at least the given role
+func (s *FormService) AuthorizeForm(form *models.Form, userID uuid.UUID, min models.WorkspaceRole) error {
+	return s.workspaceService.Authorize(form.WorkspaceID, userID, min)
+}
+
+func (s *FormService) CreateForm(userID, workspaceID uuid.UUID, req models.CreateFormRequest) (*models.Form, error) {
+	if err := s.workspaceService.Authorize(workspaceID, userID, models.WorkspaceRoleMember); err != nil {
+		return nil, err
+	}
+
+	// Check plan limits; the workspace uses its billing owner's plan
+	user, err := s.getWorkspaceOwner(workspaceID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, fmt.Errorf("failed to get workspace owner: %w", err)
 	}
 
 	limits := models.PlanLimitsMap[user.PlanType]
 	if limits.FormsLimit != -1 {
-		formCount, err := s.getUserFormCount(userID)
+		formCount, err := s.getOwnerFormCount(user.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get form count: %w", err)
 		}
 		if formCount >= limits.FormsLimit {
-			return nil, fmt.Errorf("form limit reached for plan %s", user.PlanType)
+			return nil, &PlanLimitError{Capability: CapabilityForms, PlanType: user.PlanType, Limit: limits.FormsLimit, Used: formCount}
 		}
 	}
+	if req.WebhookURL != "" && !limits.Webhooks {
+		return nil, &PlanLimitError{Capability: CapabilityWebhooks, PlanType: user.PlanType}
+	}
+
+	publicID, err := generateFormPublicID()
+	if err != nil {
+		return nil, err
+	}
 
 	form := &models.Form{
 		ID:             uuid.New(),
+		PublicID:       publicID,
 		UserID:         userID,
+		WorkspaceID:    workspaceID,
 		Name:           req.Name,
 		Description:    req.Description,
 		TargetEmail:    req.TargetEmail,
@@ -53,6 +96,8 @@ func (s *FormService) CreateForm(userID uuid.UUID, req models.CreateFormRequest)
 		WebhookURL:     req.WebhookURL,
 		SpamProtection: req.SpamProtection,
 		RecaptchaSecret: req.RecaptchaSecret,
+		CaptchaProvider: req.CaptchaProvider,
+		CaptchaMinScore: req.CaptchaMinScore,
 		FileUploads:    req.FileUploads && limits.FileUploads,
 		MaxFileSize:    req.MaxFileSize,
 		IsActive:       true,
@@ -66,22 +111,27 @@ func (s *FormService) CreateForm(userID uuid.UUID, req models.CreateFormRequest)
 		form.CCEmails = string(ccEmailsJSON)
 	}
 
-	if len(req.AllowedOrigins) > 0 {
-		originsJSON, _ := json.Marshal(req.AllowedOrigins)
-		form.AllowedOrigins = string(originsJSON)
+	originsJSON, err := allowedOriginsJSON(req.AllowedOrigins)
+	if err != nil {
+		return nil, err
+	}
+	form.AllowedOrigins = originsJSON
+
+	if form.CaptchaMinScore, err = validateCaptcha(form.CaptchaProvider, form.RecaptchaSecret, form.CaptchaMinScore); err != nil {
+		return nil, err
 	}
 
 	query := `
-		INSERT INTO forms (id, user_id, name, description, target_email, cc_emails, subject, 
-			success_message, redirect_url, webhook_url, spam_protection, recaptcha_secret,
-			file_uploads, max_file_size, allowed_origins, is_active, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO forms (id, public_id, user_id, workspace_id, name, description, target_email, cc_emails, subject, 
+			success_message, redirect_url, webhook_url, spam_protection, recaptcha_secret, captcha_provider,
+			captcha_min_score, file_uploads, max_file_size, allowed_origins, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = s.db.Exec(query,
-		form.ID, form.UserID, form.Name, form.Description, form.TargetEmail,
+		form.ID, form.PublicID, form.UserID, form.WorkspaceID, form.Name, form.Description, form.TargetEmail,
 		form.CCEmails, form.Subject, form.SuccessMessage, form.RedirectURL,
-		form.WebhookURL, form.SpamProtection, form.RecaptchaSecret,
+		form.WebhookURL, form.SpamProtection, form.RecaptchaSecret, nullString(form.CaptchaProvider), form.CaptchaMinScore,
 		form.FileUploads, form.MaxFileSize, form.AllowedOrigins,
 		form.IsActive, form.CreatedAt, form.UpdatedAt,
 	)
@@ -94,19 +144,29 @@ func (s *FormService) CreateForm(userID uuid.UUID, req models.CreateFormRequest)
 }
 
 func (s *FormService) GetFormByID(formID uuid.UUID) (*models.Form, error) {
+	return s.getForm("id", formID)
+}
+
+// GetFormByPublicID finds an active form by the public ID used in /submit requests
+func (s *FormService) GetFormByPublicID(publicID string) (*models.Form, error) {
+	return s.getForm("public_id", publicID)
+}
+
+func (s *FormService) getForm(column string, value interface{}) (*models.Form, error) {
 	form := &models.Form{}
 	query := `
-		SELECT id, user_id, name, description, target_email, cc_emails, subject,
+		SELECT id, public_id, user_id, workspace_id, name, description, target_email, cc_emails, subject,
 			success_message, redirect_url, webhook_url, spam_protection, recaptcha_secret,
-			file_uploads, max_file_size, allowed_origins, is_active, submission_count,
-			created_at, updated_at
-		FROM forms WHERE id = ? AND is_active = true
+			COALESCE(captcha_provider, ''), captcha_min_score, file_uploads, max_file_size, allowed_origins,
+			is_active, submission_count, created_at, updated_at
+		FROM forms WHERE ` + column + ` = ? AND is_active = true
 	`
 
-	err := s.db.QueryRow(query, formID).Scan(
-		&form.ID, &form.UserID, &form.Name, &form.Description, &form.TargetEmail,
+	err := s.db.QueryRow(query, value).Scan(
+		&form.ID, &form.PublicID, &form.UserID, &form.WorkspaceID, &form.Name, &form.Description, &form.TargetEmail,
 		&form.CCEmails, &form.Subject, &form.SuccessMessage, &form.RedirectURL,
 		&form.WebhookURL, &form.SpamProtection, &form.RecaptchaSecret,
+		&form.CaptchaProvider, &form.CaptchaMinScore,
 		&form.FileUploads, &form.MaxFileSize, &form.AllowedOrigins,
 		&form.IsActive, &form.SubmissionCount, &form.CreatedAt, &form.UpdatedAt,
 	)
@@ -121,17 +181,17 @@ func (s *FormService) GetFormByID(formID uuid.UUID) (*models.Form, error) {
 	return form, nil
 }
 
-func (s *FormService) GetUserForms(userID uuid.UUID) ([]models.Form, error) {
+func (s *FormService) GetWorkspaceForms(workspaceID uuid.UUID) ([]models.Form, error) {
 	query := `
-		SELECT id, user_id, name, description, target_email, cc_emails, subject,
+		SELECT id, public_id, user_id, workspace_id, name, description, target_email, cc_emails, subject,
 			success_message, redirect_url, webhook_url, spam_protection, recaptcha_secret,
-			file_uploads, max_file_size, allowed_origins, is_active, submission_count,
-			created_at, updated_at
-		FROM forms WHERE user_id = ? AND is_active = true
+			COALESCE(captcha_provider, ''), captcha_min_score, file_uploads, max_file_size, allowed_origins,
+			is_active, submission_count, created_at, updated_at
+		FROM forms WHERE workspace_id = ? AND is_active = true
 		ORDER BY created_at DESC
 	`
 
-	rows, err := s.db.Query(query, userID)
+	rows, err := s.db.Query(query, workspaceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get forms: %w", err)
 	}
@@ -141,9 +201,10 @@ func (s *FormService) GetUserForms(userID uuid.UUID) ([]models.Form, error) {
 	for rows.Next() {
 		var form models.Form
 		err := rows.Scan(
-			&form.ID, &form.UserID, &form.Name, &form.Description, &form.TargetEmail,
+			&form.ID, &form.PublicID, &form.UserID, &form.WorkspaceID, &form.Name, &form.Description, &form.TargetEmail,
 			&form.CCEmails, &form.Subject, &form.SuccessMessage, &form.RedirectURL,
 			&form.WebhookURL, &form.SpamProtection, &form.RecaptchaSecret,
+			&form.CaptchaProvider, &form.CaptchaMinScore,
 			&form.FileUploads, &form.MaxFileSize, &form.AllowedOrigins,
 			&form.IsActive, &form.SubmissionCount, &form.CreatedAt, &form.UpdatedAt,
 		)
@@ -163,17 +224,20 @@ func (s *FormService) UpdateForm(formID uuid.UUID, userID uuid.UUID, req models.
 		return nil, err
 	}
 
-	if form.UserID != userID {
-		return nil, fmt.Errorf("unauthorized")
+	if err := s.AuthorizeForm(form, userID, models.WorkspaceRoleMember); err != nil {
+		return nil, err
 	}
 
-	// Get user for plan limits
-	user, err := s.getUserByID(userID)
+	// Get the workspace owner for plan limits
+	user, err := s.getWorkspaceOwner(form.WorkspaceID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, fmt.Errorf("failed to get workspace owner: %w", err)
 	}
 
 	limits := models.PlanLimitsMap[user.PlanType]
+	if req.WebhookURL != "" && !limits.Webhooks {
+		return nil, &PlanLimitError{Capability: CapabilityWebhooks, PlanType: user.PlanType}
+	}
 
 	// Convert slice fields to JSON
 	ccEmailsJSON := ""
@@ -182,42 +246,70 @@ func (s *FormService) UpdateForm(formID uuid.UUID, userID uuid.UUID, req models.
 		ccEmailsJSON = string(ccEmails)
 	}
 
-	originsJSON := ""
-	if len(req.AllowedOrigins) > 0 {
-		origins, _ := json.Marshal(req.AllowedOrigins)
-		originsJSON = string(origins)
+	originsJSON, err := allowedOriginsJSON(req.AllowedOrigins)
+	if err != nil {
+		return nil, err
+	}
+
+	// The secret is never returned to clients, so an update that leaves it out keeps the stored one
+	captchaSecret := req.RecaptchaSecret
+	if captchaSecret == "" && req.CaptchaProvider != "" {
+		captchaSecret = form.RecaptchaSecret
+	}
+	captchaMinScore, err := validateCaptcha(req.CaptchaProvider, captchaSecret, req.CaptchaMinScore)
+	if err != nil {
+		return nil, err
 	}
 
 	query := `
 		UPDATE forms SET 
 			name = ?, description = ?, target_email = ?, cc_emails = ?,
 			subject = ?, success_message = ?, redirect_url = ?, webhook_url = ?,
-			spam_protection = ?, recaptcha_secret = ?, file_uploads = ?,
+			spam_protection = ?, recaptcha_secret = ?, captcha_provider = ?,
+			captcha_min_score = ?, file_uploads = ?,
 			max_file_size = ?, allowed_origins = ?, updated_at = ?
-		WHERE id = ? AND user_id = ?
+		WHERE id = ?
 	`
 
 	_, err = s.db.Exec(query,
-		formID, req.Name, req.Description, req.TargetEmail, ccEmailsJSON,
+		req.Name, req.Description, req.TargetEmail, ccEmailsJSON,
 		req.Subject, req.SuccessMessage, req.RedirectURL, req.WebhookURL,
-		req.SpamProtection, req.RecaptchaSecret, req.FileUploads && limits.FileUploads,
-		req.MaxFileSize, originsJSON, time.Now(), userID,
+		req.SpamProtection, captchaSecret, nullString(req.CaptchaProvider),
+		captchaMinScore, req.FileUploads && limits.FileUploads,
+		req.MaxFileSize, originsJSON, time.Now(), formID,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to update form: %w", err)
 	}
 
-	return s.GetFormByID(formID)
+	updated, err := s.GetFormByID(formID)
+	if err != nil {
+		return nil, err
+	}
+
+	publishWebhookEvent(s.webhookService, formID, WebhookEventFormUpdated, "", formUpdatedEventData(updated, userID))
+
+	return updated, nil
 }
 
 func (s *FormService) DeleteForm(formID uuid.UUID, userID uuid.UUID) error {
+	form, err := s.GetFormByID(formID)
+	if err != nil {
+		return err
+	}
+
+	// Deleting a shared form takes an admin
+	if err := s.AuthorizeForm(form, userID, models.WorkspaceRoleAdmin); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE forms SET is_active = false, updated_at = ? 
-		WHERE id = ? AND user_id = ?
+		WHERE id = ?
 	`
 
-	result, err := s.db.Exec(query, time.Now(), formID, userID)
+	result, err := s.db.Exec(query, time.Now(), formID)
 	if err != nil {
 		return fmt.Errorf("failed to delete form: %w", err)
 	}
@@ -244,6 +336,301 @@ func (s *FormService) IncrementSubmissionCount(formID uuid.UUID) error {
 	return nil
 }
 
+// Form fields
+
+// GetFormFields returns the form's active fields in order, with their options and rules
+func (s *FormService) GetFormFields(formID uuid.UUID) ([]models.FormField, error) {
+	fields, err := loadFormFields(s.db, formID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get form fields: %w", err)
+	}
+	return fields, nil
+}
+
+// CreateFormField adds a field to a form
+func (s *FormService) CreateFormField(formID uuid.UUID, req models.CreateFormFieldRequest) (*models.FormField, error) {
+	fields, err := s.CreateFormFields(formID, []models.CreateFormFieldRequest{req})
+	if err != nil {
+		return nil, err
+	}
+	return &fields[0], nil
+}
+
+// CreateFormFields adds fields to a form in one transaction
+func (s *FormService) CreateFormFields(formID uuid.UUID, reqs []models.CreateFormFieldRequest) ([]models.FormField, error) {
+	existing, err := loadFormFields(s.db, formID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get form fields: %w", err)
+	}
+	names := make(map[string]bool)
+	for _, field := range existing {
+		names[field.Name] = true
+	}
+	for _, req := range reqs {
+		if names[req.Name] {
+			return nil, fmt.Errorf("%w: field %s already exists", ErrInvalidFormRequest, req.Name)
+		}
+		names[req.Name] = true
+	}
+	// Rules may test any field of the form, including ones created in the same request
+	for _, req := range reqs {
+		if err := validateFieldRules(req.Name, req.Rules, names); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	fieldIDs := make([]uuid.UUID, 0, len(reqs))
+	for _, req := range reqs {
+		fieldID := uuid.New()
+		validationJSON, fileSettingsJSON, rulesJSON, err := formFieldJSON(req)
+		if err != nil {
+			return nil, err
+		}
+
+		query := `
+			INSERT INTO form_fields (id, form_id, name, label, type, required, placeholder,
+				default_value, validation, file_settings, rules, field_order, step, is_active, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, true, ?, ?)
+		`
+		now := time.Now()
+		_, err = tx.Exec(query,
+			fieldID, formID, req.Name, req.Label, req.Type, req.Required, req.Placeholder,
+			req.DefaultValue, validationJSON, fileSettingsJSON, rulesJSON, req.Order, fieldStep(req), now, now,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create field %s: %w", req.Name, err)
+		}
+
+		if err := insertFieldOptions(tx, fieldID, req.Options); err != nil {
+			return nil, err
+		}
+		fieldIDs = append(fieldIDs, fieldID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit fields: %w", err)
+	}
+
+	fields := make([]models.FormField, 0, len(fieldIDs))
+	for _, fieldID := range fieldIDs {
+		field, err := s.getFormField(formID, fieldID)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, *field)
+	}
+	return fields, nil
+}
+
+// UpdateFormField replaces a field's configuration and options. A field other fields' rules
+// test cannot be renamed.
+func (s *FormService) UpdateFormField(formID, fieldID uuid.UUID, req models.CreateFormFieldRequest) (*models.FormField, error) {
+	existing, err := loadFormFields(s.db, formID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get form fields: %w", err)
+	}
+
+	names := map[string]bool{req.Name: true}
+	for _, field := range existing {
+		if field.ID == fieldID {
+			if field.Name != req.Name {
+				if err := checkFieldUnreferenced(existing, field); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if field.Name == req.Name {
+			return nil, fmt.Errorf("%w: field %s already exists", ErrInvalidFormRequest, req.Name)
+		}
+		names[field.Name] = true
+	}
+
+	if err := validateFieldRules(req.Name, req.Rules, names); err != nil {
+		return nil, err
+	}
+
+	validationJSON, fileSettingsJSON, rulesJSON, err := formFieldJSON(req)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE form_fields SET name = ?, label = ?, type = ?, required = ?, placeholder = ?,
+			default_value = ?, validation = ?, file_settings = ?, rules = ?, field_order = ?, step = ?, updated_at = ?
+		WHERE id = ? AND form_id = ? AND is_active = true
+	`
+	result, err := tx.Exec(query,
+		req.Name, req.Label, req.Type, req.Required, req.Placeholder,
+		req.DefaultValue, validationJSON, fileSettingsJSON, rulesJSON, req.Order, fieldStep(req), time.Now(),
+		fieldID, formID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update field: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil, ErrFormFieldNotFound
+	}
+
+	if _, err := tx.Exec("DELETE FROM form_field_options WHERE field_id = ?", fieldID); err != nil {
+		return nil, fmt.Errorf("failed to replace field options: %w", err)
+	}
+	if err := insertFieldOptions(tx, fieldID, req.Options); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit field: %w", err)
+	}
+
+	return s.getFormField(formID, fieldID)
+}
+
+// DeleteFormField removes a field and its options from a form. A field other fields' rules test
+// cannot be deleted.
+func (s *FormService) DeleteFormField(formID, fieldID uuid.UUID) error {
+	existing, err := loadFormFields(s.db, formID)
+	if err != nil {
+		return fmt.Errorf("failed to get form fields: %w", err)
+	}
+	for _, field := range existing {
+		if field.ID == fieldID {
+			if err := checkFieldUnreferenced(existing, field); err != nil {
+				return err
+			}
+		}
+	}
+
+	result, err := s.db.Exec("DELETE FROM form_fields WHERE id = ? AND form_id = ?", fieldID, formID)
+	if err != nil {
+		return fmt.Errorf("failed to delete field: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrFormFieldNotFound
+	}
+	return nil
+}
+
+// UpdateFieldOrder sets the position of each listed field of the form
+func (s *FormService) UpdateFieldOrder(formID uuid.UUID, order []models.FieldOrder) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, item := range order {
+		result, err := tx.Exec(
+			"UPDATE form_fields SET field_order = ?, updated_at = ? WHERE id = ? AND form_id = ?",
+			item.Order, now, item.ID, formID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update field order: %w", err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			return ErrFormFieldNotFound
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *FormService) getFormField(formID, fieldID uuid.UUID) (*models.FormField, error) {
+	query := `SELECT ` + formFieldColumns + ` FROM form_fields WHERE id = ? AND form_id = ?`
+
+	field, err := scanFormField(s.db.QueryRow(query, fieldID, formID))
+	if err == sql.ErrNoRows {
+		return nil, ErrFormFieldNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get field: %w", err)
+	}
+
+	if err := loadFieldOptions(s.db, field); err != nil {
+		return nil, fmt.Errorf("failed to get field options: %w", err)
+	}
+	return field, nil
+}
+
+// checkFieldUnreferenced rejects changes that would leave another field's rule testing a field
+// that no longer exists
+func checkFieldUnreferenced(fields []models.FormField, target models.FormField) error {
+	for _, field := range fields {
+		if field.ID == target.ID {
+			continue
+		}
+		for _, rule := range field.Rules {
+			for _, condition := range rule.Conditions {
+				if condition.Field == target.Name {
+					return fmt.Errorf("%w: field %s is used in the rules of field %s", ErrInvalidFormRequest, target.Name, field.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// formFieldJSON encodes the JSON columns of a field; absent settings are stored as NULL
+func formFieldJSON(req models.CreateFormFieldRequest) (validation string, fileSettings, rules sql.NullString, err error) {
+	data, err := json.Marshal(req.Validation)
+	if err != nil {
+		return "", fileSettings, rules, fmt.Errorf("failed to encode field validation: %w", err)
+	}
+	validation = string(data)
+
+	if req.FileSettings != nil {
+		data, err := json.Marshal(req.FileSettings)
+		if err != nil {
+			return "", fileSettings, rules, fmt.Errorf("failed to encode file settings: %w", err)
+		}
+		fileSettings = sql.NullString{String: string(data), Valid: true}
+	}
+
+	if len(req.Rules) > 0 {
+		data, err := json.Marshal(req.Rules)
+		if err != nil {
+			return "", fileSettings, rules, fmt.Errorf("failed to encode field rules: %w", err)
+		}
+		rules = sql.NullString{String: string(data), Valid: true}
+	}
+
+	return validation, fileSettings, rules, nil
+}
+
+// fieldStep is the step a field is shown on; single-page forms put every field on step 1
+func fieldStep(req models.CreateFormFieldRequest) int {
+	if req.Step < 1 {
+		return 1
+	}
+	return req.Step
+}
+
+func insertFieldOptions(tx *sql.Tx, fieldID uuid.UUID, options []models.CreateFieldOptionRequest) error {
+	for _, option := range options {
+		_, err := tx.Exec(
+			"INSERT INTO form_field_options (id, field_id, label, value, selected, option_order) VALUES (?, ?, ?, ?, ?, ?)",
+			uuid.New(), fieldID, option.Label, option.Value, option.Selected, option.Order,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create field option %s: %w", option.Value, err)
+		}
+	}
+	return nil
+}
+
 func (s *FormService) getUserByID(userID uuid.UUID) (*models.User, error) {
 	user := &models.User{}
 	query := `
@@ -265,9 +652,95 @@ func (s *FormService) getUserByID(userID uuid.UUID) (*models.User, error) {
 	return user, nil
 }
 
-func (s *FormService) getUserFormCount(userID uuid.UUID) (int, error) {
+// getWorkspaceOwner returns the workspace's billing owner
+func (s *FormService) getWorkspaceOwner(workspaceID uuid.UUID) (*models.User, error) {
+	ownerID, err := s.workspaceService.BillingOwner(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.getUserByID(ownerID)
+}
+
+// getOwnerFormCount counts active forms across every workspace the user owns
+func (s *FormService) getOwnerFormCount(ownerID uuid.UUID) (int, error) {
 	var count int
-	query := `SELECT COUNT(*) FROM forms WHERE user_id = ? AND is_active = true`
-	err := s.db.QueryRow(query, userID).Scan(&count)
+	query := `
+		SELECT COUNT(*) FROM forms f
+		JOIN workspaces w ON f.workspace_id = w.id
+		WHERE w.owner_id = ? AND f.is_active = true`
+	err := s.db.QueryRow(query, ownerID).Scan(&count)
 	return count, err
-}
\ No newline at end of file
+}
+
+// FormAllowedOrigins returns the origins allowed to submit to the form, normalized for
+// utils.OriginAllowed; an empty list allows any origin
+func FormAllowedOrigins(form *models.Form) []string {
+	if form.AllowedOrigins == "" {
+		return nil
+	}
+
+	var entries []string
+	if err := json.Unmarshal([]byte(form.AllowedOrigins), &entries); err != nil {
+		log.Printf("Form %s has malformed allowed origins: %v", form.ID, err)
+		return nil
+	}
+
+	// Older forms stored bare domains, which normalize to https origins
+	origins := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if origin := utils.NormalizeAllowedOrigin(entry); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// allowedOriginsJSON validates and normalizes a form's origin allow-list for storage
+func allowedOriginsJSON(entries []string) (string, error) {
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	origins := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		origin := utils.NormalizeAllowedOrigin(entry)
+		if origin == "" {
+			return "", fmt.Errorf("%w: %q is not an origin such as https://example.com or https://*.example.com", ErrInvalidFormRequest, entry)
+		}
+		origins = append(origins, origin)
+	}
+
+	originsJSON, _ := json.Marshal(origins)
+	return string(originsJSON), nil
+}
+
+// validateCaptcha checks a form's captcha settings and returns the v3 score threshold to store
+func validateCaptcha(provider, secret string, minScore float64) (float64, error) {
+	switch utils.CaptchaProvider(provider) {
+	case "":
+		return DefaultCaptchaMinScore, nil
+	case utils.ProviderRecaptchaV2, utils.ProviderRecaptchaV3, utils.ProviderHCaptcha, utils.ProviderTurnstile:
+	default:
+		return 0, fmt.Errorf("%w: captcha_provider must be recaptcha_v2, recaptcha_v3, hcaptcha or turnstile", ErrInvalidFormRequest)
+	}
+
+	if secret == "" {
+		return 0, fmt.Errorf("%w: recaptcha_secret is required with a captcha provider", ErrInvalidFormRequest)
+	}
+	if minScore < 0 || minScore > 1 {
+		return 0, fmt.Errorf("%w: captcha_min_score must be between 0 and 1", ErrInvalidFormRequest)
+	}
+	if minScore == 0 {
+		minScore = DefaultCaptchaMinScore
+	}
+	return minScore, nil
+}
+
+// generateFormPublicID returns a random identifier for addressing a form in public /submit requests
+func generateFormPublicID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate form public ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
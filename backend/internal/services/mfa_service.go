@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"formhub/internal/models"
+	"formhub/pkg/utils"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidMFACode is returned for a wrong, reused or expired authenticator or recovery code
+var ErrInvalidMFACode = fmt.Errorf("invalid authentication code")
+
+// ErrInvalidMFAChallenge is returned for an unknown, expired or exhausted MFA login challenge
+var ErrInvalidMFAChallenge = fmt.Errorf("invalid or expired MFA challenge")
+
+// ErrMFALocked is returned while a user's second factor is locked after too many wrong codes
+var ErrMFALocked = fmt.Errorf("too many failed authentication codes, try again later")
+
+// ErrMFAAlreadyEnabled is returned when enrolling a user who already has MFA enabled
+var ErrMFAAlreadyEnabled = fmt.Errorf("two-factor authentication is already enabled")
+
+// ErrMFANotEnabled is returned for MFA operations on a user without MFA
+var ErrMFANotEnabled = fmt.Errorf("two-factor authentication is not enabled")
+
+const (
+	mfaIssuer = "FormHub"
+
+	// mfaChallengeTTL is how long a password-verified login waits for the second factor
+	mfaChallengeTTL = 5 * time.Minute
+
+	// mfaFailureLimit is how many wrong login codes, across all of a user's challenges, lock the
+	// user's second factor for mfaLockout after the last failure
+	mfaFailureLimit = 5
+	mfaLockout      = 15 * time.Minute
+
+	recoveryCodeCount = 10
+)
+
+// MFAService manages TOTP two-factor authentication: enrollment, one-time recovery codes and the
+// short-lived challenge that sits between the password and the second factor at login. TOTP
+// secrets are stored encrypted by secrets.
+type MFAService struct {
+	db      *sql.DB
+	redis   *redis.Client
+	secrets *utils.SecretBox
+}
+
+func NewMFAService(db *sql.DB, redis *redis.Client, secrets *utils.SecretBox) *MFAService {
+	return &MFAService{
+		db:      db,
+		redis:   redis,
+		secrets: secrets,
+	}
+}
+
+// BeginEnrollment generates a new secret for the user. MFA is not enabled until a code from it
+// is confirmed with ConfirmEnrollment.
+func (s *MFAService) BeginEnrollment(userID uuid.UUID) (*models.MFAEnrollment, error) {
+	var email string
+	var enabledAt sql.NullTime
+	err := s.db.QueryRow(`SELECT email, mfa_enabled_at FROM users WHERE id = ? AND is_active = true`, userID).Scan(&email, &enabledAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if enabledAt.Valid {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.secrets.Seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt MFA secret: %w", err)
+	}
+
+	if _, err := s.db.Exec(`UPDATE users SET mfa_secret = ?, mfa_last_step = NULL WHERE id = ?`, sealed, userID); err != nil {
+		return nil, fmt.Errorf("failed to store MFA secret: %w", err)
+	}
+
+	return &models.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(mfaIssuer, email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables MFA once the user proves their authenticator produces valid codes,
+// and returns their recovery codes. The codes are shown only this once.
+func (s *MFAService) ConfirmEnrollment(userID uuid.UUID, code string) ([]string, error) {
+	var secret sql.NullString
+	var enabledAt sql.NullTime
+	err := s.db.QueryRow(`SELECT mfa_secret, mfa_enabled_at FROM users WHERE id = ?`, userID).Scan(&secret, &enabledAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if enabledAt.Valid {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if !secret.Valid {
+		return nil, ErrMFANotEnabled
+	}
+	plainSecret, err := s.openSecret(userID, secret.String)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyTOTP(userID, plainSecret, code); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.Exec(`UPDATE users SET mfa_enabled_at = ? WHERE id = ?`, time.Now(), userID); err != nil {
+		return nil, fmt.Errorf("failed to enable MFA: %w", err)
+	}
+
+	return s.replaceRecoveryCodes(userID)
+}
+
+// Disable turns MFA off after checking both the password and a current code
+func (s *MFAService) Disable(userID uuid.UUID, password, code string) error {
+	var passwordHash string
+	if err := s.db.QueryRow(`SELECT password_hash FROM users WHERE id = ?`, userID).Scan(&passwordHash); err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	if err := s.Verify(userID, code); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(
+		`UPDATE users SET mfa_secret = NULL, mfa_enabled_at = NULL, mfa_last_step = NULL WHERE id = ?`, userID,
+	); err != nil {
+		return fmt.Errorf("failed to disable MFA: %w", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a current code
+func (s *MFAService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(userID)
+}
+
+// IsEnabled reports whether the user has completed MFA enrollment
+func (s *MFAService) IsEnabled(userID uuid.UUID) (bool, error) {
+	var enabledAt sql.NullTime
+	if err := s.db.QueryRow(`SELECT mfa_enabled_at FROM users WHERE id = ?`, userID).Scan(&enabledAt); err != nil {
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+	return enabledAt.Valid, nil
+}
+
+// Verify checks a code from the user's authenticator or, failing that, one of their unused
+// recovery codes, which is then spent
+func (s *MFAService) Verify(userID uuid.UUID, code string) error {
+	var secret sql.NullString
+	var enabledAt sql.NullTime
+	err := s.db.QueryRow(`SELECT mfa_secret, mfa_enabled_at FROM users WHERE id = ?`, userID).Scan(&secret, &enabledAt)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !enabledAt.Valid || !secret.Valid {
+		return ErrMFANotEnabled
+	}
+	plainSecret, err := s.openSecret(userID, secret.String)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyTOTP(userID, plainSecret, code); err == nil {
+		return nil
+	}
+
+	return s.useRecoveryCode(userID, code)
+}
+
+// CreateChallenge starts the second step of a login for a user whose password was accepted.
+// The returned token is exchanged, with a code, by VerifyChallenge.
+func (s *MFAService) CreateChallenge(userID uuid.UUID) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate MFA challenge: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	if err := s.redis.Set(context.Background(), mfaChallengeKey(token), userID.String(), mfaChallengeTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store MFA challenge: %w", err)
+	}
+	return token, nil
+}
+
+// VerifyChallenge checks the code for a login challenge and returns the user it belongs to.
+// A challenge can be completed once. Wrong codes are counted per user rather than per challenge,
+// since a correct password can always open a new one.
+func (s *MFAService) VerifyChallenge(token, code string) (uuid.UUID, error) {
+	ctx := context.Background()
+	key := mfaChallengeKey(token)
+
+	value, err := s.redis.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return uuid.Nil, ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get MFA challenge: %w", err)
+	}
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, ErrInvalidMFAChallenge
+	}
+
+	failuresKey := mfaFailuresKey(userID)
+	failures, err := s.redis.Get(ctx, failuresKey).Int()
+	if err != nil && err != redis.Nil {
+		return uuid.Nil, fmt.Errorf("failed to get MFA failures: %w", err)
+	}
+	if failures >= mfaFailureLimit {
+		s.redis.Del(ctx, key)
+		return uuid.Nil, ErrMFALocked
+	}
+
+	if err := s.Verify(userID, code); err != nil {
+		failures, incrErr := s.redis.Incr(ctx, failuresKey).Result()
+		if incrErr != nil {
+			log.Printf("Failed to count MFA failure of user %s: %v", userID, incrErr)
+		}
+		s.redis.Expire(ctx, failuresKey, mfaLockout)
+		if failures >= mfaFailureLimit {
+			log.Printf("Locked second factor of user %s after %d failed codes", userID, failures)
+			s.redis.Del(ctx, key)
+		}
+		return uuid.Nil, err
+	}
+
+	// Only the request that deletes the challenge may complete the login
+	deleted, err := s.redis.Del(ctx, key).Result()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to complete MFA challenge: %w", err)
+	}
+	if deleted == 0 {
+		return uuid.Nil, ErrInvalidMFAChallenge
+	}
+	s.redis.Del(ctx, failuresKey)
+
+	return userID, nil
+}
+
+// openSecret decrypts a stored TOTP secret. Secrets stored in plain text before they were
+// encrypted are sealed on first use.
+func (s *MFAService) openSecret(userID uuid.UUID, stored string) (string, error) {
+	secret, err := s.secrets.Open(stored)
+	if err != nil {
+		return "", fmt.Errorf("failed to read MFA secret: %w", err)
+	}
+	if utils.IsSealed(stored) {
+		return secret, nil
+	}
+
+	sealed, err := s.secrets.Seal(secret)
+	if err != nil {
+		log.Printf("Failed to encrypt MFA secret of user %s: %v", userID, err)
+		return secret, nil
+	}
+	if _, err := s.db.Exec(`UPDATE users SET mfa_secret = ? WHERE id = ? AND mfa_secret = ?`, sealed, userID, stored); err != nil {
+		log.Printf("Failed to encrypt MFA secret of user %s: %v", userID, err)
+	}
+	return secret, nil
+}
+
+// verifyTOTP accepts a code for a time step later than the last one the user used, so an
+// observed code cannot be replayed
+func (s *MFAService) verifyTOTP(userID uuid.UUID, secret, code string) error {
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	result, err := s.db.Exec(
+		`UPDATE users SET mfa_last_step = ? WHERE id = ? AND (mfa_last_step IS NULL OR mfa_last_step < ?)`,
+		step, userID, step,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record MFA code use: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *MFAService) useRecoveryCode(userID uuid.UUID, code string) error {
+	result, err := s.db.Exec(
+		`UPDATE user_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now(), userID, hashRecoveryCode(code),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrInvalidMFACode
+	}
+
+	log.Printf("User %s used a recovery code", userID)
+	return nil
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and returns a fresh set
+func (s *MFAService) replaceRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+		codes[i] = code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	for _, code := range codes {
+		if _, err := tx.Exec(
+			`INSERT INTO user_recovery_codes (id, user_id, code_hash, created_at) VALUES (?, ?, ?, ?)`,
+			uuid.New(), userID, hashRecoveryCode(code), now,
+		); err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// hashRecoveryCode normalizes a recovery code as typed by the user and hashes it for storage
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func mfaChallengeKey(token string) string {
+	return fmt.Sprintf("auth:mfa_challenge:%s", token)
+}
+
+func mfaFailuresKey(userID uuid.UUID) string {
+	return fmt.Sprintf("auth:mfa_failures:%s", userID)
+}
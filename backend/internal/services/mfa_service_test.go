@@ -0,0 +1,193 @@
+package services
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"formhub/pkg/utils"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeMFAUsers answers the queries MFAService.Verify makes for a single enrolled user whose only
+// accepted code is recoveryCode
+type fakeMFAUsers struct {
+	sealedSecret string
+	recoveryCode string
+}
+
+var registerFakeMFADriver sync.Once
+
+// newFakeMFADB returns a database holding users
+func newFakeMFADB(t *testing.T, users *fakeMFAUsers) *sql.DB {
+	t.Helper()
+	registerFakeMFADriver.Do(func() {
+		sql.Register("fakemfa", fakeMFADriver{})
+	})
+	fakeMFADBs.Store(t.Name(), users)
+
+	db, err := sql.Open("fakemfa", t.Name())
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+var fakeMFADBs sync.Map
+
+type fakeMFADriver struct{}
+
+func (fakeMFADriver) Open(name string) (driver.Conn, error) {
+	users, ok := fakeMFADBs.Load(name)
+	if !ok {
+		return nil, errors.New("unknown fake database " + name)
+	}
+	return &fakeMFAConn{users: users.(*fakeMFAUsers)}, nil
+}
+
+type fakeMFAConn struct {
+	users *fakeMFAUsers
+}
+
+func (c *fakeMFAConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeMFAStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeMFAConn) Close() error { return nil }
+
+func (c *fakeMFAConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeMFAStmt struct {
+	conn  *fakeMFAConn
+	query string
+}
+
+func (s *fakeMFAStmt) Close() error  { return nil }
+func (s *fakeMFAStmt) NumInput() int { return -1 }
+
+func (s *fakeMFAStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, "UPDATE user_recovery_codes"):
+		if args[2] == hashRecoveryCode(s.conn.users.recoveryCode) {
+			return driver.RowsAffected(1), nil
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.query, "UPDATE users"):
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+func (s *fakeMFAStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(s.query, "SELECT mfa_secret, mfa_enabled_at FROM users") {
+		return &fakeMFARows{values: []driver.Value{s.conn.users.sealedSecret, time.Now()}}, nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+type fakeMFARows struct {
+	values []driver.Value
+	read   bool
+}
+
+func (r *fakeMFARows) Columns() []string { return []string{"mfa_secret", "mfa_enabled_at"} }
+func (r *fakeMFARows) Close() error      { return nil }
+
+func (r *fakeMFARows) Next(dest []driver.Value) error {
+	if r.read {
+		return io.EOF
+	}
+	r.read = true
+	copy(dest, r.values)
+	return nil
+}
+
+func newTestMFAService(t *testing.T, recoveryCode string) *MFAService {
+	t.Helper()
+	secrets, err := utils.NewSecretBox("test-secret", "mfa")
+	if err != nil {
+		t.Fatalf("creating secret box: %v", err)
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generating secret: %v", err)
+	}
+	sealed, err := secrets.Seal(secret)
+	if err != nil {
+		t.Fatalf("sealing secret: %v", err)
+	}
+
+	db := newFakeMFADB(t, &fakeMFAUsers{sealedSecret: sealed, recoveryCode: recoveryCode})
+	return NewMFAService(db, newFakeRedis(t), secrets)
+}
+
+func TestVerifyChallengeCountsFailuresAcrossChallenges(t *testing.T) {
+	const recoveryCode = "abcd-efgh-ijkl-mnop"
+	service := newTestMFAService(t, recoveryCode)
+	userID := uuid.New()
+
+	// One wrong code per challenge, as a caller who knows the password could do
+	for i := 0; i < mfaFailureLimit; i++ {
+		token, err := service.CreateChallenge(userID)
+		if err != nil {
+			t.Fatalf("CreateChallenge: %v", err)
+		}
+		if _, err := service.VerifyChallenge(token, "wrong"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("VerifyChallenge attempt %d = %v; want ErrInvalidMFACode", i+1, err)
+		}
+	}
+
+	token, err := service.CreateChallenge(userID)
+	if err != nil {
+		t.Fatalf("CreateChallenge: %v", err)
+	}
+	if _, err := service.VerifyChallenge(token, recoveryCode); !errors.Is(err, ErrMFALocked) {
+		t.Fatalf("VerifyChallenge on a new challenge after %d failures = %v; want ErrMFALocked", mfaFailureLimit, err)
+	}
+}
+
+func TestVerifyChallengeSuccessClearsFailures(t *testing.T) {
+	const recoveryCode = "abcd-efgh-ijkl-mnop"
+	service := newTestMFAService(t, recoveryCode)
+	userID := uuid.New()
+
+	fail := func() {
+		t.Helper()
+		for i := 0; i < mfaFailureLimit-1; i++ {
+			token, err := service.CreateChallenge(userID)
+			if err != nil {
+				t.Fatalf("CreateChallenge: %v", err)
+			}
+			if _, err := service.VerifyChallenge(token, "wrong"); !errors.Is(err, ErrInvalidMFACode) {
+				t.Fatalf("VerifyChallenge = %v; want ErrInvalidMFACode", err)
+			}
+		}
+	}
+	succeed := func() {
+		t.Helper()
+		token, err := service.CreateChallenge(userID)
+		if err != nil {
+			t.Fatalf("CreateChallenge: %v", err)
+		}
+		got, err := service.VerifyChallenge(token, recoveryCode)
+		if err != nil {
+			t.Fatalf("VerifyChallenge with a valid code: %v", err)
+		}
+		if got != userID {
+			t.Fatalf("VerifyChallenge = %s; want %s", got, userID)
+		}
+	}
+
+	fail()
+	succeed()
+	fail()
+	succeed()
+}
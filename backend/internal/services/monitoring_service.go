@@ -21,6 +21,7 @@ type MonitoringService struct {
 	redis            *database.RedisClient
 	analyticsService *AnalyticsService
 	realTimeService  *RealTimeService
+	webhookService   *EnhancedWebhookService
 }
 
 type AlertCondition struct {
@@ -59,6 +60,11 @@ func NewMonitoringService(
 	}
 }
 
+// SetWebhookService sets the service alert.triggered events are published through
+func (m *MonitoringService) SetWebhookService(webhookService *EnhancedWebhookService) {
+	m.webhookService = webhookService
+}
+
 // CreateAlert creates a new monitoring alert
 func (m *MonitoringService) CreateAlert(ctx context.Context, userID uuid.UUID, req *models.CreateMonitoringAlertRequest) (*models.MonitoringAlert, error) {
 	alert := &models.MonitoringAlert{
@@ -511,6 +517,11 @@ func (m *MonitoringService) triggerAlert(ctx context.Context, alert *models.Moni
 	severity := m.determineSeverity(evaluation)
 	m.realTimeService.BroadcastAlert(alert.UserID, string(alert.AlertType), evaluation.Message, severity)
 
+	// Deliveries belong to a form, so the event goes to the webhooks of each form the alert covers
+	for _, formID := range alert.FormIDs {
+		publishWebhookEvent(m.webhookService, formID, WebhookEventAlertTriggered, "", alertTriggeredEventData(alert, evaluation, severity))
+	}
+
 	return nil
 }
 
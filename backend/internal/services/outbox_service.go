@@ -0,0 +1,371 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"formhub/internal/models"
+	"formhub/pkg/utils"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxService dispatches submission outbox messages to the email queue and webhook service.
+// Messages are delivered at least once; each carries an idempotency key that downstream
+// services use to drop or flag duplicates.
+type OutboxService struct {
+	db                *sql.DB
+	submissionService *SubmissionService
+	formService       *FormService
+	emailQueueService *EmailQueueService
+	webhookService    *EnhancedWebhookService
+	isProcessing      bool
+	processingMux     sync.RWMutex
+	stopChan          chan bool
+	wakeChan          chan struct{}
+	config            OutboxConfig
+}
+
+type OutboxConfig struct {
+	BatchSize       int           `json:"batch_size"`       // Number of messages to claim at once
+	MaxAttempts     int           `json:"max_attempts"`     // Attempts before a message is marked failed
+	RetryDelay      time.Duration `json:"retry_delay"`      // Base retry delay
+	LockTimeout     time.Duration `json:"lock_timeout"`     // How long a claimed message stays locked
+	ProcessInterval time.Duration `json:"process_interval"` // How often to poll the outbox
+}
+
+func NewOutboxService(db *sql.DB, submissionService *SubmissionService, formService *FormService, emailQueueService *EmailQueueService, webhookService *EnhancedWebhookService) *OutboxService {
+	config := OutboxConfig{
+		BatchSize:       50,
+		MaxAttempts:     10,
+		RetryDelay:      time.Second * 30,
+		LockTimeout:     time.Minute * 5,
+		ProcessInterval: time.Second * 15,
+	}
+
+	return &OutboxService{
+		db:                db,
+		submissionService: submissionService,
+		formService:       formService,
+		emailQueueService: emailQueueService,
+		webhookService:    webhookService,
+		stopChan:          make(chan bool),
+		wakeChan:          make(chan struct{}, 1),
+		config:            config,
+	}
+}
+
+// StartProcessor starts the outbox dispatcher
+func (s *OutboxService) StartProcessor() error {
+	s.processingMux.Lock()
+	defer s.processingMux.Unlock()
+
+	if s.isProcessing {
+		return fmt.Errorf("processor is already running")
+	}
+
+	s.isProcessing = true
+
+	go s.processLoop()
+
+	log.Println("Submission outbox processor started")
+	return nil
+}
+
+// StopProcessor stops the outbox dispatcher
+func (s *OutboxService) StopProcessor() error {
+	s.processingMux.Lock()
+	defer s.processingMux.Unlock()
+
+	if !s.isProcessing {
+		return fmt.Errorf("processor is not running")
+	}
+
+	s.stopChan <- true
+	s.isProcessing = false
+
+	log.Println("Submission outbox processor stopped")
+	return nil
+}
+
+// Wake asks the dispatcher to poll the outbox now instead of waiting for the next tick
+func (s *OutboxService) Wake() {
+	select {
+	case s.wakeChan <- struct{}{}:
+	default:
+		// A wake-up is already pending
+	}
+}
+
+// ProcessPending dispatches all outbox messages that are due
+func (s *OutboxService) ProcessPending() (int, error) {
+	messages, err := s.claimPending()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range messages {
+		s.dispatch(&messages[i])
+	}
+
+	return len(messages), nil
+}
+
+// RetryFailed resets a failed outbox message so the dispatcher picks it up again
+func (s *OutboxService) RetryFailed(messageID uuid.UUID) error {
+	query := `
+		UPDATE submission_outbox
+		SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?`
+
+	now := time.Now()
+	result, err := s.db.Exec(query, models.OutboxStatusPending, now, now, messageID, models.OutboxStatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to retry outbox message: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("outbox message not found or not failed")
+	}
+
+	s.Wake()
+	return nil
+}
+
+// Private methods
+
+func (s *OutboxService) processLoop() {
+	ticker := time.NewTicker(s.config.ProcessInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wakeChan:
+		case <-s.stopChan:
+			return
+		}
+
+		if processed, err := s.ProcessPending(); err != nil {
+			log.Printf("Error processing submission outbox: %v", err)
+		} else if processed > 0 {
+			log.Printf("Dispatched %d submission outbox messages", processed)
+		}
+	}
+}
+
+// claimPending locks due messages, including ones whose previous claim expired after a crash
+func (s *OutboxService) claimPending() ([]models.OutboxMessage, error) {
+	query := `
+		SELECT id, submission_id, form_id, kind, idempotency_key, status, attempts, next_attempt_at, created_at
+		FROM submission_outbox
+		WHERE (status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until <= ?)
+		ORDER BY next_attempt_at ASC
+		LIMIT ?`
+
+	now := time.Now()
+	rows, err := s.db.Query(query,
+		models.OutboxStatusPending, now, models.OutboxStatusProcessing, now, s.config.BatchSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []models.OutboxMessage
+	for rows.Next() {
+		var message models.OutboxMessage
+		if err := rows.Scan(
+			&message.ID, &message.SubmissionID, &message.FormID, &message.Kind,
+			&message.IdempotencyKey, &message.Status, &message.Attempts,
+			&message.NextAttemptAt, &message.CreatedAt,
+		); err == nil {
+			candidates = append(candidates, message)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox messages: %w", err)
+	}
+
+	// Claim each row with a conditional update so concurrent dispatchers never share a message
+	claimQuery := `
+		UPDATE submission_outbox
+		SET status = ?, attempts = attempts + 1, locked_until = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND attempts = ?`
+
+	var claimed []models.OutboxMessage
+	for _, message := range candidates {
+		result, err := s.db.Exec(claimQuery,
+			models.OutboxStatusProcessing, now.Add(s.config.LockTimeout), now,
+			message.ID, message.Status, message.Attempts,
+		)
+		if err != nil {
+			log.Printf("Failed to claim outbox message %s: %v", message.ID, err)
+			continue
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			message.Status = models.OutboxStatusProcessing
+			message.Attempts++
+			claimed = append(claimed, message)
+		}
+	}
+
+	return claimed, nil
+}
+
+func (s *OutboxService) dispatch(message *models.OutboxMessage) {
+	var err error
+	switch message.Kind {
+	case models.OutboxKindEmail:
+		err = s.dispatchEmail(message)
+	case models.OutboxKindWebhook:
+		err = s.dispatchWebhook(message)
+	default:
+		err = fmt.Errorf("unknown outbox message kind: %s", message.Kind)
+	}
+
+	if err != nil {
+		log.Printf("Failed to dispatch %s outbox message %s: %v", message.Kind, message.ID, err)
+		s.markRetry(message, err)
+		return
+	}
+
+	s.markDispatched(message)
+}
+
+// dispatchEmail renders the notification and hands it to the email queue
+func (s *OutboxService) dispatchEmail(message *models.OutboxMessage) error {
+	form, submission, err := s.load(message)
+	if err != nil {
+		return err
+	}
+
+	emailService := s.submissionService.emailService
+	rendered, err := emailService.RenderFormSubmission(s.submissionService.buildEmailData(form, submission))
+	if err != nil {
+		return err
+	}
+
+	queued := &models.EmailQueue{
+		UserID:         form.UserID,
+		FormID:         &form.ID,
+		SubmissionID:   &submission.ID,
+		ToEmails:       rendered.ToEmails,
+		CCEmails:       rendered.CCEmails,
+		Subject:        rendered.Subject,
+		HTMLContent:    rendered.HTMLContent,
+		TextContent:    rendered.TextContent,
+		Priority:       10, // Submission notifications go ahead of bulk and scheduled mail
+		IdempotencyKey: message.IdempotencyKey,
+	}
+
+	// The submission is flagged email_sent by the email queue once the email actually goes out
+	return s.emailQueueService.QueueEmail(queued)
+}
+
+// dispatchWebhook delivers the legacy Form.WebhookURL payload and queues the event with the webhook
+// service. SendWebhook returns once a delivery per endpoint is stored in webhook_deliveries, so the
+// message is only marked dispatched when the event can no longer be lost.
+func (s *OutboxService) dispatchWebhook(message *models.OutboxMessage) error {
+	form, submission, err := s.load(message)
+	if err != nil {
+		return err
+	}
+
+	delivered := false
+
+	if form.WebhookURL != "" {
+		payload := s.submissionService.buildWebhookPayload(form, submission)
+		payload.DeliveryID = message.IdempotencyKey
+
+		response, err := utils.SendWebhook(form.WebhookURL, payload)
+		if err != nil {
+			return fmt.Errorf("failed to send webhook: %w", err)
+		}
+		if !response.Success {
+			return fmt.Errorf("webhook returned non-success status: %d", response.StatusCode)
+		}
+		delivered = true
+	}
+
+	if s.webhookService != nil {
+		event := &EnhancedWebhookEvent{
+			ID:            message.IdempotencyKey,
+			Type:          WebhookEventSubmissionCreated,
+			SchemaVersion: 1,
+			Timestamp:     submission.CreatedAt,
+			FormID:        form.ID.String(),
+			SubmissionID:  submission.ID.String(),
+			UserID:        form.UserID.String(),
+			Data:          submission.Data,
+			Metadata: map[string]interface{}{
+				"form_name": form.Name,
+				"files":     s.submissionService.webhookFiles(submission.Files),
+			},
+			Source:    "formhub",
+			Version:   "2.0",
+			IPAddress: submission.IPAddress,
+			UserAgent: submission.UserAgent,
+		}
+
+		if err := s.webhookService.SendWebhook(form.ID.String(), event); err != nil {
+			return err
+		}
+	}
+
+	if delivered {
+		s.submissionService.markWebhookSent(submission.ID)
+	}
+
+	return nil
+}
+
+func (s *OutboxService) load(message *models.OutboxMessage) (*models.Form, *models.Submission, error) {
+	form, err := s.formService.GetFormByID(message.FormID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	submission, err := s.submissionService.getSubmissionWithFiles(message.SubmissionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return form, submission, nil
+}
+
+func (s *OutboxService) markDispatched(message *models.OutboxMessage) {
+	query := `
+		UPDATE submission_outbox
+		SET status = ?, last_error = '', locked_until = NULL, dispatched_at = ?, updated_at = ?
+		WHERE id = ?`
+
+	now := time.Now()
+	if _, err := s.db.Exec(query, models.OutboxStatusDispatched, now, now, message.ID); err != nil {
+		log.Printf("Failed to mark outbox message %s dispatched: %v", message.ID, err)
+	}
+}
+
+// markRetry schedules another attempt with exponential backoff, or fails the message for good
+func (s *OutboxService) markRetry(message *models.OutboxMessage, dispatchErr error) {
+	now := time.Now()
+
+	if message.Attempts >= s.config.MaxAttempts {
+		query := `UPDATE submission_outbox SET status = ?, last_error = ?, locked_until = NULL, updated_at = ? WHERE id = ?`
+		if _, err := s.db.Exec(query, models.OutboxStatusFailed, dispatchErr.Error(), now, message.ID); err != nil {
+			log.Printf("Failed to mark outbox message %s failed: %v", message.ID, err)
+		}
+		return
+	}
+
+	backoff := s.config.RetryDelay * time.Duration(1<<(message.Attempts-1)) // 2^(attempts-1)
+	query := `
+		UPDATE submission_outbox
+		SET status = ?, last_error = ?, next_attempt_at = ?, locked_until = NULL, updated_at = ?
+		WHERE id = ?`
+
+	if _, err := s.db.Exec(query, models.OutboxStatusPending, dispatchErr.Error(), now.Add(backoff), now, message.ID); err != nil {
+		log.Printf("Failed to reschedule outbox message %s: %v", message.ID, err)
+	}
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"formhub/internal/models"
+	"formhub/pkg/utils"
+	"html"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Errors returned when saving or resuming the progress of a multi-step form
+var (
+	ErrProgressNotFound      = fmt.Errorf("saved progress not found")
+	ErrProgressCompleted     = fmt.Errorf("saved progress has already been submitted")
+	ErrInvalidStep           = fmt.Errorf("step does not exist on this form")
+	ErrInvalidResumeLink     = fmt.Errorf("resume link is invalid or has expired")
+	ErrResumeURLNotAllowed   = fmt.Errorf("resume URL is not on an allowed origin of this form")
+	ErrResumeLinkRateLimited = fmt.Errorf("too many resume links requested")
+)
+
+const (
+	// partialSubmissionTTL is how long saved progress is kept after it was last saved
+	partialSubmissionTTL = 30 * 24 * time.Hour
+
+	// A session can be sent one resume link per cooldown, and an IP address a few per hour
+	resumeLinkCooldown   = 5 * time.Minute
+	resumeLinksPerIPHour = 10
+)
+
+// PartialSubmissionService saves the progress of respondents working through multi-step forms
+type PartialSubmissionService struct {
+	db                *sql.DB
+	redis             *redis.Client
+	validationService *FieldValidationService
+	emailQueueService *EmailQueueService
+	analyticsService  *AnalyticsService
+	urlSigner         *utils.URLSigner
+	frontendURL       string
+}
+
+func NewPartialSubmissionService(db *sql.DB, redis *redis.Client, validationService *FieldValidationService, emailQueueService *EmailQueueService, urlSigner *utils.URLSigner, frontendURL string) *PartialSubmissionService {
+	return &PartialSubmissionService{
+		db:                db,
+		redis:             redis,
+		validationService: validationService,
+		emailQueueService: emailQueueService,
+		urlSigner:         urlSigner,
+		frontendURL:       frontendURL,
+	}
+}
+
+func (s *PartialSubmissionService) SetAnalyticsService(analyticsService *AnalyticsService) {
+	s.analyticsService = analyticsService
+}
+
+// SaveProgress merges the values of a step into the saved progress the token belongs to after
+// validating the step's fields. Without a token new progress is started, and its token is returned
+// in the progress's Token. An invalid step returns the validation result and saves nothing.
+func (s *PartialSubmissionService) SaveProgress(form *models.Form, token string, step int, data map[string]interface{}, ipAddress, userAgent, referrer string) (*models.PartialSubmission, *models.FormValidationResult, error) {
+	fields, err := loadFormFields(s.db, form.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get form fields: %w", err)
+	}
+	lastStep := 1
+	for _, field := range fields {
+		if field.Step > lastStep {
+			lastStep = field.Step
+		}
+	}
+	if step < 1 || step > lastStep {
+		return nil, nil, ErrInvalidStep
+	}
+
+	var partial *models.PartialSubmission
+	if token == "" {
+		if token, err = generateProgressToken(); err != nil {
+			return nil, nil, err
+		}
+		partial = &models.PartialSubmission{
+			ID:        uuid.New(),
+			FormID:    form.ID,
+			SessionID: uuid.New().String(),
+			Data:      make(map[string]interface{}),
+			Token:     token,
+			CreatedAt: time.Now(),
+		}
+	} else if partial, err = s.GetProgress(form.ID, token); err != nil {
+		return nil, nil, err
+	}
+	if partial.CompletedAt != nil {
+		return nil, nil, ErrProgressCompleted
+	}
+
+	for key, value := range data {
+		partial.Data[key] = value
+	}
+
+	result, err := s.validationService.ValidateFormStep(form.ID, step, partial.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !result.IsValid {
+		return nil, result, nil
+	}
+
+	if step > partial.CurrentStep {
+		partial.CurrentStep = step
+	}
+	partial.UpdatedAt = time.Now()
+	partial.ExpiresAt = partial.UpdatedAt.Add(partialSubmissionTTL)
+
+	dataJSON, err := json.Marshal(partial.Data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode progress: %w", err)
+	}
+
+	query := `
+		INSERT INTO form_partial_submissions (
+			id, form_id, session_id, token_hash, data, current_step, expires_at, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			data = VALUES(data),
+			current_step = VALUES(current_step),
+			expires_at = VALUES(expires_at),
+			updated_at = VALUES(updated_at)
+	`
+	_, err = s.db.Exec(query,
+		partial.ID, partial.FormID, partial.SessionID, hashProgressToken(token), string(dataJSON), partial.CurrentStep,
+		partial.ExpiresAt, partial.CreatedAt, partial.UpdatedAt,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to save progress: %w", err)
+	}
+
+	s.recordStep(form, partial, step, ipAddress, userAgent, referrer)
+
+	return partial, result, nil
+}
+
+// GetProgress returns the unexpired saved progress on the form the token belongs to
+func (s *PartialSubmissionService) GetProgress(formID uuid.UUID, token string) (*models.PartialSubmission, error) {
+	if token == "" {
+		return nil, ErrProgressNotFound
+	}
+	return s.getProgress("token_hash", formID, hashProgressToken(token))
+}
+
+// ResumeProgress returns the saved progress a signed resume link points to. A new token is issued
+// in the progress's Token for the browser that opened the link, and the old one stops working.
+func (s *PartialSubmissionService) ResumeProgress(formID uuid.UUID, partialID, expires, signature string) (*models.PartialSubmission, error) {
+	if err := s.urlSigner.VerifyResumeToken(partialID, expires, signature); err != nil {
+		return nil, ErrInvalidResumeLink
+	}
+
+	id, err := uuid.Parse(partialID)
+	if err != nil {
+		return nil, ErrInvalidResumeLink
+	}
+
+	partial, err := s.getProgress("id", formID, id)
+	if err != nil {
+		return nil, err
+	}
+	if partial.CompletedAt != nil {
+		return nil, ErrProgressCompleted
+	}
+
+	token, err := generateProgressToken()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`UPDATE form_partial_submissions SET token_hash = ? WHERE id = ?`, hashProgressToken(token), partial.ID); err != nil {
+		return nil, fmt.Errorf("failed to issue progress token: %w", err)
+	}
+	partial.Token = token
+
+	return partial, nil
+}
+
+// SendResumeLink emails a signed link to the token's saved progress. The link points at
+// resumeURL, the page hosting the form, which must be on one of the form's allowed origins or on
+// the FormHub frontend. A form without allowed origins can only link to the frontend, so the
+// endpoint cannot be used to mail links to arbitrary sites.
+func (s *PartialSubmissionService) SendResumeLink(form *models.Form, token, emailAddress, resumeURL, ipAddress string) error {
+	u, err := url.Parse(resumeURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ErrResumeURLNotAllowed
+	}
+	origins := FormAllowedOrigins(form)
+	if frontendOrigin := utils.NormalizeOrigin(s.frontendURL); frontendOrigin != "" {
+		origins = append(origins, frontendOrigin)
+	}
+	// OriginAllowed lets anything through an empty list
+	if len(origins) == 0 || !utils.OriginAllowed(origins, resumeURL) {
+		return ErrResumeURLNotAllowed
+	}
+
+	partial, err := s.GetProgress(form.ID, token)
+	if err != nil {
+		return err
+	}
+	if partial.CompletedAt != nil {
+		return ErrProgressCompleted
+	}
+
+	if err := s.checkResumeLinkLimit(partial, ipAddress); err != nil {
+		return err
+	}
+
+	link, err := s.urlSigner.SignResumeURL(resumeURL, partial.ID.String(), time.Until(partial.ExpiresAt))
+	if err != nil {
+		return ErrResumeURLNotAllowed
+	}
+
+	queued := &models.EmailQueue{
+		UserID:   form.UserID,
+		FormID:   &form.ID,
+		ToEmails: []string{emailAddress},
+		Subject:  fmt.Sprintf("Continue your %s submission", form.Name),
+		HTMLContent: fmt.Sprintf(
+			`<p>Your answers to <strong>%s</strong> have been saved.</p><p><a href="%s">Continue where you left off</a></p><p>This link expires on %s.</p>`,
+			html.EscapeString(form.Name), html.EscapeString(link), partial.ExpiresAt.Format("January 2, 2006"),
+		),
+		TextContent: fmt.Sprintf(
+			"Your answers to %s have been saved.\n\nContinue where you left off: %s\n\nThis link expires on %s.",
+			form.Name, link, partial.ExpiresAt.Format("January 2, 2006"),
+		),
+		Priority: 10, // Respondents are waiting for it
+	}
+	if err := s.emailQueueService.QueueEmail(queued); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(`UPDATE form_partial_submissions SET email = ? WHERE id = ?`, emailAddress, partial.ID); err != nil {
+		log.Printf("Failed to record resume email for progress %s: %v", partial.ID, err)
+	}
+	return nil
+}
+
+// CompleteProgress marks saved progress as turned into the submission. It runs in the transaction
+// saving the submission, so when two final submits race only one of them commits; the other gets
+// ErrProgressCompleted.
+func (s *PartialSubmissionService) CompleteProgress(tx *sql.Tx, partialID, submissionID uuid.UUID) error {
+	result, err := tx.Exec(
+		`UPDATE form_partial_submissions SET submission_id = ?, completed_at = ? WHERE id = ? AND completed_at IS NULL`,
+		submissionID, time.Now(), partialID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete progress: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to complete progress: %w", err)
+	}
+	if affected != 1 {
+		return ErrProgressCompleted
+	}
+	return nil
+}
+
+// PruneExpiredProgress deletes saved progress that expired
+func (s *PartialSubmissionService) PruneExpiredProgress(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM form_partial_submissions WHERE expires_at < ?`, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to prune saved progress: %w", err)
+	}
+	return nil
+}
+
+// ProgressResponse maps an error from saving or resuming progress to the response for the respondent
+func ProgressResponse(err error) *models.SubmissionResponse {
+	response := &models.SubmissionResponse{Success: false}
+
+	switch {
+	case errors.Is(err, ErrProgressNotFound):
+		response.StatusCode = 404
+		response.Message = "No saved progress for this token"
+	case errors.Is(err, ErrProgressCompleted):
+		response.StatusCode = 409
+		response.Message = "This form has already been submitted"
+	case errors.Is(err, ErrInvalidStep):
+		response.StatusCode = 400
+		response.Message = "Step does not exist on this form"
+	case errors.Is(err, ErrInvalidResumeLink):
+		response.StatusCode = 403
+		response.Message = "Resume link is invalid or has expired"
+	case errors.Is(err, ErrResumeURLNotAllowed):
+		response.StatusCode = 400
+		response.Message = "resume_url must be a page on one of the form's allowed origins or the FormHub site"
+	case errors.Is(err, ErrResumeLinkRateLimited):
+		response.StatusCode = 429
+		response.Message = "A resume link was sent recently"
+	default:
+		log.Printf("Failed to handle saved progress: %v", err)
+		response.StatusCode = 500
+		response.Message = "Failed to process saved progress"
+	}
+
+	return response
+}
+
+func (s *PartialSubmissionService) getProgress(column string, formID uuid.UUID, value interface{}) (*models.PartialSubmission, error) {
+	query := `
+		SELECT id, form_id, session_id, data, current_step, email, submission_id,
+			completed_at, expires_at, created_at, updated_at
+		FROM form_partial_submissions
+		WHERE form_id = ? AND ` + column + ` = ? AND expires_at > ?
+	`
+
+	partial := &models.PartialSubmission{}
+	var dataJSON string
+	var email, submissionID sql.NullString
+	var completedAt sql.NullTime
+
+	err := s.db.QueryRow(query, formID, value, time.Now()).Scan(
+		&partial.ID, &partial.FormID, &partial.SessionID, &dataJSON, &partial.CurrentStep,
+		&email, &submissionID, &completedAt, &partial.ExpiresAt, &partial.CreatedAt, &partial.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrProgressNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved progress: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(dataJSON), &partial.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode saved progress: %w", err)
+	}
+	if partial.Data == nil {
+		partial.Data = make(map[string]interface{})
+	}
+	if email.Valid {
+		partial.Email = &email.String
+	}
+	if submissionID.Valid {
+		if id, err := uuid.Parse(submissionID.String); err == nil {
+			partial.SubmissionID = &id
+		}
+	}
+	if completedAt.Valid {
+		partial.CompletedAt = &completedAt.Time
+	}
+
+	return partial, nil
+}
+
+// generateProgressToken returns a random token for saved progress. Only its hash is stored.
+func generateProgressToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate progress token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashProgressToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkResumeLinkLimit allows one resume link per session per cooldown and a few per IP address
+// per hour, so the endpoint cannot be used to mail arbitrary addresses
+func (s *PartialSubmissionService) checkResumeLinkLimit(partial *models.PartialSubmission, ipAddress string) error {
+	ctx := context.Background()
+
+	ipKey := fmt.Sprintf("partial:resume_links:%s", ipAddress)
+	count, err := s.redis.Incr(ctx, ipKey).Result()
+	if err != nil {
+		log.Printf("Failed to check resume link rate limit: %v", err)
+		return nil
+	}
+	if count == 1 {
+		s.redis.Expire(ctx, ipKey, time.Hour)
+	}
+	if count > resumeLinksPerIPHour {
+		return ErrResumeLinkRateLimited
+	}
+
+	cooldownKey := fmt.Sprintf("partial:resume_link:%s", partial.ID)
+	allowed, err := s.redis.SetNX(ctx, cooldownKey, 1, resumeLinkCooldown).Result()
+	if err != nil {
+		log.Printf("Failed to check resume link cooldown: %v", err)
+		return nil
+	}
+	if !allowed {
+		return ErrResumeLinkRateLimited
+	}
+	return nil
+}
+
+// recordStep records a form_step_complete event and refreshes the step drop-off of the day the
+// respondent started, which is the funnel day their progress counts towards
+func (s *PartialSubmissionService) recordStep(form *models.Form, partial *models.PartialSubmission, step int, ipAddress, userAgent, referrer string) {
+	if s.analyticsService == nil {
+		return
+	}
+
+	event := submissionEvent(form, models.EventTypeFormStepComplete, ipAddress, userAgent, referrer, partial.SessionID)
+	event.EventData = map[string]interface{}{"step": step}
+
+	go func() {
+		if err := s.analyticsService.RecordEvent(context.Background(), event); err != nil {
+			log.Printf("Failed to record %s event for form %s: %v", event.EventType, event.FormID, err)
+		}
+		s.analyticsService.RefreshFormFunnel(form.ID, partial.CreatedAt)
+	}()
+}
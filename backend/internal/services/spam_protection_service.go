@@ -336,8 +336,9 @@ func (sps *SpamProtectionService) AnalyzeSubmission(formID string, data map[stri
 		}
 	}
 	
-	// 8. CAPTCHA verification
-	captchaRequired := sps.shouldRequireCaptcha(result.SpamScore, formConfig)
+	// 8. CAPTCHA verification; skipped when the form's own captcha already spent the token
+	captchaVerified, _ := metadata["captcha_verified"].(bool)
+	captchaRequired := !captchaVerified && sps.shouldRequireCaptcha(result.SpamScore, formConfig)
 	if captchaRequired {
 		result.CaptchaRequired = true
 		
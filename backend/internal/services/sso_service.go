@@ -0,0 +1,1269 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"formhub/internal/models"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+)
+
+// ErrInvalidSSOConnection is returned for SSO connection settings that fail validation
+var ErrInvalidSSOConnection = fmt.Errorf("invalid SSO connection")
+
+// ErrSSOConnectionNotFound is returned for unknown or disabled SSO connections
+var ErrSSOConnectionNotFound = fmt.Errorf("SSO connection not found")
+
+// ErrSSODomainTaken is returned when a domain is already routed to another connection
+var ErrSSODomainTaken = fmt.Errorf("email domain is already used by another SSO connection")
+
+// ErrInvalidSSOState is returned for an unknown, expired or mismatched sign-in state or login code
+var ErrInvalidSSOState = fmt.Errorf("invalid or expired SSO sign-in")
+
+// ErrSSOLoginRejected is returned when the identity provider's answer fails verification or
+// names a user the connection may not sign in
+var ErrSSOLoginRejected = fmt.Errorf("SSO sign-in rejected")
+
+// ErrSSORequired is returned for a password login to an account whose domain must use SSO
+var ErrSSORequired = fmt.Errorf("this account must sign in with SSO")
+
+const (
+	// ssoStateTTL is how long a user has to finish signing in at the identity provider
+	ssoStateTTL = 10 * time.Minute
+
+	// ssoLoginCodeTTL is how long the dashboard has to exchange a finished sign-in for tokens
+	ssoLoginCodeTTL = time.Minute
+
+	// oidcDiscoveryTTL is how long an issuer's discovery document and signing keys are cached
+	oidcDiscoveryTTL = time.Hour
+)
+
+// SAML attribute names identity providers commonly use for the user's email and name
+var (
+	samlEmailAttributes = []string{
+		"email", "mail", "emailaddress", "urn:oid:0.9.2342.19200300.100.1.3",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+	}
+	samlFirstNameAttributes = []string{
+		"firstname", "givenname", "urn:oid:2.5.4.42",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname",
+	}
+	samlLastNameAttributes = []string{
+		"lastname", "surname", "sn", "urn:oid:2.5.4.4",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname",
+	}
+)
+
+// SSOService signs users in through their company's identity provider, over OpenID Connect or as a
+// SAML 2.0 service provider. Users are matched to a connection by email domain, linked by the IdP's
+// subject, and created on first login when the connection allows it.
+//
+// A sign-in ends with a redirect to the dashboard carrying a short-lived login code, which the
+// dashboard exchanges for tokens through AuthService.CompleteSSOLogin, so tokens never appear in URLs.
+type SSOService struct {
+	db          *sql.DB
+	redis       *redis.Client
+	baseURL     string
+	frontendURL string
+	httpClient  *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*oidcProvider // Keyed by issuer
+}
+
+// ssoIdentity is the user an identity provider vouched for
+type ssoIdentity struct {
+	Subject   string
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// ssoState is what a sign-in remembers between leaving for the identity provider and coming back
+type ssoState struct {
+	ConnectionID  uuid.UUID `json:"connection_id"`
+	Nonce         string    `json:"nonce,omitempty"`           // OIDC nonce bound into the ID token
+	CodeVerifier  string    `json:"code_verifier,omitempty"`   // OIDC PKCE verifier
+	SAMLRequestID string    `json:"saml_request_id,omitempty"` // ID the SAML response must answer
+}
+
+func NewSSOService(db *sql.DB, redis *redis.Client, baseURL, frontendURL string) *SSOService {
+	return &SSOService{
+		db:          db,
+		redis:       redis,
+		baseURL:     baseURL,
+		frontendURL: frontendURL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		providers:   make(map[string]*oidcProvider),
+	}
+}
+
+// CreateConnection validates and stores a new connection
+func (s *SSOService) CreateConnection(req models.CreateSSOConnectionRequest) (*models.SSOConnection, error) {
+	conn := &models.SSOConnection{
+		ID:               uuid.New(),
+		WorkspaceID:      req.WorkspaceID,
+		Name:             strings.TrimSpace(req.Name),
+		Protocol:         req.Protocol,
+		Domains:          req.Domains,
+		DefaultRole:      req.DefaultRole,
+		JITProvisioning:  req.JITProvisioning == nil || *req.JITProvisioning,
+		EnforceSSO:       req.EnforceSSO,
+		OIDCIssuer:       strings.TrimRight(strings.TrimSpace(req.OIDCIssuer), "/"),
+		OIDCClientID:     strings.TrimSpace(req.OIDCClientID),
+		OIDCClientSecret: req.OIDCClientSecret,
+		SAMLIdPMetadata:  req.SAMLMetadataXML,
+		IsActive:         true,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+	if conn.DefaultRole == "" {
+		conn.DefaultRole = models.WorkspaceRoleMember
+	}
+
+	if conn.Protocol == models.SSOProtocolSAML && req.SAMLMetadataURL != "" {
+		metadata, err := s.fetchSAMLMetadata(req.SAMLMetadataURL)
+		if err != nil {
+			return nil, err
+		}
+		conn.SAMLIdPMetadata = metadata
+	}
+
+	if err := s.validateConnection(conn); err != nil {
+		return nil, err
+	}
+
+	var workspaceExists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM workspaces WHERE id = ?)`, conn.WorkspaceID).Scan(&workspaceExists); err != nil {
+		return nil, fmt.Errorf("failed to check workspace: %w", err)
+	}
+	if !workspaceExists {
+		return nil, fmt.Errorf("%w: workspace not found", ErrInvalidSSOConnection)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO sso_connections (id, workspace_id, name, protocol, default_role, jit_provisioning,
+			enforce_sso, oidc_issuer, oidc_client_id, oidc_client_secret, saml_idp_metadata, is_active,
+			created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		conn.ID, conn.WorkspaceID, conn.Name, conn.Protocol, conn.DefaultRole, conn.JITProvisioning,
+		conn.EnforceSSO, nullString(conn.OIDCIssuer), nullString(conn.OIDCClientID), nullString(conn.OIDCClientSecret),
+		nullString(conn.SAMLIdPMetadata), conn.IsActive, conn.CreatedAt, conn.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSO connection: %w", err)
+	}
+
+	if err := s.replaceDomains(tx, conn.ID, conn.Domains); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit SSO connection: %w", err)
+	}
+
+	s.describe(conn)
+	return conn, nil
+}
+
+// ListConnections returns every connection, active or not
+func (s *SSOService) ListConnections() ([]models.SSOConnection, error) {
+	rows, err := s.db.Query(`SELECT ` + ssoConnectionColumns + ` FROM sso_connections ORDER BY created_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SSO connections: %w", err)
+	}
+	defer rows.Close()
+
+	var connections []models.SSOConnection
+	for rows.Next() {
+		conn, err := scanSSOConnection(rows)
+		if err != nil {
+			return nil, err
+		}
+		connections = append(connections, *conn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get SSO connections: %w", err)
+	}
+
+	for i := range connections {
+		if connections[i].Domains, err = s.getDomains(connections[i].ID); err != nil {
+			return nil, err
+		}
+		s.describe(&connections[i])
+	}
+
+	return connections, nil
+}
+
+// GetConnection returns a connection by ID, active or not
+func (s *SSOService) GetConnection(connectionID uuid.UUID) (*models.SSOConnection, error) {
+	conn, err := scanSSOConnection(s.db.QueryRow(`SELECT `+ssoConnectionColumns+` FROM sso_connections WHERE id = ?`, connectionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSSOConnectionNotFound
+		}
+		return nil, err
+	}
+
+	if conn.Domains, err = s.getDomains(conn.ID); err != nil {
+		return nil, err
+	}
+	s.describe(conn)
+
+	return conn, nil
+}
+
+// UpdateConnection changes the fields present in req and revalidates the connection
+func (s *SSOService) UpdateConnection(connectionID uuid.UUID, req models.UpdateSSOConnectionRequest) (*models.SSOConnection, error) {
+	conn, err := s.GetConnection(connectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		conn.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Domains != nil {
+		conn.Domains = req.Domains
+	}
+	if req.DefaultRole != nil {
+		conn.DefaultRole = *req.DefaultRole
+	}
+	if req.JITProvisioning != nil {
+		conn.JITProvisioning = *req.JITProvisioning
+	}
+	if req.EnforceSSO != nil {
+		conn.EnforceSSO = *req.EnforceSSO
+	}
+	if req.OIDCIssuer != nil {
+		conn.OIDCIssuer = strings.TrimRight(strings.TrimSpace(*req.OIDCIssuer), "/")
+	}
+	if req.OIDCClientID != nil {
+		conn.OIDCClientID = strings.TrimSpace(*req.OIDCClientID)
+	}
+	if req.OIDCClientSecret != nil {
+		conn.OIDCClientSecret = *req.OIDCClientSecret
+	}
+	if req.SAMLMetadataXML != nil {
+		conn.SAMLIdPMetadata = *req.SAMLMetadataXML
+	}
+	if req.SAMLMetadataURL != nil && *req.SAMLMetadataURL != "" {
+		metadata, err := s.fetchSAMLMetadata(*req.SAMLMetadataURL)
+		if err != nil {
+			return nil, err
+		}
+		conn.SAMLIdPMetadata = metadata
+	}
+	if req.IsActive != nil {
+		conn.IsActive = *req.IsActive
+	}
+
+	if err := s.validateConnection(conn); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	conn.UpdatedAt = time.Now()
+	_, err = tx.Exec(`
+		UPDATE sso_connections SET name = ?, default_role = ?, jit_provisioning = ?, enforce_sso = ?,
+			oidc_issuer = ?, oidc_client_id = ?, oidc_client_secret = ?, saml_idp_metadata = ?, is_active = ?,
+			updated_at = ?
+		WHERE id = ?`,
+		conn.Name, conn.DefaultRole, conn.JITProvisioning, conn.EnforceSSO, nullString(conn.OIDCIssuer),
+		nullString(conn.OIDCClientID), nullString(conn.OIDCClientSecret), nullString(conn.SAMLIdPMetadata),
+		conn.IsActive, conn.UpdatedAt, conn.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update SSO connection: %w", err)
+	}
+
+	if req.Domains != nil {
+		if err := s.replaceDomains(tx, conn.ID, conn.Domains); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit SSO connection: %w", err)
+	}
+
+	return conn, nil
+}
+
+// DeleteConnection removes a connection. Users it provisioned keep their accounts and can set a
+// password through the reset flow.
+func (s *SSOService) DeleteConnection(connectionID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM sso_connections WHERE id = ?`, connectionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete SSO connection: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrSSOConnectionNotFound
+	}
+	return nil
+}
+
+// ConnectionForEmail returns the active connection that claims the email's domain
+func (s *SSOService) ConnectionForEmail(email string) (*models.SSOConnection, error) {
+	domain := emailDomain(email)
+	if domain == "" {
+		return nil, ErrSSOConnectionNotFound
+	}
+
+	var connectionID uuid.UUID
+	err := s.db.QueryRow(`
+		SELECT c.id FROM sso_connection_domains d
+		JOIN sso_connections c ON c.id = d.connection_id
+		WHERE d.domain = ? AND c.is_active = true`, domain,
+	).Scan(&connectionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSSOConnectionNotFound
+		}
+		return nil, fmt.Errorf("failed to find SSO connection: %w", err)
+	}
+
+	return s.GetConnection(connectionID)
+}
+
+// SSORequired reports whether the email belongs to a domain whose connection refuses password logins
+func (s *SSOService) SSORequired(email string) (bool, error) {
+	conn, err := s.ConnectionForEmail(email)
+	if err == ErrSSOConnectionNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return conn.EnforceSSO, nil
+}
+
+// StartLogin returns the identity provider URL that begins a sign-in through the connection.
+// loginHint, when set, is passed to OIDC providers to prefill the user's email.
+func (s *SSOService) StartLogin(connectionID uuid.UUID, loginHint string) (string, error) {
+	conn, err := s.activeConnection(connectionID)
+	if err != nil {
+		return "", err
+	}
+
+	stateToken, err := randomSSOToken()
+	if err != nil {
+		return "", err
+	}
+	state := ssoState{ConnectionID: conn.ID}
+
+	var redirectURL string
+	switch conn.Protocol {
+	case models.SSOProtocolOIDC:
+		config, _, err := s.oidcConfig(conn)
+		if err != nil {
+			return "", err
+		}
+
+		if state.Nonce, err = randomSSOToken(); err != nil {
+			return "", err
+		}
+		state.CodeVerifier = oauth2.GenerateVerifier()
+
+		options := []oauth2.AuthCodeOption{
+			oauth2.S256ChallengeOption(state.CodeVerifier),
+			oauth2.SetAuthURLParam("nonce", state.Nonce),
+		}
+		if loginHint != "" {
+			options = append(options, oauth2.SetAuthURLParam("login_hint", loginHint))
+		}
+		redirectURL = config.AuthCodeURL(stateToken, options...)
+
+	case models.SSOProtocolSAML:
+		sp, err := s.serviceProvider(conn)
+		if err != nil {
+			return "", err
+		}
+
+		authnRequest, err := sp.MakeAuthenticationRequest(
+			sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding,
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to create SAML request: %w", err)
+		}
+		state.SAMLRequestID = authnRequest.ID
+
+		u, err := authnRequest.Redirect(stateToken, sp)
+		if err != nil {
+			return "", fmt.Errorf("failed to create SAML request: %w", err)
+		}
+		redirectURL = u.String()
+
+	default:
+		return "", ErrInvalidSSOConnection
+	}
+
+	payload, _ := json.Marshal(state)
+	if err := s.redis.Set(context.Background(), ssoStateKey(stateToken), payload, ssoStateTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store SSO state: %w", err)
+	}
+
+	return redirectURL, nil
+}
+
+// HandleOIDCCallback verifies the provider's answer to StartLogin and returns a login code
+func (s *SSOService) HandleOIDCCallback(connectionID uuid.UUID, stateToken, code string) (string, error) {
+	state, err := s.consumeState(connectionID, stateToken)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := s.activeConnection(connectionID)
+	if err != nil {
+		return "", err
+	}
+	if conn.Protocol != models.SSOProtocolOIDC {
+		return "", ErrInvalidSSOState
+	}
+
+	identity, err := s.exchangeOIDCCode(conn, state, code)
+	if err != nil {
+		return "", err
+	}
+
+	return s.finishLogin(conn, identity)
+}
+
+// exchangeOIDCCode redeems the authorization code and returns the identity its ID token vouches
+// for, once the token's signature, claims and nonce check out
+func (s *SSOService) exchangeOIDCCode(conn *models.SSOConnection, state *ssoState, code string) (ssoIdentity, error) {
+	config, provider, err := s.oidcConfig(conn)
+	if err != nil {
+		return ssoIdentity{}, err
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, s.httpClient)
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(state.CodeVerifier))
+	if err != nil {
+		log.Printf("SSO connection %s: code exchange failed: %v", conn.ID, err)
+		return ssoIdentity{}, ErrSSOLoginRejected
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		log.Printf("SSO connection %s: token response has no id_token", conn.ID)
+		return ssoIdentity{}, ErrSSOLoginRejected
+	}
+
+	claims, err := s.verifyIDToken(provider, conn.OIDCClientID, rawIDToken)
+	if err != nil {
+		log.Printf("SSO connection %s: ID token rejected: %v", conn.ID, err)
+		return ssoIdentity{}, ErrSSOLoginRejected
+	}
+	if claims.Nonce != state.Nonce {
+		log.Printf("SSO connection %s: ID token nonce mismatch", conn.ID)
+		return ssoIdentity{}, ErrSSOLoginRejected
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		log.Printf("SSO connection %s: email %s is not verified at the IdP", conn.ID, claims.Email)
+		return ssoIdentity{}, ErrSSOLoginRejected
+	}
+
+	identity := ssoIdentity{
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		FirstName: claims.GivenName,
+		LastName:  claims.FamilyName,
+	}
+	if identity.FirstName == "" && identity.LastName == "" && claims.Name != "" {
+		identity.FirstName, identity.LastName, _ = strings.Cut(claims.Name, " ")
+	}
+
+	return identity, nil
+}
+
+// HandleSAMLResponse verifies a SAML response posted to the connection's ACS URL and returns a
+// login code. Only responses to a request from StartLogin are accepted; IdP-initiated sign-in is not.
+func (s *SSOService) HandleSAMLResponse(connectionID uuid.UUID, r *http.Request) (string, error) {
+	if err := r.ParseForm(); err != nil {
+		return "", ErrInvalidSSOState
+	}
+
+	state, err := s.consumeState(connectionID, r.PostForm.Get("RelayState"))
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := s.activeConnection(connectionID)
+	if err != nil {
+		return "", err
+	}
+	if conn.Protocol != models.SSOProtocolSAML {
+		return "", ErrInvalidSSOState
+	}
+
+	identity, err := s.verifySAMLResponse(conn, state, r)
+	if err != nil {
+		return "", err
+	}
+
+	return s.finishLogin(conn, identity)
+}
+
+// verifySAMLResponse checks the SAML response answers the request StartLogin made and returns the
+// identity its assertion vouches for
+func (s *SSOService) verifySAMLResponse(conn *models.SSOConnection, state *ssoState, r *http.Request) (ssoIdentity, error) {
+	sp, err := s.serviceProvider(conn)
+	if err != nil {
+		return ssoIdentity{}, err
+	}
+
+	assertion, err := sp.ParseResponse(r, []string{state.SAMLRequestID})
+	if err != nil {
+		if invalid, ok := err.(*saml.InvalidResponseError); ok {
+			err = invalid.PrivateErr
+		}
+		log.Printf("SSO connection %s: SAML response rejected: %v", conn.ID, err)
+		return ssoIdentity{}, ErrSSOLoginRejected
+	}
+
+	identity := ssoIdentity{}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		identity.Subject = assertion.Subject.NameID.Value
+	}
+	identity.Email = samlAttribute(assertion, samlEmailAttributes)
+	if identity.Email == "" && strings.Contains(identity.Subject, "@") {
+		identity.Email = identity.Subject
+	}
+	identity.FirstName = samlAttribute(assertion, samlFirstNameAttributes)
+	identity.LastName = samlAttribute(assertion, samlLastNameAttributes)
+
+	return identity, nil
+}
+
+// SAMLMetadata returns the service provider metadata XML to register with the identity provider
+func (s *SSOService) SAMLMetadata(connectionID uuid.UUID) ([]byte, error) {
+	conn, err := s.GetConnection(connectionID)
+	if err != nil {
+		return nil, err
+	}
+	if conn.Protocol != models.SSOProtocolSAML {
+		return nil, ErrSSOConnectionNotFound
+	}
+
+	sp, err := s.serviceProvider(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SAML metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// ConsumeLoginCode returns the user a finished sign-in belongs to. Each code works once.
+func (s *SSOService) ConsumeLoginCode(code string) (uuid.UUID, error) {
+	value, err := s.redis.GetDel(context.Background(), ssoLoginCodeKey(code)).Result()
+	if err == redis.Nil {
+		return uuid.Nil, ErrInvalidSSOState
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get SSO login code: %w", err)
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, ErrInvalidSSOState
+	}
+	return userID, nil
+}
+
+// CallbackURL is where the dashboard receives a finished sign-in, as a login code or an error
+func (s *SSOService) CallbackURL(code string, loginErr error) string {
+	query := url.Values{}
+	if loginErr != nil {
+		query.Set("error", loginErr.Error())
+	} else {
+		query.Set("code", code)
+	}
+	return s.frontendURL + "/sso/callback?" + query.Encode()
+}
+
+// finishLogin provisions or links the user and issues the login code for the dashboard
+func (s *SSOService) finishLogin(conn *models.SSOConnection, identity ssoIdentity) (string, error) {
+	userID, err := s.provisionUser(conn, identity)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := randomSSOToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(context.Background(), ssoLoginCodeKey(code), userID.String(), ssoLoginCodeTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store SSO login code: %w", err)
+	}
+
+	return code, nil
+}
+
+// provisionUser finds the user for an identity the connection vouched for: by the IdP subject,
+// then by email, and finally by creating the account when just-in-time provisioning is on.
+// The user is added to the connection's workspace if they are not a member yet.
+func (s *SSOService) provisionUser(conn *models.SSOConnection, identity ssoIdentity) (uuid.UUID, error) {
+	identity.Email = strings.ToLower(strings.TrimSpace(identity.Email))
+	if identity.Subject == "" || identity.Email == "" {
+		log.Printf("SSO connection %s: identity has no subject or email", conn.ID)
+		return uuid.Nil, ErrSSOLoginRejected
+	}
+
+	// The IdP may only vouch for addresses in the domains the connection claims
+	if !domainListed(conn.Domains, emailDomain(identity.Email)) {
+		log.Printf("SSO connection %s: email %s is outside the connection's domains", conn.ID, identity.Email)
+		return uuid.Nil, ErrSSOLoginRejected
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	var userID uuid.UUID
+	var isActive bool
+	err = tx.QueryRow(`
+		SELECT u.id, u.is_active FROM sso_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.connection_id = ? AND i.subject = ?`, conn.ID, identity.Subject,
+	).Scan(&userID, &isActive)
+
+	switch {
+	case err == nil:
+		_, err = tx.Exec(
+			`UPDATE sso_identities SET last_login_at = ? WHERE connection_id = ? AND subject = ?`,
+			now, conn.ID, identity.Subject,
+		)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to update SSO identity: %w", err)
+		}
+
+	case err == sql.ErrNoRows:
+		err = tx.QueryRow(`SELECT id, is_active FROM users WHERE email = ?`, identity.Email).Scan(&userID, &isActive)
+		if err == sql.ErrNoRows {
+			if !conn.JITProvisioning {
+				log.Printf("SSO connection %s: no account for %s and provisioning is off", conn.ID, identity.Email)
+				return uuid.Nil, ErrSSOLoginRejected
+			}
+			if userID, err = s.createUser(tx, conn, identity, now); err != nil {
+				return uuid.Nil, err
+			}
+			isActive = true
+		} else if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO sso_identities (connection_id, subject, user_id, last_login_at, created_at)
+			VALUES (?, ?, ?, ?, ?)`,
+			conn.ID, identity.Subject, userID, now, now,
+		)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to link SSO identity: %w", err)
+		}
+
+	default:
+		return uuid.Nil, fmt.Errorf("failed to get SSO identity: %w", err)
+	}
+
+	if !isActive {
+		log.Printf("SSO connection %s: user %s is deactivated", conn.ID, userID)
+		return uuid.Nil, ErrSSOLoginRejected
+	}
+
+	// Existing members keep their current role
+	_, err = tx.Exec(`
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE role = role`,
+		conn.WorkspaceID, userID, conn.DefaultRole, now,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to add workspace member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit SSO sign-in: %w", err)
+	}
+
+	return userID, nil
+}
+
+// createUser provisions an account for an identity. It has no usable password, and its email
+// counts as verified because the identity provider vouched for it.
+func (s *SSOService) createUser(tx *sql.Tx, conn *models.SSOConnection, identity ssoIdentity, now time.Time) (uuid.UUID, error) {
+	userID := uuid.New()
+	firstName, lastName := identity.FirstName, identity.LastName
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO users (id, email, password_hash, first_name, last_name, company, plan_type, role,
+			email_verified_at, current_workspace_id, is_active, created_at, updated_at)
+		VALUES (?, ?, '', ?, ?, '', 'free', ?, ?, ?, true, ?, ?)`,
+		userID, identity.Email, firstName, lastName, models.RoleMember, now, conn.WorkspaceID, now, now,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	log.Printf("SSO connection %s provisioned user %s", conn.ID, userID)
+	return userID, nil
+}
+
+// consumeState takes the state a sign-in stored in StartLogin. Each state works once.
+func (s *SSOService) consumeState(connectionID uuid.UUID, stateToken string) (*ssoState, error) {
+	if stateToken == "" {
+		return nil, ErrInvalidSSOState
+	}
+
+	payload, err := s.redis.GetDel(context.Background(), ssoStateKey(stateToken)).Result()
+	if err == redis.Nil {
+		return nil, ErrInvalidSSOState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SSO state: %w", err)
+	}
+
+	var state ssoState
+	if err := json.Unmarshal([]byte(payload), &state); err != nil || state.ConnectionID != connectionID {
+		return nil, ErrInvalidSSOState
+	}
+	return &state, nil
+}
+
+func (s *SSOService) activeConnection(connectionID uuid.UUID) (*models.SSOConnection, error) {
+	conn, err := s.GetConnection(connectionID)
+	if err != nil {
+		return nil, err
+	}
+	if !conn.IsActive {
+		return nil, ErrSSOConnectionNotFound
+	}
+	return conn, nil
+}
+
+func (s *SSOService) validateConnection(conn *models.SSOConnection) error {
+	if conn.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSSOConnection)
+	}
+	if conn.DefaultRole == models.WorkspaceRoleOwner || !conn.DefaultRole.Valid() {
+		return fmt.Errorf("%w: invalid default role %q", ErrInvalidSSOConnection, conn.DefaultRole)
+	}
+
+	if len(conn.Domains) == 0 {
+		return fmt.Errorf("%w: at least one email domain is required", ErrInvalidSSOConnection)
+	}
+	domains := make([]string, 0, len(conn.Domains))
+	for _, domain := range conn.Domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" || strings.ContainsAny(domain, "@/ ") || !strings.Contains(domain, ".") {
+			return fmt.Errorf("%w: invalid domain %q", ErrInvalidSSOConnection, domain)
+		}
+		if !domainListed(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	conn.Domains = domains
+
+	switch conn.Protocol {
+	case models.SSOProtocolOIDC:
+		if conn.OIDCIssuer == "" || conn.OIDCClientID == "" || conn.OIDCClientSecret == "" {
+			return fmt.Errorf("%w: OIDC connections need an issuer, client ID and client secret", ErrInvalidSSOConnection)
+		}
+		if u, err := url.Parse(conn.OIDCIssuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: invalid OIDC issuer", ErrInvalidSSOConnection)
+		}
+		if _, err := s.discover(conn.OIDCIssuer); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSSOConnection, err)
+		}
+		conn.SAMLIdPMetadata = ""
+
+	case models.SSOProtocolSAML:
+		metadata, err := parseIdPMetadata(conn.SAMLIdPMetadata)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSSOConnection, err)
+		}
+		if len(metadata.IDPSSODescriptors) == 0 {
+			return fmt.Errorf("%w: SAML metadata has no IdP SSO descriptor", ErrInvalidSSOConnection)
+		}
+		conn.OIDCIssuer, conn.OIDCClientID, conn.OIDCClientSecret = "", "", ""
+
+	default:
+		return fmt.Errorf("%w: protocol must be oidc or saml", ErrInvalidSSOConnection)
+	}
+
+	return nil
+}
+
+// replaceDomains points the given domains at the connection, refusing domains another connection owns
+func (s *SSOService) replaceDomains(tx *sql.Tx, connectionID uuid.UUID, domains []string) error {
+	if _, err := tx.Exec(`DELETE FROM sso_connection_domains WHERE connection_id = ?`, connectionID); err != nil {
+		return fmt.Errorf("failed to delete SSO domains: %w", err)
+	}
+
+	for _, domain := range domains {
+		var owner uuid.UUID
+		err := tx.QueryRow(`SELECT connection_id FROM sso_connection_domains WHERE domain = ?`, domain).Scan(&owner)
+		if err == nil {
+			return fmt.Errorf("%w: %s", ErrSSODomainTaken, domain)
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("failed to check SSO domain: %w", err)
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO sso_connection_domains (domain, connection_id) VALUES (?, ?)`, domain, connectionID,
+		); err != nil {
+			return fmt.Errorf("failed to store SSO domain: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SSOService) getDomains(connectionID uuid.UUID) ([]string, error) {
+	rows, err := s.db.Query(`SELECT domain FROM sso_connection_domains WHERE connection_id = ? ORDER BY domain`, connectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SSO domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := []string{}
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, fmt.Errorf("failed to scan SSO domain: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// describe fills in the URLs an administrator registers at the identity provider
+func (s *SSOService) describe(conn *models.SSOConnection) {
+	base := s.connectionURL(conn.ID)
+	conn.LoginURL = base + "/login"
+	switch conn.Protocol {
+	case models.SSOProtocolOIDC:
+		conn.RedirectURL = base + "/oidc/callback"
+	case models.SSOProtocolSAML:
+		conn.SAMLMetadataURL = base + "/saml/metadata"
+		conn.SAMLACSURL = base + "/saml/acs"
+	}
+}
+
+func (s *SSOService) connectionURL(connectionID uuid.UUID) string {
+	return fmt.Sprintf("%s/api/v1/auth/sso/%s", s.baseURL, connectionID)
+}
+
+// OpenID Connect
+
+// oidcProvider is an issuer's discovery document and signing keys
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	keys      map[string]interface{} // Public keys by key ID
+	fetchedAt time.Time
+	keysAt    time.Time
+}
+
+// oidcClaims are the ID token claims FormHub reads
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func (s *SSOService) oidcConfig(conn *models.SSOConnection) (*oauth2.Config, *oidcProvider, error) {
+	provider, err := s.discover(conn.OIDCIssuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &oauth2.Config{
+		ClientID:     conn.OIDCClientID,
+		ClientSecret: conn.OIDCClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider.AuthorizationEndpoint,
+			TokenURL: provider.TokenEndpoint,
+		},
+		RedirectURL: s.connectionURL(conn.ID) + "/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	}, provider, nil
+}
+
+// discover returns the issuer's discovery document, cached for oidcDiscoveryTTL
+func (s *SSOService) discover(issuer string) (*oidcProvider, error) {
+	s.mu.Lock()
+	provider, ok := s.providers[issuer]
+	s.mu.Unlock()
+	if ok && time.Since(provider.fetchedAt) < oidcDiscoveryTTL {
+		return provider, nil
+	}
+
+	provider = &oidcProvider{}
+	if err := s.getJSON(issuer+"/.well-known/openid-configuration", provider); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC discovery document: %w", err)
+	}
+	if strings.TrimRight(provider.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC discovery document is for issuer %q", provider.Issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document is missing endpoints")
+	}
+	provider.fetchedAt = time.Now()
+
+	s.mu.Lock()
+	s.providers[issuer] = provider
+	s.mu.Unlock()
+
+	return provider, nil
+}
+
+// verifyIDToken checks the ID token's signature against the issuer's keys and its issuer,
+// audience and expiry
+func (s *SSOService) verifyIDToken(provider *oidcProvider, clientID, rawIDToken string) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		return s.signingKey(provider, keyID)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// signingKey returns the issuer's public key with the given ID, refetching the key set when the ID
+// is unknown so key rotation at the provider is picked up
+func (s *SSOService) signingKey(provider *oidcProvider, keyID string) (interface{}, error) {
+	s.mu.Lock()
+	keys, keysAt := provider.keys, provider.keysAt
+	s.mu.Unlock()
+
+	if key := pickKey(keys, keyID); key != nil && time.Since(keysAt) < oidcDiscoveryTTL {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := s.getJSON(provider.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC signing keys: %w", err)
+	}
+
+	keys = make(map[string]interface{})
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+
+	s.mu.Lock()
+	provider.keys, provider.keysAt = keys, time.Now()
+	s.mu.Unlock()
+
+	if key := pickKey(keys, keyID); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", keyID)
+}
+
+// pickKey returns the key with the ID, or the only key when the token names none
+func pickKey(keys map[string]interface{}, keyID string) interface{} {
+	if keyID == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[keyID]
+}
+
+// jsonWebKey is an RSA or EC public key from a JWKS document (RFC 7517)
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	decode := func(value string) (*big.Int, error) {
+		buf, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(buf), nil
+	}
+
+	switch k.KeyType {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("key is not on curve %s", k.Curve)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+func (s *SSOService) getJSON(rawURL string, v interface{}) error {
+	resp, err := s.httpClient.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// SAML
+
+// serviceProvider is FormHub's SAML service provider for one connection. Its entity ID is the
+// connection's metadata URL.
+func (s *SSOService) serviceProvider(conn *models.SSOConnection) (*saml.ServiceProvider, error) {
+	metadata, err := parseIdPMetadata(conn.SAMLIdPMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	base := s.connectionURL(conn.ID)
+	metadataURL, err := url.Parse(base + "/saml/metadata")
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML metadata URL: %w", err)
+	}
+	acsURL, err := url.Parse(base + "/saml/acs")
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML ACS URL: %w", err)
+	}
+
+	return &saml.ServiceProvider{
+		EntityID:          metadataURL.String(),
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       metadata,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		HTTPClient:        s.httpClient,
+	}, nil
+}
+
+func (s *SSOService) fetchSAMLMetadata(rawURL string) (string, error) {
+	resp, err := s.httpClient.Get(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to fetch SAML metadata: %v", ErrInvalidSSOConnection, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: SAML metadata URL returned HTTP %d", ErrInvalidSSOConnection, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read SAML metadata: %v", ErrInvalidSSOConnection, err)
+	}
+	return string(body), nil
+}
+
+// parseIdPMetadata reads IdP metadata given as an EntityDescriptor, or an EntitiesDescriptor
+// holding one
+func parseIdPMetadata(raw string) (*saml.EntityDescriptor, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("SAML IdP metadata is required")
+	}
+
+	entity := &saml.EntityDescriptor{}
+	if err := xml.Unmarshal([]byte(raw), entity); err == nil {
+		return entity, nil
+	}
+
+	entities := &saml.EntitiesDescriptor{}
+	if err := xml.Unmarshal([]byte(raw), entities); err != nil {
+		return nil, fmt.Errorf("invalid SAML IdP metadata: %v", err)
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+	return nil, fmt.Errorf("SAML metadata has no IdP entity")
+}
+
+// samlAttribute returns the first value of the first attribute whose name or friendly name matches
+// one of names, ignoring case
+func samlAttribute(assertion *saml.Assertion, names []string) string {
+	for _, name := range names {
+		for _, statement := range assertion.AttributeStatements {
+			for _, attribute := range statement.Attributes {
+				if !strings.EqualFold(attribute.Name, name) && !strings.EqualFold(attribute.FriendlyName, name) {
+					continue
+				}
+				for _, value := range attribute.Values {
+					if v := strings.TrimSpace(value.Value); v != "" {
+						return v
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Helpers
+
+const ssoConnectionColumns = `id, workspace_id, name, protocol, default_role, jit_provisioning, enforce_sso,
+	oidc_issuer, oidc_client_id, oidc_client_secret, saml_idp_metadata, is_active, created_at, updated_at`
+
+func scanSSOConnection(row rowScanner) (*models.SSOConnection, error) {
+	conn := &models.SSOConnection{}
+	var issuer, clientID, clientSecret, metadata sql.NullString
+	err := row.Scan(
+		&conn.ID, &conn.WorkspaceID, &conn.Name, &conn.Protocol, &conn.DefaultRole, &conn.JITProvisioning,
+		&conn.EnforceSSO, &issuer, &clientID, &clientSecret, &metadata, &conn.IsActive, &conn.CreatedAt, &conn.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan SSO connection: %w", err)
+	}
+
+	conn.OIDCIssuer = issuer.String
+	conn.OIDCClientID = clientID.String
+	conn.OIDCClientSecret = clientSecret.String
+	conn.SAMLIdPMetadata = metadata.String
+
+	return conn, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+func domainListed(domains []string, domain string) bool {
+	for _, d := range domains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+func randomSSOToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate SSO token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func ssoStateKey(state string) string {
+	return fmt.Sprintf("auth:sso_state:%s", state)
+}
+
+func ssoLoginCodeKey(code string) string {
+	return fmt.Sprintf("auth:sso_login:%s", code)
+}
@@ -0,0 +1,584 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"formhub/internal/models"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	testSSOBaseURL  = "https://api.formhub.test"
+	testOIDCClient  = "formhub-client"
+	testOIDCKeyID   = "key-1"
+	testOIDCCode    = "authorization-code"
+	testIdPEntityID = "https://idp.example.com/metadata"
+)
+
+func newTestSSOService(t *testing.T) *SSOService {
+	t.Helper()
+	return NewSSOService(nil, newFakeRedis(t), testSSOBaseURL, "https://app.formhub.test")
+}
+
+// Sign-in state
+
+func TestSSOConsumeState(t *testing.T) {
+	s := newTestSSOService(t)
+	connectionID := uuid.New()
+
+	storeState := func(token string, state ssoState) {
+		payload, _ := json.Marshal(state)
+		if err := s.redis.Set(context.Background(), ssoStateKey(token), payload, ssoStateTTL).Err(); err != nil {
+			t.Fatalf("storing state: %v", err)
+		}
+	}
+
+	storeState("good", ssoState{ConnectionID: connectionID, Nonce: "nonce-1"})
+	state, err := s.consumeState(connectionID, "good")
+	if err != nil {
+		t.Fatalf("consumeState: %v", err)
+	}
+	if state.Nonce != "nonce-1" {
+		t.Errorf("nonce = %q; want nonce-1", state.Nonce)
+	}
+
+	if _, err := s.consumeState(connectionID, "good"); !errors.Is(err, ErrInvalidSSOState) {
+		t.Errorf("reused state: err = %v; want ErrInvalidSSOState", err)
+	}
+	if _, err := s.consumeState(connectionID, ""); !errors.Is(err, ErrInvalidSSOState) {
+		t.Errorf("empty state: err = %v; want ErrInvalidSSOState", err)
+	}
+	if _, err := s.consumeState(connectionID, "unknown"); !errors.Is(err, ErrInvalidSSOState) {
+		t.Errorf("unknown state: err = %v; want ErrInvalidSSOState", err)
+	}
+
+	storeState("other", ssoState{ConnectionID: uuid.New()})
+	if _, err := s.consumeState(connectionID, "other"); !errors.Is(err, ErrInvalidSSOState) {
+		t.Errorf("state of another connection: err = %v; want ErrInvalidSSOState", err)
+	}
+}
+
+// OpenID Connect
+
+// mockOIDCProvider is an issuer serving discovery, signing keys and a token endpoint that answers
+// testOIDCCode with idToken
+type mockOIDCProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	verifier string // PKCE verifier the token endpoint expects
+
+	mu      sync.Mutex
+	idToken string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	p := &mockOIDCProvider{key: newTestRSAKey(t), verifier: "pkce-verifier-0123456789-0123456789-0123456789"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testOIDCKeyID,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != testOIDCCode || r.PostFormValue("code_verifier") != p.verifier {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		p.mu.Lock()
+		idToken := p.idToken
+		p.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// claims returns valid ID token claims for the nonce
+func (p *mockOIDCProvider) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            "user-123",
+		"aud":            testOIDCClient,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+}
+
+// issue makes the token endpoint answer with claims signed by key
+func (p *mockOIDCProvider) issue(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testOIDCKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing ID token: %v", err)
+	}
+	p.mu.Lock()
+	p.idToken = signed
+	p.mu.Unlock()
+}
+
+func (p *mockOIDCProvider) connection() *models.SSOConnection {
+	return &models.SSOConnection{
+		ID:           uuid.New(),
+		Protocol:     models.SSOProtocolOIDC,
+		OIDCIssuer:   p.server.URL,
+		OIDCClientID: testOIDCClient,
+		IsActive:     true,
+	}
+}
+
+func TestSSOOIDCLogin(t *testing.T) {
+	s := newTestSSOService(t)
+	p := newMockOIDCProvider(t)
+	p.issue(t, p.key, p.claims("nonce-1"))
+
+	state := &ssoState{Nonce: "nonce-1", CodeVerifier: p.verifier}
+	identity, err := s.exchangeOIDCCode(p.connection(), state, testOIDCCode)
+	if err != nil {
+		t.Fatalf("exchangeOIDCCode: %v", err)
+	}
+
+	want := ssoIdentity{Subject: "user-123", Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"}
+	if identity != want {
+		t.Errorf("identity = %+v; want %+v", identity, want)
+	}
+}
+
+func TestSSOOIDCLoginRejected(t *testing.T) {
+	otherKey := newTestRSAKey(t)
+
+	tests := []struct {
+		name     string
+		key      func(p *mockOIDCProvider) *rsa.PrivateKey
+		claims   func(claims jwt.MapClaims)
+		nonce    string
+		verifier string
+	}{
+		{
+			name: "bad signature",
+			key:  func(*mockOIDCProvider) *rsa.PrivateKey { return otherKey },
+		},
+		{
+			name: "expired token",
+			claims: func(claims jwt.MapClaims) {
+				claims["iat"] = time.Now().Add(-time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-10 * time.Minute).Unix()
+			},
+		},
+		{
+			name:   "missing expiry",
+			claims: func(claims jwt.MapClaims) { delete(claims, "exp") },
+		},
+		{
+			name:  "nonce mismatch",
+			nonce: "nonce-from-another-sign-in",
+		},
+		{
+			name:   "missing nonce",
+			claims: func(claims jwt.MapClaims) { delete(claims, "nonce") },
+		},
+		{
+			name:   "wrong audience",
+			claims: func(claims jwt.MapClaims) { claims["aud"] = "another-client" },
+		},
+		{
+			name:   "wrong issuer",
+			claims: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" },
+		},
+		{
+			name:   "unverified email",
+			claims: func(claims jwt.MapClaims) { claims["email_verified"] = false },
+		},
+		{
+			name:     "wrong PKCE verifier",
+			verifier: "another-verifier-0123456789-0123456789-0123456789",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSSOService(t)
+			p := newMockOIDCProvider(t)
+
+			key := p.key
+			if tt.key != nil {
+				key = tt.key(p)
+			}
+			claims := p.claims("nonce-1")
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			p.issue(t, key, claims)
+
+			state := &ssoState{Nonce: "nonce-1", CodeVerifier: p.verifier}
+			if tt.nonce != "" {
+				state.Nonce = tt.nonce
+			}
+			if tt.verifier != "" {
+				state.CodeVerifier = tt.verifier
+			}
+
+			if _, err := s.exchangeOIDCCode(p.connection(), state, testOIDCCode); !errors.Is(err, ErrSSOLoginRejected) {
+				t.Errorf("err = %v; want ErrSSOLoginRejected", err)
+			}
+		})
+	}
+}
+
+// SAML
+
+// testIdP is a SAML identity provider whose metadata a connection trusts
+type testIdP struct {
+	*saml.IdentityProvider
+	metadata string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key := newTestRSAKey(t)
+	idp := &saml.IdentityProvider{
+		Key:         key,
+		Certificate: newTestCertificate(t, key),
+		MetadataURL: mustParseURL(t, testIdPEntityID),
+		SSOURL:      mustParseURL(t, "https://idp.example.com/sso"),
+	}
+
+	metadata, err := xml.Marshal(idp.Metadata())
+	if err != nil {
+		t.Fatalf("encoding IdP metadata: %v", err)
+	}
+	return &testIdP{IdentityProvider: idp, metadata: string(metadata)}
+}
+
+// respond returns the ACS request carrying a response to requestID, with an assertion for audience
+func (idp *testIdP) respond(t *testing.T, sp *saml.ServiceProvider, requestID, audience string) *http.Request {
+	t.Helper()
+	spMetadata := sp.Metadata()
+	spMetadata.EntityID = audience
+
+	req := &saml.IdpAuthnRequest{
+		IDP:                     idp.IdentityProvider,
+		HTTPRequest:             httptest.NewRequest(http.MethodGet, "https://idp.example.com/sso", nil),
+		Request:                 saml.AuthnRequest{ID: requestID, IssueInstant: saml.TimeNow()},
+		ServiceProviderMetadata: spMetadata,
+		SPSSODescriptor:         &spMetadata.SPSSODescriptors[0],
+		ACSEndpoint:             &spMetadata.SPSSODescriptors[0].AssertionConsumerServices[0],
+		Now:                     saml.TimeNow(),
+	}
+	session := &saml.Session{
+		NameID:        "jane@example.com",
+		NameIDFormat:  string(saml.EmailAddressNameIDFormat),
+		UserGivenName: "Jane",
+		UserSurname:   "Doe",
+		CreateTime:    saml.TimeNow(),
+		Index:         "session-1",
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		t.Fatalf("making assertion: %v", err)
+	}
+	if err := req.MakeResponse(); err != nil {
+		t.Fatalf("making response: %v", err)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(req.ResponseEl)
+	response, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("encoding response: %v", err)
+	}
+
+	form := url.Values{"SAMLResponse": {base64.StdEncoding.EncodeToString(response)}}
+	r := httptest.NewRequest(http.MethodPost, sp.AcsURL.String(), strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := r.ParseForm(); err != nil {
+		t.Fatalf("parsing ACS form: %v", err)
+	}
+	return r
+}
+
+func newTestSAMLConnection(t *testing.T, s *SSOService, idp *testIdP) (*models.SSOConnection, *saml.ServiceProvider) {
+	t.Helper()
+	conn := &models.SSOConnection{
+		ID:              uuid.New(),
+		Protocol:        models.SSOProtocolSAML,
+		SAMLIdPMetadata: idp.metadata,
+		IsActive:        true,
+	}
+	sp, err := s.serviceProvider(conn)
+	if err != nil {
+		t.Fatalf("serviceProvider: %v", err)
+	}
+	return conn, sp
+}
+
+func TestSSOSAMLResponse(t *testing.T) {
+	s := newTestSSOService(t)
+	idp := newTestIdP(t)
+	conn, sp := newTestSAMLConnection(t, s, idp)
+
+	r := idp.respond(t, sp, "id-request-1", sp.EntityID)
+	identity, err := s.verifySAMLResponse(conn, &ssoState{SAMLRequestID: "id-request-1"}, r)
+	if err != nil {
+		t.Fatalf("verifySAMLResponse: %v", err)
+	}
+
+	want := ssoIdentity{Subject: "jane@example.com", Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"}
+	if identity != want {
+		t.Errorf("identity = %+v; want %+v", identity, want)
+	}
+}
+
+func TestSSOSAMLResponseRejectsBadSignature(t *testing.T) {
+	s := newTestSSOService(t)
+	idp := newTestIdP(t)
+	conn, sp := newTestSAMLConnection(t, s, idp)
+
+	// Same entity ID as the trusted IdP, different signing key
+	impostor := newTestIdP(t)
+	r := impostor.respond(t, sp, "id-request-1", sp.EntityID)
+
+	if _, err := s.verifySAMLResponse(conn, &ssoState{SAMLRequestID: "id-request-1"}, r); !errors.Is(err, ErrSSOLoginRejected) {
+		t.Errorf("err = %v; want ErrSSOLoginRejected", err)
+	}
+}
+
+func TestSSOSAMLResponseRejectsWrongAudience(t *testing.T) {
+	s := newTestSSOService(t)
+	idp := newTestIdP(t)
+	conn, sp := newTestSAMLConnection(t, s, idp)
+
+	r := idp.respond(t, sp, "id-request-1", "https://other-sp.example.com/saml/metadata")
+
+	if _, err := s.verifySAMLResponse(conn, &ssoState{SAMLRequestID: "id-request-1"}, r); !errors.Is(err, ErrSSOLoginRejected) {
+		t.Errorf("err = %v; want ErrSSOLoginRejected", err)
+	}
+}
+
+func TestSSOSAMLResponseRejectsUnrequestedResponse(t *testing.T) {
+	s := newTestSSOService(t)
+	idp := newTestIdP(t)
+	conn, sp := newTestSAMLConnection(t, s, idp)
+
+	r := idp.respond(t, sp, "id-request-1", sp.EntityID)
+
+	if _, err := s.verifySAMLResponse(conn, &ssoState{SAMLRequestID: "id-request-2"}, r); !errors.Is(err, ErrSSOLoginRejected) {
+		t.Errorf("err = %v; want ErrSSOLoginRejected", err)
+	}
+}
+
+// Helpers
+
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	return key
+}
+
+func newTestCertificate(t *testing.T, key *rsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+	return cert
+}
+
+func mustParseURL(t *testing.T, rawURL string) url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", rawURL, err)
+	}
+	return *u
+}
+
+// newFakeRedis returns a client for an in-memory stand-in that speaks enough RESP2 for the SSO
+// state and login codes: SET, GET, GETDEL and DEL. Expiry is ignored.
+func newFakeRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	values := make(map[string]string)
+
+	handle := func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch strings.ToUpper(args[0]) {
+		case "SET":
+			values[args[1]] = args[2]
+			return "+OK\r\n"
+		case "GET", "GETDEL":
+			value, ok := values[args[1]]
+			if !ok {
+				return "$-1\r\n"
+			}
+			if strings.EqualFold(args[0], "GETDEL") {
+				delete(values, args[1])
+			}
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+		case "DEL":
+			deleted := 0
+			for _, key := range args[1:] {
+				if _, ok := values[key]; ok {
+					delete(values, key)
+					deleted++
+				}
+			}
+			return fmt.Sprintf(":%d\r\n", deleted)
+		case "INCR":
+			count, _ := strconv.Atoi(values[args[1]])
+			count++
+			values[args[1]] = strconv.Itoa(count)
+			return fmt.Sprintf(":%d\r\n", count)
+		case "EXPIRE":
+			if _, ok := values[args[1]]; !ok {
+				return ":0\r\n"
+			}
+			return ":1\r\n"
+		}
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readRESPCommand(reader)
+					if err != nil {
+						return
+					}
+					if _, err := io.WriteString(conn, handle(args)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{
+		Addr:             listener.Addr().String(),
+		Protocol:         2,
+		DisableIndentity: true,
+	})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// readRESPCommand reads one command sent as an array of bulk strings
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	readLength := func(prefix byte) (int, error) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if len(line) < 3 || line[0] != prefix {
+			return 0, fmt.Errorf("unexpected RESP line %q", line)
+		}
+		return strconv.Atoi(strings.TrimSpace(line[1:]))
+	}
+
+	count, err := readLength('*')
+	if err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("empty RESP command")
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		size, err := readLength('$')
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
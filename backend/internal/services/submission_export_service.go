@@ -0,0 +1,360 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"formhub/internal/models"
+	"formhub/pkg/utils"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportFormat identifies a submission export file format
+type ExportFormat string
+
+const (
+	ExportFormatCSV    ExportFormat = "csv"
+	ExportFormatXLSX   ExportFormat = "xlsx"
+	ExportFormatNDJSON ExportFormat = "ndjson"
+)
+
+const (
+	exportPageSize    = 500
+	exportFilesColumn = "files"
+)
+
+// Fixed columns written before the dynamic data columns
+var exportBaseColumns = []string{
+	"id", "created_at", "ip_address", "user_agent", "referrer",
+	"is_spam", "spam_score", "spam_action", "email_sent", "webhook_sent",
+}
+
+// SubmissionExportService streams a form's submissions as CSV, XLSX or NDJSON
+type SubmissionExportService struct {
+	submissionService      *SubmissionService
+	fieldValidationService *FieldValidationService
+	urlSigner              *utils.URLSigner
+	linkTTL                time.Duration // Lifetime of the signed file links written into exports
+}
+
+func NewSubmissionExportService(submissionService *SubmissionService, fieldValidationService *FieldValidationService, urlSigner *utils.URLSigner, linkTTL time.Duration) *SubmissionExportService {
+	return &SubmissionExportService{
+		submissionService:      submissionService,
+		fieldValidationService: fieldValidationService,
+		urlSigner:              urlSigner,
+		linkTTL:                linkTTL,
+	}
+}
+
+// ContentType returns the MIME type for an export format
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ExportFormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// IsValid reports whether the format is supported
+func (f ExportFormat) IsValid() bool {
+	return f == ExportFormatCSV || f == ExportFormatXLSX || f == ExportFormatNDJSON
+}
+
+// exportRowWriter is implemented by each output format
+type exportRowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(columns []string, values []interface{}) error
+	Close() error
+}
+
+// ExportSubmissions writes every submission of a form matching params to w.
+// Rows are read page by page so memory use does not grow with the form size.
+func (s *SubmissionExportService) ExportSubmissions(w io.Writer, form *models.Form, format ExportFormat, params models.SubmissionListParams) error {
+	columns, fileColumns, err := s.buildColumns(form.ID)
+	if err != nil {
+		return err
+	}
+
+	writer, err := newExportRowWriter(w, format, form.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.WriteHeader(columns); err != nil {
+		return fmt.Errorf("failed to write export header: %w", err)
+	}
+
+	params.Cursor = ""
+	params.Limit = exportPageSize
+
+	for {
+		page, err := s.submissionService.listSubmissionsPage(form.ID, params)
+		if err != nil {
+			return err
+		}
+
+		for _, submission := range page.Submissions {
+			values := s.buildRow(submission, columns, fileColumns)
+			if err := writer.WriteRow(columns, values); err != nil {
+				return fmt.Errorf("failed to write export row: %w", err)
+			}
+		}
+
+		if !page.HasMore {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+
+	return writer.Close()
+}
+
+// buildColumns returns the export columns: fixed columns, then form fields in field_order,
+// then any other keys seen in submission data, then a catch-all column for files that
+// do not belong to a known file field.
+func (s *SubmissionExportService) buildColumns(formID uuid.UUID) ([]string, map[string]bool, error) {
+	fields, err := s.fieldValidationService.getFormFields(formID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load form fields: %w", err)
+	}
+
+	dataKeys, err := s.submissionService.getDataKeys(formID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := append([]string{}, exportBaseColumns...)
+	seen := make(map[string]bool)
+	for _, column := range columns {
+		seen[column] = true
+	}
+
+	fileColumns := make(map[string]bool)
+	for _, field := range fields {
+		if seen[field.Name] {
+			continue
+		}
+		seen[field.Name] = true
+		columns = append(columns, field.Name)
+		if field.Type == models.FieldTypeFile {
+			fileColumns[field.Name] = true
+		}
+	}
+
+	sort.Strings(dataKeys)
+	for _, key := range dataKeys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		columns = append(columns, key)
+	}
+
+	if !seen[exportFilesColumn] {
+		columns = append(columns, exportFilesColumn)
+		fileColumns[exportFilesColumn] = true
+	}
+
+	return columns, fileColumns, nil
+}
+
+func (s *SubmissionExportService) buildRow(submission models.Submission, columns []string, fileColumns map[string]bool) []interface{} {
+	base := map[string]interface{}{
+		"id":           submission.ID.String(),
+		"created_at":   submission.CreatedAt.UTC().Format(time.RFC3339),
+		"ip_address":   submission.IPAddress,
+		"user_agent":   submission.UserAgent,
+		"referrer":     submission.Referrer,
+		"is_spam":      submission.IsSpam,
+		"spam_score":   submission.SpamScore,
+		"spam_action":  string(submission.SpamAction),
+		"email_sent":   submission.EmailSent,
+		"webhook_sent": submission.WebhookSent,
+	}
+
+	// Group signed links by the file field they were uploaded to
+	links := make(map[string][]string)
+	for _, file := range submission.Files {
+		column := file.FieldName
+		if !fileColumns[column] {
+			column = exportFilesColumn
+		}
+		links[column] = append(links[column], s.urlSigner.SignFileURL(file.ID.String(), s.linkTTL))
+	}
+
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		if value, ok := base[column]; ok {
+			values[i] = value
+			continue
+		}
+		if fileLinks, ok := links[column]; ok {
+			values[i] = fileLinks
+			continue
+		}
+		values[i] = submission.Data[column]
+	}
+
+	return values
+}
+
+func newExportRowWriter(w io.Writer, format ExportFormat, sheetName string) (exportRowWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return &csvExportWriter{writer: csv.NewWriter(w)}, nil
+	case ExportFormatXLSX:
+		xlsx, err := utils.NewXLSXWriter(w, xlsxSheetName(sheetName))
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxExportWriter{writer: xlsx}, nil
+	case ExportFormatNDJSON:
+		return &ndjsonExportWriter{writer: bufio.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvExportWriter) WriteHeader(columns []string) error {
+	return c.writer.Write(columns)
+}
+
+func (c *csvExportWriter) WriteRow(columns []string, values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		// Escape the formatted cell, since lists and numbers can start with a formula character too
+		record[i] = escapeSpreadsheetFormula(flattenExportValue(value))
+	}
+	if err := c.writer.Write(record); err != nil {
+		return err
+	}
+	// Flush per row so the client starts receiving data immediately
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvExportWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type xlsxExportWriter struct {
+	writer *utils.XLSXWriter
+}
+
+func (x *xlsxExportWriter) WriteHeader(columns []string) error {
+	return x.writer.WriteRow(columns)
+}
+
+func (x *xlsxExportWriter) WriteRow(columns []string, values []interface{}) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = flattenExportValue(value)
+	}
+	return x.writer.WriteRow(cells)
+}
+
+func (x *xlsxExportWriter) Close() error {
+	return x.writer.Close()
+}
+
+type ndjsonExportWriter struct {
+	writer *bufio.Writer
+}
+
+func (n *ndjsonExportWriter) WriteHeader(columns []string) error {
+	return nil
+}
+
+func (n *ndjsonExportWriter) WriteRow(columns []string, values []interface{}) error {
+	record := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if values[i] != nil {
+			record[column] = values[i]
+		}
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := n.writer.Write(line); err != nil {
+		return err
+	}
+	return n.writer.WriteByte('\n')
+}
+
+func (n *ndjsonExportWriter) Close() error {
+	return n.writer.Flush()
+}
+
+// flattenExportValue turns a submission value into a single spreadsheet cell
+func flattenExportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, "\n")
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = flattenExportValue(item)
+		}
+		return strings.Join(parts, ", ")
+	case map[string]interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// escapeSpreadsheetFormula prevents CSV injection when a cell is opened in a spreadsheet
+func escapeSpreadsheetFormula(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
+
+// xlsxSheetName strips characters Excel does not allow in sheet names
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	if name == "" {
+		name = "Submissions"
+	}
+	if len([]rune(name)) > 31 {
+		name = string([]rune(name)[:31])
+	}
+	return name
+}
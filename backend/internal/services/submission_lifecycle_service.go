@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"formhub/internal/models"
@@ -15,10 +17,19 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+const (
+	// rejectionDedupWindow is how long a rejection from the same origin and IP is recorded only once
+	rejectionDedupWindow = 10 * time.Minute
+	// rejectionFormLimit caps the rejections recorded per form each minute, however many origins
+	// and addresses they come from
+	rejectionFormLimit = 30
+)
+
 type SubmissionLifecycleService struct {
 	db               *sqlx.DB
 	redis            *database.RedisClient
 	analyticsService *AnalyticsService
+	webhookService   *EnhancedWebhookService
 }
 
 func NewSubmissionLifecycleService(db *sqlx.DB, redis *database.RedisClient, analyticsService *AnalyticsService) *SubmissionLifecycleService {
@@ -29,6 +40,11 @@ func NewSubmissionLifecycleService(db *sqlx.DB, redis *database.RedisClient, ana
 	}
 }
 
+// SetWebhookService publishes submission events to the forms' webhook endpoints
+func (s *SubmissionLifecycleService) SetWebhookService(webhookService *EnhancedWebhookService) {
+	s.webhookService = webhookService
+}
+
 // CreateSubmissionLifecycle creates a new submission lifecycle entry
 func (s *SubmissionLifecycleService) CreateSubmissionLifecycle(ctx context.Context, submissionID, formID, userID uuid.UUID) (*models.SubmissionLifecycle, error) {
 	trackingID := s.analyticsService.GenerateTrackingID()
@@ -68,6 +84,17 @@ func (s *SubmissionLifecycleService) CreateSubmissionLifecycle(ctx context.Conte
 
 // UpdateSubmissionStatus updates the status of a submission lifecycle
 func (s *SubmissionLifecycleService) UpdateSubmissionStatus(ctx context.Context, submissionID uuid.UUID, status models.SubmissionStatus, processingTimeMs *int) error {
+	// The previous status is reported with submission.status_changed
+	var formID uuid.UUID
+	var previousStatus sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT s.form_id, l.status FROM submissions s
+		LEFT JOIN submission_lifecycle l ON l.submission_id = s.id
+		WHERE s.id = ?`, submissionID).Scan(&formID, &previousStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get submission status: %w", err)
+	}
+
 	updates := []string{"status = ?", "updated_at = ?"}
 	args := []interface{}{status, time.Now().UTC()}
 
@@ -84,7 +111,7 @@ func (s *SubmissionLifecycleService) UpdateSubmissionStatus(ctx context.Context,
 
 	args = append(args, submissionID)
 
-	_, err := s.db.ExecContext(ctx, query, args...)
+	_, err = s.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update submission status: %w", err)
 	}
@@ -99,6 +126,11 @@ func (s *SubmissionLifecycleService) UpdateSubmissionStatus(ctx context.Context,
 	// Record status change event for analytics
 	s.recordStatusChangeEvent(ctx, submissionID, status)
 
+	if formID != uuid.Nil && previousStatus.String != string(status) {
+		publishWebhookEvent(s.webhookService, formID, WebhookEventSubmissionStatusChanged, "",
+			statusChangedEventData(submissionID, status, previousStatus, processingTimeMs))
+	}
+
 	return nil
 }
 
@@ -149,6 +181,19 @@ func (s *SubmissionLifecycleService) UpdateSpamDetection(ctx context.Context, su
 	return nil
 }
 
+// FlagSpam records the verdict of spam protection on a submission it quarantined or blocked and
+// publishes submission.spam_flagged
+func (s *SubmissionLifecycleService) FlagSpam(ctx context.Context, submission *models.Submission, reasons []string) error {
+	if err := s.UpdateSpamDetection(ctx, submission.ID, submission.SpamScore, reasons); err != nil {
+		return err
+	}
+
+	publishWebhookEvent(s.webhookService, submission.FormID, WebhookEventSubmissionSpamFlagged,
+		fmt.Sprintf("submission:%s:spam_flagged", submission.ID), spamFlaggedEventData(submission, reasons))
+
+	return nil
+}
+
 // UpdateEmailDelivery updates email delivery status and timing
 func (s *SubmissionLifecycleService) UpdateEmailDelivery(ctx context.Context, submissionID uuid.UUID, status models.EmailDeliveryStatus, deliveryTimeMs int) error {
 	query := `
@@ -371,6 +416,78 @@ func (s *SubmissionLifecycleService) GetSubmissionsByStatus(ctx context.Context,
 	return lifecycles, nil
 }
 
+// RecordRejection records a public submission to the form that was refused before it was saved.
+// Writes are rate-limited, since refused requests can arrive far faster than the owner needs to
+// see them: repeats from the same origin and IP are dropped for rejectionDedupWindow, and at most
+// rejectionFormLimit rejections are recorded per form each minute.
+func (s *SubmissionLifecycleService) RecordRejection(ctx context.Context, formID uuid.UUID, reason models.SubmissionRejectionReason, origin, ipAddress string) error {
+	if !s.allowRejectionRecord(ctx, formID, reason, origin, ipAddress) {
+		return nil
+	}
+
+	query := `
+		INSERT INTO submission_rejections (id, form_id, reason, origin, ip_address, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		uuid.New(), formID, reason, sql.NullString{String: origin, Valid: origin != ""},
+		sql.NullString{String: ipAddress, Valid: ipAddress != ""}, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record submission rejection: %w", err)
+	}
+
+	return nil
+}
+
+// allowRejectionRecord applies RecordRejection's rate limits. Rejections are recorded when Redis
+// cannot be reached, so refused embeds still show up.
+func (s *SubmissionLifecycleService) allowRejectionRecord(ctx context.Context, formID uuid.UUID, reason models.SubmissionRejectionReason, origin, ipAddress string) bool {
+	source := sha256.Sum256([]byte(origin + "\x00" + ipAddress))
+	dedupKey := fmt.Sprintf("rejections:seen:%s:%s:%s", formID, reason, hex.EncodeToString(source[:16]))
+
+	first, err := s.redis.Client.SetNX(ctx, dedupKey, 1, rejectionDedupWindow).Result()
+	if err != nil {
+		log.Printf("Failed to rate-limit rejection for form %s: %v", formID, err)
+		return true
+	}
+	if !first {
+		return false
+	}
+
+	limitKey := fmt.Sprintf("rejections:count:%s:%d", formID, time.Now().Unix()/60)
+	count, err := s.redis.Client.Incr(ctx, limitKey).Result()
+	if err != nil {
+		log.Printf("Failed to rate-limit rejection for form %s: %v", formID, err)
+		return true
+	}
+	if count == 1 {
+		s.redis.Client.Expire(ctx, limitKey, 2*time.Minute)
+	}
+	return count <= rejectionFormLimit
+}
+
+// GetSubmissionRejections returns the form's most recent rejected submissions, newest first.
+// Forms outside the workspace have none.
+func (s *SubmissionLifecycleService) GetSubmissionRejections(ctx context.Context, formID, workspaceID uuid.UUID, limit int) ([]models.SubmissionRejection, error) {
+	query := `
+		SELECT sr.id, sr.form_id, sr.reason, sr.origin, sr.ip_address, sr.created_at
+		FROM submission_rejections sr
+		JOIN forms f ON f.id = sr.form_id
+		WHERE sr.form_id = ? AND f.workspace_id = ?
+		ORDER BY sr.created_at DESC
+		LIMIT ?
+	`
+
+	rejections := []models.SubmissionRejection{}
+	if err := s.db.SelectContext(ctx, &rejections, query, formID, workspaceID, limit); err != nil {
+		return nil, fmt.Errorf("failed to get submission rejections: %w", err)
+	}
+
+	return rejections, nil
+}
+
 // GetLifecycleStats gets lifecycle statistics for a user or form
 func (s *SubmissionLifecycleService) GetLifecycleStats(ctx context.Context, userID uuid.UUID, formID *uuid.UUID, startDate, endDate time.Time) (map[string]interface{}, error) {
 	whereClause := "WHERE user_id = ? AND created_at BETWEEN ? AND ?"
@@ -538,6 +655,17 @@ func (s *SubmissionLifecycleService) ArchiveOldSubmissions(ctx context.Context,
 	return nil
 }
 
+// PruneSubmissionRejections deletes rejected submissions older than the retention period
+func (s *SubmissionLifecycleService) PruneSubmissionRejections(ctx context.Context, retentionDays int) error {
+	cutoffDate := time.Now().UTC().AddDate(0, 0, -retentionDays)
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM submission_rejections WHERE created_at < ?", cutoffDate); err != nil {
+		return fmt.Errorf("failed to prune submission rejections: %w", err)
+	}
+
+	return nil
+}
+
 // GetPendingActions gets submissions that require action
 func (s *SubmissionLifecycleService) GetPendingActions(ctx context.Context, userID uuid.UUID) (map[string]interface{}, error) {
 	queries := map[string]string{
@@ -1,9 +1,11 @@
 package services
 
 import (
-	"crypto/md5"
+	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"formhub/internal/models"
 	"formhub/pkg/email"
@@ -16,11 +18,44 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = fmt.Errorf("invalid cursor")
+
+// Errors returned when resolving the form a public submission is addressed to
+var (
+	ErrInvalidAccessKey = fmt.Errorf("invalid access key")
+	ErrFormNotFound     = fmt.Errorf("form not found")
+	ErrFormNotAllowed   = fmt.Errorf("access key is not allowed to submit to this form")
+	ErrFormRequired     = fmt.Errorf("form_id is required")
+	ErrOriginNotAllowed = fmt.Errorf("origin is not allowed to submit to this form")
+)
+
+// Errors returned when reviewing quarantined submissions
+var (
+	ErrSubmissionNotFound       = fmt.Errorf("submission not found")
+	ErrSubmissionNotQuarantined = fmt.Errorf("submission is not quarantined")
+	ErrInvalidReviewDecision    = fmt.Errorf("decision must be approve, reject or spam")
+)
+
+// outboxHoldDelay keeps new outbox rows from being dispatched before their files are attached.
+// HandleSubmission releases them early; after a crash they are picked up once the hold expires.
+const outboxHoldDelay = time.Minute
+
 type SubmissionService struct {
-	db           *sql.DB
-	redis        *redis.Client
-	emailService *email.SMTPService
-	formService  *FormService
+	db                *sql.DB
+	redis             *redis.Client
+	emailService      *email.SMTPService
+	formService       *FormService
+	fileUploadService *FileUploadService
+	outboxService     *OutboxService
+	spamService       *SpamProtectionService
+	usageService      *UsageService
+	authService       *AuthService
+	lifecycleService  *SubmissionLifecycleService
+	analyticsService  *AnalyticsService
+	validationService *FieldValidationService
+	partialService    *PartialSubmissionService
+	webhookService    *EnhancedWebhookService
 }
 
 func NewSubmissionService(db *sql.DB, redis *redis.Client, emailService *email.SMTPService) *SubmissionService {
@@ -35,16 +70,61 @@ func (s *SubmissionService) SetFormService(formService *FormService) {
 	s.formService = formService
 }
 
+func (s *SubmissionService) SetFileUploadService(fileUploadService *FileUploadService) {
+	s.fileUploadService = fileUploadService
+}
+
+func (s *SubmissionService) SetOutboxService(outboxService *OutboxService) {
+	s.outboxService = outboxService
+}
+
+func (s *SubmissionService) SetSpamProtectionService(spamService *SpamProtectionService) {
+	s.spamService = spamService
+}
+
+func (s *SubmissionService) SetUsageService(usageService *UsageService) {
+	s.usageService = usageService
+}
+
+func (s *SubmissionService) SetAuthService(authService *AuthService) {
+	s.authService = authService
+}
+
+func (s *SubmissionService) SetLifecycleService(lifecycleService *SubmissionLifecycleService) {
+	s.lifecycleService = lifecycleService
+}
+
+func (s *SubmissionService) SetAnalyticsService(analyticsService *AnalyticsService) {
+	s.analyticsService = analyticsService
+}
+
+func (s *SubmissionService) SetFieldValidationService(validationService *FieldValidationService) {
+	s.validationService = validationService
+}
+
+func (s *SubmissionService) SetPartialSubmissionService(partialService *PartialSubmissionService) {
+	s.partialService = partialService
+}
+
+// SetWebhookService publishes quarantine reviews to the forms' webhook endpoints
+func (s *SubmissionService) SetWebhookService(webhookService *EnhancedWebhookService) {
+	s.webhookService = webhookService
+}
+
 func (s *SubmissionService) HandleSubmission(req models.SubmissionRequest, ipAddress, userAgent, referrer string) (*models.SubmissionResponse, error) {
-	// Find form by access key (API key)
-	form, apiKey, err := s.getFormByAccessKey(req.AccessKey)
+	// Find the form the submission is addressed to and check the access key may use it
+	form, apiKey, err := s.ResolveForm(req.AccessKey, req.FormID, ipAddress, req.Origin)
 	if err != nil {
-		return &models.SubmissionResponse{
-			Success:    false,
-			StatusCode: 401,
-			Message:    "Invalid access key",
-		}, nil
+		return FormResolutionResponse(err), nil
 	}
+	return s.HandleSubmissionWithFiles(form, apiKey, req, ipAddress, userAgent, referrer, "", false)
+}
+
+// HandleSubmissionWithFiles saves a submission and attaches any files uploaded under sessionID
+// before notifications are sent, so emails and webhooks can link to them. form and apiKey are the
+// ones ResolveForm returned for the request.
+func (s *SubmissionService) HandleSubmissionWithFiles(form *models.Form, apiKey *models.APIKey, req models.SubmissionRequest, ipAddress, userAgent, referrer, sessionID string, hasFiles bool) (*models.SubmissionResponse, error) {
+	var err error
 
 	// Check form is active
 	if !form.IsActive {
@@ -81,8 +161,76 @@ func (s *SubmissionService) HandleSubmission(req models.SubmissionRequest, ipAdd
 		formData[key] = value
 	}
 
-	// Basic spam detection
-	isSpam, spamScore := s.detectSpam(formData, ipAddress)
+	// A final submit of a multi-step form assembles the saved progress; values sent with the
+	// final submit win
+	var partial *models.PartialSubmission
+	if req.CompleteProgress {
+		if s.partialService == nil {
+			return ProgressResponse(ErrProgressNotFound), nil
+		}
+		partial, err = s.partialService.GetProgress(form.ID, req.ProgressToken)
+		if err == nil && partial.CompletedAt != nil {
+			err = ErrProgressCompleted
+		}
+		if err != nil {
+			return ProgressResponse(err), nil
+		}
+		for key, value := range partial.Data {
+			if _, ok := formData[key]; !ok {
+				formData[key] = value
+			}
+		}
+	}
+
+	// Validate against the form's field schema before the captcha token is spent
+	if s.validationService != nil {
+		if response := s.validateFields(form, formData, ipAddress, userAgent, referrer, sessionID); response != nil {
+			return response, nil
+		}
+	}
+
+	// Check the form's captcha before the submission counts against the plan
+	captchaVerified := false
+	if form.CaptchaProvider != "" {
+		if response := s.verifyCaptcha(form, req, ipAddress, userAgent, referrer, sessionID); response != nil {
+			return response, nil
+		}
+		captchaVerified = true
+	}
+
+	// Enforce the plan of the workspace's billing owner. Only a successful reservation is released
+	// when the submission is not kept after all.
+	var plan *UserPlan
+	reserved := false
+	if s.usageService != nil {
+		plan, err = s.usageService.GetWorkspacePlan(form.WorkspaceID)
+		if err != nil {
+			log.Printf("Failed to get plan for workspace %s: %v", form.WorkspaceID, err)
+			return &models.SubmissionResponse{
+				Success:    false,
+				StatusCode: 500,
+				Message:    "Failed to process submission",
+			}, nil
+		}
+
+		if hasFiles {
+			if err := plan.CheckFeature(CapabilityFileUploads); err != nil {
+				return planLimitResponse(err), nil
+			}
+		}
+
+		if err := s.usageService.ReserveSubmission(plan); err != nil {
+			if _, ok := AsPlanLimitError(err); ok {
+				return planLimitResponse(err), nil
+			}
+			log.Printf("Failed to meter submission for workspace %s: %v", form.WorkspaceID, err)
+		} else {
+			reserved = true
+		}
+	}
+
+	// Run the form's spam protection pipeline
+	verdict := s.analyzeSpam(form, formData, req, ipAddress, userAgent, referrer, captchaVerified)
 
 	// Create submission
 	submission := &models.Submission{
@@ -92,15 +240,22 @@ func (s *SubmissionService) HandleSubmission(req models.SubmissionRequest, ipAdd
 		IPAddress:   ipAddress,
 		UserAgent:   userAgent,
 		Referrer:    referrer,
-		IsSpam:      isSpam,
-		SpamScore:   spamScore,
+		IsSpam:      verdict.Action != models.SpamActionAllow,
+		SpamScore:   verdict.Score,
+		SpamAction:  verdict.Action,
 		EmailSent:   false,
 		WebhookSent: false,
 		CreatedAt:   time.Now(),
 	}
 
-	// Save submission to database
-	if err := s.saveSubmission(submission); err != nil {
+	// Save submission together with its pending notifications and the progress it completes
+	if err := s.saveSubmission(submission, s.outboxMessages(form, submission, plan), partial); err != nil {
+		if reserved {
+			s.usageService.ReleaseSubmission(plan.UserID)
+		}
+		if errors.Is(err, ErrProgressCompleted) {
+			return ProgressResponse(err), nil
+		}
 		log.Printf("Failed to save submission: %v", err)
 		return &models.SubmissionResponse{
 			Success:    false,
@@ -109,22 +264,49 @@ func (s *SubmissionService) HandleSubmission(req models.SubmissionRequest, ipAdd
 		}, nil
 	}
 
-	// Send email notification if not spam
-	if !isSpam {
-		if err := s.sendEmailNotification(form, submission); err != nil {
-			log.Printf("Failed to send email notification: %v", err)
-		} else {
-			s.markEmailSent(submission.ID)
+	if verdict.Action != models.SpamActionAllow && s.lifecycleService != nil {
+		var reasons []string
+		if verdict.Result != nil {
+			for _, trigger := range verdict.Result.Triggers {
+				reasons = append(reasons, trigger.Type)
+			}
+		}
+		if err := s.lifecycleService.FlagSpam(context.Background(), submission, reasons); err != nil {
+			log.Printf("Failed to record spam verdict for submission %s: %v", submission.ID, err)
+		}
+	}
+
+	// Blocked submissions are kept for audit but rejected; their uploads stay in the temporary area
+	if verdict.Action == models.SpamActionBlock {
+		if reserved {
+			s.usageService.ReleaseSubmission(plan.UserID)
 		}
 
-		// Send webhook if configured
-		if form.WebhookURL != "" {
-			if err := s.sendWebhook(form, submission); err != nil {
-				log.Printf("Failed to send webhook: %v", err)
-			} else {
-				s.markWebhookSent(submission.ID)
+		response := &models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 403,
+			Message:    "Submission rejected by spam protection",
+			SpamAction: models.SpamActionBlock,
+		}
+		if verdict.Result != nil && verdict.Result.CaptchaRequired {
+			response.Data = map[string]interface{}{
+				"captcha_required":  true,
+				"captcha_challenge": verdict.Result.CaptchaChallenge,
 			}
 		}
+		return response, nil
+	}
+
+	// Move uploaded files from the session into the submission
+	if hasFiles && sessionID != "" && s.fileUploadService != nil {
+		if err := s.fileUploadService.AssociateFilesWithSubmission(submission.ID, sessionID); err != nil {
+			log.Printf("Failed to associate files with submission %s: %v", submission.ID, err)
+		}
+	}
+
+	// Notifications are delivered by the outbox dispatcher; quarantined submissions wait for review
+	if verdict.Action == models.SpamActionAllow {
+		s.releaseOutbox(submission.ID)
 
 		// Increment form submission count
 		if err := s.formService.IncrementSubmissionCount(form.ID); err != nil {
@@ -150,46 +332,169 @@ func (s *SubmissionService) HandleSubmission(req models.SubmissionRequest, ipAdd
 	return response, nil
 }
 
-func (s *SubmissionService) getFormByAccessKey(accessKey string) (*models.Form, *models.APIKey, error) {
-	// Get API key details
-	apiKeyQuery := `
-		SELECT id, user_id, name, permissions, rate_limit, is_active, last_used_at
-		FROM api_keys 
-		WHERE key_hash = ? AND is_active = true
-	`
+// ResolveForm finds the form a public submission is addressed to and checks that the access key
+// may submit to it. Without a form public ID, a key scoped to a single form submits to that form,
+// and keys that opted into the default form fallback use the workspace's newest form.
+// ipAddress and origin identify the submitter for the key's and the form's allow-lists.
+func (s *SubmissionService) ResolveForm(accessKey, formPublicID, ipAddress, origin string) (*models.Form, *models.APIKey, error) {
+	form, apiKey, err := s.resolveForm(accessKey, formPublicID, ipAddress, origin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkFormOrigin(form, origin, ipAddress); err != nil {
+		return nil, nil, err
+	}
+	return form, apiKey, nil
+}
 
-	var apiKey models.APIKey
-	keyHash := fmt.Sprintf("%x", md5.Sum([]byte(accessKey)))
-	
-	err := s.db.QueryRow(apiKeyQuery, keyHash).Scan(
-		&apiKey.ID, &apiKey.UserID, &apiKey.Name, &apiKey.Permissions,
-		&apiKey.RateLimit, &apiKey.IsActive, &apiKey.LastUsedAt,
-	)
-	
+func (s *SubmissionService) resolveForm(accessKey, formPublicID, ipAddress, origin string) (*models.Form, *models.APIKey, error) {
+	apiKey, err := s.getAPIKey(accessKey, ipAddress, origin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if formPublicID != "" {
+		form, err := s.formService.GetFormByPublicID(formPublicID)
+		if err != nil {
+			return nil, nil, ErrFormNotFound
+		}
+		if err := checkKeyForm(apiKey, form); err != nil {
+			return nil, nil, err
+		}
+		return form, apiKey, nil
+	}
+
+	if len(apiKey.FormIDs) == 1 {
+		form, err := s.formService.GetFormByID(apiKey.FormIDs[0])
+		if err != nil {
+			return nil, nil, ErrFormNotFound
+		}
+		return form, apiKey, nil
+	}
+
+	if !apiKey.DefaultFormFallback {
+		return nil, nil, ErrFormRequired
+	}
+
+	form, err := s.getDefaultForm(apiKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return form, apiKey, nil
+}
+
+// ResolveFormByID is ResolveForm for requests that address the form by its internal ID
+func (s *SubmissionService) ResolveFormByID(accessKey string, formID uuid.UUID, ipAddress, origin string) (*models.Form, *models.APIKey, error) {
+	apiKey, err := s.getAPIKey(accessKey, ipAddress, origin)
 	if err != nil {
-		return nil, nil, fmt.Errorf("api key not found")
+		return nil, nil, err
 	}
 
-	// Update last used timestamp
-	s.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", time.Now(), apiKey.ID.String())
+	form, err := s.formService.GetFormByID(formID)
+	if err != nil {
+		return nil, nil, ErrFormNotFound
+	}
+	if err := checkKeyForm(apiKey, form); err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkFormOrigin(form, origin, ipAddress); err != nil {
+		return nil, nil, err
+	}
 
-	// Try to get an existing form for this user
+	return form, apiKey, nil
+}
+
+// FormResolutionResponse maps a ResolveForm error to the response returned to the submitter
+func FormResolutionResponse(err error) *models.SubmissionResponse {
+	response := &models.SubmissionResponse{Success: false}
+
+	switch {
+	case errors.Is(err, ErrInvalidAccessKey):
+		response.StatusCode = 401
+		response.Message = "Invalid access key"
+	case errors.Is(err, ErrAPIKeyRestricted):
+		response.StatusCode = 403
+		response.Message = "Access key is not allowed from this address or origin"
+	case errors.Is(err, ErrAPIKeyScope):
+		response.StatusCode = 403
+		response.Message = "Access key does not have the submit scope"
+	case errors.Is(err, ErrFormNotFound):
+		response.StatusCode = 404
+		response.Message = "Form not found"
+	case errors.Is(err, ErrFormNotAllowed):
+		response.StatusCode = 403
+		response.Message = "Access key is not allowed to submit to this form"
+	case errors.Is(err, ErrOriginNotAllowed):
+		response.StatusCode = 403
+		response.Message = "Submissions from this origin are not allowed"
+	case errors.Is(err, ErrFormRequired):
+		response.StatusCode = 400
+		response.Message = "form_id is required"
+	default:
+		log.Printf("Failed to resolve submission form: %v", err)
+		response.StatusCode = 500
+		response.Message = "Failed to process submission"
+	}
+
+	return response
+}
+
+// getAPIKey authenticates the access key for a public submission, which needs the submit scope
+func (s *SubmissionService) getAPIKey(accessKey, ipAddress, origin string) (*models.APIKey, error) {
+	apiKey, err := s.authService.AuthenticateAPIKey(accessKey, ipAddress, origin)
+	if err != nil {
+		return nil, err
+	}
+	if !apiKey.HasScope(models.ScopeSubmit) {
+		return nil, ErrAPIKeyScope
+	}
+	return apiKey, nil
+}
+
+// checkKeyForm rejects forms outside the key's workspace or, for form-scoped keys, outside its forms
+func checkKeyForm(apiKey *models.APIKey, form *models.Form) error {
+	if form.WorkspaceID != apiKey.WorkspaceID || !apiKey.AllowsForm(form.ID) {
+		return ErrFormNotAllowed
+	}
+	return nil
+}
+
+// checkFormOrigin rejects submissions from origins outside the form's allow-list, and records them
+// so the form's owner can see which embeds are being refused
+func (s *SubmissionService) checkFormOrigin(form *models.Form, origin, ipAddress string) error {
+	if utils.OriginAllowed(FormAllowedOrigins(form), origin) {
+		return nil
+	}
+
+	if s.lifecycleService != nil {
+		if err := s.lifecycleService.RecordRejection(context.Background(), form.ID, models.SubmissionRejectionOrigin, origin, ipAddress); err != nil {
+			log.Printf("Failed to record rejected origin %q for form %s: %v", origin, form.ID, err)
+		}
+	}
+	return ErrOriginNotAllowed
+}
+
+// getDefaultForm implements the opt-in fallback for keys used without a form: the workspace's
+// newest active form, or an auto-created default form if the workspace has none
+func (s *SubmissionService) getDefaultForm(apiKey *models.APIKey) (*models.Form, error) {
+	// Try to get an existing form in the key's workspace
 	formQuery := `
-		SELECT id, user_id, name, description, target_email, cc_emails, subject,
+		SELECT id, public_id, user_id, workspace_id, name, description, target_email, cc_emails, subject,
 			success_message, redirect_url, webhook_url, spam_protection, recaptcha_secret,
-			file_uploads, max_file_size, allowed_origins, is_active, submission_count,
-			created_at, updated_at
+			COALESCE(captcha_provider, ''), captcha_min_score, file_uploads, max_file_size, allowed_origins,
+			is_active, submission_count, created_at, updated_at
 		FROM forms 
-		WHERE user_id = ? AND is_active = true 
+		WHERE workspace_id = ? AND is_active = true 
 		ORDER BY created_at DESC 
 		LIMIT 1
 	`
 
 	var form models.Form
-	err = s.db.QueryRow(formQuery, apiKey.UserID.String()).Scan(
-		&form.ID, &form.UserID, &form.Name, &form.Description, &form.TargetEmail,
+	err := s.db.QueryRow(formQuery, apiKey.WorkspaceID.String()).Scan(
+		&form.ID, &form.PublicID, &form.UserID, &form.WorkspaceID, &form.Name, &form.Description, &form.TargetEmail,
 		&form.CCEmails, &form.Subject, &form.SuccessMessage, &form.RedirectURL,
 		&form.WebhookURL, &form.SpamProtection, &form.RecaptchaSecret,
+		&form.CaptchaProvider, &form.CaptchaMinScore,
 		&form.FileUploads, &form.MaxFileSize, &form.AllowedOrigins,
 		&form.IsActive, &form.SubmissionCount, &form.CreatedAt, &form.UpdatedAt,
 	)
@@ -199,15 +504,16 @@ func (s *SubmissionService) getFormByAccessKey(accessKey string) (*models.Form,
 			// No form found, create a default form using user's email (like Web3Forms)
 			userEmail, userErr := s.getUserEmail(apiKey.UserID)
 			if userErr != nil {
-				return nil, nil, fmt.Errorf("no form configured and unable to get user email: %w", userErr)
+				return nil, fmt.Errorf("no form configured and unable to get user email: %w", userErr)
 			}
 
 			// Check again for form creation race condition (concurrent requests)
 			// Try one more time in case another request created a form
-			err = s.db.QueryRow(formQuery, apiKey.UserID.String()).Scan(
-				&form.ID, &form.UserID, &form.Name, &form.Description, &form.TargetEmail,
+			err = s.db.QueryRow(formQuery, apiKey.WorkspaceID.String()).Scan(
+				&form.ID, &form.PublicID, &form.UserID, &form.WorkspaceID, &form.Name, &form.Description, &form.TargetEmail,
 				&form.CCEmails, &form.Subject, &form.SuccessMessage, &form.RedirectURL,
 				&form.WebhookURL, &form.SpamProtection, &form.RecaptchaSecret,
+				&form.CaptchaProvider, &form.CaptchaMinScore,
 				&form.FileUploads, &form.MaxFileSize, &form.AllowedOrigins,
 				&form.IsActive, &form.SubmissionCount, &form.CreatedAt, &form.UpdatedAt,
 			)
@@ -215,44 +521,45 @@ func (s *SubmissionService) getFormByAccessKey(accessKey string) (*models.Form,
 			if err == nil {
 				// Form was created by another concurrent request
 				log.Printf("Found form created by concurrent request for user %s", apiKey.UserID.String())
-				return &form, &apiKey, nil
+				return &form, nil
 			}
 
 			// Still no form, proceed with creation
-			defaultForm, createErr := s.createDefaultForm(apiKey.UserID, userEmail)
+			defaultForm, createErr := s.createDefaultForm(apiKey.UserID, apiKey.WorkspaceID, userEmail)
 			if createErr != nil {
 				// Check if the error is due to concurrent form creation (duplicate key)
 				if strings.Contains(createErr.Error(), "duplicate") || strings.Contains(createErr.Error(), "Duplicate") {
 					log.Printf("Concurrent form creation detected for user %s, retrying form lookup", apiKey.UserID.String())
 					
 					// Try to get the form that was created by the concurrent request
-					finalErr := s.db.QueryRow(formQuery, apiKey.UserID.String()).Scan(
-						&form.ID, &form.UserID, &form.Name, &form.Description, &form.TargetEmail,
+					finalErr := s.db.QueryRow(formQuery, apiKey.WorkspaceID.String()).Scan(
+						&form.ID, &form.PublicID, &form.UserID, &form.WorkspaceID, &form.Name, &form.Description, &form.TargetEmail,
 						&form.CCEmails, &form.Subject, &form.SuccessMessage, &form.RedirectURL,
 						&form.WebhookURL, &form.SpamProtection, &form.RecaptchaSecret,
+						&form.CaptchaProvider, &form.CaptchaMinScore,
 						&form.FileUploads, &form.MaxFileSize, &form.AllowedOrigins,
 						&form.IsActive, &form.SubmissionCount, &form.CreatedAt, &form.UpdatedAt,
 					)
 					
 					if finalErr == nil {
-						return &form, &apiKey, nil
+						return &form, nil
 					}
 				}
 				
-				return nil, nil, fmt.Errorf("failed to create default form: %w", createErr)
+				return nil, fmt.Errorf("failed to create default form: %w", createErr)
 			}
 
 			log.Printf("Auto-created default form (ID: %s) for user %s with email %s", 
 				defaultForm.ID.String(), apiKey.UserID.String(), userEmail)
 
-			return defaultForm, &apiKey, nil
+			return defaultForm, nil
 		}
 		
 		// Other database error
-		return nil, nil, fmt.Errorf("database error while looking up form: %w", err)
+		return nil, fmt.Errorf("database error while looking up form: %w", err)
 	}
 
-	return &form, &apiKey, nil
+	return &form, nil
 }
 
 func (s *SubmissionService) getUserEmail(userID uuid.UUID) (string, error) {
@@ -265,11 +572,18 @@ func (s *SubmissionService) getUserEmail(userID uuid.UUID) (string, error) {
 	return email, nil
 }
 
-func (s *SubmissionService) createDefaultForm(userID uuid.UUID, userEmail string) (*models.Form, error) {
+func (s *SubmissionService) createDefaultForm(userID, workspaceID uuid.UUID, userEmail string) (*models.Form, error) {
+	publicID, err := generateFormPublicID()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create default form with proper UUID and timestamps
 	defaultForm := &models.Form{
 		ID:              uuid.New(),
+		PublicID:        publicID,
 		UserID:          userID,
+		WorkspaceID:     workspaceID,
 		Name:            "Default Form",
 		Description:     "Auto-created default form for Web3Forms API submissions",
 		TargetEmail:     userEmail,
@@ -293,16 +607,16 @@ func (s *SubmissionService) createDefaultForm(userID uuid.UUID, userEmail string
 
 	// Insert form into database - MySQL compatible query
 	query := `
-		INSERT INTO forms (id, user_id, name, description, target_email, cc_emails, subject, 
+		INSERT INTO forms (id, public_id, user_id, workspace_id, name, description, target_email, cc_emails, subject, 
 			success_message, redirect_url, webhook_url, spam_protection, recaptcha_secret,
 			file_uploads, max_file_size, allowed_origins, is_active, submission_count, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	log.Printf("Creating default form for user %s with email %s", userID.String(), userEmail)
 	
 	result, err := tx.Exec(query,
-		defaultForm.ID.String(), defaultForm.UserID.String(), defaultForm.Name, defaultForm.Description, 
+		defaultForm.ID.String(), defaultForm.PublicID, defaultForm.UserID.String(), defaultForm.WorkspaceID.String(), defaultForm.Name, defaultForm.Description,
 		defaultForm.TargetEmail, defaultForm.CCEmails, defaultForm.Subject, 
 		defaultForm.SuccessMessage, defaultForm.RedirectURL, defaultForm.WebhookURL,
 		defaultForm.SpamProtection, defaultForm.RecaptchaSecret, defaultForm.FileUploads,
@@ -343,6 +657,204 @@ func (s *SubmissionService) checkRateLimit(apiKey *models.APIKey, ipAddress stri
 	return nil
 }
 
+// spamVerdict is the outcome of spam analysis for an incoming submission
+type spamVerdict struct {
+	Action models.SpamAction
+	Score  float64
+	Result *SpamDetectionResult // nil when the keyword fallback was used
+}
+
+// analyzeSpam runs the form's FormSpamConfig pipeline, falling back to keyword detection
+// when the spam protection service is unavailable. captchaVerified reports that the form's own
+// captcha passed, which spends the token.
+func (s *SubmissionService) analyzeSpam(form *models.Form, data map[string]interface{}, req models.SubmissionRequest, ipAddress, userAgent, referrer string, captchaVerified bool) spamVerdict {
+	if !form.SpamProtection {
+		return spamVerdict{Action: models.SpamActionAllow}
+	}
+
+	if s.spamService != nil {
+		metadata := map[string]interface{}{
+			"client_ip":  ipAddress,
+			"user_agent": userAgent,
+			"referer":    referrer,
+			"form_id":    form.ID.String(),
+			"endpoint":   "/api/v1/submit",
+			"timestamp":  time.Now().UTC(),
+		}
+		if captchaVerified {
+			metadata["captcha_verified"] = true
+		} else if req.RecaptchaResponse != "" {
+			metadata["captcha_token"] = req.RecaptchaResponse
+		}
+		if req.BehavioralData != "" {
+			metadata["behavioral_data"] = req.BehavioralData
+		}
+
+		result, err := s.spamService.AnalyzeSubmission(form.ID.String(), data, metadata)
+		if err == nil {
+			action := models.SpamAction(result.Action)
+			if action != models.SpamActionBlock && action != models.SpamActionQuarantine {
+				action = models.SpamActionAllow
+			}
+			return spamVerdict{Action: action, Score: result.SpamScore, Result: result}
+		}
+		log.Printf("Spam analysis failed for form %s, using keyword detection: %v", form.ID, err)
+	}
+
+	isSpam, spamScore := s.detectSpam(data, ipAddress)
+	if isSpam {
+		return spamVerdict{Action: models.SpamActionQuarantine, Score: spamScore}
+	}
+	return spamVerdict{Action: models.SpamActionAllow, Score: spamScore}
+}
+
+// verifyCaptcha checks the token for the form's captcha provider, recording the outcome in analytics.
+// It returns the response to reject the submission with, or nil if the captcha passed.
+func (s *SubmissionService) verifyCaptcha(form *models.Form, req models.SubmissionRequest, ipAddress, userAgent, referrer, sessionID string) *models.SubmissionResponse {
+	provider := utils.CaptchaProvider(form.CaptchaProvider)
+
+	event := submissionEvent(form, models.EventTypeRecaptchaSolve, ipAddress, userAgent, referrer, sessionID)
+
+	token := captchaToken(provider, req)
+	if token == "" {
+		event.EventData = map[string]interface{}{"provider": provider, "success": false, "error": "missing-input-response"}
+		s.recordEvent(event)
+		return &models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 400,
+			Message:    "Captcha response is required",
+		}
+	}
+
+	result, err := utils.NewCaptchaService(captchaConfig(form)).VerifyCaptcha(provider, token, ipAddress)
+	if err != nil {
+		log.Printf("Failed to verify %s captcha for form %s: %v", provider, form.ID, err)
+		event.EventData = map[string]interface{}{"provider": provider, "success": false, "error": "verification-unavailable"}
+		s.recordEvent(event)
+		return &models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 503,
+			Message:    "Captcha verification is unavailable, please try again",
+		}
+	}
+
+	event.EventData = map[string]interface{}{
+		"provider":    provider,
+		"success":     result.Success,
+		"hostname":    result.Hostname,
+		"error_codes": result.ErrorCodes,
+	}
+	if provider == utils.ProviderRecaptchaV3 {
+		event.EventData["score"] = result.Score
+		event.EventData["min_score"] = form.CaptchaMinScore
+		event.EventData["action"] = result.Action
+	}
+	s.recordEvent(event)
+
+	if !result.Success {
+		return &models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 403,
+			Message:    "Captcha verification failed",
+		}
+	}
+	return nil
+}
+
+// validateFields checks the submission against the form's configured fields and their rules,
+// dropping the values of fields the rules hide and recording a validation_error analytics event
+// per invalid field. It returns the response to reject the submission with, or nil if it is valid.
+func (s *SubmissionService) validateFields(form *models.Form, data map[string]interface{}, ipAddress, userAgent, referrer, sessionID string) *models.SubmissionResponse {
+	result, err := s.validationService.ValidateFormSubmission(form.ID, data)
+	if err != nil {
+		log.Printf("Failed to validate submission for form %s: %v", form.ID, err)
+		return &models.SubmissionResponse{
+			Success:    false,
+			StatusCode: 500,
+			Message:    "Failed to process submission",
+		}
+	}
+	if result.IsValid {
+		return nil
+	}
+
+	for fieldName, fieldErrors := range result.Errors {
+		fieldName := fieldName
+		event := submissionEvent(form, models.EventTypeValidationError, ipAddress, userAgent, referrer, sessionID)
+		event.FieldName = &fieldName
+		if len(fieldErrors) > 0 {
+			event.FieldValidationError = &fieldErrors[0]
+		}
+		event.EventData = map[string]interface{}{"errors": fieldErrors, "source": "server"}
+		s.recordEvent(event)
+	}
+
+	return &models.SubmissionResponse{
+		Success:    false,
+		StatusCode: 422,
+		Message:    "Form validation failed",
+		Data: map[string]interface{}{
+			"validation_errors": result.Errors,
+			"field_results":     result.FieldResults,
+		},
+	}
+}
+
+// submissionEvent starts an analytics event about a public submission to the form
+func submissionEvent(form *models.Form, eventType models.AnalyticsEventType, ipAddress, userAgent, referrer, sessionID string) *models.FormAnalyticsEvent {
+	return &models.FormAnalyticsEvent{
+		FormID:     form.ID,
+		UserID:     form.UserID,
+		SessionID:  sessionID,
+		EventType:  eventType,
+		PageURL:    referrer,
+		DeviceType: models.DeviceTypeUnknown,
+		IPAddress:  ipAddress,
+		UserAgent:  &userAgent,
+	}
+}
+
+// recordEvent records the analytics event in the background
+func (s *SubmissionService) recordEvent(event *models.FormAnalyticsEvent) {
+	if s.analyticsService == nil {
+		return
+	}
+
+	go func() {
+		if err := s.analyticsService.RecordEvent(context.Background(), event); err != nil {
+			log.Printf("Failed to record %s event for form %s: %v", event.EventType, event.FormID, err)
+		}
+	}()
+}
+
+// captchaToken returns the submitted token for the provider. hCaptcha and Turnstile widgets can
+// also be configured to post their token as g-recaptcha-response.
+func captchaToken(provider utils.CaptchaProvider, req models.SubmissionRequest) string {
+	switch {
+	case provider == utils.ProviderHCaptcha && req.HCaptchaResponse != "":
+		return req.HCaptchaResponse
+	case provider == utils.ProviderTurnstile && req.TurnstileResponse != "":
+		return req.TurnstileResponse
+	}
+	return req.RecaptchaResponse
+}
+
+// captchaConfig configures utils.CaptchaService with the form's secret for its provider
+func captchaConfig(form *models.Form) *utils.CaptchaConfig {
+	config := &utils.CaptchaConfig{RecaptchaV3MinScore: form.CaptchaMinScore}
+	switch utils.CaptchaProvider(form.CaptchaProvider) {
+	case utils.ProviderRecaptchaV2:
+		config.RecaptchaV2Secret = form.RecaptchaSecret
+	case utils.ProviderRecaptchaV3:
+		config.RecaptchaV3Secret = form.RecaptchaSecret
+	case utils.ProviderHCaptcha:
+		config.HCaptchaSecret = form.RecaptchaSecret
+	case utils.ProviderTurnstile:
+		config.TurnstileSecret = form.RecaptchaSecret
+	}
+	return config
+}
+
 func (s *SubmissionService) detectSpam(data map[string]interface{}, ipAddress string) (bool, float64) {
 	spamScore := 0.0
 	
@@ -389,28 +901,222 @@ func (s *SubmissionService) detectSpam(data map[string]interface{}, ipAddress st
 	return spamScore >= 0.5, spamScore
 }
 
-func (s *SubmissionService) saveSubmission(submission *models.Submission) error {
+// saveSubmission inserts the submission and its outbox messages in a single transaction, marking
+// the saved progress of a multi-step form as completed by it
+func (s *SubmissionService) saveSubmission(submission *models.Submission, messages []models.OutboxMessage, partial *models.PartialSubmission) error {
 	dataJSON, err := json.Marshal(submission.Data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal submission data: %w", err)
 	}
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
 		INSERT INTO submissions (id, form_id, data, ip_address, user_agent, referrer,
-			is_spam, spam_score, email_sent, webhook_sent, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			is_spam, spam_score, spam_action, email_sent, webhook_sent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err = s.db.Exec(query,
+	_, err = tx.Exec(query,
 		submission.ID, submission.FormID, string(dataJSON), submission.IPAddress,
 		submission.UserAgent, submission.Referrer, submission.IsSpam, submission.SpamScore,
-		submission.EmailSent, submission.WebhookSent, submission.CreatedAt,
+		submission.SpamAction, submission.EmailSent, submission.WebhookSent, submission.CreatedAt,
 	)
+	if err != nil {
+		return fmt.Errorf("failed to insert submission: %w", err)
+	}
+
+	if err := insertOutboxMessages(tx, messages); err != nil {
+		return err
+	}
+
+	if partial != nil {
+		if err := s.partialService.CompleteProgress(tx, partial.ID, submission.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertOutboxMessages(tx *sql.Tx, messages []models.OutboxMessage) error {
+	query := `
+		INSERT INTO submission_outbox (id, submission_id, form_id, kind, idempotency_key,
+			status, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for _, message := range messages {
+		_, err := tx.Exec(query,
+			message.ID, message.SubmissionID, message.FormID, message.Kind, message.IdempotencyKey,
+			message.Status, message.Attempts, message.NextAttemptAt, message.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert outbox message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// outboxMessages returns the notifications to record for a new submission
+func (s *SubmissionService) outboxMessages(form *models.Form, submission *models.Submission, plan *UserPlan) []models.OutboxMessage {
+	if submission.SpamAction != models.SpamActionAllow {
+		return nil
+	}
+
+	// Webhook rows are recorded whenever the plan includes webhooks; the dispatcher skips forms without one
+	kinds := []models.OutboxKind{models.OutboxKindEmail}
+	if plan == nil || plan.CheckFeature(CapabilityWebhooks) == nil {
+		kinds = append(kinds, models.OutboxKindWebhook)
+	}
+
+	messages := make([]models.OutboxMessage, 0, len(kinds))
+	for _, kind := range kinds {
+		messages = append(messages, models.OutboxMessage{
+			ID:             uuid.New(),
+			SubmissionID:   submission.ID,
+			FormID:         form.ID,
+			Kind:           kind,
+			IdempotencyKey: fmt.Sprintf("submission:%s:%s", submission.ID, kind),
+			Status:         models.OutboxStatusPending,
+			NextAttemptAt:  submission.CreatedAt.Add(outboxHoldDelay),
+			CreatedAt:      submission.CreatedAt,
+		})
+	}
+
+	return messages
+}
 
-	return err
+// releaseOutbox makes a submission's held outbox messages available to the dispatcher immediately
+func (s *SubmissionService) releaseOutbox(submissionID uuid.UUID) {
+	query := `UPDATE submission_outbox SET next_attempt_at = ? WHERE submission_id = ? AND status = ?`
+	if _, err := s.db.Exec(query, time.Now(), submissionID, models.OutboxStatusPending); err != nil {
+		log.Printf("Failed to release outbox for submission %s: %v", submissionID, err)
+		return
+	}
+
+	if s.outboxService != nil {
+		s.outboxService.Wake()
+	}
 }
 
-func (s *SubmissionService) sendEmailNotification(form *models.Form, submission *models.Submission) error {
+// ReviewQuarantinedSubmission settles a submission spam protection quarantined. Approved
+// submissions are delivered as if they had been allowed; rejected ones are blocked. The decision
+// is published as quarantine.reviewed.
+func (s *SubmissionService) ReviewQuarantinedSubmission(submissionID uuid.UUID, decision, reviewedBy, notes string) (*models.Submission, error) {
+	if decision != "approve" && decision != "reject" && decision != "spam" {
+		return nil, ErrInvalidReviewDecision
+	}
+
+	submission, err := s.getSubmissionWithFiles(submissionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSubmissionNotFound
+		}
+		return nil, err
+	}
+	if submission.SpamAction != models.SpamActionQuarantine {
+		return nil, ErrSubmissionNotQuarantined
+	}
+
+	form, err := s.formService.GetFormByID(submission.FormID)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *UserPlan
+	if s.usageService != nil {
+		if plan, err = s.usageService.GetWorkspacePlan(form.WorkspaceID); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	action := models.SpamActionBlock
+	if decision == "approve" {
+		action = models.SpamActionAllow
+	}
+
+	// The status check keeps two reviewers from settling the same submission twice
+	result, err := tx.Exec(`UPDATE submissions SET is_spam = ?, spam_action = ? WHERE id = ? AND spam_action = ?`,
+		action != models.SpamActionAllow, action, submissionID, models.SpamActionQuarantine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update submission: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil, ErrSubmissionNotQuarantined
+	}
+
+	submission.SpamAction = action
+	submission.IsSpam = action != models.SpamActionAllow
+	if err := insertOutboxMessages(tx, s.outboxMessages(form, submission, plan)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if action == models.SpamActionAllow {
+		s.releaseOutbox(submission.ID)
+		if err := s.formService.IncrementSubmissionCount(form.ID); err != nil {
+			log.Printf("Failed to increment submission count: %v", err)
+		}
+	}
+
+	publishWebhookEvent(s.webhookService, form.ID, WebhookEventQuarantineReviewed,
+		fmt.Sprintf("submission:%s:reviewed", submission.ID),
+		quarantineReviewedEventData(submission.ID, decision, reviewedBy, notes, time.Now().UTC()))
+
+	return submission, nil
+}
+
+// GetSubmissionFormID returns the form a submission belongs to
+func (s *SubmissionService) GetSubmissionFormID(submissionID uuid.UUID) (uuid.UUID, error) {
+	var formID uuid.UUID
+	err := s.db.QueryRow(`SELECT form_id FROM submissions WHERE id = ?`, submissionID).Scan(&formID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, ErrSubmissionNotFound
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get submission: %w", err)
+	}
+	return formID, nil
+}
+
+// getSubmissionWithFiles loads a submission and its attachments by ID
+func (s *SubmissionService) getSubmissionWithFiles(submissionID uuid.UUID) (*models.Submission, error) {
+	query := `
+		SELECT id, form_id, data, ip_address, user_agent, referrer,
+			is_spam, spam_score, spam_action, email_sent, webhook_sent, created_at
+		FROM submissions
+		WHERE id = ?`
+
+	submission, err := scanSubmission(s.db.QueryRow(query, submissionID))
+	if err != nil {
+		return nil, err
+	}
+
+	submissions := []models.Submission{*submission}
+	if err := s.attachFiles(submissions); err != nil {
+		return nil, err
+	}
+
+	return &submissions[0], nil
+}
+
+// buildEmailData prepares the notification email for a submission
+func (s *SubmissionService) buildEmailData(form *models.Form, submission *models.Submission) email.EmailData {
 	// Parse CC emails
 	var ccEmails []string
 	if form.CCEmails != "" {
@@ -428,19 +1134,20 @@ func (s *SubmissionService) sendEmailNotification(form *models.Form, submission
 		Timestamp:      submission.CreatedAt.Format("2006-01-02 15:04:05 UTC"),
 	}
 
-	return s.emailService.SendFormSubmission(emailData)
-}
-
-func (s *SubmissionService) sendWebhook(form *models.Form, submission *models.Submission) error {
-	if form.WebhookURL == "" {
-		return fmt.Errorf("webhook URL is not configured")
+	for _, file := range submission.Files {
+		emailData.Attachments = append(emailData.Attachments, email.AttachmentLink{
+			Name: file.OriginalName,
+			URL:  s.fileURL(file.ID),
+			Size: file.Size,
+		})
 	}
 
-	// Import the webhook utility
-	// Note: You'll need to add this import at the top of the file
-	// "formhub/pkg/utils"
+	return emailData
+}
 
-	payload := utils.WebhookPayload{
+// buildWebhookPayload prepares the legacy webhook payload sent to Form.WebhookURL
+func (s *SubmissionService) buildWebhookPayload(form *models.Form, submission *models.Submission) utils.WebhookPayload {
+	return utils.WebhookPayload{
 		Event:     "form.submission",
 		FormID:    form.ID.String(),
 		FormName:  form.Name,
@@ -450,6 +1157,7 @@ func (s *SubmissionService) sendWebhook(form *models.Form, submission *models.Su
 		Submission: utils.WebhookSubmission{
 			ID:        submission.ID.String(),
 			Data:      submission.Data,
+			Files:     s.webhookFiles(submission.Files),
 			IsSpam:    submission.IsSpam,
 			SpamScore: submission.SpamScore,
 			CreatedAt: submission.CreatedAt.Format(time.RFC3339),
@@ -459,25 +1167,29 @@ func (s *SubmissionService) sendWebhook(form *models.Form, submission *models.Su
 			"user_id":   form.UserID.String(),
 		},
 	}
+}
 
-	response, err := utils.SendWebhook(form.WebhookURL, payload)
-	if err != nil {
-		return fmt.Errorf("failed to send webhook: %w", err)
-	}
-
-	if !response.Success {
-		return fmt.Errorf("webhook returned non-success status: %d", response.StatusCode)
+// fileURL returns a signed download link for an attachment
+func (s *SubmissionService) fileURL(fileID uuid.UUID) string {
+	if s.fileUploadService == nil {
+		return ""
 	}
-
-	log.Printf("Webhook sent successfully to %s (status: %d, duration: %s)", 
-		form.WebhookURL, response.StatusCode, response.Duration)
-
-	return nil
+	return s.fileUploadService.SignedURL(fileID)
 }
 
-func (s *SubmissionService) markEmailSent(submissionID uuid.UUID) {
-	query := `UPDATE submissions SET email_sent = true WHERE id = ?`
-	s.db.Exec(query, submissionID)
+func (s *SubmissionService) webhookFiles(files []models.FileUpload) []utils.WebhookFile {
+	webhookFiles := make([]utils.WebhookFile, 0, len(files))
+	for _, file := range files {
+		webhookFiles = append(webhookFiles, utils.WebhookFile{
+			ID:          file.ID.String(),
+			FieldName:   file.FieldName,
+			Name:        file.OriginalName,
+			ContentType: file.ContentType,
+			Size:        file.Size,
+			URL:         s.fileURL(file.ID),
+		})
+	}
+	return webhookFiles
 }
 
 func (s *SubmissionService) markWebhookSent(submissionID uuid.UUID) {
@@ -485,9 +1197,280 @@ func (s *SubmissionService) markWebhookSent(submissionID uuid.UUID) {
 	s.db.Exec(query, submissionID)
 }
 
+// planLimitResponse turns a PlanLimitError into a rejected submission response
+func planLimitResponse(err error) *models.SubmissionResponse {
+	response := &models.SubmissionResponse{
+		Success:    false,
+		StatusCode: 403,
+		Message:    err.Error(),
+	}
+	if limitErr, ok := AsPlanLimitError(err); ok {
+		response.StatusCode = limitErr.StatusCode()
+	}
+	return response
+}
+
 func getSuccessMessage(form *models.Form) string {
 	if form.SuccessMessage != "" {
 		return form.SuccessMessage
 	}
 	return "Thank you for your submission! We'll get back to you soon."
-}
\ No newline at end of file
+}
+
+// ListSubmissions returns a page of submissions for a form using keyset pagination on (created_at, id)
+func (s *SubmissionService) ListSubmissions(formID uuid.UUID, params models.SubmissionListParams) (*models.SubmissionListResponse, error) {
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 25
+	}
+
+	return s.listSubmissionsPage(formID, params)
+}
+
+// listSubmissionsPage runs the page query without clamping the limit, for internal bulk readers
+func (s *SubmissionService) listSubmissionsPage(formID uuid.UUID, params models.SubmissionListParams) (*models.SubmissionListResponse, error) {
+	descending := !strings.EqualFold(params.SortOrder, "asc")
+
+	query := `
+		SELECT id, form_id, data, ip_address, user_agent, referrer,
+			is_spam, spam_score, spam_action, email_sent, webhook_sent, created_at
+		FROM submissions
+		WHERE form_id = ?`
+	args := []interface{}{formID}
+
+	if params.StartDate != nil {
+		query += " AND created_at >= ?"
+		args = append(args, *params.StartDate)
+	}
+	if params.EndDate != nil {
+		query += " AND created_at <= ?"
+		args = append(args, *params.EndDate)
+	}
+	if params.IsSpam != nil {
+		query += " AND is_spam = ?"
+		args = append(args, *params.IsSpam)
+	}
+	if params.EmailSent != nil {
+		query += " AND email_sent = ?"
+		args = append(args, *params.EmailSent)
+	}
+	if params.WebhookSent != nil {
+		query += " AND webhook_sent = ?"
+		args = append(args, *params.WebhookSent)
+	}
+
+	for field, value := range params.FieldEquals {
+		query += " AND JSON_UNQUOTE(JSON_EXTRACT(data, ?)) = ?"
+		args = append(args, jsonFieldPath(field), value)
+	}
+	for field, value := range params.FieldContains {
+		query += " AND JSON_UNQUOTE(JSON_EXTRACT(data, ?)) LIKE ?"
+		args = append(args, jsonFieldPath(field), "%"+escapeLike(value)+"%")
+	}
+
+	if params.Cursor != "" {
+		cursorTime, cursorID, err := decodeSubmissionCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if descending {
+			query += " AND (created_at < ? OR (created_at = ? AND id < ?))"
+		} else {
+			query += " AND (created_at > ? OR (created_at = ? AND id > ?))"
+		}
+		args = append(args, cursorTime, cursorTime, cursorID.String())
+	}
+
+	if descending {
+		query += " ORDER BY created_at DESC, id DESC"
+	} else {
+		query += " ORDER BY created_at ASC, id ASC"
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query += " LIMIT ?"
+	args = append(args, params.Limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list submissions: %w", err)
+	}
+	defer rows.Close()
+
+	submissions := []models.Submission{}
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, *submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate submissions: %w", err)
+	}
+
+	response := &models.SubmissionListResponse{}
+	if len(submissions) > params.Limit {
+		submissions = submissions[:params.Limit]
+		last := submissions[len(submissions)-1]
+		response.HasMore = true
+		response.NextCursor = encodeSubmissionCursor(last.CreatedAt, last.ID)
+	}
+
+	if err := s.attachFiles(submissions); err != nil {
+		return nil, err
+	}
+
+	response.Submissions = submissions
+	return response, nil
+}
+
+// getDataKeys returns the union of top-level keys in the data column for a form
+func (s *SubmissionService) getDataKeys(formID uuid.UUID) ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT JSON_KEYS(data) FROM submissions WHERE form_id = ?`, formID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submission data keys: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	var keys []string
+	for rows.Next() {
+		var keysJSON []byte
+		if err := rows.Scan(&keysJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan submission data keys: %w", err)
+		}
+
+		var rowKeys []string
+		if len(keysJSON) > 0 {
+			if err := json.Unmarshal(keysJSON, &rowKeys); err != nil {
+				return nil, fmt.Errorf("failed to decode submission data keys: %w", err)
+			}
+		}
+		for _, key := range rowKeys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys, rows.Err()
+}
+
+// attachFiles loads FileUpload rows for the given submissions in a single query
+func (s *SubmissionService) attachFiles(submissions []models.Submission) error {
+	if len(submissions) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(submissions))
+	args := make([]interface{}, len(submissions))
+	index := make(map[uuid.UUID]int, len(submissions))
+	for i, submission := range submissions {
+		placeholders[i] = "?"
+		args[i] = submission.ID
+		index[submission.ID] = i
+	}
+
+	query := `
+		SELECT id, submission_id, field_name, file_name, original_name, content_type, size,
+			storage_path, created_at
+		FROM file_uploads
+		WHERE submission_id IN (` + strings.Join(placeholders, ",") + `)
+		ORDER BY created_at ASC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to load submission files: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var file models.FileUpload
+		var fieldName sql.NullString
+		if err := rows.Scan(
+			&file.ID, &file.SubmissionID, &fieldName, &file.FileName, &file.OriginalName,
+			&file.ContentType, &file.Size, &file.StoragePath, &file.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to scan submission file: %w", err)
+		}
+		file.FieldName = fieldName.String
+		if i, ok := index[file.SubmissionID]; ok {
+			submissions[i].Files = append(submissions[i].Files, file)
+		}
+	}
+
+	return rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubmission(row rowScanner) (*models.Submission, error) {
+	var submission models.Submission
+	var dataJSON []byte
+	var ipAddress, userAgent, referrer sql.NullString
+
+	err := row.Scan(
+		&submission.ID, &submission.FormID, &dataJSON, &ipAddress, &userAgent,
+		&referrer, &submission.IsSpam, &submission.SpamScore, &submission.SpamAction,
+		&submission.EmailSent, &submission.WebhookSent, &submission.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan submission: %w", err)
+	}
+
+	submission.IPAddress = ipAddress.String
+	submission.UserAgent = userAgent.String
+	submission.Referrer = referrer.String
+
+	if len(dataJSON) > 0 {
+		if err := json.Unmarshal(dataJSON, &submission.Data); err != nil {
+			return nil, fmt.Errorf("failed to parse submission data: %w", err)
+		}
+	}
+
+	return &submission, nil
+}
+
+// jsonFieldPath builds a MySQL JSON path for a top-level key of the data column
+func jsonFieldPath(field string) string {
+	escaped := strings.ReplaceAll(field, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+	return `$."` + escaped + `"`
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
+
+func encodeSubmissionCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSubmissionCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	return createdAt, id, nil
+}
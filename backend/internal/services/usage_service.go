@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"formhub/internal/models"
+	"formhub/pkg/email"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// PlanCapability names a plan limit that is metered or gated
+type PlanCapability string
+
+const (
+	CapabilitySubmissions PlanCapability = "submissions"
+	CapabilityForms       PlanCapability = "forms"
+	CapabilityFileUploads PlanCapability = "file_uploads"
+	CapabilityWebhooks    PlanCapability = "webhooks"
+	CapabilityAnalytics   PlanCapability = "analytics"
+)
+
+const (
+	usageWarningApproaching = "approaching"
+	usageWarningReached     = "reached"
+)
+
+// reconcileCounterScript sets a usage counter to the database count only while it still holds the
+// value read before counting, so reservations made in the meantime are never overwritten.
+// KEYS[1] is the counter; ARGV is the value read, the new count and the TTL in milliseconds.
+var reconcileCounterScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// PlanLimitError is returned when an action is not allowed by the user's plan
+type PlanLimitError struct {
+	Capability PlanCapability
+	PlanType   string
+	Limit      int
+	Used       int
+}
+
+func (e *PlanLimitError) Error() string {
+	if e.Limit > 0 {
+		return fmt.Sprintf("%s limit reached for plan %s (%d of %d)", e.Capability, e.PlanType, e.Used, e.Limit)
+	}
+	return fmt.Sprintf("%s not available on plan %s", e.Capability, e.PlanType)
+}
+
+// StatusCode is 402 for an exhausted quota and 403 for a feature the plan does not include
+func (e *PlanLimitError) StatusCode() int {
+	if e.Capability == CapabilitySubmissions || e.Capability == CapabilityForms {
+		return 402
+	}
+	return 403
+}
+
+// AsPlanLimitError reports whether err is a PlanLimitError
+func AsPlanLimitError(err error) (*PlanLimitError, bool) {
+	var limitErr *PlanLimitError
+	if errors.As(err, &limitErr) {
+		return limitErr, true
+	}
+	return nil, false
+}
+
+// UserPlan is a user's plan type together with its limits
+type UserPlan struct {
+	UserID uuid.UUID
+	Type   string
+	Limits models.PlanLimits
+}
+
+// UsageService meters plan usage per billing owner per billing period. A workspace uses its owner's
+// plan, and usage is shared across all workspaces the owner pays for. Counters live in Redis and are
+// seeded from, and periodically reconciled against, the submissions table.
+// Billing periods are calendar months in UTC.
+type UsageService struct {
+	db           *sql.DB
+	redis        *redis.Client
+	emailService *email.SMTPService
+}
+
+func NewUsageService(db *sql.DB, redis *redis.Client, emailService *email.SMTPService) *UsageService {
+	return &UsageService{
+		db:           db,
+		redis:        redis,
+		emailService: emailService,
+	}
+}
+
+// GetPlan loads the user's plan; unknown plan types get the free plan's limits
+func (s *UsageService) GetPlan(userID uuid.UUID) (*UserPlan, error) {
+	var planType string
+	err := s.db.QueryRow("SELECT plan_type FROM users WHERE id = ?", userID).Scan(&planType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user plan: %w", err)
+	}
+
+	limits, ok := models.PlanLimitsMap[planType]
+	if !ok {
+		limits = models.PlanLimitsMap["free"]
+	}
+
+	return &UserPlan{UserID: userID, Type: planType, Limits: limits}, nil
+}
+
+// GetWorkspacePlan loads the plan of the workspace's billing owner
+func (s *UsageService) GetWorkspacePlan(workspaceID uuid.UUID) (*UserPlan, error) {
+	var ownerID uuid.UUID
+	if err := s.db.QueryRow("SELECT owner_id FROM workspaces WHERE id = ?", workspaceID).Scan(&ownerID); err != nil {
+		return nil, fmt.Errorf("failed to get workspace owner: %w", err)
+	}
+	return s.GetPlan(ownerID)
+}
+
+// CheckFeature returns a PlanLimitError when the workspace's plan does not include the capability
+func (s *UsageService) CheckFeature(workspaceID uuid.UUID, capability PlanCapability) error {
+	plan, err := s.GetWorkspacePlan(workspaceID)
+	if err != nil {
+		return err
+	}
+	return plan.CheckFeature(capability)
+}
+
+// CheckFeature returns a PlanLimitError when the plan does not include the capability
+func (p *UserPlan) CheckFeature(capability PlanCapability) error {
+	allowed := true
+	switch capability {
+	case CapabilityFileUploads:
+		allowed = p.Limits.FileUploads
+	case CapabilityWebhooks:
+		allowed = p.Limits.Webhooks
+	case CapabilityAnalytics:
+		allowed = p.Limits.Analytics
+	}
+
+	if !allowed {
+		return &PlanLimitError{Capability: capability, PlanType: p.Type}
+	}
+	return nil
+}
+
+// ReserveSubmission counts a submission against the monthly quota, rejecting it when the quota
+// is used up. Call ReleaseSubmission if the submission is not stored after all.
+func (s *UsageService) ReserveSubmission(plan *UserPlan) error {
+	limit := plan.Limits.SubmissionsPerMonth
+	start, end := s.CurrentPeriod()
+
+	if err := s.seedSubmissionCounter(plan.UserID, start, end); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	key := submissionUsageKey(plan.UserID, start)
+	used, err := s.redis.Incr(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to meter submission: %w", err)
+	}
+
+	if limit != -1 && int(used) > limit {
+		s.redis.Decr(ctx, key)
+		return &PlanLimitError{Capability: CapabilitySubmissions, PlanType: plan.Type, Limit: limit, Used: limit}
+	}
+
+	if warning := usageWarning(CapabilitySubmissions, int(used), limit); warning != nil {
+		s.notifyWarning(plan.UserID, start, *warning)
+	}
+
+	return nil
+}
+
+// ReleaseSubmission gives back a reservation made by ReserveSubmission
+func (s *UsageService) ReleaseSubmission(userID uuid.UUID) {
+	start, _ := s.CurrentPeriod()
+	if err := s.redis.Decr(context.Background(), submissionUsageKey(userID, start)).Err(); err != nil {
+		log.Printf("Failed to release submission reservation for user %s: %v", userID, err)
+	}
+}
+
+// GetUsage returns the usage of the workspace's billing owner for the current billing period.
+// Usage is shared across every workspace the owner pays for.
+func (s *UsageService) GetUsage(workspaceID uuid.UUID) (*models.UsageSummary, error) {
+	plan, err := s.GetWorkspacePlan(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	userID := plan.UserID
+
+	start, end := s.CurrentPeriod()
+	if err := s.seedSubmissionCounter(userID, start, end); err != nil {
+		return nil, err
+	}
+
+	submissions, err := s.redis.Get(context.Background(), submissionUsageKey(userID, start)).Int()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submission usage: %w", err)
+	}
+
+	var forms int
+	formsQuery := `
+		SELECT COUNT(*) FROM forms f
+		JOIN workspaces w ON f.workspace_id = w.id
+		WHERE w.owner_id = ? AND f.is_active = true`
+	if err := s.db.QueryRow(formsQuery, userID).Scan(&forms); err != nil {
+		return nil, fmt.Errorf("failed to get form count: %w", err)
+	}
+
+	summary := &models.UsageSummary{
+		PlanType:    plan.Type,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Submissions: usageMeter(submissions, plan.Limits.SubmissionsPerMonth),
+		Forms:       usageMeter(forms, plan.Limits.FormsLimit),
+		Features: map[string]bool{
+			string(CapabilityFileUploads): plan.Limits.FileUploads,
+			string(CapabilityWebhooks):    plan.Limits.Webhooks,
+			string(CapabilityAnalytics):   plan.Limits.Analytics,
+		},
+		Warnings: []models.UsageWarning{},
+	}
+
+	if warning := usageWarning(CapabilitySubmissions, submissions, plan.Limits.SubmissionsPerMonth); warning != nil {
+		summary.Warnings = append(summary.Warnings, *warning)
+	}
+	if warning := usageWarning(CapabilityForms, forms, plan.Limits.FormsLimit); warning != nil {
+		summary.Warnings = append(summary.Warnings, *warning)
+	}
+
+	return summary, nil
+}
+
+// Reconcile resets the current period's submission counters from the database, correcting
+// drift from lost Redis writes or releases that never happened. A counter that changes while it
+// is being recounted is left alone until the next run.
+func (s *UsageService) Reconcile() error {
+	start, end := s.CurrentPeriod()
+
+	query := `
+		SELECT DISTINCT w.owner_id
+		FROM submissions s
+		JOIN forms f ON s.form_id = f.id
+		JOIN workspaces w ON f.workspace_id = w.id
+		WHERE s.created_at >= ? AND s.created_at < ? AND s.spam_action != ?`
+
+	rows, err := s.db.Query(query, start, end, models.SpamActionBlock)
+	if err != nil {
+		return fmt.Errorf("failed to count submissions: %w", err)
+	}
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read submission owners: %w", err)
+	}
+
+	for _, userID := range userIDs {
+		if err := s.reconcileSubmissionCounter(userID, start, end); err != nil {
+			log.Printf("Failed to reconcile submission usage for user %s: %v", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// CurrentPeriod returns the start and end of the current billing period
+func (s *UsageService) CurrentPeriod() (time.Time, time.Time) {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// Private methods
+
+// seedSubmissionCounter loads the period's count from the database when Redis has no counter yet
+func (s *UsageService) seedSubmissionCounter(userID uuid.UUID, start, end time.Time) error {
+	ctx := context.Background()
+	key := submissionUsageKey(userID, start)
+
+	exists, err := s.redis.Exists(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read submission usage: %w", err)
+	}
+	if exists == 1 {
+		return nil
+	}
+
+	count, err := s.countSubmissions(userID, start, end)
+	if err != nil {
+		return err
+	}
+
+	// SetNX so a concurrent request that already seeded and incremented is not overwritten
+	if err := s.redis.SetNX(ctx, key, count, usageCounterTTL(end)).Err(); err != nil {
+		return fmt.Errorf("failed to seed submission usage: %w", err)
+	}
+	return nil
+}
+
+// reconcileSubmissionCounter recounts the user's submissions and stores the count, unless the
+// counter was reserved against or released while the database was being counted
+func (s *UsageService) reconcileSubmissionCounter(userID uuid.UUID, start, end time.Time) error {
+	ctx := context.Background()
+	key := submissionUsageKey(userID, start)
+
+	before, err := s.redis.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil // Seeded from the database on the next reservation
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read submission usage: %w", err)
+	}
+
+	count, err := s.countSubmissions(userID, start, end)
+	if err != nil {
+		return err
+	}
+
+	ttl := usageCounterTTL(end).Milliseconds()
+	if err := reconcileCounterScript.Run(ctx, s.redis, []string{key}, before, count, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store submission usage: %w", err)
+	}
+	return nil
+}
+
+// countSubmissions counts the submissions metered against the user in the period
+func (s *UsageService) countSubmissions(userID uuid.UUID, start, end time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM submissions s
+		JOIN forms f ON s.form_id = f.id
+		JOIN workspaces w ON f.workspace_id = w.id
+		WHERE w.owner_id = ? AND s.created_at >= ? AND s.created_at < ? AND s.spam_action != ?`
+
+	var count int
+	if err := s.db.QueryRow(query, userID, start, end, models.SpamActionBlock).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count submissions: %w", err)
+	}
+	return count, nil
+}
+
+// notifyWarning emails the account owner the first time a threshold is crossed in a period
+func (s *UsageService) notifyWarning(userID uuid.UUID, start time.Time, warning models.UsageWarning) {
+	ctx := context.Background()
+	key := fmt.Sprintf("usage:%s:%s:%s:warned:%s", userID, start.Format("2006-01"), warning.Capability, warning.Level)
+	first, err := s.redis.SetNX(ctx, key, 1, usageCounterTTL(start.AddDate(0, 1, 0))).Result()
+	if err != nil || !first {
+		return
+	}
+
+	log.Printf("Usage warning for user %s: %s", userID, warning.Message)
+
+	if s.emailService == nil {
+		return
+	}
+
+	go func() {
+		var userEmail string
+		if err := s.db.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&userEmail); err != nil {
+			log.Printf("Failed to get email for usage warning to user %s: %v", userID, err)
+			return
+		}
+
+		message := email.Message{
+			ToEmails:    []string{userEmail},
+			Subject:     "FormHub usage: " + warning.Message,
+			TextContent: fmt.Sprintf("%s\n\nYou have used %d of %d %s this billing period. Upgrade your plan to raise the limit.", warning.Message, warning.Used, warning.Limit, warning.Capability),
+		}
+		if err := s.emailService.Send(message); err != nil {
+			log.Printf("Failed to send usage warning to user %s: %v", userID, err)
+		}
+	}()
+}
+
+func submissionUsageKey(userID uuid.UUID, periodStart time.Time) string {
+	return fmt.Sprintf("usage:%s:%s:submissions", userID, periodStart.Format("2006-01"))
+}
+
+// usageCounterTTL keeps counters a week past the end of their period
+func usageCounterTTL(periodEnd time.Time) time.Duration {
+	return time.Until(periodEnd) + 7*24*time.Hour
+}
+
+func usageMeter(used, limit int) models.UsageMeter {
+	meter := models.UsageMeter{Used: used, Limit: limit, Remaining: -1}
+	if limit > 0 {
+		meter.Remaining = limit - used
+		if meter.Remaining < 0 {
+			meter.Remaining = 0
+		}
+		meter.Percent = float64(used) / float64(limit) * 100
+	}
+	return meter
+}
+
+// usageWarning returns a warning at 80% and 100% of a limited quota
+func usageWarning(capability PlanCapability, used, limit int) *models.UsageWarning {
+	if limit <= 0 {
+		return nil
+	}
+
+	percent := float64(used) / float64(limit) * 100
+	warning := &models.UsageWarning{
+		Capability: string(capability),
+		Used:       used,
+		Limit:      limit,
+		Percent:    percent,
+	}
+
+	switch {
+	case used >= limit:
+		warning.Level = usageWarningReached
+		warning.Message = fmt.Sprintf("%s limit reached", capability)
+	case percent >= 80:
+		warning.Level = usageWarningApproaching
+		warning.Message = fmt.Sprintf("%s usage is at %.0f%% of the plan limit", capability, percent)
+	default:
+		return nil
+	}
+
+	return warning
+}
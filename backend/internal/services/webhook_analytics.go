@@ -3,7 +3,6 @@ package services
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"sort"
@@ -12,7 +11,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -98,8 +96,7 @@ func (wa *WebhookAnalytics) RecordWebhookResult(formID, endpointID string, resul
 		log.Printf("Failed to record webhook result analytics: %v", err)
 	}
 	
-	// Store detailed record in database for long-term analytics
-	go wa.storeWebhookRecord(formID, endpointID, result)
+	// The detailed record for long-term analytics is written to webhook_logs by logWebhookAttempt
 }
 
 // RecordRateLimit records rate limiting events
@@ -632,48 +629,7 @@ func (wa *WebhookAnalytics) getTopErrors(formID string, timeRange TimeRange) ([]
 }
 
 func (wa *WebhookAnalytics) getFormEndpoints(formID string) ([]WebhookEndpoint, error) {
-	query := `SELECT webhook_config FROM forms WHERE id = ? AND webhook_config IS NOT NULL`
-	
-	var configJSON string
-	err := wa.db.QueryRow(query, formID).Scan(&configJSON)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return []WebhookEndpoint{}, nil
-		}
-		return nil, err
-	}
-	
-	var config FormWebhookConfig
-	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
-		return nil, err
-	}
-	
-	return config.Endpoints, nil
-}
-
-func (wa *WebhookAnalytics) storeWebhookRecord(formID, endpointID string, result *WebhookResult) {
-	query := `
-		INSERT INTO webhook_logs 
-		(id, endpoint_id, form_id, url, status_code, response_time_ms, attempts, success, error_message, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-	
-	_, err := wa.db.Exec(query,
-		uuid.New().String(),
-		endpointID,
-		formID,
-		result.URL,
-		result.StatusCode,
-		result.ResponseTime.Milliseconds(),
-		result.Attempts,
-		result.Success,
-		result.Error,
-		time.Now(),
-	)
-	
-	if err != nil {
-		log.Printf("Failed to store webhook record: %v", err)
-	}
+	return loadFormEndpoints(wa.db, formID)
 }
 
 func (wa *WebhookAnalytics) categorizeError(errorMessage string) string {
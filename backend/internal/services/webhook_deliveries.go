@@ -0,0 +1,406 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWebhookDeliveryNotFound = fmt.Errorf("webhook delivery not found")
+)
+
+// Webhook delivery statuses. A dead delivery exhausted its endpoint's retries, or failed in a way
+// that retrying cannot fix, and waits in the dead-letter store to be replayed or purged.
+const (
+	WebhookDeliveryPending    = "pending"
+	WebhookDeliveryProcessing = "processing"
+	WebhookDeliveryDelivered  = "delivered"
+	WebhookDeliveryDead       = "dead"
+)
+
+// WebhookDelivery is one event queued for one endpoint
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	FormID         string                `json:"form_id"`
+	EndpointID     string                `json:"endpoint_id"`
+	EventID        string                `json:"event_id"`
+	EventType      string                `json:"event_type"`
+	Event          *EnhancedWebhookEvent `json:"event,omitempty"`
+	Status         string                `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	LastStatusCode *int                  `json:"last_status_code,omitempty"`
+	LastError      *string               `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	DeadLetteredAt *time.Time            `json:"dead_lettered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+const webhookDeliveryColumns = `id, form_id, endpoint_id, event_id, event_type, event, status, attempts,
+	next_attempt_at, last_status_code, last_error, delivered_at, dead_lettered_at, created_at, updated_at`
+
+// ListDeadLetters returns a form's dead-lettered deliveries, newest first, optionally for one endpoint.
+// Events are left out of the listing; GetDeadLetter returns a delivery with its event.
+func (ews *EnhancedWebhookService) ListDeadLetters(formID, endpointID string, limit, offset int) ([]WebhookDelivery, int, error) {
+	where := " WHERE form_id = ? AND status = ?"
+	args := []interface{}{formID, WebhookDeliveryDead}
+	if endpointID != "" {
+		where += " AND endpoint_id = ?"
+		args = append(args, endpointID)
+	}
+
+	var total int
+	if err := ews.db.QueryRow("SELECT COUNT(*) FROM webhook_deliveries"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries" + where +
+		" ORDER BY dead_lettered_at DESC LIMIT ? OFFSET ?"
+	rows, err := ews.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		delivery.Event = nil
+		deliveries = append(deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+
+	return deliveries, total, nil
+}
+
+// GetDeadLetter returns a dead-lettered delivery of the form, including its event
+func (ews *EnhancedWebhookService) GetDeadLetter(formID, deliveryID string) (*WebhookDelivery, error) {
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE id = ? AND form_id = ? AND status = ?"
+
+	delivery, err := scanWebhookDelivery(ews.db.QueryRow(query, deliveryID, formID, WebhookDeliveryDead))
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// ReplayDeadLetter queues a dead-lettered delivery again with a fresh set of retries. The
+// endpoint's current configuration is used, so a fixed URL or secret applies to the replay.
+func (ews *EnhancedWebhookService) ReplayDeadLetter(formID, deliveryID string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = 0, next_attempt_at = ?, dead_lettered_at = NULL, updated_at = ?
+		WHERE id = ? AND form_id = ? AND status = ?`
+
+	now := time.Now()
+	result, err := ews.db.Exec(query, WebhookDeliveryPending, now, now, deliveryID, formID, WebhookDeliveryDead)
+	if err != nil {
+		return fmt.Errorf("failed to replay dead letter: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrWebhookDeliveryNotFound
+	}
+
+	ews.workerPool.Wake()
+	return nil
+}
+
+// ReplayDeadLetters queues all of a form's dead-lettered deliveries again, optionally for one
+// endpoint, and returns how many were replayed
+func (ews *EnhancedWebhookService) ReplayDeadLetters(formID, endpointID string) (int64, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = 0, next_attempt_at = ?, dead_lettered_at = NULL, updated_at = ?
+		WHERE form_id = ? AND status = ?`
+
+	now := time.Now()
+	args := []interface{}{WebhookDeliveryPending, now, now, formID, WebhookDeliveryDead}
+	if endpointID != "" {
+		query += " AND endpoint_id = ?"
+		args = append(args, endpointID)
+	}
+
+	result, err := ews.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay dead letters: %w", err)
+	}
+
+	replayed, _ := result.RowsAffected()
+	if replayed > 0 {
+		ews.workerPool.Wake()
+	}
+	return replayed, nil
+}
+
+// PurgeDeadLetter deletes a dead-lettered delivery of the form
+func (ews *EnhancedWebhookService) PurgeDeadLetter(formID, deliveryID string) error {
+	result, err := ews.db.Exec(`DELETE FROM webhook_deliveries WHERE id = ? AND form_id = ? AND status = ?`,
+		deliveryID, formID, WebhookDeliveryDead)
+	if err != nil {
+		return fmt.Errorf("failed to purge dead letter: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrWebhookDeliveryNotFound
+	}
+	return nil
+}
+
+// PurgeDeadLetters deletes all of a form's dead-lettered deliveries, optionally for one endpoint,
+// and returns how many were deleted
+func (ews *EnhancedWebhookService) PurgeDeadLetters(formID, endpointID string) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE form_id = ? AND status = ?`
+	args := []interface{}{formID, WebhookDeliveryDead}
+	if endpointID != "" {
+		query += " AND endpoint_id = ?"
+		args = append(args, endpointID)
+	}
+
+	result, err := ews.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+
+	purged, _ := result.RowsAffected()
+	return purged, nil
+}
+
+// Queue operations
+
+// enqueueDeliveries stores one pending delivery per endpoint of the job. An event that is sent
+// again, as when the submission outbox redelivers, is queued only once per endpoint.
+func (ews *EnhancedWebhookService) enqueueDeliveries(job *WebhookJob) error {
+	eventJSON, err := json.Marshal(job.Event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	tx, err := ews.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT IGNORE INTO webhook_deliveries
+		(id, form_id, endpoint_id, event_id, event_type, event, status, attempts, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?)`
+
+	now := time.Now()
+	for _, endpoint := range job.Endpoints {
+		if _, err := tx.Exec(query,
+			uuid.New().String(), job.FormID, endpoint.ID, job.Event.ID, job.Event.Type, string(eventJSON),
+			WebhookDeliveryPending, now, now, now,
+		); err != nil {
+			return fmt.Errorf("failed to queue webhook delivery: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// claimDeliveries leases due deliveries to the given owner, including ones whose previous lease
+// expired after a crash. Each claim counts as an attempt.
+func (ews *EnhancedWebhookService) claimDeliveries(owner string, limit int, leaseTimeout time.Duration) ([]WebhookDelivery, error) {
+	query := "SELECT " + webhookDeliveryColumns + ` FROM webhook_deliveries
+		WHERE (status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until <= ?)
+		ORDER BY next_attempt_at ASC
+		LIMIT ?`
+
+	now := time.Now()
+	rows, err := ews.db.Query(query,
+		WebhookDeliveryPending, now, WebhookDeliveryProcessing, now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			log.Printf("Skipping unreadable webhook delivery: %v", err)
+			continue
+		}
+		candidates = append(candidates, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+
+	// Claim each row with a conditional update so replicas never lease the same delivery
+	claimQuery := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, locked_by = ?, locked_until = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND attempts = ?`
+
+	var claimed []WebhookDelivery
+	for _, delivery := range candidates {
+		result, err := ews.db.Exec(claimQuery,
+			WebhookDeliveryProcessing, owner, now.Add(leaseTimeout), now,
+			delivery.ID, delivery.Status, delivery.Attempts,
+		)
+		if err != nil {
+			log.Printf("Failed to claim webhook delivery %s: %v", delivery.ID, err)
+			continue
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			delivery.Status = WebhookDeliveryProcessing
+			delivery.Attempts++
+			claimed = append(claimed, delivery)
+		}
+	}
+
+	return claimed, nil
+}
+
+// completeDelivery marks a leased delivery as delivered
+func (ews *EnhancedWebhookService) completeDelivery(delivery *WebhookDelivery, owner string, result *WebhookResult) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, last_status_code = ?, last_error = NULL, delivered_at = ?,
+		    locked_by = NULL, locked_until = NULL, updated_at = ?
+		WHERE id = ? AND locked_by = ?`
+
+	now := time.Now()
+	if _, err := ews.db.Exec(query, WebhookDeliveryDelivered, result.StatusCode, now, now, delivery.ID, owner); err != nil {
+		log.Printf("Failed to mark webhook delivery %s delivered: %v", delivery.ID, err)
+	}
+}
+
+// retryDelivery returns a leased delivery to the queue until next. An attempt that never reached
+// the endpoint, because its circuit was open or it was rate limited, is not counted.
+func (ews *EnhancedWebhookService) retryDelivery(delivery *WebhookDelivery, owner string, result *WebhookResult, next time.Time, counted bool) {
+	attempts := delivery.Attempts
+	if !counted {
+		attempts--
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?,
+		    locked_by = NULL, locked_until = NULL, updated_at = ?
+		WHERE id = ? AND locked_by = ?`
+
+	if _, err := ews.db.Exec(query,
+		WebhookDeliveryPending, attempts, next, result.StatusCode, result.Error, time.Now(), delivery.ID, owner,
+	); err != nil {
+		log.Printf("Failed to reschedule webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// releaseDelivery gives back a leased delivery that was never attempted
+func (ews *EnhancedWebhookService) releaseDelivery(delivery *WebhookDelivery, owner string) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts - 1, locked_by = NULL, locked_until = NULL, updated_at = ?
+		WHERE id = ? AND locked_by = ?`
+
+	if _, err := ews.db.Exec(query, WebhookDeliveryPending, time.Now(), delivery.ID, owner); err != nil {
+		log.Printf("Failed to release webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// deadLetterDelivery moves a leased delivery to the dead-letter store
+func (ews *EnhancedWebhookService) deadLetterDelivery(delivery *WebhookDelivery, owner string, statusCode int, reason string) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, last_status_code = ?, last_error = ?, dead_lettered_at = ?,
+		    locked_by = NULL, locked_until = NULL, updated_at = ?
+		WHERE id = ? AND locked_by = ?`
+
+	now := time.Now()
+	if _, err := ews.db.Exec(query, WebhookDeliveryDead, statusCode, reason, now, now, delivery.ID, owner); err != nil {
+		log.Printf("Failed to dead-letter webhook delivery %s: %v", delivery.ID, err)
+		return
+	}
+
+	log.Printf("Webhook delivery %s to endpoint %s dead-lettered after %d attempts: %s",
+		delivery.ID, delivery.EndpointID, delivery.Attempts, reason)
+}
+
+// findEndpoint looks up an endpoint receiving the form's events, returning nil if it is gone
+func (ews *EnhancedWebhookService) findEndpoint(formID, endpointID string) (*WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE id = ? AND ` + formEndpointsCondition
+	endpoint, err := scanWebhookEndpoint(ews.db.QueryRow(query, endpointID, formID, formID, formID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+// retryBackoff returns the delay before the next attempt, doubling the endpoint's retry delay
+// after each failed attempt up to the service maximum
+func (ews *EnhancedWebhookService) retryBackoff(endpoint *WebhookEndpoint, attempts int) time.Duration {
+	base := time.Duration(endpoint.RetryDelay) * time.Second
+	if base <= 0 {
+		base = ews.baseRetryDelay
+	}
+
+	delay := time.Duration(math.Pow(2, float64(attempts-1))) * base
+	if delay > ews.maxRetryDelay || delay <= 0 {
+		delay = ews.maxRetryDelay
+	}
+	return delay
+}
+
+func scanWebhookDelivery(row rowScanner) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	var eventJSON []byte
+	var lastStatusCode sql.NullInt64
+	var lastError sql.NullString
+	var deliveredAt, deadLetteredAt sql.NullTime
+
+	if err := row.Scan(
+		&delivery.ID, &delivery.FormID, &delivery.EndpointID, &delivery.EventID, &delivery.EventType,
+		&eventJSON, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+		&lastStatusCode, &lastError, &deliveredAt, &deadLetteredAt,
+		&delivery.CreatedAt, &delivery.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	var event EnhancedWebhookEvent
+	if err := json.Unmarshal(eventJSON, &event); err != nil {
+		return nil, fmt.Errorf("invalid event for delivery %s: %w", delivery.ID, err)
+	}
+	delivery.Event = &event
+
+	if lastStatusCode.Valid {
+		code := int(lastStatusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	if lastError.Valid {
+		delivery.LastError = &lastError.String
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	if deadLetteredAt.Valid {
+		delivery.DeadLetteredAt = &deadLetteredAt.Time
+	}
+
+	return &delivery, nil
+}
@@ -0,0 +1,500 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"formhub/pkg/webhooks"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWebhookEndpointConflict = fmt.Errorf("webhook endpoint has been changed since it was read")
+	ErrInvalidEndpointForms    = fmt.Errorf("account endpoints need all_forms or form_ids listing forms of the workspace")
+)
+
+const webhookEndpointColumns = `id, form_id, workspace_id, all_forms, name, url, secret, previous_secrets, events,
+	headers, content_type, method, timeout, max_retries, retry_delay, enabled, rate_limit_enabled, verify_ssl,
+	custom_payload, transform_config, conditional_rules, priority, tags, version, created_at, updated_at`
+
+// formEndpointsCondition matches a form's own endpoints and the account-level endpoints of its
+// workspace subscribed to it. It takes the form ID three times.
+const formEndpointsCondition = `(form_id = ? OR (workspace_id = (SELECT workspace_id FROM forms WHERE id = ?)
+	AND (all_forms = TRUE OR id IN (SELECT endpoint_id FROM webhook_endpoint_forms WHERE form_id = ?))))`
+
+// webhookEndpointScope is the owner of the endpoints a request works with: a form, or a workspace
+// for account-level endpoints
+type webhookEndpointScope struct {
+	formID      string
+	workspaceID string
+}
+
+func (s webhookEndpointScope) condition() (string, string) {
+	if s.workspaceID != "" {
+		return "workspace_id = ?", s.workspaceID
+	}
+	return "form_id = ?", s.formID
+}
+
+// ListWebhookEndpoints returns the endpoints receiving the form's events: its own endpoints and the
+// account-level endpoints subscribed to it
+func (ews *EnhancedWebhookService) ListWebhookEndpoints(formID string) ([]WebhookEndpoint, error) {
+	return loadFormEndpoints(ews.db, formID)
+}
+
+// GetWebhookEndpoint returns one of the form's own endpoints
+func (ews *EnhancedWebhookService) GetWebhookEndpoint(formID, endpointID string) (*WebhookEndpoint, error) {
+	return ews.getEndpoint(webhookEndpointScope{formID: formID}, endpointID)
+}
+
+// ListAccountWebhookEndpoints returns the workspace's account-level endpoints with their subscriptions
+func (ews *EnhancedWebhookService) ListAccountWebhookEndpoints(workspaceID string) ([]WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE workspace_id = ? ORDER BY priority, created_at`
+	rows, err := ews.db.Query(query, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list account webhook endpoints: %w", err)
+	}
+	endpoints, err := scanWebhookEndpoints(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	formRows, err := ews.db.Query(`
+		SELECT ef.endpoint_id, ef.form_id FROM webhook_endpoint_forms ef
+		JOIN webhook_endpoints e ON e.id = ef.endpoint_id
+		WHERE e.workspace_id = ?`, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list endpoint subscriptions: %w", err)
+	}
+	defer formRows.Close()
+
+	formIDs := make(map[string][]string)
+	for formRows.Next() {
+		var endpointID, formID string
+		if err := formRows.Scan(&endpointID, &formID); err != nil {
+			return nil, err
+		}
+		formIDs[endpointID] = append(formIDs[endpointID], formID)
+	}
+	if err := formRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range endpoints {
+		endpoints[i].FormIDs = formIDs[endpoints[i].ID]
+	}
+	return endpoints, nil
+}
+
+// GetAccountWebhookEndpoint returns one of the workspace's account-level endpoints
+func (ews *EnhancedWebhookService) GetAccountWebhookEndpoint(workspaceID, endpointID string) (*WebhookEndpoint, error) {
+	return ews.getEndpoint(webhookEndpointScope{workspaceID: workspaceID}, endpointID)
+}
+
+// CreateAccountWebhookEndpoint creates an endpoint receiving events from all of the workspace's
+// forms, or from the forms in its FormIDs
+func (ews *EnhancedWebhookService) CreateAccountWebhookEndpoint(workspaceID string, endpoint *WebhookEndpoint) error {
+	return ews.createEndpoint(webhookEndpointScope{workspaceID: workspaceID}, endpoint)
+}
+
+// UpdateAccountWebhookEndpoint replaces an account-level endpoint's configuration and subscriptions.
+// A non-zero version must match the stored one.
+func (ews *EnhancedWebhookService) UpdateAccountWebhookEndpoint(workspaceID, endpointID string, updates *WebhookEndpoint, version int) error {
+	return ews.updateEndpoint(webhookEndpointScope{workspaceID: workspaceID}, endpointID, updates, version)
+}
+
+// DeleteAccountWebhookEndpoint removes an account-level endpoint. A non-zero version must match the
+// stored one.
+func (ews *EnhancedWebhookService) DeleteAccountWebhookEndpoint(workspaceID, endpointID string, version int) error {
+	return ews.deleteEndpoint(webhookEndpointScope{workspaceID: workspaceID}, endpointID, version)
+}
+
+// RotateAccountWebhookSecret gives an account-level endpoint a new secret, like RotateWebhookSecret
+func (ews *EnhancedWebhookService) RotateAccountWebhookSecret(workspaceID, endpointID string, gracePeriod time.Duration) (*WebhookEndpoint, error) {
+	return ews.rotateSecret(webhookEndpointScope{workspaceID: workspaceID}, endpointID, gracePeriod)
+}
+
+func (ews *EnhancedWebhookService) getEndpoint(scope webhookEndpointScope, endpointID string) (*WebhookEndpoint, error) {
+	condition, owner := scope.condition()
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE id = ? AND ` + condition
+
+	endpoint, err := scanWebhookEndpoint(ews.db.QueryRow(query, endpointID, owner))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookEndpointNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+
+	if scope.workspaceID != "" {
+		rows, err := ews.db.Query(`SELECT form_id FROM webhook_endpoint_forms WHERE endpoint_id = ?`, endpointID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get endpoint subscriptions: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var formID string
+			if err := rows.Scan(&formID); err != nil {
+				return nil, err
+			}
+			endpoint.FormIDs = append(endpoint.FormIDs, formID)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return endpoint, nil
+}
+
+func (ews *EnhancedWebhookService) createEndpoint(scope webhookEndpointScope, endpoint *WebhookEndpoint) error {
+	// Validate endpoint
+	if err := ews.validateEndpoint(endpoint); err != nil {
+		return fmt.Errorf("invalid endpoint configuration: %w", err)
+	}
+	if err := ews.checkEndpointForms(scope, endpoint); err != nil {
+		return err
+	}
+
+	// Generate ID if not provided
+	if endpoint.ID == "" {
+		endpoint.ID = uuid.New().String()
+	}
+
+	// Every endpoint signs its deliveries; generate a secret if none was chosen
+	if endpoint.Secret == "" {
+		secret, err := webhooks.GenerateSecret()
+		if err != nil {
+			return err
+		}
+		endpoint.Secret = secret
+	}
+	endpoint.PreviousSecrets = nil
+
+	endpoint.FormID = scope.formID
+	endpoint.WorkspaceID = scope.workspaceID
+	endpoint.Version = 1
+	endpoint.CreatedAt = time.Now()
+	endpoint.UpdatedAt = endpoint.CreatedAt
+
+	values, err := webhookEndpointValues(endpoint)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ews.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO webhook_endpoints (id, form_id, workspace_id, all_forms, name, url, secret, previous_secrets,
+			events, headers, content_type, method, timeout, max_retries, retry_delay, enabled, rate_limit_enabled,
+			verify_ssl, custom_payload, transform_config, conditional_rules, priority, tags, version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	args := append([]interface{}{endpoint.ID, nullString(scope.formID), nullString(scope.workspaceID)}, values...)
+	args = append(args, endpoint.Version, endpoint.CreatedAt, endpoint.UpdatedAt)
+	if _, err := tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+
+	if err := saveEndpointForms(tx, endpoint); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ews *EnhancedWebhookService) updateEndpoint(scope webhookEndpointScope, endpointID string, updates *WebhookEndpoint, version int) error {
+	current, err := ews.getEndpoint(scope, endpointID)
+	if err != nil {
+		return err
+	}
+	if version > 0 && version != current.Version {
+		return ErrWebhookEndpointConflict
+	}
+
+	// Validate updates
+	if err := ews.validateEndpoint(updates); err != nil {
+		return fmt.Errorf("invalid endpoint updates: %w", err)
+	}
+	if err := ews.checkEndpointForms(scope, updates); err != nil {
+		return err
+	}
+
+	// A changed secret is rotated rather than replaced outright, so receivers still verifying
+	// with the old one keep working for the grace period.
+	now := time.Now()
+	updates.PreviousSecrets = activeWebhookSecrets(current.PreviousSecrets, now)
+	if current.Secret != "" && updates.Secret != current.Secret {
+		updates.PreviousSecrets = append(updates.PreviousSecrets, WebhookSecret{
+			Secret:    current.Secret,
+			ExpiresAt: now.Add(DefaultWebhookSecretGracePeriod),
+		})
+	}
+	updates.ID = current.ID
+	updates.FormID = current.FormID
+	updates.WorkspaceID = current.WorkspaceID
+	updates.Version = current.Version + 1
+	updates.CreatedAt = current.CreatedAt
+	updates.UpdatedAt = now
+
+	values, err := webhookEndpointValues(updates)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ews.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_endpoints SET all_forms = ?, name = ?, url = ?, secret = ?, previous_secrets = ?,
+			events = ?, headers = ?, content_type = ?, method = ?, timeout = ?, max_retries = ?, retry_delay = ?,
+			enabled = ?, rate_limit_enabled = ?, verify_ssl = ?, custom_payload = ?, transform_config = ?,
+			conditional_rules = ?, priority = ?, tags = ?, version = version + 1, updated_at = ?
+		WHERE id = ? AND version = ?
+	`
+	args := append(values, updates.UpdatedAt, endpointID, current.Version)
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook endpoint: %w", err)
+	}
+	// Another request changed the endpoint between our read and this write
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrWebhookEndpointConflict
+	}
+
+	if scope.workspaceID != "" {
+		if _, err := tx.Exec(`DELETE FROM webhook_endpoint_forms WHERE endpoint_id = ?`, endpointID); err != nil {
+			return fmt.Errorf("failed to update endpoint subscriptions: %w", err)
+		}
+		if err := saveEndpointForms(tx, updates); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (ews *EnhancedWebhookService) deleteEndpoint(scope webhookEndpointScope, endpointID string, version int) error {
+	condition, owner := scope.condition()
+	query := `DELETE FROM webhook_endpoints WHERE id = ? AND ` + condition
+	args := []interface{}{endpointID, owner}
+	if version > 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+
+	result, err := ews.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		// Tell a missing endpoint apart from one whose version moved on
+		if _, err := ews.getEndpoint(scope, endpointID); err != nil {
+			return err
+		}
+		return ErrWebhookEndpointConflict
+	}
+
+	// Archive endpoint data
+	ews.archiveEndpointData(endpointID)
+	return nil
+}
+
+func (ews *EnhancedWebhookService) rotateSecret(scope webhookEndpointScope, endpointID string, gracePeriod time.Duration) (*WebhookEndpoint, error) {
+	if gracePeriod < 0 || gracePeriod > maxWebhookSecretGracePeriod {
+		return nil, ErrInvalidGracePeriod
+	}
+
+	endpoint, err := ews.getEndpoint(scope, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	endpoint.PreviousSecrets = activeWebhookSecrets(endpoint.PreviousSecrets, now)
+	if endpoint.Secret != "" && gracePeriod > 0 {
+		endpoint.PreviousSecrets = append(endpoint.PreviousSecrets, WebhookSecret{
+			Secret:    endpoint.Secret,
+			ExpiresAt: now.Add(gracePeriod),
+		})
+	}
+	endpoint.Secret = secret
+	endpoint.UpdatedAt = now
+
+	previousSecrets, err := json.Marshal(endpoint.PreviousSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE webhook_endpoints SET secret = ?, previous_secrets = ?, version = version + 1, updated_at = ?
+		WHERE id = ? AND version = ?
+	`
+	result, err := ews.db.Exec(query, endpoint.Secret, string(previousSecrets), now, endpoint.ID, endpoint.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save webhook secret: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil, ErrWebhookEndpointConflict
+	}
+
+	endpoint.Version++
+	return endpoint, nil
+}
+
+// checkEndpointForms checks an account-level endpoint's subscriptions against its workspace. Form
+// endpoints never carry subscriptions.
+func (ews *EnhancedWebhookService) checkEndpointForms(scope webhookEndpointScope, endpoint *WebhookEndpoint) error {
+	if scope.workspaceID == "" {
+		endpoint.AllForms = false
+		endpoint.FormIDs = nil
+		return nil
+	}
+	if endpoint.AllForms {
+		endpoint.FormIDs = nil
+		return nil
+	}
+
+	unique := make(map[string]bool, len(endpoint.FormIDs))
+	formIDs := make([]string, 0, len(endpoint.FormIDs))
+	for _, formID := range endpoint.FormIDs {
+		if formID != "" && !unique[formID] {
+			unique[formID] = true
+			formIDs = append(formIDs, formID)
+		}
+	}
+	if len(formIDs) == 0 {
+		return ErrInvalidEndpointForms
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(formIDs)), ", ")
+	args := []interface{}{scope.workspaceID}
+	for _, formID := range formIDs {
+		args = append(args, formID)
+	}
+
+	var count int
+	query := `SELECT COUNT(*) FROM forms WHERE workspace_id = ? AND id IN (` + placeholders + `)`
+	if err := ews.db.QueryRow(query, args...).Scan(&count); err != nil {
+		return fmt.Errorf("failed to check subscribed forms: %w", err)
+	}
+	if count != len(formIDs) {
+		return ErrInvalidEndpointForms
+	}
+
+	endpoint.FormIDs = formIDs
+	return nil
+}
+
+func saveEndpointForms(tx *sql.Tx, endpoint *WebhookEndpoint) error {
+	for _, formID := range endpoint.FormIDs {
+		if _, err := tx.Exec(`INSERT INTO webhook_endpoint_forms (endpoint_id, form_id) VALUES (?, ?)`, endpoint.ID, formID); err != nil {
+			return fmt.Errorf("failed to save endpoint subscriptions: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadFormEndpoints returns the endpoints receiving a form's events
+func loadFormEndpoints(db *sql.DB, formID string) ([]WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE ` + formEndpointsCondition + `
+		ORDER BY priority, created_at`
+	rows, err := db.Query(query, formID, formID, formID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoints: %w", err)
+	}
+	return scanWebhookEndpoints(rows)
+}
+
+// webhookEndpointValues returns the configurable columns of an endpoint, from all_forms to tags
+func webhookEndpointValues(endpoint *WebhookEndpoint) ([]interface{}, error) {
+	encoded := make([]string, 6)
+	for i, value := range []interface{}{
+		endpoint.PreviousSecrets, endpoint.Events, endpoint.Headers,
+		endpoint.TransformConfig, endpoint.ConditionalRules, endpoint.Tags,
+	} {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode webhook endpoint: %w", err)
+		}
+		encoded[i] = string(data)
+	}
+
+	return []interface{}{
+		endpoint.AllForms, endpoint.Name, endpoint.URL, endpoint.Secret, encoded[0], encoded[1], encoded[2],
+		endpoint.ContentType, endpoint.Method, endpoint.Timeout, endpoint.MaxRetries, endpoint.RetryDelay,
+		endpoint.Enabled, endpoint.RateLimitEnabled, endpoint.VerifySSL, nullString(endpoint.CustomPayload),
+		encoded[3], encoded[4], endpoint.Priority, encoded[5],
+	}, nil
+}
+
+func scanWebhookEndpoints(rows *sql.Rows) ([]WebhookEndpoint, error) {
+	defer rows.Close()
+
+	endpoints := []WebhookEndpoint{}
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, *endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func scanWebhookEndpoint(row rowScanner) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	var formID, workspaceID, customPayload sql.NullString
+	var previousSecrets, events, headers, transformConfig, conditionalRules, tags []byte
+
+	if err := row.Scan(
+		&endpoint.ID, &formID, &workspaceID, &endpoint.AllForms, &endpoint.Name, &endpoint.URL, &endpoint.Secret,
+		&previousSecrets, &events, &headers, &endpoint.ContentType, &endpoint.Method, &endpoint.Timeout,
+		&endpoint.MaxRetries, &endpoint.RetryDelay, &endpoint.Enabled, &endpoint.RateLimitEnabled, &endpoint.VerifySSL,
+		&customPayload, &transformConfig, &conditionalRules, &endpoint.Priority, &tags, &endpoint.Version,
+		&endpoint.CreatedAt, &endpoint.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	endpoint.FormID = formID.String
+	endpoint.WorkspaceID = workspaceID.String
+	endpoint.CustomPayload = customPayload.String
+
+	for _, field := range []struct {
+		data []byte
+		dest interface{}
+	}{
+		{previousSecrets, &endpoint.PreviousSecrets},
+		{events, &endpoint.Events},
+		{headers, &endpoint.Headers},
+		{transformConfig, &endpoint.TransformConfig},
+		{conditionalRules, &endpoint.ConditionalRules},
+		{tags, &endpoint.Tags},
+	} {
+		if len(field.data) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(field.data, field.dest); err != nil {
+			return nil, fmt.Errorf("invalid configuration for webhook endpoint %s: %w", endpoint.ID, err)
+		}
+	}
+
+	return &endpoint, nil
+}
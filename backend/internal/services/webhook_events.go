@@ -0,0 +1,446 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"formhub/internal/models"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types published to endpoints
+const (
+	WebhookEventSubmissionCreated       = "submission.created"
+	WebhookEventSubmissionSpamFlagged   = "submission.spam_flagged"
+	WebhookEventSubmissionStatusChanged = "submission.status_changed"
+	WebhookEventEmailFailed             = "email.failed"
+	WebhookEventEmailOpened             = "email.opened"
+	WebhookEventFormUpdated             = "form.updated"
+	WebhookEventFileUploaded            = "file.uploaded"
+	WebhookEventQuarantineReviewed      = "quarantine.reviewed"
+	WebhookEventAlertTriggered          = "alert.triggered"
+)
+
+var (
+	ErrUnknownWebhookEvent       = fmt.Errorf("unknown webhook event type")
+	ErrWebhookEventSchemaMissing = fmt.Errorf("webhook event schema version not found")
+)
+
+// legacyWebhookEvents maps event names endpoints may still subscribe to onto their catalogue type
+var legacyWebhookEvents = map[string]string{
+	"form.submitted": WebhookEventSubmissionCreated,
+}
+
+// WebhookEventDefinition describes one version of an event's data in the catalogue
+type WebhookEventDefinition struct {
+	Type        string          `json:"type"`
+	Version     int             `json:"version"`
+	Description string          `json:"description"`
+	Source      string          `json:"source"` // Service publishing the event
+	Schema      json.RawMessage `json:"schema"` // JSON Schema of the event's data
+}
+
+// webhookEventCatalogue lists every version of every event. A change to an event's data that
+// receivers could trip over gets a new version rather than an edit to an existing one.
+var webhookEventCatalogue = []WebhookEventDefinition{
+	{
+		Type:        WebhookEventSubmissionCreated,
+		Version:     1,
+		Description: "A submission passed spam checks and its files are attached. Data holds the submitted values by field name; metadata holds form_name and files.",
+		Source:      "OutboxService",
+		Schema: eventSchema(WebhookEventSubmissionCreated, 1, `{
+			"type": "object",
+			"additionalProperties": true
+		}`),
+	},
+	{
+		Type:        WebhookEventSubmissionSpamFlagged,
+		Version:     1,
+		Description: "Spam protection quarantined or blocked a submission.",
+		Source:      "SubmissionLifecycleService",
+		Schema: eventSchema(WebhookEventSubmissionSpamFlagged, 1, `{
+			"type": "object",
+			"required": ["submission_id", "spam_score", "action"],
+			"properties": {
+				"submission_id": {"type": "string", "format": "uuid"},
+				"spam_score": {"type": "number", "minimum": 0, "maximum": 1},
+				"action": {"type": "string", "enum": ["quarantine", "block"]},
+				"reasons": {"type": "array", "items": {"type": "string"}}
+			}
+		}`),
+	},
+	{
+		Type:        WebhookEventSubmissionStatusChanged,
+		Version:     1,
+		Description: "A submission moved to a new lifecycle status.",
+		Source:      "SubmissionLifecycleService",
+		Schema: eventSchema(WebhookEventSubmissionStatusChanged, 1, `{
+			"type": "object",
+			"required": ["submission_id", "status"],
+			"properties": {
+				"submission_id": {"type": "string", "format": "uuid"},
+				"status": {"type": "string"},
+				"previous_status": {"type": ["string", "null"]},
+				"processing_time_ms": {"type": ["integer", "null"]}
+			}
+		}`),
+	},
+	{
+		Type:        WebhookEventEmailFailed,
+		Version:     1,
+		Description: "A notification email for the form could not be sent after all of its retries.",
+		Source:      "EmailQueueService",
+		Schema: eventSchema(WebhookEventEmailFailed, 1, `{
+			"type": "object",
+			"required": ["queue_id", "recipients", "reason"],
+			"properties": {
+				"queue_id": {"type": "string", "format": "uuid"},
+				"submission_id": {"type": ["string", "null"], "format": "uuid"},
+				"recipients": {"type": "array", "items": {"type": "string"}},
+				"subject": {"type": "string"},
+				"attempts": {"type": "integer"},
+				"reason": {"type": "string"}
+			}
+		}`),
+	},
+	{
+		Type:        WebhookEventEmailOpened,
+		Version:     1,
+		Description: "A recipient opened a tracked notification email for the first time.",
+		Source:      "EmailAnalyticsService",
+		Schema: eventSchema(WebhookEventEmailOpened, 1, `{
+			"type": "object",
+			"required": ["queue_id", "email_address", "opened_at"],
+			"properties": {
+				"queue_id": {"type": "string", "format": "uuid"},
+				"email_address": {"type": "string"},
+				"opened_at": {"type": "string", "format": "date-time"},
+				"user_agent": {"type": "string"}
+			}
+		}`),
+	},
+	{
+		Type:        WebhookEventFormUpdated,
+		Version:     1,
+		Description: "The form's settings were changed.",
+		Source:      "FormService",
+		Schema: eventSchema(WebhookEventFormUpdated, 1, `{
+			"type": "object",
+			"required": ["form_id", "name", "updated_by", "updated_at"],
+			"properties": {
+				"form_id": {"type": "string", "format": "uuid"},
+				"name": {"type": "string"},
+				"updated_by": {"type": "string", "format": "uuid"},
+				"updated_at": {"type": "string", "format": "date-time"}
+			}
+		}`),
+	},
+	{
+		Type:        WebhookEventFileUploaded,
+		Version:     1,
+		Description: "An uploaded file was stored with its submission.",
+		Source:      "FileUploadService",
+		Schema: eventSchema(WebhookEventFileUploaded, 1, `{
+			"type": "object",
+			"required": ["file_id", "submission_id", "field_name", "original_name", "content_type", "size"],
+			"properties": {
+				"file_id": {"type": "string", "format": "uuid"},
+				"submission_id": {"type": "string", "format": "uuid"},
+				"field_name": {"type": "string"},
+				"original_name": {"type": "string"},
+				"content_type": {"type": "string"},
+				"size": {"type": "integer", "minimum": 0}
+			}
+		}`),
+	},
+	{
+		Type:        WebhookEventQuarantineReviewed,
+		Version:     1,
+		Description: "A reviewer approved or rejected a quarantined submission. Approved submissions are delivered as submission.created.",
+		Source:      "SubmissionService",
+		Schema: eventSchema(WebhookEventQuarantineReviewed, 1, `{
+			"type": "object",
+			"required": ["submission_id", "decision", "reviewed_by", "reviewed_at"],
+			"properties": {
+				"submission_id": {"type": "string", "format": "uuid"},
+				"decision": {"type": "string", "enum": ["approve", "reject", "spam"]},
+				"reviewed_by": {"type": "string"},
+				"reviewed_at": {"type": "string", "format": "date-time"},
+				"notes": {"type": "string"}
+			}
+		}`),
+	},
+	{
+		Type:        WebhookEventAlertTriggered,
+		Version:     1,
+		Description: "A monitoring alert covering the form fired.",
+		Source:      "MonitoringService",
+		Schema: eventSchema(WebhookEventAlertTriggered, 1, `{
+			"type": "object",
+			"required": ["alert_id", "alert_name", "alert_type", "severity", "message", "triggered_at"],
+			"properties": {
+				"alert_id": {"type": "string", "format": "uuid"},
+				"alert_name": {"type": "string"},
+				"alert_type": {"type": "string"},
+				"severity": {"type": "string"},
+				"message": {"type": "string"},
+				"current_value": {"type": "number"},
+				"threshold": {"type": "number"},
+				"triggered_at": {"type": "string", "format": "date-time"}
+			}
+		}`),
+	},
+}
+
+// eventSchema wraps the data schema of an event version with its identifiers
+func eventSchema(eventType string, version int, data string) json.RawMessage {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &schema); err != nil {
+		panic(fmt.Sprintf("invalid schema for webhook event %s v%d: %v", eventType, version, err))
+	}
+
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = fmt.Sprintf("urn:formhub:webhook-event:%s:%d", eventType, version)
+	schema["title"] = fmt.Sprintf("%s data, version %d", eventType, version)
+
+	encoded, _ := json.Marshal(schema)
+	return encoded
+}
+
+// WebhookEventCatalogue returns the latest version of every event, ordered by type
+func WebhookEventCatalogue() []WebhookEventDefinition {
+	latest := make(map[string]WebhookEventDefinition)
+	for _, definition := range webhookEventCatalogue {
+		if current, ok := latest[definition.Type]; !ok || definition.Version > current.Version {
+			latest[definition.Type] = definition
+		}
+	}
+
+	definitions := make([]WebhookEventDefinition, 0, len(latest))
+	for _, definition := range latest {
+		definitions = append(definitions, definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Type < definitions[j].Type
+	})
+	return definitions
+}
+
+// WebhookEventSchema returns a version of an event's definition; version 0 means the latest
+func WebhookEventSchema(eventType string, version int) (*WebhookEventDefinition, error) {
+	var found *WebhookEventDefinition
+	for i := range webhookEventCatalogue {
+		definition := &webhookEventCatalogue[i]
+		if definition.Type != eventType {
+			continue
+		}
+		if version == definition.Version {
+			return definition, nil
+		}
+		if version == 0 && (found == nil || definition.Version > found.Version) {
+			found = definition
+		}
+	}
+
+	if found != nil {
+		return found, nil
+	}
+	if !isCatalogueEvent(eventType) {
+		return nil, ErrUnknownWebhookEvent
+	}
+	return nil, ErrWebhookEventSchemaMissing
+}
+
+// normalizeEventSubscriptions checks an endpoint's event list against the catalogue. Entries are
+// catalogue types, "*", or prefixes such as "submission.*" matching at least one type; legacy
+// names are rewritten to their catalogue type. An empty list subscribes to every event.
+func normalizeEventSubscriptions(events []string) ([]string, error) {
+	seen := make(map[string]bool, len(events))
+	normalized := make([]string, 0, len(events))
+
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if current, ok := legacyWebhookEvents[event]; ok {
+			event = current
+		}
+
+		valid := event == "*" || isCatalogueEvent(event)
+		if !valid && strings.HasSuffix(event, ".*") {
+			prefix := strings.TrimSuffix(event, "*")
+			for _, definition := range webhookEventCatalogue {
+				if strings.HasPrefix(definition.Type, prefix) {
+					valid = true
+					break
+				}
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownWebhookEvent, event)
+		}
+
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+
+	return normalized, nil
+}
+
+func isCatalogueEvent(eventType string) bool {
+	for _, definition := range webhookEventCatalogue {
+		if definition.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishEvent delivers a catalogue event to the endpoints subscribed to the form's events.
+// Deliveries are keyed by event ID, so publishing the same occurrence twice under one ID queues it
+// once; an empty ID gets a random one.
+func (ews *EnhancedWebhookService) PublishEvent(formID, eventType, eventID string, data map[string]interface{}) error {
+	definition, err := WebhookEventSchema(eventType, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %q", err, eventType)
+	}
+
+	if eventID == "" {
+		eventID = uuid.New().String()
+	}
+
+	event := &EnhancedWebhookEvent{
+		ID:            eventID,
+		Type:          eventType,
+		SchemaVersion: definition.Version,
+		Timestamp:     time.Now().UTC(),
+		FormID:        formID,
+		Data:          data,
+		Source:        "formhub",
+		Version:       "2.0",
+	}
+	if submissionID, ok := data["submission_id"].(string); ok {
+		event.SubmissionID = submissionID
+	}
+
+	return ews.SendWebhook(formID, event)
+}
+
+// publishWebhookEvent publishes an event on behalf of another service. The change the event
+// reports has already been made, so failures are logged rather than returned.
+func publishWebhookEvent(ews *EnhancedWebhookService, formID uuid.UUID, eventType, eventID string, data map[string]interface{}) {
+	if ews == nil {
+		return
+	}
+	if err := ews.PublishEvent(formID.String(), eventType, eventID, data); err != nil {
+		log.Printf("Failed to publish %s webhook event for form %s: %v", eventType, formID, err)
+	}
+}
+
+// The builders below assemble the data of each event's latest catalogue version, so the payload
+// shapes sit next to the schemas describing them.
+
+// spamFlaggedEventData is the data of submission.spam_flagged
+func spamFlaggedEventData(submission *models.Submission, reasons []string) map[string]interface{} {
+	if reasons == nil {
+		reasons = []string{}
+	}
+	return map[string]interface{}{
+		"submission_id": submission.ID.String(),
+		"spam_score":    submission.SpamScore,
+		"action":        submission.SpamAction,
+		"reasons":       reasons,
+	}
+}
+
+// statusChangedEventData is the data of submission.status_changed
+func statusChangedEventData(submissionID uuid.UUID, status models.SubmissionStatus, previousStatus sql.NullString, processingTimeMs *int) map[string]interface{} {
+	data := map[string]interface{}{
+		"submission_id":      submissionID.String(),
+		"status":             status,
+		"previous_status":    nil,
+		"processing_time_ms": processingTimeMs,
+	}
+	if previousStatus.Valid {
+		data["previous_status"] = previousStatus.String
+	}
+	return data
+}
+
+// emailFailedEventData is the data of email.failed
+func emailFailedEventData(queued *models.EmailQueue, attempts int, reason string) map[string]interface{} {
+	var submissionID interface{}
+	if queued.SubmissionID != nil {
+		submissionID = queued.SubmissionID.String()
+	}
+	return map[string]interface{}{
+		"queue_id":      queued.ID.String(),
+		"submission_id": submissionID,
+		"recipients":    queued.ToEmails,
+		"subject":       queued.Subject,
+		"attempts":      attempts,
+		"reason":        reason,
+	}
+}
+
+// emailOpenedEventData is the data of email.opened
+func emailOpenedEventData(queueID uuid.UUID, emailAddress, userAgent string, openedAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"queue_id":      queueID.String(),
+		"email_address": emailAddress,
+		"opened_at":     openedAt,
+		"user_agent":    userAgent,
+	}
+}
+
+// formUpdatedEventData is the data of form.updated
+func formUpdatedEventData(form *models.Form, updatedBy uuid.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"form_id":    form.ID.String(),
+		"name":       form.Name,
+		"updated_by": updatedBy.String(),
+		"updated_at": form.UpdatedAt,
+	}
+}
+
+// fileUploadedEventData is the data of file.uploaded
+func fileUploadedEventData(fileID, submissionID uuid.UUID, file *models.FileUpload) map[string]interface{} {
+	return map[string]interface{}{
+		"file_id":       fileID.String(),
+		"submission_id": submissionID.String(),
+		"field_name":    file.FieldName,
+		"original_name": file.OriginalName,
+		"content_type":  file.ContentType,
+		"size":          file.Size,
+	}
+}
+
+// quarantineReviewedEventData is the data of quarantine.reviewed
+func quarantineReviewedEventData(submissionID uuid.UUID, decision, reviewedBy, notes string, reviewedAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"submission_id": submissionID.String(),
+		"decision":      decision,
+		"reviewed_by":   reviewedBy,
+		"reviewed_at":   reviewedAt,
+		"notes":         notes,
+	}
+}
+
+// alertTriggeredEventData is the data of alert.triggered
+func alertTriggeredEventData(alert *models.MonitoringAlert, evaluation *AlertEvaluation, severity string) map[string]interface{} {
+	return map[string]interface{}{
+		"alert_id":      alert.ID.String(),
+		"alert_name":    alert.AlertName,
+		"alert_type":    string(alert.AlertType),
+		"severity":      severity,
+		"message":       evaluation.Message,
+		"current_value": evaluation.CurrentValue,
+		"threshold":     evaluation.Threshold,
+		"triggered_at":  evaluation.EvaluatedAt,
+	}
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"formhub/internal/models"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// eventSchemaNode is a compiled catalogue schema. Only the JSON Schema keywords the catalogue uses
+// are supported; compiling a schema with any other keyword fails, so the validator can never
+// silently skip a constraint.
+type eventSchemaNode struct {
+	types                []string
+	required             []string
+	properties           map[string]*eventSchemaNode
+	additionalProperties bool
+	items                *eventSchemaNode
+	enum                 []interface{}
+	minimum, maximum     *float64
+	format               string
+}
+
+var eventSchemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+func compileEventSchema(raw json.RawMessage) (*eventSchemaNode, error) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+	return compileEventSchemaNode(schema, "#", true)
+}
+
+func compileEventSchemaNode(schema map[string]interface{}, path string, root bool) (*eventSchemaNode, error) {
+	node := &eventSchemaNode{}
+
+	for keyword, value := range schema {
+		var err error
+		switch keyword {
+		case "$schema", "$id", "title":
+			if !root {
+				err = fmt.Errorf("only allowed at the root")
+			} else if _, ok := value.(string); !ok {
+				err = fmt.Errorf("must be a string")
+			}
+		case "type":
+			node.types, err = schemaStrings(value, true)
+			for _, t := range node.types {
+				if !eventSchemaTypes[t] {
+					err = fmt.Errorf("unknown type %q", t)
+				}
+			}
+		case "required":
+			node.required, err = schemaStrings(value, false)
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				err = fmt.Errorf("must be an object")
+				break
+			}
+			node.properties = make(map[string]*eventSchemaNode, len(properties))
+			for name, property := range properties {
+				propertySchema, ok := property.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%s/properties/%s: must be an object", path, name)
+				}
+				if node.properties[name], err = compileEventSchemaNode(propertySchema, path+"/properties/"+name, false); err != nil {
+					return nil, err
+				}
+			}
+		case "additionalProperties":
+			var ok bool
+			if node.additionalProperties, ok = value.(bool); !ok {
+				err = fmt.Errorf("must be a boolean")
+			}
+		case "items":
+			items, ok := value.(map[string]interface{})
+			if !ok {
+				err = fmt.Errorf("must be an object")
+				break
+			}
+			node.items, err = compileEventSchemaNode(items, path+"/items", false)
+		case "enum":
+			var ok bool
+			if node.enum, ok = value.([]interface{}); !ok || len(node.enum) == 0 {
+				err = fmt.Errorf("must be a non-empty array")
+			}
+		case "minimum", "maximum":
+			number, ok := value.(float64)
+			if !ok {
+				err = fmt.Errorf("must be a number")
+			} else if keyword == "minimum" {
+				node.minimum = &number
+			} else {
+				node.maximum = &number
+			}
+		case "format":
+			node.format, _ = value.(string)
+			if node.format != "uuid" && node.format != "date-time" {
+				err = fmt.Errorf("unsupported format %v", value)
+			}
+		default:
+			err = fmt.Errorf("unsupported keyword")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %v", path, keyword, err)
+		}
+	}
+
+	if len(node.types) == 0 {
+		return nil, fmt.Errorf("%s: type is required", path)
+	}
+	for _, name := range node.required {
+		if node.properties != nil && node.properties[name] == nil {
+			return nil, fmt.Errorf("%s: required property %q is not described", path, name)
+		}
+	}
+	return node, nil
+}
+
+func schemaStrings(value interface{}, single bool) ([]string, error) {
+	if s, ok := value.(string); ok && single {
+		return []string{s}, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be an array of strings")
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// validate checks a decoded JSON value against the schema. Objects with described properties
+// may not carry undescribed ones unless additionalProperties is true, so every key a payload
+// sends is documented in the catalogue.
+func (n *eventSchemaNode) validate(value interface{}, path string) []string {
+	if !n.hasType(value) {
+		return []string{fmt.Sprintf("%s: %s is not of type %s", path, jsonTypeOf(value), strings.Join(n.types, " or "))}
+	}
+
+	var problems []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range n.required {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		for name, property := range v {
+			if schema, ok := n.properties[name]; ok {
+				problems = append(problems, schema.validate(property, path+"."+name)...)
+			} else if n.properties != nil && !n.additionalProperties {
+				problems = append(problems, fmt.Sprintf("%s: property %q is not in the schema", path, name))
+			}
+		}
+	case []interface{}:
+		if n.items != nil {
+			for i, item := range v {
+				problems = append(problems, n.items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case float64:
+		if n.minimum != nil && v < *n.minimum {
+			problems = append(problems, fmt.Sprintf("%s: %v is below the minimum %v", path, v, *n.minimum))
+		}
+		if n.maximum != nil && v > *n.maximum {
+			problems = append(problems, fmt.Sprintf("%s: %v is above the maximum %v", path, v, *n.maximum))
+		}
+	case string:
+		switch n.format {
+		case "uuid":
+			if _, err := uuid.Parse(v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a uuid", path, v))
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", path, v))
+			}
+		}
+	}
+
+	if n.enum != nil {
+		found := false
+		for _, allowed := range n.enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", path, value, n.enum))
+		}
+	}
+
+	return problems
+}
+
+func (n *eventSchemaNode) hasType(value interface{}) bool {
+	actual := jsonTypeOf(value)
+	for _, t := range n.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// validateEventData validates event data the way a receiver sees it, after JSON encoding
+func validateEventData(t *testing.T, schema *eventSchemaNode, data map[string]interface{}) []string {
+	t.Helper()
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("failed to encode event data: %v", err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("failed to decode event data: %v", err)
+	}
+	return schema.validate(decoded, "data")
+}
+
+func TestWebhookEventCatalogueSchemasCompile(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, definition := range webhookEventCatalogue {
+		key := fmt.Sprintf("%s v%d", definition.Type, definition.Version)
+		if seen[key] {
+			t.Errorf("%s is listed twice", key)
+		}
+		seen[key] = true
+
+		if _, err := compileEventSchema(definition.Schema); err != nil {
+			t.Errorf("%s: schema does not compile: %v", key, err)
+		}
+	}
+}
+
+// samplePublishedEvents returns the data each publisher sends, built with the same builders, in
+// every shape the builders produce. Every catalogue event must have at least one.
+func samplePublishedEvents() map[string][]map[string]interface{} {
+	now := time.Now().UTC()
+	submissionID := uuid.New()
+	processingTime := 1250
+
+	submission := &models.Submission{
+		ID:         submissionID,
+		FormID:     uuid.New(),
+		Data:       map[string]interface{}{"email": "ada@example.com", "message": "Hello", "topics": []interface{}{"a", "b"}},
+		SpamScore:  0.92,
+		SpamAction: models.SpamActionQuarantine,
+	}
+	blocked := *submission
+	blocked.SpamScore = 1
+	blocked.SpamAction = models.SpamActionBlock
+
+	queued := &models.EmailQueue{
+		ID:       uuid.New(),
+		ToEmails: []string{"owner@example.com"},
+		Subject:  "New submission",
+	}
+	queuedForSubmission := *queued
+	queuedForSubmission.SubmissionID = &submissionID
+
+	form := &models.Form{ID: uuid.New(), Name: "Contact", UpdatedAt: now}
+	file := &models.FileUpload{FieldName: "resume", OriginalName: "cv.pdf", ContentType: "application/pdf", Size: 52431}
+	alert := &models.MonitoringAlert{ID: uuid.New(), AlertName: "Spam spike", AlertType: models.AlertTypeHighSpamRate}
+	evaluation := &AlertEvaluation{Triggered: true, CurrentValue: 0.4, Threshold: 0.25, Message: "Spam rate is 40%", EvaluatedAt: now}
+
+	return map[string][]map[string]interface{}{
+		WebhookEventSubmissionCreated: {submission.Data},
+		WebhookEventSubmissionSpamFlagged: {
+			spamFlaggedEventData(submission, []string{"honeypot"}),
+			spamFlaggedEventData(&blocked, nil),
+		},
+		WebhookEventSubmissionStatusChanged: {
+			statusChangedEventData(submissionID, models.SubmissionStatusProcessing, sql.NullString{}, nil),
+			statusChangedEventData(submissionID, models.SubmissionStatusCompleted, sql.NullString{String: "processing", Valid: true}, &processingTime),
+		},
+		WebhookEventEmailFailed: {
+			emailFailedEventData(queued, 3, "mailbox unavailable"),
+			emailFailedEventData(&queuedForSubmission, 3, "mailbox unavailable"),
+		},
+		WebhookEventEmailOpened: {
+			emailOpenedEventData(queued.ID, "owner@example.com", "Mozilla/5.0", now),
+		},
+		WebhookEventFormUpdated: {
+			formUpdatedEventData(form, uuid.New()),
+		},
+		WebhookEventFileUploaded: {
+			fileUploadedEventData(uuid.New(), submissionID, file),
+		},
+		WebhookEventQuarantineReviewed: {
+			quarantineReviewedEventData(submissionID, "approve", "reviewer@example.com", "", now),
+			quarantineReviewedEventData(submissionID, "spam", "reviewer@example.com", "Obvious spam", now),
+		},
+		WebhookEventAlertTriggered: {
+			alertTriggeredEventData(alert, evaluation, "high"),
+		},
+	}
+}
+
+func TestPublishedWebhookEventsMatchSchemas(t *testing.T) {
+	samples := samplePublishedEvents()
+
+	for _, definition := range WebhookEventCatalogue() {
+		schema, err := compileEventSchema(definition.Schema)
+		if err != nil {
+			t.Fatalf("%s: schema does not compile: %v", definition.Type, err)
+		}
+
+		payloads := samples[definition.Type]
+		if len(payloads) == 0 {
+			t.Errorf("%s: no published payload is checked against the schema", definition.Type)
+			continue
+		}
+		for i, data := range payloads {
+			for _, problem := range validateEventData(t, schema, data) {
+				t.Errorf("%s payload %d: %s", definition.Type, i, problem)
+			}
+		}
+	}
+
+	var unknown []string
+	for eventType := range samples {
+		if !isCatalogueEvent(eventType) {
+			unknown = append(unknown, eventType)
+		}
+	}
+	sort.Strings(unknown)
+	if len(unknown) > 0 {
+		t.Errorf("payloads for events missing from the catalogue: %v", unknown)
+	}
+}
+
+func TestEventSchemaValidatorRejectsMismatches(t *testing.T) {
+	definition, err := WebhookEventSchema(WebhookEventSubmissionSpamFlagged, 1)
+	if err != nil {
+		t.Fatalf("WebhookEventSchema: %v", err)
+	}
+	schema, err := compileEventSchema(definition.Schema)
+	if err != nil {
+		t.Fatalf("schema does not compile: %v", err)
+	}
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"submission_id": uuid.New().String(),
+			"spam_score":    0.5,
+			"action":        "block",
+			"reasons":       []string{"honeypot"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(map[string]interface{})
+	}{
+		{"missing required property", func(d map[string]interface{}) { delete(d, "action") }},
+		{"wrong type", func(d map[string]interface{}) { d["spam_score"] = "high" }},
+		{"not in enum", func(d map[string]interface{}) { d["action"] = "allow" }},
+		{"above maximum", func(d map[string]interface{}) { d["spam_score"] = 1.5 }},
+		{"bad uuid", func(d map[string]interface{}) { d["submission_id"] = "42" }},
+		{"wrong item type", func(d map[string]interface{}) { d["reasons"] = []int{1} }},
+		{"undescribed property", func(d map[string]interface{}) { d["extra"] = true }},
+	}
+
+	if problems := validateEventData(t, schema, valid()); len(problems) > 0 {
+		t.Fatalf("valid data rejected: %v", problems)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := valid()
+			tt.mutate(data)
+			if problems := validateEventData(t, schema, data); len(problems) == 0 {
+				t.Error("invalid data accepted")
+			}
+		})
+	}
+
+	if _, err := compileEventSchema(json.RawMessage(`{"type": "object", "pattern": "x"}`)); err == nil {
+		t.Error("schema with an unsupported keyword compiled")
+	}
+}
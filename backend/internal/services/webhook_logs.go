@@ -0,0 +1,381 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// maxLoggedResponseBody is how much of a response body is read and logged
+const maxLoggedResponseBody = 10240
+
+// DefaultWebhookLogRetention is how long delivery attempts are kept when no retention is configured
+const DefaultWebhookLogRetention = 30 * 24 * time.Hour
+
+var (
+	ErrWebhookLogNotFound          = fmt.Errorf("webhook log not found")
+	ErrWebhookLogNotResendable     = fmt.Errorf("webhook log has no captured request to resend")
+	ErrInvalidWebhookLogComparison = fmt.Errorf("between 2 and 5 webhook logs can be compared")
+)
+
+// WebhookLog is one logged delivery attempt with the request sent and the response received
+type WebhookLog struct {
+	ID                string            `json:"id"`
+	FormID            string            `json:"form_id"`
+	EndpointID        string            `json:"endpoint_id"`
+	DeliveryID        *string           `json:"delivery_id,omitempty"`
+	EventID           *string           `json:"event_id,omitempty"`
+	EventType         string            `json:"event_type"`
+	URL               string            `json:"url"`
+	Attempt           int               `json:"attempt"`
+	RequestMethod     *string           `json:"request_method,omitempty"`
+	RequestHeaders    map[string]string `json:"request_headers,omitempty"`
+	RequestBody       *string           `json:"request_body,omitempty"`
+	StatusCode        *int              `json:"status_code,omitempty"`
+	ResponseHeaders   map[string]string `json:"response_headers,omitempty"`
+	ResponseBody      *string           `json:"response_body,omitempty"`
+	ResponseTruncated bool              `json:"response_truncated"`
+	ResponseTimeMs    int64             `json:"response_time_ms"`
+	Success           bool              `json:"success"`
+	ErrorClass        *string           `json:"error_class,omitempty"`
+	ErrorMessage      *string           `json:"error_message,omitempty"`
+	ResentFrom        *string           `json:"resent_from,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+}
+
+// WebhookLogFilter narrows a listing of a form's delivery attempts
+type WebhookLogFilter struct {
+	EndpointID string
+	DeliveryID string
+	EventID    string
+	EventType  string
+	Success    *bool
+	StatusCode int
+	ErrorClass string
+	Search     string // Matched against the URL, error message and response body
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+	Offset     int
+}
+
+// WebhookLogComparison lines up attempts side by side
+type WebhookLogComparison struct {
+	Logs        []WebhookLog `json:"logs"`
+	Differences []string     `json:"differences"` // e.g. "status_code" or "response_headers.Retry-After"
+}
+
+// Listings leave out headers and bodies; GetWebhookLog returns them
+const (
+	webhookLogSummaryColumns = `id, form_id, endpoint_id, delivery_id, event_id, event_type, url, attempts,
+		request_method, NULL, NULL, status_code, NULL, NULL, response_truncated, response_time_ms, success,
+		error_class, error_message, resent_from, created_at`
+	webhookLogColumns = `id, form_id, endpoint_id, delivery_id, event_id, event_type, url, attempts,
+		request_method, request_headers, request_body, status_code, response_headers, response_body,
+		response_truncated, response_time_ms, success, error_class, error_message, resent_from, created_at`
+)
+
+// SetLogRetention sets how long delivery attempts are kept in the log
+func (ews *EnhancedWebhookService) SetLogRetention(retention time.Duration) {
+	if retention > 0 {
+		ews.logRetention = retention
+	}
+}
+
+// ListWebhookLogs returns a page of the form's delivery attempts, newest first, and the number of
+// attempts matching the filter
+func (ews *EnhancedWebhookService) ListWebhookLogs(formID string, filter WebhookLogFilter) ([]WebhookLog, int, error) {
+	where := " WHERE form_id = ?"
+	args := []interface{}{formID}
+
+	if filter.EndpointID != "" {
+		where += " AND endpoint_id = ?"
+		args = append(args, filter.EndpointID)
+	}
+	if filter.DeliveryID != "" {
+		where += " AND delivery_id = ?"
+		args = append(args, filter.DeliveryID)
+	}
+	if filter.EventID != "" {
+		where += " AND event_id = ?"
+		args = append(args, filter.EventID)
+	}
+	if filter.EventType != "" {
+		where += " AND event_type = ?"
+		args = append(args, filter.EventType)
+	}
+	if filter.Success != nil {
+		where += " AND success = ?"
+		args = append(args, *filter.Success)
+	}
+	if filter.StatusCode != 0 {
+		where += " AND status_code = ?"
+		args = append(args, filter.StatusCode)
+	}
+	if filter.ErrorClass != "" {
+		where += " AND error_class = ?"
+		args = append(args, filter.ErrorClass)
+	}
+	if filter.Search != "" {
+		pattern := "%" + filter.Search + "%"
+		where += " AND (url LIKE ? OR error_message LIKE ? OR response_body LIKE ?)"
+		args = append(args, pattern, pattern, pattern)
+	}
+	if filter.Since != nil {
+		where += " AND created_at >= ?"
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		where += " AND created_at < ?"
+		args = append(args, *filter.Until)
+	}
+
+	var total int
+	if err := ews.db.QueryRow("SELECT COUNT(*) FROM webhook_logs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook logs: %w", err)
+	}
+
+	query := "SELECT " + webhookLogSummaryColumns + " FROM webhook_logs" + where +
+		" ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	rows, err := ews.db.Query(query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := []WebhookLog{}
+	for rows.Next() {
+		entry, err := scanWebhookLog(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook log: %w", err)
+		}
+		logs = append(logs, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read webhook logs: %w", err)
+	}
+
+	return logs, total, nil
+}
+
+// GetWebhookLog returns one of the form's delivery attempts with its request and response
+func (ews *EnhancedWebhookService) GetWebhookLog(formID, logID string) (*WebhookLog, error) {
+	query := "SELECT " + webhookLogColumns + " FROM webhook_logs WHERE id = ? AND form_id = ?"
+
+	entry, err := scanWebhookLog(ews.db.QueryRow(query, logID, formID))
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookLogNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook log: %w", err)
+	}
+
+	return entry, nil
+}
+
+// CompareWebhookLogs returns the attempts in the order given and the fields that differ between them
+func (ews *EnhancedWebhookService) CompareWebhookLogs(formID string, logIDs []string) (*WebhookLogComparison, error) {
+	if len(logIDs) < 2 || len(logIDs) > 5 {
+		return nil, ErrInvalidWebhookLogComparison
+	}
+
+	comparison := &WebhookLogComparison{Logs: make([]WebhookLog, 0, len(logIDs))}
+	for _, logID := range logIDs {
+		entry, err := ews.GetWebhookLog(formID, logID)
+		if err != nil {
+			return nil, err
+		}
+		comparison.Logs = append(comparison.Logs, *entry)
+	}
+
+	fields := map[string]func(entry *WebhookLog) string{
+		"endpoint_id":    func(entry *WebhookLog) string { return entry.EndpointID },
+		"url":            func(entry *WebhookLog) string { return entry.URL },
+		"request_method": func(entry *WebhookLog) string { return stringValue(entry.RequestMethod) },
+		"request_body":   func(entry *WebhookLog) string { return stringValue(entry.RequestBody) },
+		"status_code": func(entry *WebhookLog) string {
+			if entry.StatusCode == nil {
+				return ""
+			}
+			return fmt.Sprint(*entry.StatusCode)
+		},
+		"success":       func(entry *WebhookLog) string { return fmt.Sprint(entry.Success) },
+		"error_class":   func(entry *WebhookLog) string { return stringValue(entry.ErrorClass) },
+		"error_message": func(entry *WebhookLog) string { return stringValue(entry.ErrorMessage) },
+		"response_body": func(entry *WebhookLog) string { return stringValue(entry.ResponseBody) },
+	}
+	for name, value := range fields {
+		if logsDiffer(comparison.Logs, value) {
+			comparison.Differences = append(comparison.Differences, name)
+		}
+	}
+
+	comparison.Differences = append(comparison.Differences,
+		headerDifferences("request_headers", comparison.Logs, func(entry *WebhookLog) map[string]string { return entry.RequestHeaders })...)
+	comparison.Differences = append(comparison.Differences,
+		headerDifferences("response_headers", comparison.Logs, func(entry *WebhookLog) map[string]string { return entry.ResponseHeaders })...)
+
+	sort.Strings(comparison.Differences)
+	return comparison, nil
+}
+
+// ResendWebhookAttempt sends a logged attempt's request body to its endpoint again and logs the new
+// attempt. The endpoint's current URL, headers and secrets are used, and the request is signed
+// afresh under the original event ID so receivers can still deduplicate it. The attempt's queued
+// delivery, if any, is left as it is.
+func (ews *EnhancedWebhookService) ResendWebhookAttempt(formID, logID string) (*WebhookResult, error) {
+	entry, err := ews.GetWebhookLog(formID, logID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.RequestBody == nil || entry.RequestMethod == nil {
+		return nil, ErrWebhookLogNotResendable
+	}
+
+	endpoint, err := ews.findEndpoint(formID, entry.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil {
+		return nil, ErrWebhookEndpointNotFound
+	}
+
+	eventID := entry.ID
+	if entry.EventID != nil {
+		eventID = *entry.EventID
+	}
+
+	startTime := time.Now()
+	result := &WebhookResult{
+		EndpointID: endpoint.ID,
+		DeliveryID: stringValue(entry.DeliveryID),
+		URL:        endpoint.URL,
+		StartTime:  startTime,
+		Attempts:   entry.Attempt,
+		ResentFrom: entry.ID,
+	}
+
+	attemptResult := ews.sendWebhookHTTPRequest(endpoint, []byte(*entry.RequestBody), eventID, entry.Attempt)
+	result.applyAttempt(attemptResult)
+	result.ResponseTime = time.Since(startTime)
+
+	ews.logWebhookAttempt(result, &EnhancedWebhookEvent{ID: eventID, Type: entry.EventType, FormID: formID})
+
+	return result, nil
+}
+
+// pruneWebhookLogs deletes logged attempts, and queued deliveries that were delivered, older than
+// the retention period
+func (ews *EnhancedWebhookService) pruneWebhookLogs() {
+	cutoff := time.Now().Add(-ews.logRetention)
+
+	result, err := ews.db.Exec(`DELETE FROM webhook_logs WHERE created_at < ? LIMIT 10000`, cutoff)
+	if err != nil {
+		log.Printf("Failed to prune webhook logs: %v", err)
+	} else if affected, _ := result.RowsAffected(); affected > 0 {
+		log.Printf("Pruned %d webhook logs", affected)
+	}
+
+	if _, err := ews.db.Exec(`DELETE FROM webhook_deliveries WHERE status = ? AND delivered_at < ? LIMIT 10000`,
+		WebhookDeliveryDelivered, cutoff); err != nil {
+		log.Printf("Failed to prune delivered webhook deliveries: %v", err)
+	}
+}
+
+func scanWebhookLog(row rowScanner) (*WebhookLog, error) {
+	var entry WebhookLog
+	var deliveryID, eventID, requestMethod, requestBody, responseBody sql.NullString
+	var errorClass, errorMessage, resentFrom sql.NullString
+	var requestHeaders, responseHeaders []byte
+	var statusCode, responseTimeMs, attempt sql.NullInt64
+	var responseTruncated, success sql.NullBool
+
+	if err := row.Scan(
+		&entry.ID, &entry.FormID, &entry.EndpointID, &deliveryID, &eventID, &entry.EventType, &entry.URL, &attempt,
+		&requestMethod, &requestHeaders, &requestBody, &statusCode, &responseHeaders, &responseBody,
+		&responseTruncated, &responseTimeMs, &success, &errorClass, &errorMessage, &resentFrom, &entry.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	entry.DeliveryID = nullStringPtr(deliveryID)
+	entry.EventID = nullStringPtr(eventID)
+	entry.RequestMethod = nullStringPtr(requestMethod)
+	entry.RequestBody = nullStringPtr(requestBody)
+	entry.ResponseBody = nullStringPtr(responseBody)
+	entry.ErrorClass = nullStringPtr(errorClass)
+	entry.ErrorMessage = nullStringPtr(errorMessage)
+	entry.ResentFrom = nullStringPtr(resentFrom)
+	entry.Attempt = int(attempt.Int64)
+	entry.ResponseTimeMs = responseTimeMs.Int64
+	entry.ResponseTruncated = responseTruncated.Bool
+	entry.Success = success.Bool
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		entry.StatusCode = &code
+	}
+
+	if len(requestHeaders) > 0 {
+		if err := json.Unmarshal(requestHeaders, &entry.RequestHeaders); err != nil {
+			return nil, fmt.Errorf("invalid request headers for log %s: %w", entry.ID, err)
+		}
+	}
+	if len(responseHeaders) > 0 {
+		if err := json.Unmarshal(responseHeaders, &entry.ResponseHeaders); err != nil {
+			return nil, fmt.Errorf("invalid response headers for log %s: %w", entry.ID, err)
+		}
+	}
+
+	return &entry, nil
+}
+
+func logsDiffer(logs []WebhookLog, value func(entry *WebhookLog) string) bool {
+	for i := 1; i < len(logs); i++ {
+		if value(&logs[i]) != value(&logs[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerDifferences names the headers, prefixed with group, whose values are not the same in every log
+func headerDifferences(group string, logs []WebhookLog, headers func(entry *WebhookLog) map[string]string) []string {
+	names := map[string]bool{}
+	for i := range logs {
+		for name := range headers(&logs[i]) {
+			names[name] = true
+		}
+	}
+
+	var differences []string
+	for name := range names {
+		differs := logsDiffer(logs, func(entry *WebhookLog) string {
+			value, ok := headers(entry)[name]
+			if !ok {
+				return "\x00missing"
+			}
+			return value
+		})
+		if differs {
+			differences = append(differences, group+"."+name)
+		}
+	}
+	return differences
+}
+
+func nullStringPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -612,7 +612,14 @@ func (wm *WebhookMonitor) getEndpointHealthStatus(ctx context.Context, formID, e
 }
 
 func (wm *WebhookMonitor) getFormsWithWebhooks() ([]string, error) {
-	query := `SELECT id FROM forms WHERE webhook_config IS NOT NULL`
+	// Forms with endpoints of their own or account-level endpoints subscribed to them
+	query := `
+		SELECT form_id FROM webhook_endpoints WHERE form_id IS NOT NULL
+		UNION
+		SELECT f.id FROM forms f JOIN webhook_endpoints e ON e.workspace_id = f.workspace_id AND e.all_forms = TRUE
+		UNION
+		SELECT form_id FROM webhook_endpoint_forms
+	`
 	
 	rows, err := wm.db.Query(query)
 	if err != nil {
@@ -632,23 +639,7 @@ func (wm *WebhookMonitor) getFormsWithWebhooks() ([]string, error) {
 }
 
 func (wm *WebhookMonitor) getFormEndpoints(formID string) ([]WebhookEndpoint, error) {
-	query := `SELECT webhook_config FROM forms WHERE id = ? AND webhook_config IS NOT NULL`
-	
-	var configJSON string
-	err := wm.db.QueryRow(query, formID).Scan(&configJSON)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return []WebhookEndpoint{}, nil
-		}
-		return nil, err
-	}
-	
-	var config FormWebhookConfig
-	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
-		return nil, err
-	}
-	
-	return config.Endpoints, nil
+	return loadFormEndpoints(wm.db, formID)
 }
 
 func (wm *WebhookMonitor) storeHealthCheck(formID string, healthCheck *EndpointHealthCheck) {
@@ -8,9 +8,9 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"formhub/pkg/webhooks"
 	"io"
 	"log"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -26,11 +26,16 @@ func NewWorkerPool(service *EnhancedWebhookService, workerCount int) *WorkerPool
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	pool := &WorkerPool{
-		workerCount: workerCount,
-		jobChan:     make(chan *WebhookJob, workerCount*10), // Buffered channel
-		workers:     make([]*WebhookWorker, workerCount),
-		ctx:         ctx,
-		cancel:      cancel,
+		service:      service,
+		owner:        uuid.New().String(),
+		workerCount:  workerCount,
+		jobChan:      make(chan *WebhookDelivery, workerCount*2), // Claimed deliveries waiting for a worker
+		wakeChan:     make(chan struct{}, 1),
+		workers:      make([]*WebhookWorker, workerCount),
+		pollInterval: 5 * time.Second,
+		leaseTimeout: 5 * time.Minute,
+		ctx:          ctx,
+		cancel:       cancel,
 	}
 	
 	// Create workers
@@ -38,6 +43,7 @@ func NewWorkerPool(service *EnhancedWebhookService, workerCount int) *WorkerPool
 		worker := &WebhookWorker{
 			id:      i,
 			service: service,
+			owner:   pool.owner,
 			jobChan: pool.jobChan,
 			quit:    make(chan bool),
 		}
@@ -47,7 +53,7 @@ func NewWorkerPool(service *EnhancedWebhookService, workerCount int) *WorkerPool
 	return pool
 }
 
-// Start starts all workers in the pool
+// Start starts all workers in the pool and the poller that leases due deliveries for them
 func (wp *WorkerPool) Start() {
 	log.Printf("Starting webhook worker pool with %d workers", wp.workerCount)
 	
@@ -55,15 +61,18 @@ func (wp *WorkerPool) Start() {
 		wp.wg.Add(1)
 		go worker.Start(&wp.wg)
 	}
+	
+	wp.wg.Add(1)
+	go wp.poll()
 }
 
-// Stop stops all workers in the pool
+// Stop stops all workers in the pool. Deliveries that were leased but not yet attempted are
+// released so another pool can pick them up straight away.
 func (wp *WorkerPool) Stop() {
 	log.Println("Stopping webhook worker pool...")
 	
-	// Stop accepting new jobs
+	// Stop leasing new deliveries
 	wp.cancel()
-	close(wp.jobChan)
 	
 	// Signal all workers to stop
 	for _, worker := range wp.workers {
@@ -72,22 +81,74 @@ func (wp *WorkerPool) Stop() {
 	
 	// Wait for all workers to finish
 	wp.wg.Wait()
-	log.Println("Webhook worker pool stopped")
+	
+	for {
+		select {
+		case delivery := <-wp.jobChan:
+			wp.service.releaseDelivery(delivery, wp.owner)
+		default:
+			log.Println("Webhook worker pool stopped")
+			return
+		}
+	}
 }
 
-// AddJob adds a job to the worker pool
-func (wp *WorkerPool) AddJob(job *WebhookJob) {
+// AddJob queues a delivery of the job's event to each of its endpoints. It returns an error if
+// the deliveries could not be stored.
+func (wp *WorkerPool) AddJob(job *WebhookJob) error {
+	if err := wp.service.enqueueDeliveries(job); err != nil {
+		log.Printf("Failed to queue webhook job %s: %v", job.ID, err)
+		return err
+	}
+	
+	wp.Wake()
+	return nil
+}
+
+// Wake asks the poller to lease due deliveries now instead of waiting for the next tick
+func (wp *WorkerPool) Wake() {
 	select {
-	case wp.jobChan <- job:
-		// Job added successfully
-	case <-wp.ctx.Done():
-		// Worker pool is shutting down
-		log.Printf("Cannot add job %s: worker pool is shutting down", job.ID)
+	case wp.wakeChan <- struct{}{}:
 	default:
-		// Channel is full, handle overflow
-		log.Printf("Worker pool job queue is full, dropping job %s", job.ID)
-		// In production, you might want to implement a overflow strategy
-		// like storing jobs in Redis or database
+		// A wake-up is already pending
+	}
+}
+
+// poll leases due deliveries whenever workers have room for them
+func (wp *WorkerPool) poll() {
+	defer wp.wg.Done()
+	
+	ticker := time.NewTicker(wp.pollInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ticker.C:
+		case <-wp.wakeChan:
+		case <-wp.ctx.Done():
+			return
+		}
+		
+		for {
+			// Only lease what the workers can start on before the lease runs out
+			free := cap(wp.jobChan) - len(wp.jobChan)
+			if free == 0 {
+				break
+			}
+			
+			deliveries, err := wp.service.claimDeliveries(wp.owner, free, wp.leaseTimeout)
+			if err != nil {
+				log.Printf("Error leasing webhook deliveries: %v", err)
+				break
+			}
+			
+			for i := range deliveries {
+				wp.jobChan <- &deliveries[i]
+			}
+			if len(deliveries) < free {
+				break
+			}
+		}
 	}
 }
 
@@ -98,10 +159,8 @@ func (w *WebhookWorker) Start(wg *sync.WaitGroup) {
 	
 	for {
 		select {
-		case job := <-w.jobChan:
-			if job != nil {
-				w.processJob(job)
-			}
+		case delivery := <-w.jobChan:
+			w.processDelivery(delivery)
 		case <-w.quit:
 			log.Printf("Stopping webhook worker %d", w.id)
 			return
@@ -109,65 +168,73 @@ func (w *WebhookWorker) Start(wg *sync.WaitGroup) {
 	}
 }
 
-// processJob processes a single webhook job
-func (w *WebhookWorker) processJob(job *WebhookJob) {
-	log.Printf("Worker %d processing job %s for form %s", w.id, job.ID, job.FormID)
-	
-	processedAt := time.Now()
-	job.ProcessedAt = &processedAt
+// processDelivery makes one attempt at a leased delivery and then completes it, schedules the
+// next attempt with exponential backoff, or moves it to the dead-letter store
+func (w *WebhookWorker) processDelivery(delivery *WebhookDelivery) {
+	ews := w.service
 	
-	// Process each endpoint in the job
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, 5) // Limit concurrent requests per job
+	endpoint, err := ews.findEndpoint(delivery.FormID, delivery.EndpointID)
+	if err != nil {
+		log.Printf("Worker %d failed to load endpoint for delivery %s: %v", w.id, delivery.ID, err)
+		ews.retryDelivery(delivery, w.owner, &WebhookResult{Error: err.Error()}, time.Now().Add(ews.baseRetryDelay), false)
+		return
+	}
+	if endpoint == nil {
+		ews.deadLetterDelivery(delivery, w.owner, 0, "endpoint no longer exists")
+		return
+	}
+	if !endpoint.Enabled {
+		ews.deadLetterDelivery(delivery, w.owner, 0, "endpoint is disabled")
+		return
+	}
 	
-	for _, endpoint := range job.Endpoints {
-		wg.Add(1)
-		go func(ep WebhookEndpoint) {
-			defer wg.Done()
-			
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-			
-			// Send webhook to this endpoint
-			result := w.service.sendSingleWebhook(&ep, job.Event)
-			
-			// Record result
-			w.service.analytics.RecordWebhookResult(job.FormID, ep.ID, result)
-			
-			// Update circuit breaker
-			if result.Success {
-				w.service.circuitBreaker.RecordSuccess(ep.ID)
-			} else {
-				w.service.circuitBreaker.RecordFailure(ep.ID)
-			}
-			
-		}(endpoint)
+	result := ews.attemptWebhook(endpoint, delivery.Event, delivery.Attempts, delivery.ID)
+	if result.RetryAfter > 0 {
+		// The endpoint was never called, so the attempt is given back
+		ews.retryDelivery(delivery, w.owner, result, time.Now().Add(result.RetryAfter), false)
+		return
 	}
 	
-	// Wait for all endpoints to complete
-	wg.Wait()
+	// Record result
+	ews.analytics.RecordWebhookResult(delivery.FormID, endpoint.ID, result)
 	
-	completedAt := time.Now()
-	job.CompletedAt = &completedAt
+	// Update circuit breaker
+	if result.Success {
+		ews.circuitBreaker.RecordSuccess(endpoint.ID)
+		ews.completeDelivery(delivery, w.owner, result)
+		return
+	}
+	ews.circuitBreaker.RecordFailure(endpoint.ID)
 	
-	log.Printf("Worker %d completed job %s in %v", w.id, job.ID, completedAt.Sub(processedAt))
+	switch {
+	case ews.shouldNotRetry(result.StatusCode):
+		ews.deadLetterDelivery(delivery, w.owner, result.StatusCode, result.Error)
+	case delivery.Attempts > endpoint.MaxRetries: // +1 for the initial attempt
+		ews.deadLetterDelivery(delivery, w.owner, result.StatusCode, result.Error)
+	default:
+		next := time.Now().Add(ews.retryBackoff(endpoint, delivery.Attempts))
+		ews.retryDelivery(delivery, w.owner, result, next, true)
+	}
 }
 
-// sendSingleWebhook sends a webhook to a single endpoint
-func (ews *EnhancedWebhookService) sendSingleWebhook(endpoint *WebhookEndpoint, event *EnhancedWebhookEvent) *WebhookResult {
+// attemptWebhook makes a single delivery attempt to an endpoint. Retries are scheduled by the
+// delivery queue; an attempt skipped because of the circuit breaker or rate limit sets RetryAfter.
+func (ews *EnhancedWebhookService) attemptWebhook(endpoint *WebhookEndpoint, event *EnhancedWebhookEvent, attempt int, deliveryID string) *WebhookResult {
 	startTime := time.Now()
 	
 	result := &WebhookResult{
 		EndpointID:   endpoint.ID,
+		DeliveryID:   deliveryID,
 		URL:          endpoint.URL,
 		StartTime:    startTime,
+		Attempts:     attempt,
 		Success:      false,
 	}
 	
 	// Check if endpoint is in circuit breaker open state
 	if ews.circuitBreaker.IsOpen(endpoint.ID) {
 		result.Error = "Circuit breaker is open"
+		result.RetryAfter = ews.circuitBreaker.resetTimeout
 		result.ResponseTime = time.Since(startTime)
 		return result
 	}
@@ -175,6 +242,7 @@ func (ews *EnhancedWebhookService) sendSingleWebhook(endpoint *WebhookEndpoint,
 	// Check rate limiting
 	if endpoint.RateLimitEnabled && ews.isRateLimited(endpoint.URL) {
 		result.Error = "Rate limit exceeded"
+		result.RetryAfter = ews.rateLimitWindow
 		result.ResponseTime = time.Since(startTime)
 		ews.analytics.RecordRateLimit(event.FormID, endpoint.ID)
 		return result
@@ -184,52 +252,15 @@ func (ews *EnhancedWebhookService) sendSingleWebhook(endpoint *WebhookEndpoint,
 	payload, err := ews.prepareWebhookPayload(endpoint, event)
 	if err != nil {
 		result.Error = fmt.Sprintf("Failed to prepare payload: %v", err)
+		result.ErrorClass = "payload"
 		result.ResponseTime = time.Since(startTime)
+		ews.logWebhookAttempt(result, event)
 		return result
 	}
 	
-	// Attempt delivery with retries
-	maxAttempts := endpoint.MaxRetries + 1 // +1 for initial attempt
-	var lastErr error
-	
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		if attempt > 1 {
-			// Calculate exponential backoff delay
-			delay := time.Duration(math.Pow(2, float64(attempt-2))) * time.Duration(endpoint.RetryDelay) * time.Second
-			if delay > ews.maxRetryDelay {
-				delay = ews.maxRetryDelay
-			}
-			time.Sleep(delay)
-		}
-		
-		attemptResult := ews.sendWebhookHTTPRequest(endpoint, payload, attempt)
-		
-		if attemptResult.Success {
-			result.Success = true
-			result.StatusCode = attemptResult.StatusCode
-			result.ResponseBody = attemptResult.ResponseBody
-			result.ResponseHeaders = attemptResult.ResponseHeaders
-			result.ResponseTime = time.Since(startTime)
-			result.Attempts = attempt
-			break
-		} else {
-			lastErr = fmt.Errorf(attemptResult.Error)
-			result.StatusCode = attemptResult.StatusCode
-			result.ResponseBody = attemptResult.ResponseBody
-			
-			// Don't retry on certain status codes
-			if ews.shouldNotRetry(attemptResult.StatusCode) {
-				break
-			}
-		}
-	}
-	
-	if !result.Success {
-		result.Error = lastErr.Error()
-	}
-	
+	attemptResult := ews.sendWebhookHTTPRequest(endpoint, payload, event.ID, attempt)
+	result.applyAttempt(attemptResult)
 	result.ResponseTime = time.Since(startTime)
-	result.Attempts = maxAttempts
 	
 	// Log the webhook attempt
 	ews.logWebhookAttempt(result, event)
@@ -516,7 +547,7 @@ func (ews *EnhancedWebhookService) processPayloadTemplate(templateStr string, ev
 }
 
 // sendWebhookHTTPRequest sends HTTP request to webhook endpoint
-func (ews *EnhancedWebhookService) sendWebhookHTTPRequest(endpoint *WebhookEndpoint, payload []byte, attempt int) *WebhookAttemptResult {
+func (ews *EnhancedWebhookService) sendWebhookHTTPRequest(endpoint *WebhookEndpoint, payload []byte, eventID string, attempt int) *WebhookAttemptResult {
 	result := &WebhookAttemptResult{
 		Attempt: attempt,
 		Success: false,
@@ -536,23 +567,45 @@ func (ews *EnhancedWebhookService) sendWebhookHTTPRequest(endpoint *WebhookEndpo
 	}
 	
 	// Set headers
+	signedAt := time.Now()
 	req.Header.Set("Content-Type", ews.getContentType(endpoint))
 	req.Header.Set("User-Agent", ews.userAgent)
-	req.Header.Set(ews.timestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set(ews.timestampHeader, strconv.FormatInt(signedAt.Unix(), 10))
 	req.Header.Set("X-FormHub-Attempt", strconv.Itoa(attempt))
 	req.Header.Set("X-FormHub-Endpoint-ID", endpoint.ID)
+	req.Header.Set("X-FormHub-Event-ID", eventID) // stable across retries so receivers can deduplicate
 	
 	// Set custom headers
 	for key, value := range endpoint.Headers {
 		req.Header.Set(key, value)
 	}
 	
-	// Add signature if secret is configured
+	// Standard Webhooks headers, signed with every active secret of the endpoint
+	req.Header.Set(webhooks.HeaderID, eventID)
+	req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(signedAt.Unix(), 10))
+	if signatures := ews.signPayload(endpoint, eventID, signedAt, payload); signatures != "" {
+		req.Header.Set(webhooks.HeaderSignature, signatures)
+	}
+	
+	// Legacy body-only signature for receivers that predate webhook-signature
 	if endpoint.Secret != "" {
 		signature := ews.generateSignature(payload, endpoint.Secret)
 		req.Header.Set(ews.signatureHeader, signature)
 	}
 	
+	// Capture the request for the delivery log; custom headers often carry credentials
+	result.RequestMethod = method
+	result.RequestBody = string(payload)
+	result.RequestHeaders = make(map[string]string, len(req.Header))
+	for key, values := range req.Header {
+		if len(values) > 0 {
+			result.RequestHeaders[key] = values[0]
+		}
+	}
+	for key := range endpoint.Headers {
+		result.RequestHeaders[http.CanonicalHeaderKey(key)] = "[redacted]"
+	}
+	
 	// Set timeout
 	ctx := ews.ctx
 	if endpoint.Timeout > 0 {
@@ -577,6 +630,7 @@ func (ews *EnhancedWebhookService) sendWebhookHTTPRequest(endpoint *WebhookEndpo
 	
 	if err != nil {
 		result.Error = fmt.Sprintf("request failed: %v", err)
+		result.ErrorClass = ews.analytics.categorizeError(err.Error())
 		return result
 	}
 	defer resp.Body.Close()
@@ -590,18 +644,30 @@ func (ews *EnhancedWebhookService) sendWebhookHTTPRequest(endpoint *WebhookEndpo
 	}
 	
 	// Read response body with limit
-	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, 10240)) // 10KB limit
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponseBody+1)) // 10KB limit
 	if err != nil {
 		result.Error = fmt.Sprintf("failed to read response: %v", err)
 	} else {
+		if len(bodyBytes) > maxLoggedResponseBody {
+			bodyBytes = bodyBytes[:maxLoggedResponseBody]
+			result.ResponseTruncated = true
+		}
 		result.ResponseBody = string(bodyBytes)
 	}
 	
 	// Check if request was successful
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
 		result.Success = true
-	} else {
+	case resp.StatusCode >= 500:
+		result.Error = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, result.ResponseBody)
+		result.ErrorClass = "server_error"
+	case resp.StatusCode >= 400:
+		result.Error = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, result.ResponseBody)
+		result.ErrorClass = "client_error"
+	default:
 		result.Error = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, result.ResponseBody)
+		result.ErrorClass = "unexpected_status"
 	}
 	
 	return result
@@ -622,6 +688,41 @@ func (ews *EnhancedWebhookService) generateSignature(payload []byte, secret stri
 	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
 }
 
+// signPayload returns the space-separated Standard Webhooks signatures of a delivery, one for the
+// endpoint's current secret and one for each secret still in its rotation grace period
+func (ews *EnhancedWebhookService) signPayload(endpoint *WebhookEndpoint, msgID string, signedAt time.Time, payload []byte) string {
+	secrets := make([]string, 0, len(endpoint.PreviousSecrets)+1)
+	if endpoint.Secret != "" {
+		secrets = append(secrets, endpoint.Secret)
+	}
+	for _, previous := range activeWebhookSecrets(endpoint.PreviousSecrets, signedAt) {
+		secrets = append(secrets, previous.Secret)
+	}
+	
+	signatures := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		key, err := webhooks.SigningKey(secret)
+		if err != nil {
+			log.Printf("Skipping unusable secret of webhook endpoint %s: %v", endpoint.ID, err)
+			continue
+		}
+		signatures = append(signatures, webhooks.Sign(key, msgID, signedAt, payload))
+	}
+	
+	return strings.Join(signatures, " ")
+}
+
+// activeWebhookSecrets drops the replaced secrets whose grace period has ended
+func activeWebhookSecrets(secrets []WebhookSecret, now time.Time) []WebhookSecret {
+	var active []WebhookSecret
+	for _, secret := range secrets {
+		if now.Before(secret.ExpiresAt) {
+			active = append(active, secret)
+		}
+	}
+	return active
+}
+
 func (ews *EnhancedWebhookService) shouldNotRetry(statusCode int) bool {
 	// Don't retry on client errors (4xx) except for some specific cases
 	if statusCode >= 400 && statusCode < 500 {
@@ -671,43 +772,40 @@ func (ews *EnhancedWebhookService) isRateLimited(webhookURL string) bool {
 	return count >= int64(ews.maxWebhooksPerMin)
 }
 
+// logWebhookAttempt stores an attempt, with its captured request and response, in webhook_logs
 func (ews *EnhancedWebhookService) logWebhookAttempt(result *WebhookResult, event *EnhancedWebhookEvent) {
-	logData := map[string]interface{}{
-		"webhook_id":     uuid.New().String(),
-		"endpoint_id":    result.EndpointID,
-		"url":           result.URL,
-		"event_type":    event.Type,
-		"form_id":       event.FormID,
-		"submission_id": event.SubmissionID,
-		"status_code":   result.StatusCode,
-		"response_time": result.ResponseTime.Milliseconds(),
-		"attempts":      result.Attempts,
-		"success":       result.Success,
-		"timestamp":     time.Now(),
-	}
-	
-	if result.Error != "" {
-		logData["error"] = result.Error
-	}
+	requestHeaders, _ := json.Marshal(result.RequestHeaders)
+	responseHeaders, _ := json.Marshal(result.ResponseHeaders)
 	
-	// Store in webhook_logs table
 	query := `
 		INSERT INTO webhook_logs 
-		(id, endpoint_id, form_id, event_type, url, status_code, response_time_ms, attempts, success, error_message, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		(id, endpoint_id, form_id, delivery_id, event_id, event_type, url, request_method, request_headers, request_body,
+		 status_code, response_time_ms, attempts, success, error_message, error_class, resent_from,
+		 response_body, response_headers, response_truncated, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
 	_, err := ews.db.Exec(query,
 		uuid.New().String(),
 		result.EndpointID,
 		event.FormID,
+		nullString(result.DeliveryID),
+		event.ID,
 		event.Type,
 		result.URL,
+		nullString(result.RequestMethod),
+		string(requestHeaders),
+		result.RequestBody,
 		result.StatusCode,
 		result.ResponseTime.Milliseconds(),
 		result.Attempts,
 		result.Success,
 		result.Error,
+		nullString(result.ErrorClass),
+		nullString(result.ResentFrom),
+		result.ResponseBody,
+		string(responseHeaders),
+		result.ResponseTruncated,
 		time.Now(),
 	)
 	
@@ -758,25 +856,52 @@ func (ews *EnhancedWebhookService) marshalYAML(payload interface{}) ([]byte, err
 
 // WebhookResult represents the result of a webhook delivery
 type WebhookResult struct {
-	EndpointID      string            `json:"endpoint_id"`
-	URL             string            `json:"url"`
-	Success         bool              `json:"success"`
-	StatusCode      int               `json:"status_code"`
-	ResponseBody    string            `json:"response_body"`
-	ResponseHeaders map[string]string `json:"response_headers"`
-	ResponseTime    time.Duration     `json:"response_time"`
-	Attempts        int               `json:"attempts"`
-	Error           string            `json:"error,omitempty"`
-	StartTime       time.Time         `json:"start_time"`
+	EndpointID        string            `json:"endpoint_id"`
+	DeliveryID        string            `json:"delivery_id,omitempty"`
+	URL               string            `json:"url"`
+	Success           bool              `json:"success"`
+	RequestMethod     string            `json:"request_method,omitempty"`
+	RequestHeaders    map[string]string `json:"request_headers,omitempty"`
+	RequestBody       string            `json:"request_body,omitempty"`
+	StatusCode        int               `json:"status_code"`
+	ResponseBody      string            `json:"response_body"`
+	ResponseHeaders   map[string]string `json:"response_headers"`
+	ResponseTruncated bool              `json:"response_truncated,omitempty"`
+	ResponseTime      time.Duration     `json:"response_time"`
+	Attempts          int               `json:"attempts"`
+	Error             string            `json:"error,omitempty"`
+	ErrorClass        string            `json:"error_class,omitempty"`
+	ResentFrom        string            `json:"resent_from,omitempty"` // Log ID of the attempt a manual resend repeated
+	StartTime         time.Time         `json:"start_time"`
+	RetryAfter        time.Duration     `json:"-"` // Set when the attempt was skipped rather than made
+}
+
+// applyAttempt copies the outcome and captured request and response of an HTTP attempt
+func (r *WebhookResult) applyAttempt(attempt *WebhookAttemptResult) {
+	r.Success = attempt.Success
+	r.RequestMethod = attempt.RequestMethod
+	r.RequestHeaders = attempt.RequestHeaders
+	r.RequestBody = attempt.RequestBody
+	r.StatusCode = attempt.StatusCode
+	r.ResponseBody = attempt.ResponseBody
+	r.ResponseHeaders = attempt.ResponseHeaders
+	r.ResponseTruncated = attempt.ResponseTruncated
+	r.Error = attempt.Error
+	r.ErrorClass = attempt.ErrorClass
 }
 
 // WebhookAttemptResult represents a single attempt result
 type WebhookAttemptResult struct {
-	Attempt         int               `json:"attempt"`
-	Success         bool              `json:"success"`
-	StatusCode      int               `json:"status_code"`
-	ResponseBody    string            `json:"response_body"`
-	ResponseHeaders map[string]string `json:"response_headers"`
-	ResponseTime    time.Duration     `json:"response_time"`
-	Error           string            `json:"error,omitempty"`
+	Attempt           int               `json:"attempt"`
+	Success           bool              `json:"success"`
+	RequestMethod     string            `json:"request_method"`
+	RequestHeaders    map[string]string `json:"request_headers"`
+	RequestBody       string            `json:"request_body"`
+	StatusCode        int               `json:"status_code"`
+	ResponseBody      string            `json:"response_body"`
+	ResponseHeaders   map[string]string `json:"response_headers"`
+	ResponseTruncated bool              `json:"response_truncated"`
+	ResponseTime      time.Duration     `json:"response_time"`
+	Error             string            `json:"error,omitempty"`
+	ErrorClass        string            `json:"error_class,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,678 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"formhub/internal/models"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrWorkspaceAccessDenied is returned when a user is not a member of a workspace
+// or their role is too low for the action
+var ErrWorkspaceAccessDenied = fmt.Errorf("workspace access denied")
+
+// ErrLastWorkspaceOwner is returned when an action would leave a workspace without an owner
+var ErrLastWorkspaceOwner = fmt.Errorf("workspace must keep at least one owner")
+
+// ErrMemberNotFound is returned for a user who is not a member of the workspace
+var ErrMemberNotFound = fmt.Errorf("member not found")
+
+// Errors returned for invitations that cannot be revoked or accepted
+var (
+	ErrInvitationNotFound       = fmt.Errorf("invitation not found")
+	ErrInvitationAccepted       = fmt.Errorf("invitation already accepted")
+	ErrInvitationExpired        = fmt.Errorf("invitation expired")
+	ErrInvitationWrongAddressee = fmt.Errorf("invitation was sent to a different email address")
+)
+
+// invitationTTL is how long an emailed invitation link stays valid
+const invitationTTL = 7 * 24 * time.Hour
+
+// WorkspaceService manages workspaces, their members and invitations. Forms, email templates,
+// email providers and API keys belong to a workspace; access to them is a membership check.
+type WorkspaceService struct {
+	db          *sql.DB
+	emailQueue  *EmailQueueService
+	frontendURL string
+}
+
+func NewWorkspaceService(db *sql.DB, emailQueue *EmailQueueService, frontendURL string) *WorkspaceService {
+	return &WorkspaceService{
+		db:          db,
+		emailQueue:  emailQueue,
+		frontendURL: frontendURL,
+	}
+}
+
+// CreateWorkspace creates a workspace with ownerID as its owner and billing owner
+func (s *WorkspaceService) CreateWorkspace(ownerID uuid.UUID, name string) (*models.Workspace, error) {
+	now := time.Now()
+	workspace := &models.Workspace{
+		ID:        uuid.New(),
+		Name:      name,
+		OwnerID:   ownerID,
+		Role:      models.WorkspaceRoleOwner,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO workspaces (id, name, owner_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		workspace.ID, workspace.Name, workspace.OwnerID, workspace.CreatedAt, workspace.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`,
+		workspace.ID, ownerID, models.WorkspaceRoleOwner, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add workspace owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit workspace: %w", err)
+	}
+
+	return workspace, nil
+}
+
+// CreatePersonalWorkspace creates a new user's first workspace and makes it their current one
+func (s *WorkspaceService) CreatePersonalWorkspace(user *models.User) (*models.Workspace, error) {
+	name := "My Workspace"
+	if user.FirstName != "" {
+		name = fmt.Sprintf("%s's Workspace", user.FirstName)
+	}
+
+	workspace, err := s.CreateWorkspace(user.ID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.setCurrentWorkspace(user.ID, workspace.ID); err != nil {
+		return nil, err
+	}
+	user.CurrentWorkspaceID = &workspace.ID
+
+	return workspace, nil
+}
+
+// GetWorkspace returns a workspace by ID
+func (s *WorkspaceService) GetWorkspace(workspaceID uuid.UUID) (*models.Workspace, error) {
+	workspace := &models.Workspace{}
+	err := s.db.QueryRow(
+		`SELECT id, name, owner_id, require_mfa, created_at, updated_at FROM workspaces WHERE id = ?`, workspaceID,
+	).Scan(&workspace.ID, &workspace.Name, &workspace.OwnerID, &workspace.RequireMFA, &workspace.CreatedAt, &workspace.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("workspace not found")
+		}
+		return nil, fmt.Errorf("failed to get workspace: %w", err)
+	}
+	return workspace, nil
+}
+
+// GetUserWorkspaces returns every workspace the user belongs to, with their role in each
+func (s *WorkspaceService) GetUserWorkspaces(userID uuid.UUID) ([]models.Workspace, error) {
+	query := `
+		SELECT w.id, w.name, w.owner_id, w.require_mfa, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = ?
+		ORDER BY w.created_at ASC`
+
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	workspaces := []models.Workspace{}
+	for rows.Next() {
+		var workspace models.Workspace
+		if err := rows.Scan(
+			&workspace.ID, &workspace.Name, &workspace.OwnerID, &workspace.RequireMFA, &workspace.Role,
+			&workspace.CreatedAt, &workspace.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace: %w", err)
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+// UpdateWorkspace renames the workspace or changes whether it requires two-factor authentication.
+// An admin can only require MFA once they use it themselves, so they cannot lock themselves out.
+func (s *WorkspaceService) UpdateWorkspace(workspaceID, actorID uuid.UUID, req models.UpdateWorkspaceRequest) (*models.Workspace, error) {
+	workspace, err := s.GetWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		workspace.Name = *req.Name
+	}
+	if req.RequireMFA != nil {
+		if *req.RequireMFA && !workspace.RequireMFA {
+			var mfaEnabledAt sql.NullTime
+			if err := s.db.QueryRow(`SELECT mfa_enabled_at FROM users WHERE id = ?`, actorID).Scan(&mfaEnabledAt); err != nil {
+				return nil, fmt.Errorf("failed to get user: %w", err)
+			}
+			if !mfaEnabledAt.Valid {
+				return nil, ErrMFANotEnabled
+			}
+		}
+		workspace.RequireMFA = *req.RequireMFA
+	}
+
+	workspace.UpdatedAt = time.Now()
+	_, err = s.db.Exec(
+		`UPDATE workspaces SET name = ?, require_mfa = ?, updated_at = ? WHERE id = ?`,
+		workspace.Name, workspace.RequireMFA, workspace.UpdatedAt, workspaceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update workspace: %w", err)
+	}
+
+	return workspace, nil
+}
+
+// MFASatisfied reports whether the user meets the workspace's two-factor requirement
+func (s *WorkspaceService) MFASatisfied(workspaceID, userID uuid.UUID) (bool, error) {
+	var satisfied bool
+	err := s.db.QueryRow(`
+		SELECT NOT w.require_mfa OR u.mfa_enabled_at IS NOT NULL
+		FROM workspaces w, users u
+		WHERE w.id = ? AND u.id = ?`, workspaceID, userID,
+	).Scan(&satisfied)
+	if err != nil {
+		return false, fmt.Errorf("failed to check workspace MFA requirement: %w", err)
+	}
+	return satisfied, nil
+}
+
+// GetRole returns the user's role in the workspace, or ErrWorkspaceAccessDenied if they are not a member
+func (s *WorkspaceService) GetRole(workspaceID, userID uuid.UUID) (models.WorkspaceRole, error) {
+	var role models.WorkspaceRole
+	err := s.db.QueryRow(
+		`SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?`, workspaceID, userID,
+	).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrWorkspaceAccessDenied
+		}
+		return "", fmt.Errorf("failed to get workspace membership: %w", err)
+	}
+	return role, nil
+}
+
+// Authorize checks that the user is a member of the workspace with at least the given role
+func (s *WorkspaceService) Authorize(workspaceID, userID uuid.UUID, min models.WorkspaceRole) error {
+	role, err := s.GetRole(workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if !role.AtLeast(min) {
+		return ErrWorkspaceAccessDenied
+	}
+	return nil
+}
+
+// DefaultWorkspace returns the user's current workspace, falling back to the oldest one they belong to
+func (s *WorkspaceService) DefaultWorkspace(userID uuid.UUID) (uuid.UUID, error) {
+	var current sql.NullString
+	if err := s.db.QueryRow(`SELECT current_workspace_id FROM users WHERE id = ?`, userID).Scan(&current); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get current workspace: %w", err)
+	}
+
+	if current.Valid {
+		if workspaceID, err := uuid.Parse(current.String); err == nil {
+			if _, err := s.GetRole(workspaceID, userID); err == nil {
+				return workspaceID, nil
+			}
+		}
+	}
+
+	var workspaceID uuid.UUID
+	err := s.db.QueryRow(
+		`SELECT workspace_id FROM workspace_members WHERE user_id = ? ORDER BY created_at ASC LIMIT 1`, userID,
+	).Scan(&workspaceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, ErrWorkspaceAccessDenied
+		}
+		return uuid.Nil, fmt.Errorf("failed to get workspace: %w", err)
+	}
+
+	return workspaceID, nil
+}
+
+// SwitchWorkspace makes workspaceID the user's current workspace
+func (s *WorkspaceService) SwitchWorkspace(userID, workspaceID uuid.UUID) error {
+	if err := s.Authorize(workspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+		return err
+	}
+	return s.setCurrentWorkspace(userID, workspaceID)
+}
+
+// BillingOwner returns the user whose plan applies to the workspace
+func (s *WorkspaceService) BillingOwner(workspaceID uuid.UUID) (uuid.UUID, error) {
+	workspace, err := s.GetWorkspace(workspaceID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return workspace.OwnerID, nil
+}
+
+// ListMembers returns the workspace's members
+func (s *WorkspaceService) ListMembers(workspaceID uuid.UUID) ([]models.WorkspaceMember, error) {
+	query := `
+		SELECT m.workspace_id, m.user_id, u.email, u.first_name, u.last_name, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = ?
+		ORDER BY m.created_at ASC`
+
+	rows, err := s.db.Query(query, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.WorkspaceMember{}
+	for rows.Next() {
+		var member models.WorkspaceMember
+		if err := rows.Scan(
+			&member.WorkspaceID, &member.UserID, &member.Email, &member.FirstName,
+			&member.LastName, &member.Role, &member.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// UpdateMemberRole changes a member's role. Admins manage members; only owners grant or revoke ownership.
+func (s *WorkspaceService) UpdateMemberRole(workspaceID, actorID, userID uuid.UUID, role models.WorkspaceRole) error {
+	if !role.Valid() {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	actorRole, err := s.GetRole(workspaceID, actorID)
+	if err != nil {
+		return err
+	}
+	if !actorRole.AtLeast(models.WorkspaceRoleAdmin) {
+		return ErrWorkspaceAccessDenied
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	currentRole, err := lockMemberRole(tx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if (role == models.WorkspaceRoleOwner || currentRole == models.WorkspaceRoleOwner) && actorRole != models.WorkspaceRoleOwner {
+		return ErrWorkspaceAccessDenied
+	}
+	if currentRole == models.WorkspaceRoleOwner && role != models.WorkspaceRoleOwner {
+		if err := s.releaseOwnership(tx, workspaceID, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		`UPDATE workspace_members SET role = ? WHERE workspace_id = ? AND user_id = ?`, role, workspaceID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update member role: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role change: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember removes a member from the workspace. Members may always remove themselves.
+func (s *WorkspaceService) RemoveMember(workspaceID, actorID, userID uuid.UUID) error {
+	var actorRole models.WorkspaceRole
+	if actorID != userID {
+		var err error
+		actorRole, err = s.GetRole(workspaceID, actorID)
+		if err != nil {
+			return err
+		}
+		if !actorRole.AtLeast(models.WorkspaceRoleAdmin) {
+			return ErrWorkspaceAccessDenied
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	targetRole, err := lockMemberRole(tx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if actorID != userID && targetRole == models.WorkspaceRoleOwner && actorRole != models.WorkspaceRoleOwner {
+		return ErrWorkspaceAccessDenied
+	}
+	if targetRole == models.WorkspaceRoleOwner {
+		if err := s.releaseOwnership(tx, workspaceID, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?`, workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit member removal: %w", err)
+	}
+	return nil
+}
+
+// InviteMember records an invitation and emails its link to the invitee
+func (s *WorkspaceService) InviteMember(workspaceID, inviterID uuid.UUID, req models.InviteMemberRequest) (*models.WorkspaceInvitation, error) {
+	if !req.Role.Valid() {
+		return nil, fmt.Errorf("invalid role: %s", req.Role)
+	}
+
+	inviterRole, err := s.GetRole(workspaceID, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	if !inviterRole.AtLeast(models.WorkspaceRoleAdmin) {
+		return nil, ErrWorkspaceAccessDenied
+	}
+	if req.Role == models.WorkspaceRoleOwner && inviterRole != models.WorkspaceRoleOwner {
+		return nil, ErrWorkspaceAccessDenied
+	}
+
+	workspace, err := s.GetWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, tokenHash, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invitation := &models.WorkspaceInvitation{
+		ID:          uuid.New(),
+		WorkspaceID: workspaceID,
+		Email:       strings.ToLower(strings.TrimSpace(req.Email)),
+		Role:        req.Role,
+		TokenHash:   tokenHash,
+		InvitedBy:   inviterID,
+		ExpiresAt:   now.Add(invitationTTL),
+		CreatedAt:   now,
+	}
+
+	query := `
+		INSERT INTO workspace_invitations (id, workspace_id, email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = s.db.Exec(query,
+		invitation.ID, invitation.WorkspaceID, invitation.Email, invitation.Role,
+		invitation.TokenHash, invitation.InvitedBy, invitation.ExpiresAt, invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	s.sendInvitation(workspace, invitation, token)
+
+	return invitation, nil
+}
+
+// ListInvitations returns the workspace's pending invitations
+func (s *WorkspaceService) ListInvitations(workspaceID uuid.UUID) ([]models.WorkspaceInvitation, error) {
+	query := `
+		SELECT id, workspace_id, email, role, invited_by, expires_at, created_at
+		FROM workspace_invitations
+		WHERE workspace_id = ? AND accepted_at IS NULL AND expires_at > ?
+		ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(query, workspaceID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []models.WorkspaceInvitation{}
+	for rows.Next() {
+		var invitation models.WorkspaceInvitation
+		if err := rows.Scan(
+			&invitation.ID, &invitation.WorkspaceID, &invitation.Email, &invitation.Role,
+			&invitation.InvitedBy, &invitation.ExpiresAt, &invitation.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation deletes a pending invitation
+func (s *WorkspaceService) RevokeInvitation(workspaceID, invitationID uuid.UUID) error {
+	result, err := s.db.Exec(
+		`DELETE FROM workspace_invitations WHERE id = ? AND workspace_id = ? AND accepted_at IS NULL`,
+		invitationID, workspaceID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation adds the user to the invitation's workspace. The invitation must be addressed
+// to the user's email, unexpired and not yet used.
+func (s *WorkspaceService) AcceptInvitation(token string, userID uuid.UUID) (*models.Workspace, error) {
+	sum := sha256.Sum256([]byte(token))
+	tokenHash := hex.EncodeToString(sum[:])
+
+	var invitation models.WorkspaceInvitation
+	var acceptedAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT id, workspace_id, email, role, expires_at, accepted_at
+		FROM workspace_invitations WHERE token_hash = ?`, tokenHash,
+	).Scan(&invitation.ID, &invitation.WorkspaceID, &invitation.Email, &invitation.Role, &invitation.ExpiresAt, &acceptedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	if acceptedAt.Valid {
+		return nil, ErrInvitationAccepted
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvitationExpired
+	}
+
+	var userEmail string
+	if err := s.db.QueryRow(`SELECT email FROM users WHERE id = ?`, userID).Scan(&userEmail); err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !strings.EqualFold(userEmail, invitation.Email) {
+		return nil, ErrInvitationWrongAddressee
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		`UPDATE workspace_invitations SET accepted_at = ? WHERE id = ? AND accepted_at IS NULL`, now, invitation.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, ErrInvitationAccepted
+	}
+
+	// Existing members keep their current role
+	_, err = tx.Exec(`
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE role = role`,
+		invitation.WorkspaceID, userID, invitation.Role, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add workspace member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+
+	workspace, err := s.GetWorkspace(invitation.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	workspace.Role, _ = s.GetRole(workspace.ID, userID)
+
+	return workspace, nil
+}
+
+// Private methods
+
+func (s *WorkspaceService) setCurrentWorkspace(userID, workspaceID uuid.UUID) error {
+	_, err := s.db.Exec(`UPDATE users SET current_workspace_id = ?, updated_at = ? WHERE id = ?`, workspaceID, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set current workspace: %w", err)
+	}
+	return nil
+}
+
+// lockMemberRole returns a member's role, locking their membership row for the rest of tx
+func lockMemberRole(tx *sql.Tx, workspaceID, userID uuid.UUID) (models.WorkspaceRole, error) {
+	var role models.WorkspaceRole
+	err := tx.QueryRow(
+		`SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ? FOR UPDATE`, workspaceID, userID,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrMemberNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get member role: %w", err)
+	}
+	return role, nil
+}
+
+// releaseOwnership is called in tx before userID stops being an owner of the workspace. It locks
+// the owner rows, so two owners cannot both leave believing the other remains, and fails if
+// userID is the last owner. If userID is the workspace's recorded (billing) owner, that passes to
+// the longest-standing remaining owner.
+func (s *WorkspaceService) releaseOwnership(tx *sql.Tx, workspaceID, userID uuid.UUID) error {
+	rows, err := tx.Query(
+		`SELECT user_id FROM workspace_members WHERE workspace_id = ? AND role = ? ORDER BY created_at ASC FOR UPDATE`,
+		workspaceID, models.WorkspaceRoleOwner,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get workspace owners: %w", err)
+	}
+	successor := uuid.Nil
+	for rows.Next() {
+		var ownerID uuid.UUID
+		if err := rows.Scan(&ownerID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan workspace owner: %w", err)
+		}
+		if ownerID != userID && successor == uuid.Nil {
+			successor = ownerID
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get workspace owners: %w", err)
+	}
+	if successor == uuid.Nil {
+		return ErrLastWorkspaceOwner
+	}
+
+	_, err = tx.Exec(
+		`UPDATE workspaces SET owner_id = ?, updated_at = ? WHERE id = ? AND owner_id = ?`,
+		successor, time.Now(), workspaceID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to transfer workspace ownership: %w", err)
+	}
+	return nil
+}
+
+// sendInvitation queues the invitation email, so it is retried like any other queued email
+func (s *WorkspaceService) sendInvitation(workspace *models.Workspace, invitation *models.WorkspaceInvitation, token string) {
+	if s.emailQueue == nil {
+		return
+	}
+
+	link := fmt.Sprintf("%s/invitations/accept?token=%s", s.frontendURL, token)
+	queued := &models.EmailQueue{
+		UserID:   invitation.InvitedBy,
+		ToEmails: []string{invitation.Email},
+		Subject:  fmt.Sprintf("You're invited to join %s on FormHub", workspace.Name),
+		TextContent: fmt.Sprintf(
+			"You have been invited to join the %s workspace on FormHub as %s.\n\nAccept the invitation: %s\n\nThis link expires on %s.",
+			workspace.Name, invitation.Role, link, invitation.ExpiresAt.Format("January 2, 2006"),
+		),
+		Priority:       10, // The invitee may be waiting for it
+		IdempotencyKey: "invitation:" + invitation.ID.String(),
+	}
+	if err := s.emailQueue.QueueEmail(queued); err != nil {
+		log.Printf("Failed to queue workspace invitation %s: %v", invitation.ID, err)
+	}
+}
+
+// generateInvitationToken returns a random token and the hash stored in its place
+func generateInvitationToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	sum := sha256.Sum256([]byte(token))
+	return token, hex.EncodeToString(sum[:]), nil
+}
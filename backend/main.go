@@ -5,9 +5,12 @@ import (
 	"formhub/internal/config"
 	"formhub/internal/handlers"
 	"formhub/internal/middleware"
+	"formhub/internal/models"
 	"formhub/internal/services"
 	"formhub/pkg/database"
 	"formhub/pkg/email"
+	"formhub/pkg/storage"
+	"formhub/pkg/utils"
 	"log"
 	"net/http"
 	"os"
@@ -49,7 +52,7 @@ func main() {
 	// Initialize core services
 	formService := services.NewFormService(db, redis)
 	submissionService := services.NewSubmissionService(db, redis, emailService)
-	authService := services.NewAuthService(db, redis, cfg.JWTSecret)
+	authService := services.NewAuthService(db, redis, cfg.JWTSecret, cfg.APIKeySecret)
 	
 	// Initialize analytics services
 	analyticsService := services.NewAnalyticsService(db, redis)
@@ -71,6 +74,7 @@ func main() {
 	
 	// Initialize enhanced webhook system
 	enhancedWebhookService := services.NewEnhancedWebhookService(db, redis)
+	enhancedWebhookService.SetLogRetention(cfg.WebhookLogRetention)
 	integrationManager := services.NewIntegrationManager(db, redis)
 	
 	// Keep legacy webhook service for compatibility
@@ -84,12 +88,82 @@ func main() {
 	emailAutoresponderService := services.NewEmailAutoresponderService(db, emailTemplateService, emailProviderService, emailQueueService)
 	templateBuilderService := services.NewTemplateBuilderService(db)
 	abTestingService := services.NewEmailABTestingService(db, emailTemplateService, emailAnalyticsService, emailQueueService)
+	emailQueueService.SetSystemSender(emailService)
+
+	// Publish webhook events from the services where they happen
+	submissionService.SetWebhookService(enhancedWebhookService)
+	submissionLifecycleService.SetWebhookService(enhancedWebhookService)
+	formService.SetWebhookService(enhancedWebhookService)
+	monitoringService.SetWebhookService(enhancedWebhookService)
+	emailQueueService.SetWebhookService(enhancedWebhookService)
+	emailAnalyticsService.SetWebhookService(enhancedWebhookService)
+
+	// Initialize submission outbox dispatcher
+	outboxService := services.NewOutboxService(db, submissionService, formService, emailQueueService, enhancedWebhookService)
+	submissionService.SetOutboxService(outboxService)
+	submissionService.SetSpamProtectionService(spamService)
+	submissionService.SetAuthService(authService)
+	submissionService.SetLifecycleService(submissionLifecycleService)
+	submissionService.SetAnalyticsService(analyticsService)
+
+	// Initialize plan usage metering
+	usageService := services.NewUsageService(db, redis, emailService)
+	submissionService.SetUsageService(usageService)
+
+	// Initialize team workspaces; forms, email templates, providers and API keys belong to a workspace
+	workspaceService := services.NewWorkspaceService(db, emailQueueService, cfg.FrontendURL)
+	authService.SetWorkspaceService(workspaceService)
+	formService.SetWorkspaceService(workspaceService)
+	emailTemplateService.SetWorkspaceService(workspaceService)
+	emailProviderService.SetWorkspaceService(workspaceService)
+
+	// Initialize TOTP two-factor authentication
+	mfaSecrets, err := utils.NewSecretBox(cfg.APIKeySecret, "mfa-totp-secret")
+	if err != nil {
+		log.Fatalf("Failed to initialize MFA secret encryption: %v", err)
+	}
+	mfaService := services.NewMFAService(db, redis, mfaSecrets)
+	authService.SetMFAService(mfaService)
+
+	// Initialize single sign-on through OIDC and SAML identity providers
+	ssoService := services.NewSSOService(db, redis, cfg.BaseURL, cfg.FrontendURL)
+	authService.SetSSOService(ssoService)
+
+	// Initialize email verification, password reset and profile management
+	accountService := services.NewAccountService(db, redis, authService, emailQueueService, cfg.FrontendURL)
+
+	// Initialize file storage
+	fileStorage, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize file storage: %v", err)
+	}
+
+	// Initialize file and export services
+	fileUploadService := services.NewFileUploadService(db, fileStorage)
+	fieldValidationService := services.NewFieldValidationService(db)
+	submissionService.SetFieldValidationService(fieldValidationService)
+	urlSigner := utils.NewURLSigner(cfg.BaseURL, cfg.FileURLSecret)
+	fileUploadService.SetURLSigner(urlSigner, cfg.FileURLTTL)
+	fileUploadService.SetWebhookService(enhancedWebhookService)
+	submissionExportService := services.NewSubmissionExportService(submissionService, fieldValidationService, urlSigner, cfg.ExportLinkTTL)
+
+	// Initialize saved progress for multi-step forms
+	partialSubmissionService := services.NewPartialSubmissionService(db, redis, fieldValidationService, emailQueueService, urlSigner, cfg.FrontendURL)
+	partialSubmissionService.SetAnalyticsService(analyticsService)
+	submissionService.SetPartialSubmissionService(partialSubmissionService)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService)
-	formHandler := handlers.NewFormHandler(formService, authService)
-	submissionHandler := handlers.NewSubmissionHandler(submissionService, formService, authService)
-	spamAdminHandler := handlers.NewSpamAdminHandler(spamService, behavioralAnalyzer, mlClassifier, webhookService, authService)
+	authHandler := handlers.NewAuthHandler(authService, accountService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, authService)
+	ssoHandler := handlers.NewSSOHandler(ssoService, authService)
+	formHandler := handlers.NewFormHandler(formService, authService, fieldValidationService, fileUploadService)
+	partialSubmissionHandler := handlers.NewPartialSubmissionHandler(partialSubmissionService, submissionService)
+	submissionHandler := handlers.NewSubmissionHandler(submissionService, formService, authService, fileUploadService, fieldValidationService, submissionExportService, usageService)
+	fileHandler := handlers.NewFileHandler(fileUploadService, workspaceService)
+	usageHandler := handlers.NewUsageHandler(usageService)
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService, authService)
+	spamAdminHandler := handlers.NewSpamAdminHandler(spamService, behavioralAnalyzer, mlClassifier, webhookService, authService, submissionService)
 	emailTemplateHandler := handlers.NewEmailTemplateHandler(
 		emailTemplateService, 
 		emailProviderService, 
@@ -104,7 +178,7 @@ func main() {
 	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, submissionLifecycleService, authService)
 	
 	// Initialize enhanced webhook handler
-	enhancedWebhookHandler := handlers.NewEnhancedWebhookHandler(enhancedWebhookService, integrationManager, authService)
+	enhancedWebhookHandler := handlers.NewEnhancedWebhookHandler(enhancedWebhookService, integrationManager, authService, formService)
 
 	// Setup Gin router
 	if cfg.Environment == "production" {
@@ -115,22 +189,25 @@ func main() {
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
-	// CORS middleware
-	router.Use(cors.New(cors.Config{
+	// CORS middleware for the dashboard; the public submit endpoints answer CORS per form
+	router.Use(middleware.SkipPaths(cors.New(cors.Config{
 		AllowOrigins:     cfg.AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
-	}))
+	}), "/api/v1/submit"))
+	formCORS := middleware.FormCORS(formService)
 
 	// Initialize spam detection middleware
 	spamMiddleware := middleware.NewSpamDetectionMiddleware(spamService, securityService, behavioralAnalyzer, mlClassifier)
 	
 	// Security and spam protection middleware
 	router.Use(middleware.RateLimit(redis))
-	router.Use(spamMiddleware.SpamProtection())
+	// Full spam analysis runs per form inside the submission path, where the form's config is known;
+	// IPs flagged for repeated blocks are still turned away before it
+	router.Use(spamMiddleware.IPFlagCheck())
 	router.Use(spamMiddleware.BehavioralAnalysis())
 	router.Use(spamMiddleware.IPReputationCheck())
 	
@@ -142,7 +219,24 @@ func main() {
 	api := router.Group("/api/v1")
 	{
 		// Public endpoints
-		api.POST("/submit", submissionHandler.HandleSubmission)
+		api.OPTIONS("/submit", formCORS)
+		api.OPTIONS("/submit/:formId", formCORS)
+		api.POST("/submit", formCORS, submissionHandler.HandleSubmission)
+		api.POST("/submit/:formId", formCORS, submissionHandler.HandleSubmission)
+
+		// Saved progress of multi-step forms, keyed by X-Progress-Token
+		api.OPTIONS("/submit/:formId/progress", formCORS)
+		api.OPTIONS("/submit/:formId/progress/:partialId", formCORS)
+		api.GET("/submit/:formId/progress", formCORS, partialSubmissionHandler.GetProgress)
+		api.POST("/submit/:formId/progress", formCORS, partialSubmissionHandler.SaveProgress)
+		api.GET("/submit/:formId/progress/:partialId", formCORS, partialSubmissionHandler.ResumeProgress)
+		api.POST("/submit/:formId/progress/resume-link", formCORS, partialSubmissionHandler.SendResumeLink)
+		api.POST("/submit/:formId/progress/complete", formCORS, submissionHandler.CompleteSubmission)
+		api.GET("/files/:id/download", fileHandler.DownloadSignedFile)
+
+		// Webhook event catalogue, public so receivers can validate payloads
+		api.GET("/webhooks/events", enhancedWebhookHandler.ListWebhookEvents)
+		api.GET("/webhooks/events/:type/schema", enhancedWebhookHandler.GetWebhookEventSchema)
 		
 		// Authentication
 		auth := api.Group("/auth")
@@ -150,32 +244,55 @@ func main() {
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/mfa/verify", mfaHandler.VerifyLogin)
+			auth.POST("/verify-email", accountHandler.VerifyEmail)
+			auth.POST("/forgot-password", accountHandler.ForgotPassword)
+			auth.POST("/reset-password", accountHandler.ResetPassword)
+			auth.POST("/sso/discover", ssoHandler.Discover)
+			auth.POST("/sso/exchange", ssoHandler.Exchange)
+			auth.GET("/sso/:connectionId/login", ssoHandler.StartLogin)
+			auth.GET("/sso/:connectionId/oidc/callback", ssoHandler.OIDCCallback)
+			auth.GET("/sso/:connectionId/saml/metadata", ssoHandler.SAMLMetadata)
+			auth.POST("/sso/:connectionId/saml/acs", ssoHandler.SAMLACS)
+			auth.POST("/logout", middleware.AuthRequired(authService), authHandler.Logout)
+			auth.GET("/sessions", middleware.AuthRequired(authService), authHandler.GetSessions)
+			auth.DELETE("/sessions/:id", middleware.AuthRequired(authService), authHandler.RevokeSession)
+
+			// Two-factor enrollment sits outside the workspace check so members of workspaces
+			// that require MFA can still turn it on
+			auth.POST("/mfa/setup", middleware.AuthRequired(authService), mfaHandler.BeginEnrollment)
+			auth.POST("/mfa/enable", middleware.AuthRequired(authService), mfaHandler.ConfirmEnrollment)
+			auth.POST("/mfa/disable", middleware.AuthRequired(authService), mfaHandler.Disable)
+			auth.POST("/mfa/recovery-codes", middleware.AuthRequired(authService), mfaHandler.RegenerateRecoveryCodes)
 		}
 
-		// Protected endpoints
-		protected := api.Group("/")
-		protected.Use(middleware.AuthRequired(authService))
+		// Management API: reachable with a user session or an API key. Every route here must be
+		// guarded by RequireScope, which is what keeps API keys out of unscoped routes, and routes
+		// addressing a form or submission by RequireKeyForm or RequireKeySubmission, which keep
+		// form-limited keys to their forms.
+		management := api.Group("/")
+		management.Use(middleware.AuthOrAPIKey(authService))
+		management.Use(middleware.WorkspaceContext(workspaceService))
 		{
-			// User management
-			protected.GET("/profile", authHandler.GetProfile)
-			protected.PUT("/profile", authHandler.UpdateProfile)
-
 			// Forms
-			protected.GET("/forms", formHandler.GetForms)
-			protected.POST("/forms", formHandler.CreateForm)
-			protected.GET("/forms/:id", formHandler.GetForm)
-			protected.PUT("/forms/:id", formHandler.UpdateForm)
-			protected.DELETE("/forms/:id", formHandler.DeleteForm)
-			
+			management.GET("/forms", middleware.RequireScope(models.ScopeFormsRead), formHandler.GetForms)
+			management.GET("/forms/:id", middleware.RequireScope(models.ScopeFormsRead), middleware.RequireKeyForm("id"), formHandler.GetForm)
+			management.GET("/forms/:id/fields", middleware.RequireScope(models.ScopeFormsRead), middleware.RequireKeyForm("id"), formHandler.GetFormWithFields)
+
 			// Enhanced Webhook System
-			webhooks := protected.Group("/forms/:formId/webhooks")
+			webhooks := management.Group("/forms/:formId/webhooks")
+			webhooks.Use(middleware.RequireScope(models.ScopeWebhooksManage))
+			webhooks.Use(middleware.RequireKeyForm("formId"))
+			webhooks.Use(middleware.RequirePlanFeature(usageService, services.CapabilityWebhooks))
 			{
 				// Webhook Endpoint Management
 				webhooks.POST("/endpoints", enhancedWebhookHandler.CreateWebhookEndpoint)
 				webhooks.GET("/endpoints", enhancedWebhookHandler.GetWebhookEndpoints)
+				webhooks.GET("/endpoints/:endpointId", enhancedWebhookHandler.GetWebhookEndpoint)
 				webhooks.PUT("/endpoints/:endpointId", enhancedWebhookHandler.UpdateWebhookEndpoint)
 				webhooks.DELETE("/endpoints/:endpointId", enhancedWebhookHandler.DeleteWebhookEndpoint)
 				webhooks.POST("/endpoints/:endpointId/test", enhancedWebhookHandler.TestWebhookEndpoint)
+				webhooks.POST("/endpoints/:endpointId/rotate-secret", enhancedWebhookHandler.RotateWebhookSecret)
 				
 				// Webhook Analytics
 				webhooks.GET("/analytics", enhancedWebhookHandler.GetWebhookAnalytics)
@@ -184,20 +301,94 @@ func main() {
 				// Webhook Monitoring
 				webhooks.GET("/monitoring", enhancedWebhookHandler.GetWebhookMonitoring)
 				webhooks.GET("/monitoring/ws", enhancedWebhookHandler.WebSocketMonitoring)
+
+				// Delivery Logs
+				webhooks.GET("/logs", enhancedWebhookHandler.ListWebhookLogs)
+				webhooks.GET("/logs/compare", enhancedWebhookHandler.CompareWebhookLogs)
+				webhooks.GET("/logs/:logId", enhancedWebhookHandler.GetWebhookLog)
+				webhooks.POST("/logs/:logId/resend", enhancedWebhookHandler.ResendWebhookAttempt)
+
+				// Dead-Lettered Deliveries
+				webhooks.GET("/dead-letters", enhancedWebhookHandler.ListDeadLetters)
+				webhooks.POST("/dead-letters/replay", enhancedWebhookHandler.ReplayDeadLetters)
+				webhooks.DELETE("/dead-letters", enhancedWebhookHandler.PurgeDeadLetters)
+				webhooks.GET("/dead-letters/:deliveryId", enhancedWebhookHandler.GetDeadLetter)
+				webhooks.POST("/dead-letters/:deliveryId/replay", enhancedWebhookHandler.ReplayDeadLetter)
+				webhooks.DELETE("/dead-letters/:deliveryId", enhancedWebhookHandler.PurgeDeadLetter)
+			}
+
+			// Account-level webhook endpoints receive events from many forms of the workspace
+			accountWebhooks := management.Group("/webhooks/endpoints")
+			accountWebhooks.Use(middleware.RequireScope(models.ScopeWebhooksManage))
+			accountWebhooks.Use(middleware.RequirePlanFeature(usageService, services.CapabilityWebhooks))
+			{
+				webhookManage := middleware.RequireWorkspaceRole(models.WorkspaceRoleMember)
+				accountWebhooks.GET("", enhancedWebhookHandler.ListAccountWebhookEndpoints)
+				accountWebhooks.POST("", webhookManage, enhancedWebhookHandler.CreateAccountWebhookEndpoint)
+				accountWebhooks.GET("/:endpointId", enhancedWebhookHandler.GetAccountWebhookEndpoint)
+				accountWebhooks.PUT("/:endpointId", webhookManage, enhancedWebhookHandler.UpdateAccountWebhookEndpoint)
+				accountWebhooks.DELETE("/:endpointId", webhookManage, enhancedWebhookHandler.DeleteAccountWebhookEndpoint)
+				accountWebhooks.POST("/:endpointId/rotate-secret", webhookManage, enhancedWebhookHandler.RotateAccountWebhookSecret)
 			}
 
 			// Submissions
-			protected.GET("/forms/:id/submissions", submissionHandler.GetSubmissions)
-			protected.GET("/submissions/:id", submissionHandler.GetSubmission)
-			protected.DELETE("/submissions/:id", submissionHandler.DeleteSubmission)
+			management.GET("/forms/:id/submissions", middleware.RequireScope(models.ScopeSubmissionsRead), middleware.RequireKeyForm("id"), submissionHandler.GetSubmissions)
+			management.GET("/forms/:id/submissions/export", middleware.RequireScope(models.ScopeSubmissionsRead), middleware.RequireKeyForm("id"), submissionHandler.ExportSubmissions)
+			management.GET("/submissions/:id", middleware.RequireScope(models.ScopeSubmissionsRead), middleware.RequireKeySubmission(submissionService, "id"), submissionHandler.GetSubmission)
+			management.DELETE("/submissions/:id", middleware.RequireScope(models.ScopeSubmissionsWrite), middleware.RequireKeySubmission(submissionService, "id"), submissionHandler.DeleteSubmission)
+		}
+
+		// Protected endpoints
+		protected := api.Group("/")
+		protected.Use(middleware.AuthRequired(authService))
+		protected.Use(middleware.WorkspaceContext(workspaceService))
+		{
+			// User management
+			protected.GET("/profile", authHandler.GetProfile)
+			protected.PUT("/profile", accountHandler.UpdateProfile)
+			protected.POST("/profile/password", accountHandler.ChangePassword)
+			protected.POST("/profile/verify-email", accountHandler.ResendVerification)
+			protected.GET("/usage", usageHandler.GetUsage)
+
+			// Workspaces
+			protected.GET("/workspaces", workspaceHandler.ListWorkspaces)
+			protected.POST("/workspaces", workspaceHandler.CreateWorkspace)
+			protected.PUT("/workspaces/:id", workspaceHandler.UpdateWorkspace)
+			protected.POST("/workspaces/:id/switch", workspaceHandler.SwitchWorkspace)
+			protected.GET("/workspaces/:id/members", workspaceHandler.ListMembers)
+			protected.PUT("/workspaces/:id/members/:userId", workspaceHandler.UpdateMemberRole)
+			protected.DELETE("/workspaces/:id/members/:userId", workspaceHandler.RemoveMember)
+			protected.GET("/workspaces/:id/invitations", workspaceHandler.ListInvitations)
+			protected.POST("/workspaces/:id/invitations", middleware.RequireVerifiedEmail(accountService), workspaceHandler.InviteMember)
+			protected.DELETE("/workspaces/:id/invitations/:invitationId", workspaceHandler.RevokeInvitation)
+			protected.POST("/invitations/accept", workspaceHandler.AcceptInvitation)
+
+			// Forms
+			protected.POST("/forms", middleware.RequireVerifiedEmail(accountService), formHandler.CreateForm)
+			protected.PUT("/forms/:id", formHandler.UpdateForm)
+			protected.DELETE("/forms/:id", formHandler.DeleteForm)
+
+			// Form fields and their conditional rules
+			protected.GET("/form-field-types", formHandler.GetFormFieldTypes)
+			protected.POST("/forms/:id/fields", formHandler.AddFormField)
+			protected.PUT("/forms/:id/fields/:field_id", formHandler.UpdateFormField)
+			protected.DELETE("/forms/:id/fields/:field_id", formHandler.DeleteFormField)
+			protected.POST("/forms/:id/fields/reorder", formHandler.ReorderFormFields)
+			protected.POST("/forms/:id/validate", formHandler.ValidateFormFields)
+
+			// Submission attachments
+			protected.GET("/files/:id", fileHandler.DownloadFile)
+			protected.POST("/files/:id/signed-url", fileHandler.GetSignedURL)
 
 			// API Keys
 			protected.GET("/api-keys", authHandler.GetAPIKeys)
-			protected.POST("/api-keys", authHandler.CreateAPIKey)
-			protected.DELETE("/api-keys/:id", authHandler.DeleteAPIKey)
+			protected.POST("/api-keys", middleware.RequireVerifiedEmail(accountService), middleware.RequireWorkspaceRole(models.WorkspaceRoleAdmin), authHandler.CreateAPIKey)
+			protected.DELETE("/api-keys/:id", middleware.RequireWorkspaceRole(models.WorkspaceRoleAdmin), authHandler.DeleteAPIKey)
+			protected.POST("/api-keys/:id/rotate", middleware.RequireWorkspaceRole(models.WorkspaceRoleAdmin), authHandler.RotateAPIKey)
 			
 			// Third-party Integrations
 			integrations := protected.Group("/integrations")
+			integrations.Use(middleware.RequirePlanFeature(usageService, services.CapabilityWebhooks))
 			{
 				integrations.GET("", enhancedWebhookHandler.ListIntegrations)
 				integrations.GET("/:integration/schema", enhancedWebhookHandler.GetIntegrationSchema)
@@ -216,6 +407,7 @@ func main() {
 
 			// Analytics endpoints
 			analytics := protected.Group("/analytics")
+			analytics.Use(middleware.RequirePlanFeature(usageService, services.CapabilityAnalytics))
 			{
 				// Form Analytics
 				analytics.GET("/forms/:id/dashboard", analyticsHandler.GetFormAnalyticsDashboard)
@@ -232,6 +424,7 @@ func main() {
 				analytics.GET("/submissions/:id/lifecycle", analyticsHandler.GetSubmissionLifecycle)
 				analytics.PUT("/submissions/:id/lifecycle", analyticsHandler.UpdateSubmissionLifecycle)
 				analytics.GET("/lifecycle/stats", analyticsHandler.GetLifecycleStats)
+				analytics.GET("/forms/:id/rejections", analyticsHandler.GetSubmissionRejections)
 				analytics.GET("/pending-actions", analyticsHandler.GetPendingActions)
 			}
 			
@@ -307,39 +500,53 @@ func main() {
 			
 			// Spam Protection Administration (requires admin role)
 			admin := protected.Group("/admin")
-			// In production, add admin role check middleware here
+			admin.Use(middleware.RequireRole(models.RoleOwner, models.RoleAdmin))
 			{
+				spamRead := middleware.RequirePermission(models.PermissionSpamRead)
+				spamManage := middleware.RequirePermission(models.PermissionSpamManage)
+				
 				// Spam protection configuration
-				admin.GET("/spam/config", spamAdminHandler.GetSpamProtectionConfig)
-				admin.PUT("/spam/config", spamAdminHandler.UpdateSpamProtectionConfig)
-				admin.GET("/spam/forms/:formId/config", spamAdminHandler.GetFormSpamConfig)
-				admin.PUT("/spam/forms/:formId/config", spamAdminHandler.UpdateFormSpamConfig)
+				admin.GET("/spam/config", spamRead, spamAdminHandler.GetSpamProtectionConfig)
+				admin.PUT("/spam/config", middleware.RequirePermission(models.PermissionSpamConfigure), spamAdminHandler.UpdateSpamProtectionConfig)
+				admin.GET("/spam/forms/:formId/config", spamRead, spamAdminHandler.GetFormSpamConfig)
+				admin.PUT("/spam/forms/:formId/config", spamManage, spamAdminHandler.UpdateFormSpamConfig)
 				
 				// Statistics and monitoring
-				admin.GET("/spam/statistics", spamAdminHandler.GetSpamStatistics)
-				admin.GET("/spam/quarantined", spamAdminHandler.GetQuarantinedSubmissions)
-				admin.PUT("/spam/quarantined/:submissionId", spamAdminHandler.ReviewQuarantinedSubmission)
+				admin.GET("/spam/statistics", spamRead, spamAdminHandler.GetSpamStatistics)
+				admin.GET("/spam/quarantined", spamRead, spamAdminHandler.GetQuarantinedSubmissions)
+				admin.PUT("/spam/quarantined/:submissionId", spamManage, spamAdminHandler.ReviewQuarantinedSubmission)
 				
 				// Machine learning management
-				admin.GET("/spam/ml/stats", spamAdminHandler.GetMLModelStats)
-				admin.POST("/spam/ml/train", spamAdminHandler.TrainMLModel)
+				admin.GET("/spam/ml/stats", spamRead, spamAdminHandler.GetMLModelStats)
+				admin.POST("/spam/ml/train", middleware.RequirePermission(models.PermissionSpamTrain), spamAdminHandler.TrainMLModel)
 				
 				// Behavioral analysis
-				admin.GET("/spam/behavioral/stats", spamAdminHandler.GetBehavioralAnalysisStats)
+				admin.GET("/spam/behavioral/stats", spamRead, spamAdminHandler.GetBehavioralAnalysisStats)
 				
 				// CAPTCHA management
-				admin.GET("/spam/captcha/stats", spamAdminHandler.GetCaptchaStats)
+				admin.GET("/spam/captcha/stats", spamRead, spamAdminHandler.GetCaptchaStats)
 				
 				// Webhook management
-				admin.GET("/spam/webhooks", spamAdminHandler.GetWebhookStatus)
-				admin.POST("/spam/webhooks/test", spamAdminHandler.TestWebhook)
+				admin.GET("/spam/webhooks", spamRead, spamAdminHandler.GetWebhookStatus)
+				admin.POST("/spam/webhooks/test", spamManage, spamAdminHandler.TestWebhook)
 				
 				// IP reputation management
-				admin.GET("/spam/ip-reputation", spamAdminHandler.GetIPReputation)
-				admin.PUT("/spam/ip-reputation", spamAdminHandler.UpdateIPReputation)
+				admin.GET("/spam/ip-reputation", spamRead, spamAdminHandler.GetIPReputation)
+				admin.PUT("/spam/ip-reputation", spamManage, spamAdminHandler.UpdateIPReputation)
 				
 				// Data export
-				admin.GET("/spam/export", spamAdminHandler.ExportSpamData)
+				admin.GET("/spam/export", middleware.RequirePermission(models.PermissionSpamExport), spamAdminHandler.ExportSpamData)
+				
+				// User roles
+				admin.PUT("/users/:id/role", middleware.RequirePermission(models.PermissionUsersManage), authHandler.UpdateUserRole)
+
+				// Single sign-on connections
+				ssoManage := middleware.RequirePermission(models.PermissionSSOManage)
+				admin.GET("/sso/connections", ssoManage, ssoHandler.ListConnections)
+				admin.POST("/sso/connections", ssoManage, ssoHandler.CreateConnection)
+				admin.GET("/sso/connections/:connectionId", ssoManage, ssoHandler.GetConnection)
+				admin.PUT("/sso/connections/:connectionId", ssoManage, ssoHandler.UpdateConnection)
+				admin.DELETE("/sso/connections/:connectionId", ssoManage, ssoHandler.DeleteConnection)
 			}
 		}
 	}
@@ -372,6 +579,14 @@ func main() {
 		}
 	}()
 	
+	// Start submission outbox dispatcher
+	go func() {
+		log.Println("Starting submission outbox processor...")
+		if err := outboxService.StartProcessor(); err != nil {
+			log.Printf("Failed to start submission outbox processor: %v", err)
+		}
+	}()
+	
 	// Start analytics and monitoring services
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -444,10 +659,29 @@ func main() {
 					log.Printf("Failed to cleanup old emails: %v", err)
 				}
 				
+				// Drop expired and revoked login sessions
+				if err := authService.CleanupSessions(); err != nil {
+					log.Printf("Failed to clean up sessions: %v", err)
+				}
+				if err := accountService.CleanupTokens(); err != nil {
+					log.Printf("Failed to clean up account tokens: %v", err)
+				}
+				
+				// Reconcile plan usage counters with the database
+				if err := usageService.Reconcile(); err != nil {
+					log.Printf("Failed to reconcile plan usage: %v", err)
+				}
+				
 				// Archive old submissions
 				if err := submissionLifecycleService.ArchiveOldSubmissions(ctx, 365); err != nil {
 					log.Printf("Failed to archive old submissions: %v", err)
 				}
+				if err := submissionLifecycleService.PruneSubmissionRejections(ctx, 30); err != nil {
+					log.Printf("Failed to prune submission rejections: %v", err)
+				}
+				if err := partialSubmissionService.PruneExpiredProgress(ctx); err != nil {
+					log.Printf("Failed to prune saved progress: %v", err)
+				}
 			}
 		}
 	}()
@@ -474,6 +708,12 @@ func main() {
 
 	log.Println("Shutting down server...")
 
+	// Stop submission outbox processor
+	log.Println("Stopping submission outbox processor...")
+	if err := outboxService.StopProcessor(); err != nil {
+		log.Printf("Error stopping submission outbox processor: %v", err)
+	}
+
 	// Stop email queue processor
 	log.Println("Stopping email queue processor...")
 	if err := emailQueueService.StopProcessor(); err != nil {
@@ -0,0 +1,20 @@
+-- File Download Audit Migration
+-- Every download of an uploaded file is logged with who fetched it and how
+
+CREATE TABLE file_download_logs (
+    id CHAR(36) PRIMARY KEY,
+    file_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NULL, -- NULL for signed URL downloads
+    access_type VARCHAR(20) NOT NULL, -- authenticated, signed_url
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (file_id) REFERENCES file_uploads(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT chk_file_download_logs_access_type CHECK (
+        access_type IN ('authenticated', 'signed_url')
+    ),
+    INDEX idx_file_download_logs_file_id (file_id),
+    INDEX idx_file_download_logs_created_at (created_at)
+);
@@ -0,0 +1,32 @@
+-- Submission Outbox Migration
+-- Notifications for a submission are recorded in the same transaction as the submission
+-- and handed to the email queue and webhook service by a background dispatcher
+
+CREATE TABLE submission_outbox (
+    id CHAR(36) PRIMARY KEY,
+    submission_id CHAR(36) NOT NULL,
+    form_id CHAR(36) NOT NULL,
+    kind ENUM('email', 'webhook') NOT NULL,
+    idempotency_key VARCHAR(128) NOT NULL,
+    status ENUM('pending', 'processing', 'dispatched', 'failed') DEFAULT 'pending',
+    attempts INT DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP NULL,
+    dispatched_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (submission_id) REFERENCES submissions(id) ON DELETE CASCADE,
+    FOREIGN KEY (form_id) REFERENCES forms(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_outbox_idempotency_key (idempotency_key),
+    INDEX idx_outbox_status_next_attempt (status, next_attempt_at),
+    INDEX idx_outbox_submission_id (submission_id)
+);
+
+-- Submission notifications are rendered by FormHub rather than from a user template,
+-- and carry the outbox idempotency key so a redelivered row is queued only once
+ALTER TABLE email_queue
+MODIFY COLUMN template_id CHAR(36) NULL,
+ADD COLUMN idempotency_key VARCHAR(128) NULL AFTER priority,
+ADD UNIQUE KEY unique_email_queue_idempotency_key (idempotency_key);
@@ -0,0 +1,10 @@
+-- Submission Spam Action Migration
+-- Stores the action chosen by the per-form spam protection pipeline for each submission
+
+ALTER TABLE submissions
+ADD COLUMN spam_action ENUM('allow', 'quarantine', 'block') NOT NULL DEFAULT 'allow' AFTER spam_score;
+
+-- Existing spam was hidden from notifications, which matches quarantine
+UPDATE submissions SET spam_action = 'quarantine' WHERE is_spam = TRUE;
+
+CREATE INDEX idx_submissions_spam_action ON submissions(form_id, spam_action);
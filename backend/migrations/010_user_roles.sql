@@ -0,0 +1,11 @@
+-- User Roles Migration
+-- Adds role-based access control; /admin routes require the owner or admin role
+
+ALTER TABLE users
+ADD COLUMN role ENUM('owner', 'admin', 'member', 'viewer') NOT NULL DEFAULT 'member' AFTER plan_type;
+
+CREATE INDEX idx_users_role ON users(role);
+
+-- Promote the earliest account to owner so the installation has someone able to assign roles
+UPDATE users SET role = 'owner'
+WHERE id = (SELECT id FROM (SELECT id FROM users ORDER BY created_at ASC LIMIT 1) AS first_user);
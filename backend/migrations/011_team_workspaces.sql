@@ -0,0 +1,84 @@
+-- Team Workspaces Migration
+-- Forms, email templates, email providers and API keys belong to a workspace instead of a single
+-- user; members are invited by email and access is checked against their workspace role
+
+CREATE TABLE workspaces (
+    id CHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    owner_id CHAR(36) NOT NULL, -- Billing owner; the workspace uses this user's plan
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_workspaces_owner_id (owner_id)
+);
+
+CREATE TABLE workspace_members (
+    workspace_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    role ENUM('owner', 'admin', 'member', 'viewer') NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (workspace_id, user_id),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_workspace_members_user_id (user_id)
+);
+
+CREATE TABLE workspace_invitations (
+    id CHAR(36) PRIMARY KEY,
+    workspace_id CHAR(36) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role ENUM('owner', 'admin', 'member', 'viewer') NOT NULL DEFAULT 'member',
+    token_hash CHAR(64) NOT NULL, -- SHA-256 of the emailed token; the token itself is never stored
+    invited_by CHAR(36) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_workspace_invitation_token (token_hash),
+    INDEX idx_workspace_invitations_workspace_id (workspace_id)
+);
+
+ALTER TABLE users
+ADD COLUMN current_workspace_id CHAR(36) NULL AFTER role;
+
+ALTER TABLE forms ADD COLUMN workspace_id CHAR(36) NULL AFTER user_id;
+ALTER TABLE email_templates ADD COLUMN workspace_id CHAR(36) NULL AFTER user_id;
+ALTER TABLE email_providers ADD COLUMN workspace_id CHAR(36) NULL AFTER user_id;
+ALTER TABLE api_keys ADD COLUMN workspace_id CHAR(36) NULL AFTER user_id;
+
+-- Give every existing user a personal workspace holding everything they already own
+INSERT INTO workspaces (id, name, owner_id, created_at, updated_at)
+SELECT UUID(), CONCAT(first_name, '''s Workspace'), id, created_at, created_at FROM users;
+
+INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+SELECT id, owner_id, 'owner', created_at FROM workspaces;
+
+UPDATE users u JOIN workspaces w ON w.owner_id = u.id SET u.current_workspace_id = w.id;
+UPDATE forms f JOIN workspaces w ON w.owner_id = f.user_id SET f.workspace_id = w.id;
+UPDATE email_templates t JOIN workspaces w ON w.owner_id = t.user_id SET t.workspace_id = w.id;
+UPDATE email_providers p JOIN workspaces w ON w.owner_id = p.user_id SET p.workspace_id = w.id;
+UPDATE api_keys k JOIN workspaces w ON w.owner_id = k.user_id SET k.workspace_id = w.id;
+
+ALTER TABLE forms
+MODIFY COLUMN workspace_id CHAR(36) NOT NULL,
+ADD FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+ADD INDEX idx_forms_workspace_id (workspace_id);
+
+ALTER TABLE email_templates
+MODIFY COLUMN workspace_id CHAR(36) NOT NULL,
+ADD FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+ADD INDEX idx_email_templates_workspace_id (workspace_id);
+
+ALTER TABLE email_providers
+MODIFY COLUMN workspace_id CHAR(36) NOT NULL,
+ADD FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+ADD INDEX idx_email_providers_workspace_id (workspace_id);
+
+ALTER TABLE api_keys
+MODIFY COLUMN workspace_id CHAR(36) NOT NULL,
+ADD FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+ADD INDEX idx_api_keys_workspace_id (workspace_id);
@@ -0,0 +1,29 @@
+-- User Sessions Migration
+-- Refresh tokens are stored server-side (hashed) and rotated on every use; presenting a rotated
+-- token again revokes the whole session
+
+CREATE TABLE user_sessions (
+    id CHAR(36) PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    user_agent VARCHAR(512),
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_sessions_user_id (user_id),
+    INDEX idx_user_sessions_expires_at (expires_at)
+);
+
+CREATE TABLE refresh_tokens (
+    token_hash CHAR(64) PRIMARY KEY, -- SHA-256 of the token; the token itself is never stored
+    session_id CHAR(36) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL, -- Set when rotated; a second use is treated as theft
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (session_id) REFERENCES user_sessions(id) ON DELETE CASCADE,
+    INDEX idx_refresh_tokens_session_id (session_id)
+);
@@ -0,0 +1,27 @@
+-- Form Submission Routing Migration
+-- Public submissions name their form by public ID, API keys can be limited to specific forms,
+-- and the "newest form, else auto-create one" lookup becomes a per-key opt-in
+
+ALTER TABLE forms
+ADD COLUMN public_id VARCHAR(32) NULL AFTER id;
+
+UPDATE forms SET public_id = LEFT(SHA2(CONCAT(id, RAND(), NOW(6)), 256), 16);
+
+ALTER TABLE forms
+MODIFY COLUMN public_id VARCHAR(32) NOT NULL,
+ADD UNIQUE KEY unique_forms_public_id (public_id);
+
+-- Existing keys keep the old lookup so live integrations continue to work; new keys default to off
+ALTER TABLE api_keys
+ADD COLUMN default_form_fallback BOOLEAN NOT NULL DEFAULT FALSE AFTER rate_limit;
+
+UPDATE api_keys SET default_form_fallback = TRUE;
+
+CREATE TABLE api_key_forms (
+    api_key_id CHAR(36) NOT NULL,
+    form_id CHAR(36) NOT NULL,
+
+    PRIMARY KEY (api_key_id, form_id),
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE,
+    FOREIGN KEY (form_id) REFERENCES forms(id) ON DELETE CASCADE
+);
@@ -0,0 +1,20 @@
+-- API Key Scopes Migration
+-- Keys carry structured scopes so they can call the management API, may be limited to IPs,
+-- origins and a lifetime, and can be rotated with a grace period. Stored hashes move from MD5
+-- to HMAC-SHA256; existing MD5 hashes are rehashed the first time each key is used.
+
+ALTER TABLE api_keys
+ADD COLUMN scopes JSON NULL AFTER key_hash,
+ADD COLUMN allowed_ips JSON NULL AFTER default_form_fallback,
+ADD COLUMN allowed_origins JSON NULL AFTER allowed_ips,
+ADD COLUMN expires_at TIMESTAMP NULL AFTER allowed_origins,
+ADD COLUMN previous_key_hash VARCHAR(255) NULL AFTER key_hash, -- Hash replaced by the last rotation
+ADD COLUMN previous_key_expires_at TIMESTAMP NULL AFTER previous_key_hash,
+ADD INDEX idx_api_keys_previous_key_hash (previous_key_hash);
+
+-- Every existing key was a "form_submit" key
+UPDATE api_keys SET scopes = JSON_ARRAY('submit');
+
+ALTER TABLE api_keys
+MODIFY COLUMN scopes JSON NOT NULL,
+DROP COLUMN permissions;
@@ -0,0 +1,23 @@
+-- Account Tokens Migration
+-- New accounts confirm their email before creating forms, API keys or invitations, and users can
+-- reset a forgotten password. Both flows email a single-use token; only its hash is stored.
+
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP NULL AFTER role;
+
+-- Accounts created before verification existed are treated as verified
+UPDATE users SET email_verified_at = created_at;
+
+CREATE TABLE user_tokens (
+    token_hash CHAR(64) PRIMARY KEY, -- SHA-256 of the emailed token; the token itself is never stored
+    user_id CHAR(36) NOT NULL,
+    purpose ENUM('email_verification', 'password_reset') NOT NULL,
+    email VARCHAR(255) NOT NULL, -- Address the token was sent to; it stops working if the user's email changes
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_tokens_user_purpose (user_id, purpose),
+    INDEX idx_user_tokens_expires_at (expires_at)
+);
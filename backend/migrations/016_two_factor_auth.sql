@@ -0,0 +1,22 @@
+-- Two-Factor Authentication Migration
+-- Optional TOTP (RFC 6238) second factor for dashboard logins with hashed one-time recovery codes,
+-- and a per-workspace switch that refuses members who have not enabled it
+
+ALTER TABLE users
+ADD COLUMN mfa_secret VARCHAR(64) NULL AFTER email_verified_at, -- Base32 TOTP secret; set at enrollment, confirmed by mfa_enabled_at
+ADD COLUMN mfa_enabled_at TIMESTAMP NULL AFTER mfa_secret,
+ADD COLUMN mfa_last_step BIGINT NULL AFTER mfa_enabled_at; -- Last accepted TOTP time step; earlier codes are replays
+
+CREATE TABLE user_recovery_codes (
+    id CHAR(36) PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    code_hash CHAR(64) NOT NULL, -- SHA-256 of the normalized code; the code itself is never stored
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_user_recovery_code (user_id, code_hash)
+);
+
+ALTER TABLE workspaces
+ADD COLUMN require_mfa BOOLEAN NOT NULL DEFAULT FALSE AFTER owner_id;
@@ -0,0 +1,46 @@
+-- Single Sign-On Migration
+-- Staff sign in through the company identity provider over OpenID Connect or SAML 2.0. Each
+-- connection claims one or more email domains, is tied to the workspace its users join, and can
+-- create accounts on first login (just-in-time provisioning).
+
+CREATE TABLE sso_connections (
+    id CHAR(36) PRIMARY KEY,
+    workspace_id CHAR(36) NOT NULL, -- Workspace that provisioned users join
+    name VARCHAR(255) NOT NULL,
+    protocol ENUM('oidc', 'saml') NOT NULL,
+    default_role ENUM('admin', 'member', 'viewer') NOT NULL DEFAULT 'member',
+    jit_provisioning BOOLEAN NOT NULL DEFAULT TRUE, -- Create accounts for unknown users on first login
+    enforce_sso BOOLEAN NOT NULL DEFAULT FALSE, -- Refuse password logins for the connection's domains
+    oidc_issuer VARCHAR(500) NULL,
+    oidc_client_id VARCHAR(255) NULL,
+    oidc_client_secret VARCHAR(500) NULL,
+    saml_idp_metadata MEDIUMTEXT NULL, -- IdP metadata XML; fetched once when given as a URL
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE
+);
+
+-- A domain routes to exactly one connection
+CREATE TABLE sso_connection_domains (
+    domain VARCHAR(255) PRIMARY KEY, -- Lower-case email domain, e.g. example.com
+    connection_id CHAR(36) NOT NULL,
+
+    FOREIGN KEY (connection_id) REFERENCES sso_connections(id) ON DELETE CASCADE,
+    INDEX idx_sso_connection_domains_connection (connection_id)
+);
+
+-- Links an IdP subject to a FormHub user, so a later email change at the IdP keeps the same account
+CREATE TABLE sso_identities (
+    connection_id CHAR(36) NOT NULL,
+    subject VARCHAR(255) NOT NULL, -- OIDC "sub" claim or SAML NameID
+    user_id CHAR(36) NOT NULL,
+    last_login_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (connection_id, subject),
+    FOREIGN KEY (connection_id) REFERENCES sso_connections(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_sso_identities_user (user_id)
+);
@@ -0,0 +1,16 @@
+-- Submission Rejections Migration
+-- Public submissions refused before anything is saved, such as posts from an origin outside the
+-- form's allow-list. They have no submission row, so they are kept apart from submission_lifecycle
+-- and shown to form owners for debugging embeds.
+
+CREATE TABLE submission_rejections (
+    id CHAR(36) PRIMARY KEY,
+    form_id CHAR(36) NOT NULL,
+    reason VARCHAR(50) NOT NULL, -- e.g. origin_not_allowed
+    origin VARCHAR(255) NULL, -- Origin or Referer origin the request came from; NULL when it sent neither
+    ip_address VARCHAR(45) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (form_id) REFERENCES forms(id) ON DELETE CASCADE,
+    INDEX idx_submission_rejections_form_created (form_id, created_at)
+);
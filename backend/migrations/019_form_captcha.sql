@@ -0,0 +1,9 @@
+-- Form Captcha Migration
+-- Forms can require a reCAPTCHA, hCaptcha or Cloudflare Turnstile token on every public submission.
+-- The provider's secret key is kept in recaptcha_secret. Existing forms are left without a provider,
+-- even when they stored a secret: their embeds may not send a token, and the secret's reCAPTCHA
+-- version is unknown. Owners opt in by choosing a provider.
+
+ALTER TABLE forms
+    ADD COLUMN captcha_provider VARCHAR(20) NULL AFTER recaptcha_secret, -- recaptcha_v2, recaptcha_v3, hcaptcha, turnstile; NULL disables
+    ADD COLUMN captcha_min_score DECIMAL(3,2) NOT NULL DEFAULT 0.50 AFTER captcha_provider; -- Lowest reCAPTCHA v3 score accepted
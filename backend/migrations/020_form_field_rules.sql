@@ -0,0 +1,6 @@
+-- Form Field Rules Migration
+-- Conditional logic between fields, e.g. show company_size when type equals business, or require
+-- phone when contact_method equals phone. Evaluated on submission; hidden fields are dropped.
+
+ALTER TABLE form_fields
+    ADD COLUMN rules JSON NULL AFTER file_settings; -- Array of {action, match, conditions}
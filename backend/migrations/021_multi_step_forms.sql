@@ -0,0 +1,38 @@
+-- Multi-Step Forms Migration
+-- Fields are grouped into numbered steps. Respondents save their progress step by step under their
+-- session, can have a signed resume link emailed to them, and finish with a final submit that
+-- turns the saved progress into a submission. Saved progress feeds per-step drop-off in the funnel.
+
+ALTER TABLE form_fields
+    ADD COLUMN step INT NOT NULL DEFAULT 1 AFTER field_order; -- 1-based page the field is shown on
+
+CREATE TABLE form_partial_submissions (
+    id CHAR(36) PRIMARY KEY,
+    form_id CHAR(36) NOT NULL,
+    session_id VARCHAR(255) NOT NULL, -- X-Session-ID of the respondent
+    data JSON NOT NULL, -- Values saved so far, across all steps
+    current_step INT NOT NULL DEFAULT 1, -- Furthest step saved
+    email VARCHAR(255) NULL, -- Address the last resume link was sent to
+    submission_id CHAR(36) NULL, -- Set by the final submit
+    completed_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (form_id) REFERENCES forms(id) ON DELETE CASCADE,
+    FOREIGN KEY (submission_id) REFERENCES submissions(id) ON DELETE SET NULL,
+    UNIQUE KEY unique_partial_session (form_id, session_id),
+    INDEX idx_form_partial_submissions_created (form_id, created_at),
+    INDEX idx_form_partial_submissions_expires (expires_at)
+);
+
+-- Recorded each time a respondent saves a step
+ALTER TABLE form_analytics_events MODIFY event_type ENUM(
+    'form_view', 'form_start', 'field_focus', 'field_blur', 'field_change',
+    'form_submit', 'form_complete', 'form_abandon', 'validation_error',
+    'file_upload_start', 'file_upload_complete', 'recaptcha_solve', 'form_step_complete'
+) NOT NULL;
+
+-- [{"step": 1, "reached": 40, "dropped_off": 12, "drop_off_rate": 30.0}, ...]
+ALTER TABLE form_conversion_funnels
+    ADD COLUMN step_drop_offs JSON NULL AFTER abandonment_points;
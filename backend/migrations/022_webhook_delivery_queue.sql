@@ -0,0 +1,29 @@
+-- Webhook Delivery Queue Migration
+-- Enhanced webhook deliveries are queued here, one row per event and endpoint, instead of in
+-- process memory. Workers on every API replica lease due rows, and deliveries that exhaust their
+-- endpoint's retries stay behind as dead letters until they are replayed or purged.
+
+CREATE TABLE webhook_deliveries (
+    id CHAR(36) PRIMARY KEY,
+    form_id CHAR(36) NOT NULL,
+    endpoint_id VARCHAR(36) NOT NULL, -- ID of the endpoint in the form's webhook_config
+    event_id VARCHAR(128) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    event JSON NOT NULL, -- The event as it is rendered for the endpoint on each attempt
+    status ENUM('pending', 'processing', 'delivered', 'dead') DEFAULT 'pending',
+    attempts INT DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    locked_by VARCHAR(100) NULL, -- Worker pool holding the lease
+    locked_until TIMESTAMP NULL,
+    last_status_code INT NULL,
+    last_error TEXT,
+    delivered_at TIMESTAMP NULL,
+    dead_lettered_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (form_id) REFERENCES forms(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_webhook_delivery_event_endpoint (event_id, endpoint_id),
+    INDEX idx_webhook_deliveries_status_next_attempt (status, next_attempt_at),
+    INDEX idx_webhook_deliveries_dead_letters (form_id, status, endpoint_id, dead_lettered_at)
+);
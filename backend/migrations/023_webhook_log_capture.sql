@@ -0,0 +1,18 @@
+-- Webhook Log Capture Migration
+-- Each webhook delivery attempt is logged with the request that was sent and the response that came
+-- back, so users can browse, compare and resend attempts. Logs are pruned after the retention period
+-- set by WEBHOOK_LOG_RETENTION_DAYS.
+
+ALTER TABLE webhook_logs
+    ADD COLUMN delivery_id CHAR(36) NULL AFTER form_id, -- Queued delivery the attempt belongs to; NULL for tests
+    ADD COLUMN event_id VARCHAR(128) NULL AFTER delivery_id,
+    ADD COLUMN request_method VARCHAR(10) NULL AFTER url,
+    ADD COLUMN request_headers JSON NULL AFTER request_method, -- Values of the endpoint's custom headers are redacted
+    CHANGE COLUMN request_payload request_body MEDIUMTEXT NULL, -- The body exactly as sent, in the endpoint's content type
+    ADD COLUMN response_headers JSON NULL AFTER response_body,
+    ADD COLUMN response_truncated BOOLEAN DEFAULT FALSE AFTER response_headers, -- Body cut at 10KB
+    ADD COLUMN error_class VARCHAR(50) NULL AFTER error_message, -- timeout, connection, tls, client_error, server_error, ...
+    ADD COLUMN resent_from VARCHAR(36) NULL AFTER error_class, -- Log of the attempt a manual resend repeated
+    ADD INDEX idx_webhook_logs_form_endpoint_created (form_id, endpoint_id, created_at),
+    ADD INDEX idx_webhook_logs_delivery_id (delivery_id),
+    ADD INDEX idx_webhook_logs_event_id (event_id);
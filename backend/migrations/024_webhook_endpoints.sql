@@ -0,0 +1,116 @@
+-- Webhook Endpoints Migration
+-- Enhanced webhook endpoints move out of the forms.webhook_config JSON blob into their own rows, so
+-- concurrent edits to different endpoints no longer overwrite each other and endpoints can be queried
+-- across forms. Each row carries a version that is bumped on every change; updates are conditional on
+-- the version the client last read. Endpoints belong either to one form or to a workspace, in which
+-- case they receive events from all of its forms or from the forms listed in webhook_endpoint_forms.
+
+CREATE TABLE webhook_endpoints (
+    id VARCHAR(36) PRIMARY KEY, -- Endpoint IDs from webhook_config are kept, as deliveries and logs refer to them
+    form_id CHAR(36) NULL, -- Set for form endpoints
+    workspace_id CHAR(36) NULL, -- Set for account-level endpoints
+    all_forms BOOLEAN DEFAULT FALSE, -- Account-level endpoint receiving events from every form in the workspace
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL DEFAULT '',
+    previous_secrets JSON NULL, -- Replaced secrets still signing until they expire
+    events JSON NULL,
+    headers JSON NULL,
+    content_type VARCHAR(100) NOT NULL DEFAULT 'application/json',
+    method VARCHAR(10) NOT NULL DEFAULT 'POST',
+    timeout INT DEFAULT 0,
+    max_retries INT DEFAULT 0,
+    retry_delay INT DEFAULT 0,
+    enabled BOOLEAN DEFAULT TRUE,
+    rate_limit_enabled BOOLEAN DEFAULT FALSE,
+    verify_ssl BOOLEAN DEFAULT TRUE,
+    custom_payload TEXT NULL,
+    transform_config JSON NULL,
+    conditional_rules JSON NULL,
+    priority INT DEFAULT 0,
+    tags JSON NULL,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (form_id) REFERENCES forms(id) ON DELETE CASCADE,
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    CONSTRAINT chk_webhook_endpoints_owner CHECK ((form_id IS NULL) <> (workspace_id IS NULL)),
+    INDEX idx_webhook_endpoints_form_id (form_id),
+    INDEX idx_webhook_endpoints_workspace_id (workspace_id, all_forms)
+);
+
+-- Forms an account-level endpoint subscribes to when it does not take all forms
+CREATE TABLE webhook_endpoint_forms (
+    endpoint_id VARCHAR(36) NOT NULL,
+    form_id CHAR(36) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (endpoint_id, form_id),
+    FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    FOREIGN KEY (form_id) REFERENCES forms(id) ON DELETE CASCADE,
+    INDEX idx_webhook_endpoint_forms_form_id (form_id)
+);
+
+-- Copy the endpoints of every form's webhook_config. Timestamps were stored in RFC 3339; Go's zero
+-- time and missing values fall back to now. Missing enabled and verify_ssl flags keep their old runtime
+-- default of true. The column itself is left for the legacy webhook service.
+INSERT INTO webhook_endpoints (
+    id, form_id, name, url, secret, previous_secrets, events, headers, content_type, method,
+    timeout, max_retries, retry_delay, enabled, rate_limit_enabled, verify_ssl, custom_payload,
+    transform_config, conditional_rules, priority, tags, version, created_at, updated_at
+)
+SELECT
+    COALESCE(NULLIF(e.id, ''), UUID()),
+    f.id,
+    COALESCE(e.name, ''),
+    e.url,
+    COALESCE(e.secret, ''),
+    e.previous_secrets,
+    e.events,
+    e.headers,
+    COALESCE(NULLIF(e.content_type, ''), 'application/json'),
+    COALESCE(NULLIF(e.method, ''), 'POST'),
+    COALESCE(e.timeout, 0),
+    COALESCE(e.max_retries, 0),
+    COALESCE(e.retry_delay, 0),
+    COALESCE(e.enabled, TRUE),
+    COALESCE(e.rate_limit_enabled, FALSE),
+    COALESCE(e.verify_ssl, TRUE),
+    NULLIF(e.custom_payload, ''),
+    e.transform_config,
+    e.conditional_rules,
+    COALESCE(e.priority, 0),
+    e.tags,
+    1,
+    CASE WHEN e.created_at IS NULL OR e.created_at LIKE '0001-%' THEN CURRENT_TIMESTAMP
+         ELSE STR_TO_DATE(LEFT(e.created_at, 19), '%Y-%m-%dT%H:%i:%s') END,
+    CASE WHEN e.updated_at IS NULL OR e.updated_at LIKE '0001-%' THEN CURRENT_TIMESTAMP
+         ELSE STR_TO_DATE(LEFT(e.updated_at, 19), '%Y-%m-%dT%H:%i:%s') END
+FROM forms f,
+    JSON_TABLE(f.webhook_config, '$.endpoints[*]' COLUMNS (
+        id VARCHAR(36) PATH '$.id',
+        name VARCHAR(255) PATH '$.name',
+        url TEXT PATH '$.url',
+        secret VARCHAR(255) PATH '$.secret',
+        previous_secrets JSON PATH '$.previous_secrets',
+        events JSON PATH '$.events',
+        headers JSON PATH '$.headers',
+        content_type VARCHAR(100) PATH '$.content_type',
+        method VARCHAR(10) PATH '$.method',
+        timeout INT PATH '$.timeout',
+        max_retries INT PATH '$.max_retries',
+        retry_delay INT PATH '$.retry_delay',
+        enabled BOOLEAN PATH '$.enabled',
+        rate_limit_enabled BOOLEAN PATH '$.rate_limit_enabled',
+        verify_ssl BOOLEAN PATH '$.verify_ssl',
+        custom_payload TEXT PATH '$.custom_payload',
+        transform_config JSON PATH '$.transform_config',
+        conditional_rules JSON PATH '$.conditional_rules',
+        priority INT PATH '$.priority',
+        tags JSON PATH '$.tags',
+        created_at VARCHAR(64) PATH '$.created_at',
+        updated_at VARCHAR(64) PATH '$.updated_at'
+    )) AS e
+WHERE f.webhook_config IS NOT NULL
+  AND e.url IS NOT NULL;
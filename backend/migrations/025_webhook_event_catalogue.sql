@@ -0,0 +1,8 @@
+-- Webhook Event Catalogue Migration
+-- Endpoints now subscribe to event types from a fixed catalogue, and unknown types are rejected when
+-- an endpoint is saved. The submission event was stored as "form.submitted" by older clients; it is
+-- delivered as "submission.created", so existing subscriptions are rewritten to the catalogue name.
+
+UPDATE webhook_endpoints
+SET events = CAST(REPLACE(CAST(events AS CHAR), '"form.submitted"', '"submission.created"') AS JSON)
+WHERE JSON_CONTAINS(events, '"form.submitted"');
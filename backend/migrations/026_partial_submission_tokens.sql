@@ -0,0 +1,9 @@
+-- Partial Submission Tokens Migration
+-- Saved progress was looked up by the X-Session-ID the client chose, so anyone holding or guessing a
+-- session ID could read another respondent's answers. Progress is now found by a random token the
+-- server issues on the first save; only its SHA-256 hash is stored. Progress saved before this
+-- migration has no token and can only be reopened through a resume link, which issues one.
+
+ALTER TABLE form_partial_submissions
+    ADD COLUMN token_hash CHAR(64) NULL AFTER session_id,
+    ADD UNIQUE KEY unique_partial_token (token_hash);
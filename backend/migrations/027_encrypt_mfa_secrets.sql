@@ -0,0 +1,7 @@
+-- Encrypt MFA Secrets Migration
+-- TOTP secrets are now stored encrypted with AES-GCM under a key derived from API_KEY_SECRET, which
+-- no longer fits the column. Secrets stored in plain text before this migration are encrypted the
+-- next time they are used.
+
+ALTER TABLE users
+    MODIFY COLUMN mfa_secret VARCHAR(255) NULL; -- enc:v1: followed by the base64 nonce and ciphertext
@@ -24,6 +24,24 @@ type EmailData struct {
 	SubmissionData map[string]interface{}
 	IPAddress   string
 	Timestamp   string
+	Attachments []AttachmentLink
+}
+
+// Message is a rendered email ready to be sent
+type Message struct {
+	ToEmails    []string
+	CCEmails    []string
+	BCCEmails   []string
+	Subject     string
+	HTMLContent string
+	TextContent string
+}
+
+// AttachmentLink is an uploaded file referenced by a signed download URL
+type AttachmentLink struct {
+	Name string
+	URL  string
+	Size int64
 }
 
 func NewSMTPService(cfg config.SMTPConfig) (*SMTPService, error) {
@@ -36,32 +54,53 @@ func NewSMTPService(cfg config.SMTPConfig) (*SMTPService, error) {
 }
 
 func (s *SMTPService) SendFormSubmission(data EmailData) error {
-	m := gomail.NewMessage()
-	
-	// Set headers
-	m.SetHeader("From", fmt.Sprintf("%s <%s>", s.config.FromName, s.config.FromEmail))
-	m.SetHeader("To", data.ToEmails...)
-	if len(data.CCEmails) > 0 {
-		m.SetHeader("Cc", data.CCEmails...)
+	message, err := s.RenderFormSubmission(data)
+	if err != nil {
+		return err
 	}
 	
+	return s.Send(*message)
+}
+
+// RenderFormSubmission builds the notification email for a submission without sending it
+func (s *SMTPService) RenderFormSubmission(data EmailData) (*Message, error) {
 	subject := data.Subject
 	if subject == "" {
 		subject = fmt.Sprintf("New form submission from %s", data.FormName)
 	}
-	m.SetHeader("Subject", subject)
 	
 	// Generate HTML body
 	htmlBody, err := s.generateHTMLBody(data)
 	if err != nil {
-		return fmt.Errorf("failed to generate HTML body: %w", err)
+		return nil, fmt.Errorf("failed to generate HTML body: %w", err)
 	}
 	
-	// Generate text body
-	textBody := s.generateTextBody(data)
+	return &Message{
+		ToEmails:    data.ToEmails,
+		CCEmails:    data.CCEmails,
+		Subject:     subject,
+		HTMLContent: htmlBody,
+		TextContent: s.generateTextBody(data),
+	}, nil
+}
+
+// Send delivers a rendered message from the configured sender address
+func (s *SMTPService) Send(message Message) error {
+	m := gomail.NewMessage()
+	
+	// Set headers
+	m.SetHeader("From", fmt.Sprintf("%s <%s>", s.config.FromName, s.config.FromEmail))
+	m.SetHeader("To", message.ToEmails...)
+	if len(message.CCEmails) > 0 {
+		m.SetHeader("Cc", message.CCEmails...)
+	}
+	if len(message.BCCEmails) > 0 {
+		m.SetHeader("Bcc", message.BCCEmails...)
+	}
+	m.SetHeader("Subject", message.Subject)
 	
-	m.SetBody("text/plain", textBody)
-	m.AddAlternative("text/html", htmlBody)
+	m.SetBody("text/plain", message.TextContent)
+	m.AddAlternative("text/html", message.HTMLContent)
 	
 	// Send email
 	if err := s.dialer.DialAndSend(m); err != nil {
@@ -105,7 +144,17 @@ func (s *SMTPService) generateHTMLBody(data EmailData) (string, error) {
             </div>
             {{end}}
         </div>
-        
+        {{if .Attachments}}
+        <div class="submission-data">
+            <h3>Attachments:</h3>
+            {{range .Attachments}}
+            <div class="field">
+                <a href="{{.URL}}">{{.Name}}</a>
+                <span class="field-value">({{.Size}} bytes)</span>
+            </div>
+            {{end}}
+        </div>
+        {{end}}
         <div class="submission-data">
             <h3>Technical Details:</h3>
             <div class="field">
@@ -150,6 +199,14 @@ func (s *SMTPService) generateTextBody(data EmailData) string {
 		sb.WriteString(fmt.Sprintf("%s: %v\n", key, value))
 	}
 	
+	if len(data.Attachments) > 0 {
+		sb.WriteString("\nAttachments:\n")
+		sb.WriteString(strings.Repeat("-", 20) + "\n")
+		for _, attachment := range data.Attachments {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", attachment.Name, attachment.URL))
+		}
+	}
+	
 	sb.WriteString("\nTechnical Details:\n")
 	sb.WriteString(strings.Repeat("-", 20) + "\n")
 	sb.WriteString(fmt.Sprintf("IP Address: %s\n", data.IPAddress))
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	exerciseStorage(t, store, "temp/session-1/contact.csv")
+}
+
+func TestLocalStorageCleansKeys(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "uploads")
+	store, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	if err := store.Put(context.Background(), "../../outside.txt", strings.NewReader("x"), 1, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "outside.txt")); err != nil {
+		t.Fatalf("object was not kept under the root: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "outside.txt")); !os.IsNotExist(err) {
+		t.Fatal("object escaped the storage root")
+	}
+}
+
+func TestLocalStoragePutLeavesNoTemporaryFiles(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	if err := store.Put(context.Background(), "temp/s/a.txt", strings.NewReader("abc"), 3, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, "temp", "s"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "a.txt" {
+		t.Fatalf("directory holds %v; want only a.txt", entries)
+	}
+}
+
+func TestCopyBetweenBackends(t *testing.T) {
+	ctx := context.Background()
+	src, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	fake, server := newFakeS3(t, true)
+	dst := newTestS3Storage(t, server.URL, testSecretKey, true)
+
+	if err := src.Put(ctx, "submissions/1/a.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := Copy(ctx, src, dst, "submissions/1/a.txt", 5, "text/plain"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	object, ok := fake.object("submissions/1/a.txt")
+	if !ok || string(object.data) != "hello" {
+		t.Fatalf("copied object = %q, %v; want hello", object.data, ok)
+	}
+	if exists, _ := src.Exists(ctx, "submissions/1/a.txt"); !exists {
+		t.Fatal("Copy removed the source object")
+	}
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := map[string]string{
+		"temp/s/file.txt":    "temp/s/file.txt",
+		"/temp/s/file.txt":   "temp/s/file.txt",
+		"../../etc/passwd":   "etc/passwd",
+		`temp\s\file.txt`:    "temp/s/file.txt",
+		"temp/./s//file.txt": "temp/s/file.txt",
+		"temp/s/../file.txt": "temp/file.txt",
+	}
+	for key, want := range tests {
+		if got := CleanKey(key); got != want {
+			t.Errorf("CleanKey(%q) = %q; want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testBucket    = "formhub-test"
+	testRegion    = "us-east-1"
+	testAccessKey = "minioadmin"
+	testSecretKey = "minioadmin-secret"
+)
+
+// fakeS3 stands in for MinIO: an in-memory bucket that checks every request's SigV4 signature
+// the way S3 does and answers with S3's status codes
+type fakeS3 struct {
+	bucket    string
+	pathStyle bool
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func newFakeS3(t *testing.T, pathStyle bool) (*fakeS3, *httptest.Server) {
+	t.Helper()
+
+	fake := &fakeS3{
+		bucket:    testBucket,
+		pathStyle: pathStyle,
+		objects:   make(map[string]fakeObject),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := verifySignature(r, testSecretKey); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "<Error><Code>SignatureDoesNotMatch</Code><Message>%s</Message></Error>", err)
+		return
+	}
+
+	var key string
+	if f.pathStyle {
+		bucket, objectKey, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if bucket != f.bucket {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		key = objectKey
+	} else {
+		if !strings.HasPrefix(r.Host, f.bucket+".") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		key = strings.TrimPrefix(r.URL.Path, "/")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(object.data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case http.MethodDelete:
+		// S3 answers 204 whether or not the object existed
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) object(key string) (fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object, ok := f.objects[key]
+	return object, ok
+}
+
+// verifySignature recomputes a request's SigV4 signature from what arrived on the wire
+func verifySignature(r *http.Request, secretKey string) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return fmt.Errorf("missing SigV4 authorization")
+	}
+
+	fields := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		fields[name] = value
+	}
+
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[0] != testAccessKey {
+		return fmt.Errorf("unknown credential %q", fields["Credential"])
+	}
+	dateStamp, region := credential[1], credential[2]
+
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signedHeaders) {
+		return fmt.Errorf("signed headers are not sorted")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	escapedPath, rawQuery, _ := strings.Cut(r.RequestURI, "?")
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		escapedPath,
+		rawQuery,
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, dateStamp) {
+		return fmt.Errorf("date %q outside credential scope %q", amzDate, dateStamp)
+	}
+
+	scope := dateStamp + "/" + region + "/s3/aws4_request"
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashedRequest[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), dateStamp)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	if expected := hex.EncodeToString(hmacSHA256(key, stringToSign)); expected != fields["Signature"] {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func newTestS3Storage(t *testing.T, endpoint, secretKey string, pathStyle bool) *S3Storage {
+	t.Helper()
+
+	store, err := NewS3Storage(Config{
+		Type:        "s3",
+		S3Endpoint:  endpoint,
+		S3Region:    testRegion,
+		S3Bucket:    testBucket,
+		S3AccessKey: testAccessKey,
+		S3SecretKey: secretKey,
+		S3PathStyle: pathStyle,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return store
+}
+
+// exerciseStorage runs the Storage contract against a backend
+func exerciseStorage(t *testing.T, store Storage, key string) {
+	t.Helper()
+	ctx := context.Background()
+	content := []byte("name,email\nAda,ada@example.com\n")
+
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists before Put = %v, %v; want false, nil", exists, err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put error = %v; want ErrNotFound", err)
+	}
+
+	if err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/csv"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if exists, err := store.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("Exists after Put = %v, %v; want true, nil", exists, err)
+	}
+
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("reading object: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("Get returned %q; want %q", got, content)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists after Delete = %v, %v; want false, nil", exists, err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestS3StoragePathStyle(t *testing.T) {
+	fake, server := newFakeS3(t, true)
+	store := newTestS3Storage(t, server.URL, testSecretKey, true)
+
+	exerciseStorage(t, store, "temp/session-1/contact.csv")
+
+	ctx := context.Background()
+	if err := store.Put(ctx, "temp/session-1/kept.csv", strings.NewReader("a"), 1, "text/csv"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	object, ok := fake.object("temp/session-1/kept.csv")
+	if !ok {
+		t.Fatal("object was not stored under its key")
+	}
+	if object.contentType != "text/csv" {
+		t.Errorf("content type = %q; want text/csv", object.contentType)
+	}
+}
+
+func TestS3StorageVirtualHostedStyle(t *testing.T) {
+	_, server := newFakeS3(t, false)
+	store := newTestS3Storage(t, "http://s3.test", testSecretKey, false)
+
+	// Send formhub-test.s3.test to the fake server without touching DNS
+	addr := server.Listener.Addr().String()
+	store.client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	exerciseStorage(t, store, "submissions/abc/report.csv")
+}
+
+func TestS3StorageEscapesKeys(t *testing.T) {
+	fake, server := newFakeS3(t, true)
+	store := newTestS3Storage(t, server.URL, testSecretKey, true)
+
+	key := "temp/session 2/Résumé (final)+v2.pdf"
+	exerciseStorage(t, store, key)
+
+	if err := store.Put(context.Background(), key, strings.NewReader("pdf"), 3, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok := fake.object(key); !ok {
+		t.Fatalf("object stored under the wrong key; have %v", fake.objects)
+	}
+}
+
+func TestS3StorageCleansKeys(t *testing.T) {
+	fake, server := newFakeS3(t, true)
+	store := newTestS3Storage(t, server.URL, testSecretKey, true)
+
+	if err := store.Put(context.Background(), "../../other-bucket/x.txt", strings.NewReader("x"), 1, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok := fake.object("other-bucket/x.txt"); !ok {
+		t.Fatalf("key was not confined to the bucket; have %v", fake.objects)
+	}
+}
+
+func TestS3StorageRejectedSignature(t *testing.T) {
+	_, server := newFakeS3(t, true)
+	store := newTestS3Storage(t, server.URL, "wrong-secret", true)
+
+	err := store.Put(context.Background(), "temp/x.txt", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Put with a wrong secret error = %v; want a 403", err)
+	}
+	if _, err := store.Exists(context.Background(), "temp/x.txt"); err == nil {
+		t.Fatal("Exists with a wrong secret succeeded")
+	}
+}
+
+func TestMoveS3(t *testing.T) {
+	fake, server := newFakeS3(t, true)
+	store := newTestS3Storage(t, server.URL, testSecretKey, true)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "temp/s/file.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := Move(ctx, store, "temp/s/file.txt", "submissions/1/file.txt", 5, "text/plain"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+
+	if _, ok := fake.object("temp/s/file.txt"); ok {
+		t.Error("source object still exists after Move")
+	}
+	object, ok := fake.object("submissions/1/file.txt")
+	if !ok || string(object.data) != "hello" {
+		t.Errorf("moved object = %q, %v; want hello", object.data, ok)
+	}
+
+	if err := Move(ctx, store, "temp/s/missing.txt", "submissions/1/missing.txt", 0, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Move of a missing object error = %v; want ErrNotFound", err)
+	}
+}
+
+// TestS3StorageMinIO runs the contract against a real S3-compatible server, such as
+// `docker run -p 9000:9000 minio/minio server /data`, when FORMHUB_TEST_S3_ENDPOINT is set.
+// The bucket must exist.
+func TestS3StorageMinIO(t *testing.T) {
+	endpoint := os.Getenv("FORMHUB_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("FORMHUB_TEST_S3_ENDPOINT not set")
+	}
+
+	store, err := NewS3Storage(Config{
+		Type:        "s3",
+		S3Endpoint:  endpoint,
+		S3Region:    os.Getenv("FORMHUB_TEST_S3_REGION"),
+		S3Bucket:    os.Getenv("FORMHUB_TEST_S3_BUCKET"),
+		S3AccessKey: os.Getenv("FORMHUB_TEST_S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("FORMHUB_TEST_S3_SECRET_KEY"),
+		S3PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+
+	exerciseStorage(t, store, fmt.Sprintf("test/%d/contact form.csv", time.Now().UnixNano()))
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned when an object does not exist in the backend
+var ErrNotFound = fmt.Errorf("object not found")
+
+// Storage is an object store for uploaded files. Keys are slash-separated
+// relative paths such as "temp/<session>/<file>".
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	Name() string
+}
+
+// Config selects and configures a storage backend
+type Config struct {
+	Type      string // local or s3
+	LocalPath string
+
+	S3Endpoint  string // e.g. https://s3.amazonaws.com or http://localhost:9000 for MinIO
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool // required by MinIO and most S3-compatible servers
+}
+
+// New creates the storage backend described by cfg
+func New(cfg Config) (Storage, error) {
+	switch cfg.Type {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalPath)
+	case "s3":
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", cfg.Type)
+	}
+}
+
+// Copy copies a single object between backends
+func Copy(ctx context.Context, src, dst Storage, key string, size int64, contentType string) error {
+	reader, err := src.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s from %s: %w", key, src.Name(), err)
+	}
+	defer reader.Close()
+
+	if err := dst.Put(ctx, key, reader, size, contentType); err != nil {
+		return fmt.Errorf("failed to write %s to %s: %w", key, dst.Name(), err)
+	}
+
+	return nil
+}
+
+// Move copies an object to a new key within the same backend and removes the original
+func Move(ctx context.Context, store Storage, fromKey, toKey string, size int64, contentType string) error {
+	reader, err := store.Get(ctx, fromKey)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", fromKey, err)
+	}
+
+	err = store.Put(ctx, toKey, reader, size, contentType)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", toKey, err)
+	}
+
+	return store.Delete(ctx, fromKey)
+}
+
+// CleanKey normalises a key and strips any attempt to escape the storage root
+func CleanKey(key string) string {
+	key = strings.ReplaceAll(key, "\\", "/")
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NormalizeOrigin reduces an origin or URL to lower-case scheme://host[:port], or "" if it has none
+func NormalizeOrigin(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// NormalizeAllowedOrigin reduces an allow-list entry to the form OriginAllowed expects. Entries
+// without a scheme, such as "example.com" or "*.example.com", are taken to be https.
+func NormalizeAllowedOrigin(entry string) string {
+	entry = strings.TrimSpace(entry)
+	if entry != "" && !strings.Contains(entry, "://") {
+		entry = "https://" + entry
+	}
+	return NormalizeOrigin(entry)
+}
+
+// RequestOrigin returns the origin a request was sent from: its Origin header, or the origin of its
+// Referer when the browser sent no Origin
+func RequestOrigin(r *http.Request) string {
+	if origin := NormalizeOrigin(r.Header.Get("Origin")); origin != "" {
+		return origin
+	}
+	return NormalizeOrigin(r.Header.Get("Referer"))
+}
+
+// OriginAllowed reports whether origin matches one of the allowed origins; an empty list allows any.
+// Allowed origins are normalized scheme://host[:port] values, and a host starting with "*." matches
+// any subdomain of the rest.
+func OriginAllowed(allowed []string, origin string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	origin = NormalizeOrigin(origin)
+	if origin == "" {
+		return false
+	}
+
+	for _, entry := range allowed {
+		if entry == origin {
+			return true
+		}
+
+		scheme, host, ok := strings.Cut(entry, "://*.")
+		if !ok {
+			continue
+		}
+		prefix := scheme + "://"
+		suffix := "." + host
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) && len(origin) > len(prefix)+len(suffix) {
+			return true
+		}
+	}
+
+	return false
+}
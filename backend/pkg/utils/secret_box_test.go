@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box, err := NewSecretBox("server-secret", "mfa-totp-secret")
+	if err != nil {
+		t.Fatalf("NewSecretBox: %v", err)
+	}
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("sealed value %q is not encrypted", sealed)
+	}
+	if len(sealed) > 255 {
+		t.Errorf("sealed value is %d characters; the column holds 255", len(sealed))
+	}
+
+	again, _ := box.Seal("JBSWY3DPEHPK3PXP")
+	if again == sealed {
+		t.Error("sealing twice gave the same value")
+	}
+
+	opened, err := box.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if opened != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Open = %q; want JBSWY3DPEHPK3PXP", opened)
+	}
+}
+
+func TestSecretBoxOpensPlainValues(t *testing.T) {
+	box, err := NewSecretBox("server-secret", "mfa-totp-secret")
+	if err != nil {
+		t.Fatalf("NewSecretBox: %v", err)
+	}
+
+	opened, err := box.Open("JBSWY3DPEHPK3PXP")
+	if err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Open of a plain value = %q, %v; want it unchanged", opened, err)
+	}
+}
+
+func TestSecretBoxRejectsOtherKeys(t *testing.T) {
+	box, _ := NewSecretBox("server-secret", "mfa-totp-secret")
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	otherSecret, _ := NewSecretBox("other-secret", "mfa-totp-secret")
+	if _, err := otherSecret.Open(sealed); err == nil {
+		t.Error("a box with another secret opened the value")
+	}
+	otherPurpose, _ := NewSecretBox("server-secret", "sso-client-secret")
+	if _, err := otherPurpose.Open(sealed); err == nil {
+		t.Error("a box for another purpose opened the value")
+	}
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+	if _, err := box.Open(tampered); err == nil {
+		t.Error("a tampered value was opened")
+	}
+	if _, err := box.Open(sealedSecretPrefix + "!!"); err == nil {
+		t.Error("a malformed value was opened")
+	}
+
+	if _, err := NewSecretBox("", "mfa-totp-secret"); err == nil {
+		t.Error("NewSecretBox accepted an empty secret")
+	}
+}
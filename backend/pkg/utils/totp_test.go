@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Seed is the SHA-1 key of the RFC 6238 Appendix B test vectors
+const rfc6238Seed = "12345678901234567890"
+
+// rfc6238Vectors are the SHA-1 vectors of RFC 6238 Appendix B. The RFC lists 8-digit codes; a
+// 6-digit code is their last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		want := vector.code[len(vector.code)-totpDigits:]
+		if got := totpCode([]byte(rfc6238Seed), vector.unix/30); got != want {
+			t.Errorf("totpCode at %d = %s; want %s", vector.unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTPRFC6238(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte(rfc6238Seed))
+
+	for _, vector := range rfc6238Vectors {
+		code := vector.code[len(vector.code)-totpDigits:]
+		now := time.Unix(vector.unix, 0)
+
+		step, ok := ValidateTOTP(secret, code, now)
+		if !ok {
+			t.Errorf("ValidateTOTP rejected the code for %d", vector.unix)
+			continue
+		}
+		if step != vector.unix/30 {
+			t.Errorf("ValidateTOTP at %d matched step %d; want %d", vector.unix, step, vector.unix/30)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte(rfc6238Seed))
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / 30
+
+	tests := []struct {
+		name string
+		step int64
+		ok   bool
+	}{
+		{"previous step", step - 1, true},
+		{"current step", step, true},
+		{"next step", step + 1, true},
+		{"two steps behind", step - 2, false},
+		{"two steps ahead", step + 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := totpCode([]byte(rfc6238Seed), tt.step)
+			matched, ok := ValidateTOTP(secret, code, now)
+			if ok != tt.ok {
+				t.Fatalf("ValidateTOTP = %v; want %v", ok, tt.ok)
+			}
+			if ok && matched != tt.step {
+				t.Errorf("matched step %d; want %d", matched, tt.step)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte(rfc6238Seed))
+	now := time.Unix(59, 0)
+
+	if _, ok := ValidateTOTP(secret, "287 082", now); !ok {
+		t.Error("code with a space was rejected")
+	}
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := ValidateTOTP(secret, code, now); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", "287082", now); ok {
+		t.Error("code for a malformed secret was accepted")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret holds %d bytes; want 20", len(key))
+	}
+}
@@ -9,6 +9,7 @@ import (
 )
 
 type WebhookPayload struct {
+	DeliveryID  string                 `json:"delivery_id,omitempty"` // stable across retries so receivers can deduplicate
 	Event       string                 `json:"event"`
 	FormID      string                 `json:"form_id"`
 	FormName    string                 `json:"form_name"`
@@ -22,11 +23,22 @@ type WebhookPayload struct {
 type WebhookSubmission struct {
 	ID        string                 `json:"id"`
 	Data      map[string]interface{} `json:"data"`
+	Files     []WebhookFile          `json:"files,omitempty"`
 	IsSpam    bool                   `json:"is_spam"`
 	SpamScore float64                `json:"spam_score"`
 	CreatedAt string                 `json:"created_at"`
 }
 
+// WebhookFile describes an attachment; URL is a signed, expiring download link
+type WebhookFile struct {
+	ID          string `json:"id"`
+	FieldName   string `json:"field_name,omitempty"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
+
 type WebhookResponse struct {
 	Success     bool   `json:"success"`
 	StatusCode  int    `json:"status_code"`
@@ -73,7 +85,11 @@ func SendWebhook(webhookURL string, payload WebhookPayload) (*WebhookResponse, e
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "FormHub/1.0 (+https://formhub.com)")
 	req.Header.Set("X-FormHub-Event", payload.Event)
-	req.Header.Set("X-FormHub-Delivery", fmt.Sprintf("%d", time.Now().Unix()))
+	if payload.DeliveryID != "" {
+		req.Header.Set("X-FormHub-Delivery", payload.DeliveryID)
+	} else {
+		req.Header.Set("X-FormHub-Delivery", fmt.Sprintf("%d", time.Now().Unix()))
+	}
 
 	// Send request
 	resp, err := client.Do(req)
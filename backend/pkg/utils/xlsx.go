@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XLSXWriter streams a single-sheet workbook row by row so large exports
+// never have to be held in memory. Cells are written as inline strings.
+type XLSXWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	rows  int
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+// NewXLSXWriter writes the workbook skeleton and opens the worksheet for streaming
+func NewXLSXWriter(w io.Writer, sheetName string) (*XLSXWriter, error) {
+	zw := zip.NewWriter(w)
+
+	var escapedName strings.Builder
+	xml.EscapeText(&escapedName, []byte(sheetName))
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, escapedName.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+
+	for _, part := range parts {
+		fw, err := zw.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", part.name, err)
+		}
+		if _, err := io.WriteString(fw, part.content); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worksheet: %w", err)
+	}
+
+	header := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	if _, err := io.WriteString(sheet, header); err != nil {
+		return nil, fmt.Errorf("failed to write worksheet header: %w", err)
+	}
+
+	return &XLSXWriter{zip: zw, sheet: sheet}, nil
+}
+
+// WriteRow appends a row of string cells to the worksheet
+func (x *XLSXWriter) WriteRow(cells []string) error {
+	x.rows++
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, x.rows)
+	for i, cell := range cells {
+		fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, xlsxColumnName(i), x.rows)
+		xml.EscapeText(&b, []byte(cell))
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString(`</row>`)
+
+	_, err := io.WriteString(x.sheet, b.String())
+	return err
+}
+
+// Close finishes the worksheet and the zip archive
+func (x *XLSXWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+// xlsxColumnName converts a zero-based column index to A, B, ..., Z, AA, AB, ...
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
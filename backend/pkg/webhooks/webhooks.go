@@ -0,0 +1,155 @@
+// Package webhooks signs and verifies FormHub webhook deliveries following the Standard Webhooks
+// specification (https://www.standardwebhooks.com). Receiving services verify a delivery with:
+//
+//	verifier, err := webhooks.NewVerifier(os.Getenv("FORMHUB_WEBHOOK_SECRET"))
+//	...
+//	body, _ := io.ReadAll(r.Body)
+//	if err := verifier.Verify(body, r.Header); err != nil {
+//		http.Error(w, "invalid signature", http.StatusUnauthorized)
+//		return
+//	}
+//
+// During a secret rotation FormHub signs with both the new and the old secret, so receivers can
+// switch to the new secret at any point in the rotation window. Passing both secrets to NewVerifier
+// accepts either.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a signed delivery
+const (
+	HeaderID        = "webhook-id"        // Event ID, stable across retries so receivers can deduplicate
+	HeaderTimestamp = "webhook-timestamp" // Unix seconds at which the attempt was signed
+	HeaderSignature = "webhook-signature" // Space-separated "v1,<base64 HMAC-SHA256>" signatures
+)
+
+// SecretPrefix marks secrets generated by FormHub; the rest of the secret is the base64 key
+const SecretPrefix = "whsec_"
+
+// DefaultTolerance is how far a delivery's timestamp may be from the receiver's clock
+const DefaultTolerance = 5 * time.Minute
+
+const signatureVersion = "v1"
+
+var (
+	ErrMissingHeaders      = errors.New("webhooks: missing webhook-id, webhook-timestamp or webhook-signature header")
+	ErrInvalidTimestamp    = errors.New("webhooks: invalid webhook-timestamp")
+	ErrTimestampTolerance  = errors.New("webhooks: webhook-timestamp is outside the tolerance")
+	ErrNoMatchingSignature = errors.New("webhooks: no matching signature")
+)
+
+// GenerateSecret returns a new random signing secret in the whsec_ format
+func GenerateSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("webhooks: failed to generate secret: %w", err)
+	}
+	return SecretPrefix + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// SigningKey returns the HMAC key of a secret. A whsec_ secret holds a base64 key; any other
+// secret, such as one chosen before FormHub generated secrets, is used as is.
+func SigningKey(secret string) ([]byte, error) {
+	if !strings.HasPrefix(secret, SecretPrefix) {
+		if secret == "" {
+			return nil, fmt.Errorf("webhooks: secret is empty")
+		}
+		return []byte(secret), nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, SecretPrefix))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("webhooks: secret is not valid base64 after %q", SecretPrefix)
+	}
+	return key, nil
+}
+
+// Sign returns the versioned signature of a delivery for one key
+func Sign(key []byte, msgID string, timestamp time.Time, payload []byte) string {
+	return signatureVersion + "," + base64.StdEncoding.EncodeToString(sign(key, msgID, timestamp.Unix(), payload))
+}
+
+// Verifier checks the signatures of deliveries against one or more secrets
+type Verifier struct {
+	keys      [][]byte
+	Tolerance time.Duration // Defaults to DefaultTolerance
+}
+
+// NewVerifier returns a verifier accepting deliveries signed with any of the secrets
+func NewVerifier(secrets ...string) (*Verifier, error) {
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("webhooks: at least one secret is required")
+	}
+
+	keys := make([][]byte, 0, len(secrets))
+	for _, secret := range secrets {
+		key, err := SigningKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return &Verifier{keys: keys, Tolerance: DefaultTolerance}, nil
+}
+
+// Verify checks that payload, the raw request body, carries a valid signature for one of the
+// verifier's secrets and was signed within the tolerance
+func (v *Verifier) Verify(payload []byte, headers http.Header) error {
+	msgID := headers.Get(HeaderID)
+	timestampStr := headers.Get(HeaderTimestamp)
+	signatures := headers.Get(HeaderSignature)
+	if msgID == "" || timestampStr == "" || signatures == "" {
+		return ErrMissingHeaders
+	}
+
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	skew := time.Since(time.Unix(timestamp, 0))
+	if skew > v.Tolerance || skew < -v.Tolerance {
+		return ErrTimestampTolerance
+	}
+
+	for _, key := range v.keys {
+		expected := sign(key, msgID, timestamp, payload)
+
+		for _, versioned := range strings.Fields(signatures) {
+			version, signature, ok := strings.Cut(versioned, ",")
+			if !ok || version != signatureVersion {
+				continue
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(signature)
+			if err != nil {
+				continue
+			}
+			if hmac.Equal(decoded, expected) {
+				return nil
+			}
+		}
+	}
+
+	return ErrNoMatchingSignature
+}
+
+// sign computes the HMAC-SHA256 of "<msgID>.<timestamp>.<payload>"
+func sign(key []byte, msgID string, timestamp int64, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msgID + "." + strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
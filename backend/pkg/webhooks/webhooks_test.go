@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The example delivery of the Standard Webhooks specification. The signature was computed
+// independently of this package, with Python's hmac module, over "<id>.<timestamp>.<payload>".
+const (
+	specSecret    = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	specMsgID     = "msg_p5jXN8AQM9LZM1jvvBSpmEE1BRy"
+	specTimestamp = 1614265330
+	specPayload   = `{"test": 2432232314}`
+	specSignature = "v1,5kfUr4ByDkETac4sP7Py2LOpZNxv4/7dyuSP/J7Djes="
+)
+
+func deliveryHeaders(msgID string, timestamp time.Time, signatures ...string) http.Header {
+	headers := http.Header{}
+	headers.Set(HeaderID, msgID)
+	headers.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	headers.Set(HeaderSignature, strings.Join(signatures, " "))
+	return headers
+}
+
+func mustKey(t *testing.T, secret string) []byte {
+	t.Helper()
+	key, err := SigningKey(secret)
+	if err != nil {
+		t.Fatalf("SigningKey(%q): %v", secret, err)
+	}
+	return key
+}
+
+func mustVerifier(t *testing.T, secrets ...string) *Verifier {
+	t.Helper()
+	verifier, err := NewVerifier(secrets...)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	return verifier
+}
+
+func TestSigningKey(t *testing.T) {
+	key := mustKey(t, SecretPrefix+"aGVsbG8gd29ybGQ=")
+	if string(key) != "hello world" {
+		t.Errorf("SigningKey decoded %q; want %q", key, "hello world")
+	}
+
+	if key := mustKey(t, "legacy-secret"); string(key) != "legacy-secret" {
+		t.Errorf("SigningKey of a secret without the prefix = %q; want it unchanged", key)
+	}
+
+	for _, secret := range []string{"", "whsec_", "whsec_not base64!"} {
+		if _, err := SigningKey(secret); err == nil {
+			t.Errorf("SigningKey(%q) succeeded; want an error", secret)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if key := mustKey(t, secret); len(key) != 32 {
+		t.Errorf("generated key is %d bytes; want 32", len(key))
+	}
+}
+
+func TestSignSpecExample(t *testing.T) {
+	got := Sign(mustKey(t, specSecret), specMsgID, time.Unix(specTimestamp, 0), []byte(specPayload))
+	if got != specSignature {
+		t.Errorf("Sign = %s; want %s", got, specSignature)
+	}
+}
+
+func TestVerifySpecExample(t *testing.T) {
+	verifier := mustVerifier(t, specSecret)
+	verifier.Tolerance = time.Since(time.Unix(specTimestamp, 0)) + time.Hour
+
+	headers := deliveryHeaders(specMsgID, time.Unix(specTimestamp, 0), specSignature)
+	if err := verifier.Verify([]byte(specPayload), headers); err != nil {
+		t.Errorf("Verify of the spec example: %v", err)
+	}
+
+	if err := verifier.Verify([]byte(`{"test": 2432232315}`), headers); !errors.Is(err, ErrNoMatchingSignature) {
+		t.Errorf("Verify of a changed payload = %v; want ErrNoMatchingSignature", err)
+	}
+}
+
+func TestVerifyRotation(t *testing.T) {
+	oldSecret, _ := GenerateSecret()
+	newSecret, _ := GenerateSecret()
+	payload := []byte(`{"type":"submission.created"}`)
+	now := time.Now()
+
+	oldSignature := Sign(mustKey(t, oldSecret), "msg_1", now, payload)
+	newSignature := Sign(mustKey(t, newSecret), "msg_1", now, payload)
+
+	// During a rotation a delivery carries a signature for each secret
+	headers := deliveryHeaders("msg_1", now, newSignature, oldSignature)
+
+	tests := []struct {
+		name    string
+		secrets []string
+	}{
+		{"receiver still on the old secret", []string{oldSecret}},
+		{"receiver moved to the new secret", []string{newSecret}},
+		{"receiver accepting both", []string{newSecret, oldSecret}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := mustVerifier(t, tt.secrets...).Verify(payload, headers); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+
+	// A receiver with both secrets accepts deliveries signed with either one alone
+	both := mustVerifier(t, newSecret, oldSecret)
+	for _, signature := range []string{oldSignature, newSignature} {
+		if err := both.Verify(payload, deliveryHeaders("msg_1", now, signature)); err != nil {
+			t.Errorf("Verify with one signature: %v", err)
+		}
+	}
+}
+
+func TestVerifySkipsUnknownSignatures(t *testing.T) {
+	secret, _ := GenerateSecret()
+	payload := []byte(`{}`)
+	now := time.Now()
+
+	headers := deliveryHeaders("msg_1", now, "v2,c29tZXRoaW5n", "v1,not-base64!", Sign(mustKey(t, secret), "msg_1", now, payload))
+	if err := mustVerifier(t, secret).Verify(payload, headers); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestVerifyWrongSecret(t *testing.T) {
+	secret, _ := GenerateSecret()
+	otherSecret, _ := GenerateSecret()
+	payload := []byte(`{}`)
+	now := time.Now()
+
+	headers := deliveryHeaders("msg_1", now, Sign(mustKey(t, otherSecret), "msg_1", now, payload))
+	if err := mustVerifier(t, secret).Verify(payload, headers); !errors.Is(err, ErrNoMatchingSignature) {
+		t.Errorf("Verify with the wrong secret = %v; want ErrNoMatchingSignature", err)
+	}
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	secret, _ := GenerateSecret()
+	key := mustKey(t, secret)
+	verifier := mustVerifier(t, secret)
+	payload := []byte(`{}`)
+
+	tests := []struct {
+		name   string
+		signed time.Time
+		want   error
+	}{
+		{"now", time.Now(), nil},
+		{"within the tolerance", time.Now().Add(-DefaultTolerance + time.Minute), nil},
+		{"stale", time.Now().Add(-DefaultTolerance - time.Minute), ErrTimestampTolerance},
+		{"in the future", time.Now().Add(DefaultTolerance + time.Minute), ErrTimestampTolerance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := deliveryHeaders("msg_1", tt.signed, Sign(key, "msg_1", tt.signed, payload))
+			if err := verifier.Verify(payload, headers); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v; want %v", err, tt.want)
+			}
+		})
+	}
+
+	headers := deliveryHeaders("msg_1", time.Now(), Sign(key, "msg_1", time.Now(), payload))
+	headers.Set(HeaderTimestamp, "yesterday")
+	if err := verifier.Verify(payload, headers); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Errorf("Verify with a malformed timestamp = %v; want ErrInvalidTimestamp", err)
+	}
+
+	headers.Del(HeaderSignature)
+	if err := verifier.Verify(payload, headers); !errors.Is(err, ErrMissingHeaders) {
+		t.Errorf("Verify without a signature = %v; want ErrMissingHeaders", err)
+	}
+}